@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer/leastrequest"
+	"google.golang.org/grpc/balancer/roundrobin"
+	// Registers the client side health checks of the health check config.
+	_ "google.golang.org/grpc/health"
+
+	"git.neds.sh/matty/entain/pkg/breaker"
+	"git.neds.sh/matty/entain/pkg/config"
+	pkgendpoint "git.neds.sh/matty/entain/pkg/endpoint"
+)
+
+// The read-only methods the gateway calls on each service, which are safe to retry.
+var (
+	racingReads = []string{"ListRaces", "GetRace", "BatchGetRaces", "Search", "ListScratchings"}
+	sportsReads = []string{"ListEvents", "GetEvent", "BatchGetEvents", "Search"}
+)
+
+// dialBackend dials the replicas of a service at the endpoint, applying the policy to its calls: the balancing,
+// health checks, timeout and retries of its service config, and a circuit breaker named after the backend.
+func dialBackend(name, endpoint, service string, reads []string, policy config.BackendPolicy, reload time.Duration, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	target, resolverOpts, err := pkgendpoint.Resolve(name, endpoint, reload)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceConfig, err := backendServiceConfig(service, reads, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	b := breaker.New(name, policy.BreakerFailures, policy.BreakerOpenTimeout)
+
+	opts = append(opts, resolverOpts...)
+	opts = append(opts,
+		grpc.WithDefaultServiceConfig(serviceConfig),
+		grpc.WithChainUnaryInterceptor(b.UnaryClientInterceptor()),
+	)
+
+	return grpc.Dial(target, opts...)
+}
+
+// methodName is the name of a method, or of every method of the service without one.
+type methodName struct {
+	Service string `json:"service"`
+	Method  string `json:"method,omitempty"`
+}
+
+type methodConfig struct {
+	Name        []methodName `json:"name"`
+	Timeout     string       `json:"timeout"`
+	RetryPolicy *retryPolicy `json:"retryPolicy,omitempty"`
+}
+
+type retryPolicy struct {
+	MaxAttempts          int      `json:"maxAttempts"`
+	InitialBackoff       string   `json:"initialBackoff"`
+	MaxBackoff           string   `json:"maxBackoff"`
+	BackoffMultiplier    float64  `json:"backoffMultiplier"`
+	RetryableStatusCodes []string `json:"retryableStatusCodes"`
+}
+
+// backendServiceConfig returns the gRPC service config balancing the calls across the replicas of the service which
+// pass their health checks, bounding every method by the timeout, and retrying the read methods which failed with
+// UNAVAILABLE, i.e. before the service could handle them. Retries are throttled once a tenth of the calls fail, so
+// that they do not pile up on a failing service.
+func backendServiceConfig(service string, reads []string, policy config.BackendPolicy) (string, error) {
+	timeout := duration(policy.Timeout)
+
+	methods := []methodConfig{{Name: []methodName{{Service: service}}, Timeout: timeout}}
+
+	// gRPC only accepts retry policies of at least 2 attempts.
+	if policy.MaxAttempts > 1 {
+		read := methodConfig{
+			Timeout: timeout,
+			RetryPolicy: &retryPolicy{
+				MaxAttempts:          policy.MaxAttempts,
+				InitialBackoff:       duration(policy.InitialBackoff),
+				MaxBackoff:           duration(policy.MaxBackoff),
+				BackoffMultiplier:    2,
+				RetryableStatusCodes: []string{"UNAVAILABLE"},
+			},
+		}
+		for _, method := range reads {
+			read.Name = append(read.Name, methodName{Service: service, Method: method})
+		}
+
+		methods = append(methods, read)
+	}
+
+	balancer := map[string]interface{}{roundrobin.Name: struct{}{}}
+	if policy.Balancer == config.BalancerLeastRequest {
+		balancer = map[string]interface{}{leastrequest.Name: map[string]interface{}{"choiceCount": 2}}
+	}
+
+	serviceConfig, err := json.Marshal(map[string]interface{}{
+		"loadBalancingConfig": []interface{}{balancer},
+		// Replicas are left out while their health service does not report the service SERVING, e.g. draining.
+		"healthCheckConfig": map[string]interface{}{"serviceName": service},
+		"methodConfig":      methods,
+		"retryThrottling":   map[string]interface{}{"maxTokens": 10, "tokenRatio": 0.1},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return string(serviceConfig), nil
+}
+
+// duration writes a duration as the JSON of a protobuf Duration.
+func duration(d time.Duration) string {
+	return fmt.Sprintf("%gs", d.Seconds())
+}
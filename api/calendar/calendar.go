@@ -0,0 +1,224 @@
+// Package calendar serves the races and the sports events as iCalendar feeds, for punters to subscribe to in their
+// calendar apps.
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/utilities"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"git.neds.sh/matty/entain/api/proto/sports"
+)
+
+const (
+	// EventsPath is the gateway route of the sports events calendar.
+	EventsPath = "/v1/calendar/events.ics"
+	// RacesPath is the gateway route of the races calendar.
+	RacesPath = "/v1/calendar/races.ics"
+
+	// lookBack is how long before now the calendars start without a time filter, so that what just finished
+	// stays in the calendar apps for a day.
+	lookBack = 24 * time.Hour
+
+	// raceDuration is how long races are shown for, as they only have an advertised start.
+	raceDuration = 10 * time.Minute
+)
+
+// Handler serves the calendars from the racing and sports services.
+type Handler struct {
+	mux    *runtime.ServeMux
+	racing racing.RacingClient
+	sports sports.SportsClient
+	// now tells the time the calendars are generated at.
+	now func() time.Time
+}
+
+// NewHandler creates a new calendar handler writing its errors like the routes of mux.
+func NewHandler(mux *runtime.ServeMux, racingClient racing.RacingClient, sportsClient sports.SportsClient) *Handler {
+	return &Handler{mux: mux, racing: racingClient, sports: sportsClient, now: time.Now}
+}
+
+// Register adds the calendar routes to the mux.
+func (h *Handler) Register() error {
+	// Calendar clients probe the feeds with HEAD, which the server answers without the body.
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		if err := h.mux.HandlePath(method, EventsPath, h.Events); err != nil {
+			return err
+		}
+
+		if err := h.mux.HandlePath(method, RacesPath, h.Races); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Events answers GET /v1/calendar/events.ics with the sports events of ListEvents as an iCalendar feed. It takes
+// the query parameters of /v1/list-events, e.g. filter.sport_id, and starts a day ago when no time filter is set.
+func (h *Handler) Events(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	ctx, ok := h.annotate(w, r, "/calendar.Events", EventsPath)
+	if !ok {
+		return
+	}
+
+	req := &sports.ListEventsRequest{}
+	if !h.populate(ctx, w, r, req) {
+		return
+	}
+
+	// The calendar needs the advertised times, whatever the read mask.
+	req.ReadMask = nil
+	if req.OrderBy == "" {
+		req.OrderBy = "advertised_start_time"
+	}
+
+	now := h.now()
+
+	if req.Filter == nil {
+		req.Filter = &sports.ListEventsRequestFilter{}
+	}
+	if req.Filter.StartTimeFrom == nil && req.Filter.StartTimeTo == nil && req.Filter.LocalDate == "" {
+		req.Filter.StartTimeFrom = timestamppb.New(now.Add(-lookBack))
+	}
+
+	resp, err := h.sports.ListEvents(ctx, req)
+	if err != nil {
+		h.error(ctx, w, r, err)
+		return
+	}
+
+	cal := &calendar{name: "Sports events"}
+	for _, event := range resp.Events {
+		description := fmt.Sprintf("Sport %d", event.SportId)
+		if event.VenueId > 0 {
+			description += fmt.Sprintf(" at venue %d", event.VenueId)
+		}
+
+		cal.events = append(cal.events, vevent{
+			uid:         fmt.Sprintf("event-%d@sports.entain", event.Id),
+			sequence:    event.Sequence,
+			start:       event.AdvertisedStartTime.AsTime(),
+			end:         event.AdvertisedEndTime.AsTime(),
+			summary:     event.Name,
+			description: description,
+		})
+	}
+
+	h.write(w, "events.ics", cal, now)
+}
+
+// Races answers GET /v1/calendar/races.ics with the visible races of ListRaces as an iCalendar feed. It takes the
+// query parameters of /v1/list-races, e.g. filter.meeting_ids and filter.categories, and starts a day ago when no
+// time filter is set.
+func (h *Handler) Races(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	ctx, ok := h.annotate(w, r, "/calendar.Races", RacesPath)
+	if !ok {
+		return
+	}
+
+	req := &racing.ListRacesRequest{}
+	if !h.populate(ctx, w, r, req) {
+		return
+	}
+
+	// The calendar needs the advertised times, whatever the read mask.
+	req.ReadMask = nil
+	if req.OrderBy == "" {
+		req.OrderBy = "advertised_start_time"
+	}
+
+	now := h.now()
+
+	if req.Filter == nil {
+		req.Filter = &racing.ListRacesRequestFilter{}
+	}
+	if req.Filter.Visible == nil {
+		visible := true
+		req.Filter.Visible = &visible
+	}
+	if req.Filter.StartTimeFrom == nil && req.Filter.StartTimeTo == nil && req.Filter.LocalDate == "" {
+		req.Filter.StartTimeFrom = timestamppb.New(now.Add(-lookBack))
+	}
+
+	resp, err := h.racing.ListRaces(ctx, req)
+	if err != nil {
+		h.error(ctx, w, r, err)
+		return
+	}
+
+	cal := &calendar{name: "Races"}
+	for _, race := range resp.Races {
+		start := race.AdvertisedStartTime.AsTime()
+
+		description := fmt.Sprintf("Meeting %d, race %d", race.MeetingId, race.Number)
+		if race.Distance > 0 {
+			description += fmt.Sprintf(", %dm", race.Distance)
+		}
+
+		var categories []string
+		if race.Category != racing.RaceCategory_RACE_CATEGORY_UNSPECIFIED {
+			categories = []string{strings.ToLower(race.Category.String())}
+		}
+
+		cal.events = append(cal.events, vevent{
+			uid:         fmt.Sprintf("race-%d@racing.entain", race.Id),
+			sequence:    race.Sequence,
+			start:       start,
+			end:         start.Add(raceDuration),
+			summary:     fmt.Sprintf("R%d %s", race.Number, race.Name),
+			description: description,
+			categories:  categories,
+		})
+	}
+
+	h.write(w, "races.ics", cal, now)
+}
+
+// annotate forwards the headers of the request as the generated routes do, e.g. the trace context and Grpc-Timeout.
+func (h *Handler) annotate(w http.ResponseWriter, r *http.Request, method, path string) (context.Context, bool) {
+	ctx, err := runtime.AnnotateContext(r.Context(), h.mux, r, method, runtime.WithHTTPPathPattern(path))
+	if err != nil {
+		h.error(r.Context(), w, r, err)
+		return nil, false
+	}
+
+	return ctx, true
+}
+
+// populate sets the fields of the list request from the query parameters.
+func (h *Handler) populate(ctx context.Context, w http.ResponseWriter, r *http.Request, req proto.Message) bool {
+	if err := runtime.PopulateQueryParameters(req, r.URL.Query(), utilities.NewDoubleArray(nil)); err != nil {
+		h.error(ctx, w, r, status.Error(codes.InvalidArgument, err.Error()))
+		return false
+	}
+
+	return true
+}
+
+// error writes an error like the other gateway routes do.
+func (h *Handler) error(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	_, outbound := runtime.MarshalerForRequest(h.mux, r)
+	runtime.HTTPError(ctx, h.mux, outbound, w, r, err)
+}
+
+// write sends the calendar as a file the calendar apps can subscribe to.
+func (h *Handler) write(w http.ResponseWriter, filename string, cal *calendar, now time.Time) {
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", filename))
+
+	if err := cal.write(w, now); err != nil {
+		log.Errorf("failed writing calendar %s: %s", filename, err)
+	}
+}
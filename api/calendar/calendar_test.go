@@ -0,0 +1,321 @@
+package calendar
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"git.neds.sh/matty/entain/api/proto/sports"
+)
+
+// now is the time the calendars of the tests are generated at.
+var now = time.Date(2024, 5, 2, 10, 0, 0, 0, time.UTC)
+
+// fakeRacing answers ListRaces with its races or error, keeping the last request.
+type fakeRacing struct {
+	racing.RacingClient
+	races []*racing.Race
+	err   error
+	req   *racing.ListRacesRequest
+}
+
+func (f *fakeRacing) ListRaces(_ context.Context, in *racing.ListRacesRequest, _ ...grpc.CallOption) (*racing.ListRacesResponse, error) {
+	f.req = in
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	return &racing.ListRacesResponse{Races: f.races}, nil
+}
+
+// fakeSports answers ListEvents with its events or error, keeping the last request.
+type fakeSports struct {
+	sports.SportsClient
+	events []*sports.Event
+	err    error
+	req    *sports.ListEventsRequest
+}
+
+func (f *fakeSports) ListEvents(_ context.Context, in *sports.ListEventsRequest, _ ...grpc.CallOption) (*sports.ListEventsResponse, error) {
+	f.req = in
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	return &sports.ListEventsResponse{Events: f.events}, nil
+}
+
+// newHandler returns a handler of the fake clients generating its calendars at now, registered on a new mux.
+func newHandler(t *testing.T, racingClient racing.RacingClient, sportsClient sports.SportsClient) *Handler {
+	h := NewHandler(runtime.NewServeMux(), racingClient, sportsClient)
+	h.now = func() time.Time { return now }
+
+	if err := h.Register(); err != nil {
+		t.Fatal(err)
+	}
+
+	return h
+}
+
+// get serves a GET of the target and returns the response.
+func get(h *Handler, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+
+	return w
+}
+
+// lines joins the content lines of a calendar, each ended by CRLF.
+func lines(l ...string) string {
+	return strings.Join(l, "\r\n") + "\r\n"
+}
+
+func TestRaces(t *testing.T) {
+	racingClient := &fakeRacing{races: []*racing.Race{{
+		Id:                  7,
+		MeetingId:           3,
+		Name:                "Tigers, Den; Cup",
+		Number:              2,
+		AdvertisedStartTime: timestamppb.New(time.Date(2024, 5, 2, 19, 30, 0, 0, time.FixedZone("AEST", 10*60*60))),
+		Category:            racing.RaceCategory_THOROUGHBRED,
+		Distance:            1200,
+		Sequence:            4,
+	}}}
+	h := newHandler(t, racingClient, &fakeSports{})
+
+	w := get(h, RacesPath+"?filter.meeting_ids=3&read_mask=name")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", w.Code, w.Body)
+	}
+
+	if got, want := w.Header().Get("Content-Type"), "text/calendar; charset=utf-8"; got != want {
+		t.Errorf("got Content-Type %q, want %q", got, want)
+	}
+	if got, want := w.Header().Get("Content-Disposition"), `inline; filename="races.ics"`; got != want {
+		t.Errorf("got Content-Disposition %q, want %q", got, want)
+	}
+
+	want := lines(
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//Entain//Racing and Sports API//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"NAME:Races",
+		"X-WR-CALNAME:Races",
+		"REFRESH-INTERVAL;VALUE=DURATION:PT15M",
+		"X-PUBLISHED-TTL:PT15M",
+		"BEGIN:VEVENT",
+		"UID:race-7@racing.entain",
+		"DTSTAMP:20240502T100000Z",
+		"DTSTART:20240502T093000Z",
+		"DTEND:20240502T094000Z",
+		"SEQUENCE:4",
+		`SUMMARY:R2 Tigers\, Den\; Cup`,
+		`DESCRIPTION:Meeting 3\, race 2\, 1200m`,
+		"CATEGORIES:thoroughbred",
+		"STATUS:CONFIRMED",
+		"TRANSP:TRANSPARENT",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	)
+	if got := w.Body.String(); got != want {
+		t.Errorf("got calendar\n%q\nwant\n%q", got, want)
+	}
+
+	req := racingClient.req
+	if req.ReadMask != nil {
+		t.Errorf("got read mask %v, want none", req.ReadMask)
+	}
+	if req.OrderBy != "advertised_start_time" {
+		t.Errorf("got order %q, want advertised_start_time", req.OrderBy)
+	}
+	if len(req.Filter.MeetingIds) != 1 || req.Filter.MeetingIds[0] != 3 {
+		t.Errorf("got meeting ids %v, want [3]", req.Filter.MeetingIds)
+	}
+	if req.Filter.Visible == nil || !*req.Filter.Visible {
+		t.Errorf("got visible %v, want true", req.Filter.Visible)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	start := time.Date(2024, 5, 2, 9, 40, 0, 0, time.UTC)
+	sportsClient := &fakeSports{events: []*sports.Event{
+		{Id: 11, Name: "Richmond v Carlton", SportId: 9, VenueId: 4, AdvertisedStartTime: timestamppb.New(start), AdvertisedEndTime: timestamppb.New(start.Add(3 * time.Hour))},
+		{Id: 12, Name: "Storm v Broncos", SportId: 5, AdvertisedStartTime: timestamppb.New(start), AdvertisedEndTime: timestamppb.New(start.Add(2 * time.Hour)), Sequence: 1},
+	}}
+	h := newHandler(t, &fakeRacing{}, sportsClient)
+
+	w := get(h, EventsPath+"?order_by=name")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", w.Code, w.Body)
+	}
+
+	want := lines(
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//Entain//Racing and Sports API//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"NAME:Sports events",
+		"X-WR-CALNAME:Sports events",
+		"REFRESH-INTERVAL;VALUE=DURATION:PT15M",
+		"X-PUBLISHED-TTL:PT15M",
+		"BEGIN:VEVENT",
+		"UID:event-11@sports.entain",
+		"DTSTAMP:20240502T100000Z",
+		"DTSTART:20240502T094000Z",
+		"DTEND:20240502T124000Z",
+		"SEQUENCE:0",
+		"SUMMARY:Richmond v Carlton",
+		"DESCRIPTION:Sport 9 at venue 4",
+		"STATUS:CONFIRMED",
+		"TRANSP:TRANSPARENT",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:event-12@sports.entain",
+		"DTSTAMP:20240502T100000Z",
+		"DTSTART:20240502T094000Z",
+		"DTEND:20240502T114000Z",
+		"SEQUENCE:1",
+		"SUMMARY:Storm v Broncos",
+		"DESCRIPTION:Sport 5",
+		"STATUS:CONFIRMED",
+		"TRANSP:TRANSPARENT",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	)
+	if got := w.Body.String(); got != want {
+		t.Errorf("got calendar\n%q\nwant\n%q", got, want)
+	}
+
+	if got := sportsClient.req.OrderBy; got != "name" {
+		t.Errorf("got order %q, want the requested name", got)
+	}
+}
+
+func TestUIDStable(t *testing.T) {
+	race := &racing.Race{Id: 7, Name: "Cup", Number: 1, AdvertisedStartTime: timestamppb.New(now)}
+	h := newHandler(t, &fakeRacing{races: []*racing.Race{race}}, &fakeSports{})
+
+	first := get(h, RacesPath).Body.String()
+
+	// A later fetch of the updated race must update the same calendar event.
+	race.Name, race.Sequence = "Cup Final", 1
+	h.now = func() time.Time { return now.Add(time.Hour) }
+
+	second := get(h, RacesPath).Body.String()
+
+	for _, want := range []string{"UID:race-7@racing.entain\r\n", "DTSTAMP:20240502T100000Z\r\n", "SEQUENCE:0\r\n"} {
+		if !strings.Contains(first, want) {
+			t.Errorf("first calendar %q is missing %q", first, want)
+		}
+	}
+	for _, want := range []string{"UID:race-7@racing.entain\r\n", "DTSTAMP:20240502T110000Z\r\n", "SEQUENCE:1\r\n", "SUMMARY:R1 Cup Final\r\n"} {
+		if !strings.Contains(second, want) {
+			t.Errorf("second calendar %q is missing %q", second, want)
+		}
+	}
+}
+
+func TestLookBack(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		query string
+		// wantFrom is the start time filter the service gets, none when zero.
+		wantFrom time.Time
+	}{
+		{name: "no filter starts a day ago", query: "", wantFrom: now.Add(-lookBack)},
+		{name: "no time filter starts a day ago", query: "?order_by=name", wantFrom: now.Add(-lookBack)},
+		{name: "start time kept", query: "?filter.start_time_from=2024-05-01T00:00:00Z", wantFrom: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "end time only", query: "?filter.start_time_to=2024-05-03T00:00:00Z"},
+		{name: "local date", query: "?filter.local_date=2024-05-02&filter.time_zone=Australia/Melbourne"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			racingClient, sportsClient := &fakeRacing{}, &fakeSports{}
+			h := newHandler(t, racingClient, sportsClient)
+
+			if w := get(h, RacesPath+tc.query); w.Code != http.StatusOK {
+				t.Fatalf("races: got status %d: %s", w.Code, w.Body)
+			}
+			if w := get(h, EventsPath+tc.query); w.Code != http.StatusOK {
+				t.Fatalf("events: got status %d: %s", w.Code, w.Body)
+			}
+
+			for name, got := range map[string]*timestamppb.Timestamp{
+				"races":  racingClient.req.Filter.StartTimeFrom,
+				"events": sportsClient.req.Filter.StartTimeFrom,
+			} {
+				switch {
+				case tc.wantFrom.IsZero() && got != nil:
+					t.Errorf("%s: got start time from %s, want none", name, got.AsTime())
+				case !tc.wantFrom.IsZero() && (got == nil || !got.AsTime().Equal(tc.wantFrom)):
+					t.Errorf("%s: got start time from %v, want %s", name, got, tc.wantFrom)
+				}
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	h := newHandler(t,
+		&fakeRacing{err: status.Error(codes.Unavailable, "racing is down")},
+		&fakeSports{err: status.Error(codes.InvalidArgument, "invalid time zone")},
+	)
+
+	if w := get(h, RacesPath); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("races: got status %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+
+	if w := get(h, EventsPath); w.Code != http.StatusBadRequest {
+		t.Errorf("events: got status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	if w := get(h, EventsPath+"?filter.sport_id=nine"); w.Code != http.StatusBadRequest {
+		t.Errorf("events with an invalid query: got status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHead(t *testing.T) {
+	h := newHandler(t,
+		&fakeRacing{races: []*racing.Race{{Id: 7, Name: "Cup", Number: 1, AdvertisedStartTime: timestamppb.New(now)}}},
+		&fakeSports{},
+	)
+
+	server := httptest.NewServer(h.mux)
+	defer server.Close()
+
+	for _, path := range []string{RacesPath, EventsPath} {
+		resp, err := http.Head(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("HEAD %s: got status %d, want %d", path, resp.StatusCode, http.StatusOK)
+		}
+		if got := resp.Header.Get("Content-Type"); got != "text/calendar; charset=utf-8" {
+			t.Errorf("HEAD %s: got Content-Type %q", path, got)
+		}
+		if len(body) > 0 {
+			t.Errorf("HEAD %s: got body %q, want none", path, body)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package calendar
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// prodID identifies the producer of the calendars.
+	prodID = "-//Entain//Racing and Sports API//EN"
+
+	// refreshInterval is how often calendar apps are asked to fetch a subscribed calendar again.
+	refreshInterval = "PT15M"
+
+	// lineLimit is the most octets of a content line before it is folded.
+	lineLimit = 75
+
+	// utcLayout is the layout of the UTC date-times of a calendar.
+	utcLayout = "20060102T150405Z"
+)
+
+// calendar is an RFC 5545 iCalendar object holding published events.
+type calendar struct {
+	name   string
+	events []vevent
+}
+
+// vevent is an event of a calendar.
+type vevent struct {
+	uid         string
+	sequence    int64
+	start, end  time.Time
+	summary     string
+	description string
+	categories  []string
+}
+
+// write encodes the calendar, stamping its events with the time it is generated at.
+func (c *calendar) write(w io.Writer, stamp time.Time) error {
+	lw := &lineWriter{w: bufio.NewWriter(w)}
+
+	lw.line("BEGIN", "VCALENDAR")
+	lw.line("VERSION", "2.0")
+	lw.line("PRODID", prodID)
+	lw.line("CALSCALE", "GREGORIAN")
+	lw.line("METHOD", "PUBLISH")
+	lw.line("NAME", escape(c.name))
+	lw.line("X-WR-CALNAME", escape(c.name))
+	lw.line("REFRESH-INTERVAL;VALUE=DURATION", refreshInterval)
+	lw.line("X-PUBLISHED-TTL", refreshInterval)
+
+	for _, e := range c.events {
+		lw.line("BEGIN", "VEVENT")
+		lw.line("UID", e.uid)
+		lw.line("DTSTAMP", stamp.UTC().Format(utcLayout))
+		lw.line("DTSTART", e.start.UTC().Format(utcLayout))
+		lw.line("DTEND", e.end.UTC().Format(utcLayout))
+		lw.line("SEQUENCE", strconv.FormatInt(e.sequence, 10))
+		lw.line("SUMMARY", escape(e.summary))
+		if e.description != "" {
+			lw.line("DESCRIPTION", escape(e.description))
+		}
+		if len(e.categories) > 0 {
+			escaped := make([]string, 0, len(e.categories))
+			for _, category := range e.categories {
+				escaped = append(escaped, escape(category))
+			}
+			lw.line("CATEGORIES", strings.Join(escaped, ","))
+		}
+		lw.line("STATUS", "CONFIRMED")
+		lw.line("TRANSP", "TRANSPARENT")
+		lw.line("END", "VEVENT")
+	}
+
+	lw.line("END", "VCALENDAR")
+
+	if lw.err != nil {
+		return lw.err
+	}
+
+	return lw.w.Flush()
+}
+
+// lineWriter writes the content lines of a calendar, keeping the first error.
+type lineWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+// line writes a content line ended by CRLF, folded into lines of at most lineLimit octets. Continuation lines start
+// with a space, and a UTF-8 character is never split.
+func (lw *lineWriter) line(name, value string) {
+	if lw.err != nil {
+		return
+	}
+
+	s := name + ":" + value
+
+	limit := lineLimit
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+
+		lw.write(s[:cut] + "\r\n ")
+		s = s[cut:]
+		// The leading space of the continuation counts towards its length.
+		limit = lineLimit - 1
+	}
+
+	lw.write(s + "\r\n")
+}
+
+func (lw *lineWriter) write(s string) {
+	if lw.err == nil {
+		_, lw.err = lw.w.WriteString(s)
+	}
+}
+
+// escape escapes a TEXT value: backslashes, semicolons, commas and newlines.
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
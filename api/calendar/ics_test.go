@@ -0,0 +1,97 @@
+package calendar
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestLineFolding(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{
+			name:  "short line",
+			value: "Cup",
+			want:  "SUMMARY:Cup\r\n",
+		},
+		{
+			name:  "exactly 75 octets",
+			value: strings.Repeat("a", 67),
+			want:  "SUMMARY:" + strings.Repeat("a", 67) + "\r\n",
+		},
+		{
+			name:  "76 octets",
+			value: strings.Repeat("a", 68),
+			want:  "SUMMARY:" + strings.Repeat("a", 67) + "\r\n a\r\n",
+		},
+		{
+			name:  "continuation lines count their leading space",
+			value: strings.Repeat("a", 67+74+5),
+			want:  "SUMMARY:" + strings.Repeat("a", 67) + "\r\n " + strings.Repeat("a", 74) + "\r\n " + strings.Repeat("a", 5) + "\r\n",
+		},
+		{
+			name:  "multibyte rune across the limit",
+			value: strings.Repeat("a", 66) + "€uro",
+			want:  "SUMMARY:" + strings.Repeat("a", 66) + "\r\n €uro\r\n",
+		},
+		{
+			name:  "multibyte runes only",
+			value: strings.Repeat("é", 40),
+			want:  "SUMMARY:" + strings.Repeat("é", 33) + "\r\n " + strings.Repeat("é", 7) + "\r\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var b strings.Builder
+			lw := &lineWriter{w: bufio.NewWriter(&b)}
+
+			lw.line("SUMMARY", tc.value)
+			if err := lw.w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			got := b.String()
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+
+			if !strings.HasSuffix(got, "\r\n") {
+				t.Errorf("line %q does not end with CRLF", got)
+			}
+
+			for _, line := range strings.Split(strings.TrimSuffix(got, "\r\n"), "\r\n") {
+				if len(line) > lineLimit {
+					t.Errorf("line %q has %d octets, more than %d", line, len(line), lineLimit)
+				}
+				if !utf8.ValidString(line) {
+					t.Errorf("line %q splits a UTF-8 character", line)
+				}
+			}
+
+			if unfolded := strings.ReplaceAll(got, "\r\n ", ""); unfolded != "SUMMARY:"+tc.value+"\r\n" {
+				t.Errorf("unfolded to %q", unfolded)
+			}
+		})
+	}
+}
+
+func TestEscape(t *testing.T) {
+	for _, tc := range []struct {
+		text string
+		want string
+	}{
+		{text: "Tigers Den Cup", want: "Tigers Den Cup"},
+		{text: `C:\races`, want: `C:\\races`},
+		{text: "Meeting 3, race 2; 1200m", want: `Meeting 3\, race 2\; 1200m`},
+		{text: "first\nsecond", want: `first\nsecond`},
+		{text: "first\r\nsecond", want: `first\nsecond`},
+		{text: `\,`, want: `\\\,`},
+	} {
+		if got := escape(tc.text); got != tc.want {
+			t.Errorf("escape(%q) = %q, want %q", tc.text, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+// Package graphql serves the races and the sports events over GraphQL, for clients to fetch what they need of both
+// services in one round trip, e.g. a race, the races of its meeting and the sports events of the same day:
+//
+//	{
+//	  race(id: "5") { name advertisedStartTime meeting { races { number name } } }
+//	  listEvents(filter: {localDate: "2024-05-01", timeZone: "Australia/Melbourne"}) { name sportId }
+//	}
+//
+// The fields resolve through the gRPC clients of the gateway. The lookups by id of a query, race, races, event,
+// events and the meetings of races, are batched into BatchGetRaces, BatchGetEvents and ListRaces calls and cached
+// for the rest of the query, so that a list of races asking for their meetings costs a single call.
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	graphqlgo "github.com/graph-gophers/graphql-go"
+	gqlotel "github.com/graph-gophers/graphql-go/trace/otel"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"git.neds.sh/matty/entain/api/proto/sports"
+)
+
+const (
+	// Path is the gateway route of the GraphQL queries.
+	Path = "/v1/graphql"
+
+	// maxDepth bounds the nesting of the queries, which could otherwise go from races to meetings and back forever.
+	maxDepth = 10
+
+	// maxBodyBytes bounds the body of the POST requests.
+	maxBodyBytes = 1 << 20
+)
+
+// Handler answers the GraphQL queries with the racing and sports services.
+type Handler struct {
+	mux    *runtime.ServeMux
+	schema *graphqlgo.Schema
+	racing racing.RacingClient
+	sports sports.SportsClient
+}
+
+// request is a GraphQL request, as the JSON body of a POST or the query parameters of a GET.
+type request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// NewHandler creates a new GraphQL handler writing its errors like the routes of mux.
+func NewHandler(mux *runtime.ServeMux, racingClient racing.RacingClient, sportsClient sports.SportsClient) *Handler {
+	return &Handler{
+		mux: mux,
+		schema: graphqlgo.MustParseSchema(schema, &resolver{racing: racingClient, sports: sportsClient},
+			graphqlgo.MaxDepth(maxDepth),
+			// Lets the lookups of a whole batch wait for it concurrently.
+			graphqlgo.MaxParallelism(maxBatch),
+			graphqlgo.Tracer(gqlotel.DefaultTracer()),
+		),
+		racing: racingClient,
+		sports: sportsClient,
+	}
+}
+
+// Register adds the GraphQL route to the mux, for GET and POST requests.
+func (h *Handler) Register() error {
+	if err := h.mux.HandlePath(http.MethodGet, Path, h.Query); err != nil {
+		return err
+	}
+
+	return h.mux.HandlePath(http.MethodPost, Path, h.Query)
+}
+
+// Query answers GET /v1/graphql?query=<query>&operationName=<name>&variables=<json> and POST /v1/graphql with a
+// JSON body of the same fields. The response is the GraphQL result, data and errors, with the gRPC code of the
+// errors of the services in their extensions. Only malformed requests fail with an HTTP error.
+func (h *Handler) Query(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	_, outbound := runtime.MarshalerForRequest(h.mux, r)
+
+	// Forward the headers as the generated routes do, e.g. the trace context and Grpc-Timeout.
+	ctx, err := runtime.AnnotateContext(r.Context(), h.mux, r, "/graphql.Query", runtime.WithHTTPPathPattern(Path))
+	if err != nil {
+		runtime.HTTPError(ctx, h.mux, outbound, w, r, err)
+		return
+	}
+
+	req, err := parseRequest(w, r)
+	if err != nil {
+		runtime.HTTPError(ctx, h.mux, outbound, w, r, status.Error(codes.InvalidArgument, err.Error()))
+		return
+	}
+
+	ctx = context.WithValue(ctx, loadersKey{}, newLoaders(ctx, h.racing, h.sports))
+
+	resp := h.schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Errorf("failed writing GraphQL response: %s", err)
+	}
+}
+
+// parseRequest reads the GraphQL request from the query parameters or the body.
+func parseRequest(w http.ResponseWriter, r *http.Request) (request, error) {
+	var req request
+
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		req.Query, req.OperationName = query.Get("query"), query.Get("operationName")
+
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				return req, fmt.Errorf("malformed variables: %s", err)
+			}
+		}
+	} else {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+		if err != nil {
+			return req, fmt.Errorf("unreadable body: %s", err)
+		}
+
+		if err := json.Unmarshal(body, &req); err != nil {
+			return req, fmt.Errorf("malformed body: %s", err)
+		}
+	}
+
+	if req.Query == "" {
+		return req, errors.New("missing query")
+	}
+
+	return req, nil
+}
@@ -0,0 +1,152 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// batchWait is how long a loader collects ids before fetching them, for the resolvers running concurrently to
+	// add theirs.
+	batchWait = 5 * time.Millisecond
+
+	// maxBatch is the most ids fetched at once, the limit of the BatchGet RPCs.
+	maxBatch = 100
+)
+
+// loader batches the lookups by id of a query into calls of fetch, and caches the results for the rest of the
+// query. It lives for a single request, so that the results are never stale.
+type loader[V any] struct {
+	ctx context.Context
+	// fetch returns the values of the ids found.
+	fetch func(ctx context.Context, ids []int64) (map[int64]V, error)
+	// wait is how long a batch collects ids before it is fetched.
+	wait time.Duration
+
+	mu      sync.Mutex
+	results map[int64]*result[V]
+	current *batch[V]
+}
+
+// result is the value of an id, ready once its batch is fetched.
+type result[V any] struct {
+	ready chan struct{}
+	value V
+	found bool
+	err   error
+}
+
+// batch is a set of ids fetched together.
+type batch[V any] struct {
+	ids        []int64
+	results    []*result[V]
+	dispatched bool
+}
+
+func newLoader[V any](ctx context.Context, fetch func(ctx context.Context, ids []int64) (map[int64]V, error)) *loader[V] {
+	return &loader[V]{ctx: ctx, fetch: fetch, wait: batchWait, results: make(map[int64]*result[V])}
+}
+
+// load returns the value of the id, reporting false when there is none.
+func (l *loader[V]) load(ctx context.Context, id int64) (V, bool, error) {
+	l.mu.Lock()
+	r := l.enqueue(id)
+	l.mu.Unlock()
+
+	return r.wait(ctx)
+}
+
+// loadAll returns the values of the ids in the same order, reporting false for the ids without one. The ids are
+// fetched in the same batches.
+func (l *loader[V]) loadAll(ctx context.Context, ids []int64) ([]V, []bool, error) {
+	results := make([]*result[V], len(ids))
+
+	l.mu.Lock()
+	for i, id := range ids {
+		results[i] = l.enqueue(id)
+	}
+	l.mu.Unlock()
+
+	values, found := make([]V, len(ids)), make([]bool, len(ids))
+	for i, r := range results {
+		var err error
+		if values[i], found[i], err = r.wait(ctx); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return values, found, nil
+}
+
+// enqueue returns the result of the id, adding the id to the current batch unless it was already. It must be
+// called with the lock held.
+func (l *loader[V]) enqueue(id int64) *result[V] {
+	if r, ok := l.results[id]; ok {
+		return r
+	}
+
+	r := &result[V]{ready: make(chan struct{})}
+	l.results[id] = r
+
+	if l.current == nil {
+		b := &batch[V]{}
+		l.current = b
+		time.AfterFunc(l.wait, func() { l.dispatch(b) })
+	}
+
+	b := l.current
+	b.ids, b.results = append(b.ids, id), append(b.results, r)
+	if len(b.ids) == maxBatch {
+		l.current = nil
+		go l.dispatch(b)
+	}
+
+	return r
+}
+
+// wait returns the value of the result once fetched.
+func (r *result[V]) wait(ctx context.Context) (V, bool, error) {
+	select {
+	case <-r.ready:
+		return r.value, r.found, r.err
+	case <-ctx.Done():
+		var zero V
+		return zero, false, ctx.Err()
+	}
+}
+
+// prime caches the value of an id known from another call, e.g. a list.
+func (l *loader[V]) prime(id int64, value V) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.results[id]; !ok {
+		r := &result[V]{ready: make(chan struct{}), value: value, found: true}
+		close(r.ready)
+		l.results[id] = r
+	}
+}
+
+// dispatch fetches a batch once, when it is full or its wait is over.
+func (l *loader[V]) dispatch(b *batch[V]) {
+	l.mu.Lock()
+	if l.current == b {
+		l.current = nil
+	}
+	if b.dispatched {
+		l.mu.Unlock()
+		return
+	}
+	b.dispatched = true
+	l.mu.Unlock()
+
+	values, err := l.fetch(l.ctx, b.ids)
+
+	for i, id := range b.ids {
+		r := b.results[i]
+		r.value, r.found = values[id]
+		r.err = err
+		close(r.ready)
+	}
+}
@@ -0,0 +1,245 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeFetch records the batches fetched by a loader, and returns the name of each id but the missing ones, or err.
+type fakeFetch struct {
+	missing map[int64]bool
+	err     error
+	// release, when set, holds the fetches until it is closed.
+	release chan struct{}
+
+	mu      sync.Mutex
+	batches [][]int64
+}
+
+func (f *fakeFetch) fetch(_ context.Context, ids []int64) (map[int64]string, error) {
+	f.mu.Lock()
+	f.batches = append(f.batches, slices.Clone(ids))
+	f.mu.Unlock()
+
+	if f.release != nil {
+		<-f.release
+	}
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	values := make(map[int64]string, len(ids))
+	for _, id := range ids {
+		if !f.missing[id] {
+			values[id] = name(id)
+		}
+	}
+
+	return values, nil
+}
+
+// fetched returns the ids of each batch fetched, sorted.
+func (f *fakeFetch) fetched() [][]int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	batches := make([][]int64, 0, len(f.batches))
+	for _, batch := range f.batches {
+		batch = slices.Clone(batch)
+		slices.Sort(batch)
+		batches = append(batches, batch)
+	}
+
+	return batches
+}
+
+func name(id int64) string {
+	return "record " + strconv.FormatInt(id, 10)
+}
+
+func TestLoaderCoalesces(t *testing.T) {
+	f := &fakeFetch{missing: map[int64]bool{12: true}}
+	l := newLoader(context.Background(), f.fetch)
+	// Long enough for every goroutine to add its ids, however busy the machine.
+	l.wait = 100 * time.Millisecond
+
+	var wg sync.WaitGroup
+
+	// Concurrent lookups, some of the same ids, are fetched in one batch.
+	for i := 0; i < 20; i++ {
+		id := int64(i%10 + 1)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			value, found, err := l.load(context.Background(), id)
+			if err != nil || !found || value != name(id) {
+				t.Errorf("load(%d) = %q, %t, %v", id, value, found, err)
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		values, found, err := l.loadAll(context.Background(), []int64{11, 3, 12})
+		if err != nil {
+			t.Errorf("loadAll: %s", err)
+			return
+		}
+
+		if !slices.Equal(values, []string{name(11), name(3), ""}) || !slices.Equal(found, []bool{true, true, false}) {
+			t.Errorf("loadAll = %q, %v", values, found)
+		}
+	}()
+
+	wg.Wait()
+
+	want := [][]int64{{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}}
+	if got := f.fetched(); len(got) != 1 || !slices.Equal(got[0], want[0]) {
+		t.Errorf("fetched %v, want %v", got, want)
+	}
+
+	// The results are cached for the rest of the query.
+	if _, _, err := l.loadAll(context.Background(), []int64{1, 12}); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.fetched(); len(got) != 1 {
+		t.Errorf("fetched %v again, want the cached results", got[1:])
+	}
+}
+
+func TestLoaderSplitsBatches(t *testing.T) {
+	f := &fakeFetch{}
+	l := newLoader(context.Background(), f.fetch)
+
+	ids := make([]int64, 0, 2*maxBatch+50)
+	for id := int64(1); id <= 2*maxBatch+50; id++ {
+		ids = append(ids, id)
+	}
+
+	values, found, err := l.loadAll(context.Background(), ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, id := range ids {
+		if values[i] != name(id) || !found[i] {
+			t.Errorf("loadAll got %q, %t for %d", values[i], found[i], id)
+		}
+	}
+
+	var sizes []int
+	fetched := make(map[int64]int)
+	for _, batch := range f.fetched() {
+		sizes = append(sizes, len(batch))
+		for _, id := range batch {
+			fetched[id]++
+		}
+	}
+	slices.Sort(sizes)
+
+	if want := []int{50, maxBatch, maxBatch}; !slices.Equal(sizes, want) {
+		t.Errorf("fetched batches of %v ids, want %v", sizes, want)
+	}
+	for _, id := range ids {
+		if fetched[id] != 1 {
+			t.Errorf("fetched %d %d times, want once", id, fetched[id])
+		}
+	}
+}
+
+func TestLoaderPrime(t *testing.T) {
+	f := &fakeFetch{}
+	l := newLoader(context.Background(), f.fetch)
+
+	l.prime(5, "primed 5")
+
+	value, found, err := l.load(context.Background(), 5)
+	if err != nil || !found || value != "primed 5" {
+		t.Errorf("load(5) = %q, %t, %v, want the primed value", value, found, err)
+	}
+	if got := f.fetched(); len(got) != 0 {
+		t.Errorf("fetched %v, want nothing", got)
+	}
+
+	values, _, err := l.loadAll(context.Background(), []int64{5, 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(values, []string{"primed 5", name(6)}) {
+		t.Errorf("loadAll = %q", values)
+	}
+	if got := f.fetched(); len(got) != 1 || !slices.Equal(got[0], []int64{6}) {
+		t.Errorf("fetched %v, want [[6]]", got)
+	}
+
+	// Priming an id already loaded keeps the value loaded.
+	l.prime(6, "primed 6")
+
+	if value, _, _ := l.load(context.Background(), 6); value != name(6) {
+		t.Errorf("load(6) = %q after priming, want %q", value, name(6))
+	}
+}
+
+func TestLoaderErrors(t *testing.T) {
+	errFetch := errors.New("racing is down")
+
+	f := &fakeFetch{err: errFetch}
+	l := newLoader(context.Background(), f.fetch)
+	l.wait = 100 * time.Millisecond
+
+	var wg sync.WaitGroup
+
+	// Every lookup of the failed batch gets its error.
+	for _, id := range []int64{1, 2, 3} {
+		id := id
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if _, found, err := l.load(context.Background(), id); !errors.Is(err, errFetch) || found {
+				t.Errorf("load(%d) = %t, %v, want %v", id, found, err, errFetch)
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		if values, found, err := l.loadAll(context.Background(), []int64{2, 4}); !errors.Is(err, errFetch) || values != nil || found != nil {
+			t.Errorf("loadAll = %q, %v, %v, want %v", values, found, err, errFetch)
+		}
+	}()
+
+	wg.Wait()
+
+	if got := f.fetched(); len(got) != 1 {
+		t.Errorf("fetched %v, want a single batch", got)
+	}
+}
+
+func TestLoaderCancel(t *testing.T) {
+	f := &fakeFetch{release: make(chan struct{})}
+	defer close(f.release)
+
+	l := newLoader(context.Background(), f.fetch)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// A resolver whose context is done stops waiting for the fetch.
+	if _, _, err := l.load(ctx, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("load = %v, want %v", err, context.Canceled)
+	}
+}
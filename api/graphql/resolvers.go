@@ -0,0 +1,410 @@
+package graphql
+
+import (
+	"context"
+	"strconv"
+
+	graphqlgo "github.com/graph-gophers/graphql-go"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"git.neds.sh/matty/entain/api/proto/sports"
+)
+
+// loaders batch the lookups by id of a query.
+type loaders struct {
+	races  *loader[*racing.Race]
+	events *loader[*sports.Event]
+	// meetings loads the races of meetings.
+	meetings *loader[[]*racing.Race]
+}
+
+type loadersKey struct{}
+
+// newLoaders creates the loaders of a query, calling the services with the context of the request.
+func newLoaders(ctx context.Context, racingClient racing.RacingClient, sportsClient sports.SportsClient) *loaders {
+	l := &loaders{}
+
+	l.races = newLoader(ctx, func(ctx context.Context, ids []int64) (map[int64]*racing.Race, error) {
+		resp, err := racingClient.BatchGetRaces(ctx, &racing.BatchGetRacesRequest{Ids: ids})
+		if err != nil {
+			return nil, err
+		}
+
+		races := make(map[int64]*racing.Race, len(resp.Results))
+		for _, result := range resp.Results {
+			if result.Found {
+				races[result.Id] = result.Race
+			}
+		}
+
+		return races, nil
+	})
+
+	l.events = newLoader(ctx, func(ctx context.Context, ids []int64) (map[int64]*sports.Event, error) {
+		resp, err := sportsClient.BatchGetEvents(ctx, &sports.BatchGetEventsRequest{Ids: ids})
+		if err != nil {
+			return nil, err
+		}
+
+		events := make(map[int64]*sports.Event, len(resp.Results))
+		for _, result := range resp.Results {
+			if result.Found {
+				events[result.Id] = result.Event
+			}
+		}
+
+		return events, nil
+	})
+
+	l.meetings = newLoader(ctx, func(ctx context.Context, ids []int64) (map[int64][]*racing.Race, error) {
+		resp, err := racingClient.ListRaces(ctx, &racing.ListRacesRequest{
+			Filter:  &racing.ListRacesRequestFilter{MeetingIds: ids},
+			OrderBy: "number",
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		meetings := make(map[int64][]*racing.Race, len(ids))
+		for _, id := range ids {
+			meetings[id] = []*racing.Race{}
+		}
+		for _, race := range resp.Races {
+			meetings[race.MeetingId] = append(meetings[race.MeetingId], race)
+			l.races.prime(race.Id, race)
+		}
+
+		return meetings, nil
+	})
+
+	return l
+}
+
+// loadersFrom returns the loaders of the query of the context.
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
+
+// resolver resolves the queries with the racing and sports services.
+type resolver struct {
+	racing racing.RacingClient
+	sports sports.SportsClient
+}
+
+func (r *resolver) Race(ctx context.Context, args struct{ ID graphqlgo.ID }) (*raceResolver, error) {
+	id, err := parseID("race", args.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	race, found, err := loadersFrom(ctx).races.load(ctx, id)
+	if err != nil || !found {
+		return nil, queryError(err)
+	}
+
+	return &raceResolver{race}, nil
+}
+
+func (r *resolver) Races(ctx context.Context, args struct{ IDs []graphqlgo.ID }) ([]*raceResolver, error) {
+	ids, err := parseIDs("race", args.IDs)
+	if err != nil {
+		return nil, err
+	}
+
+	races, found, err := loadersFrom(ctx).races.loadAll(ctx, ids)
+	if err != nil {
+		return nil, queryError(err)
+	}
+
+	resolvers := make([]*raceResolver, len(races))
+	for i, race := range races {
+		if found[i] {
+			resolvers[i] = &raceResolver{race}
+		}
+	}
+
+	return resolvers, nil
+}
+
+// raceFilter is the RaceFilter input, a ListRacesRequestFilter.
+type raceFilter struct {
+	MeetingIDs    *[]graphqlgo.ID
+	Visible       *bool
+	StartTimeFrom *graphqlgo.Time
+	StartTimeTo   *graphqlgo.Time
+	LocalDate     *string
+	TimeZone      *string
+	Categories    *[]string
+}
+
+func (r *resolver) ListRaces(ctx context.Context, args struct {
+	Filter  *raceFilter
+	OrderBy *string
+}) ([]*raceResolver, error) {
+	req := &racing.ListRacesRequest{Filter: &racing.ListRacesRequestFilter{}, OrderBy: deref(args.OrderBy)}
+
+	if f := args.Filter; f != nil {
+		if f.MeetingIDs != nil {
+			ids, err := parseIDs("meeting", *f.MeetingIDs)
+			if err != nil {
+				return nil, err
+			}
+			req.Filter.MeetingIds = ids
+		}
+
+		if f.Categories != nil {
+			for _, category := range *f.Categories {
+				req.Filter.Categories = append(req.Filter.Categories, racing.RaceCategory(racing.RaceCategory_value[category]))
+			}
+		}
+
+		req.Filter.Visible = f.Visible
+		req.Filter.StartTimeFrom = timestamp(f.StartTimeFrom)
+		req.Filter.StartTimeTo = timestamp(f.StartTimeTo)
+		req.Filter.LocalDate = deref(f.LocalDate)
+		req.Filter.TimeZone = deref(f.TimeZone)
+	}
+
+	resp, err := r.racing.ListRaces(ctx, req)
+	if err != nil {
+		return nil, queryError(err)
+	}
+
+	races := loadersFrom(ctx).races
+
+	resolvers := make([]*raceResolver, 0, len(resp.Races))
+	for _, race := range resp.Races {
+		races.prime(race.Id, race)
+		resolvers = append(resolvers, &raceResolver{race})
+	}
+
+	return resolvers, nil
+}
+
+func (r *resolver) Event(ctx context.Context, args struct{ ID graphqlgo.ID }) (*eventResolver, error) {
+	id, err := parseID("event", args.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	event, found, err := loadersFrom(ctx).events.load(ctx, id)
+	if err != nil || !found {
+		return nil, queryError(err)
+	}
+
+	return &eventResolver{event}, nil
+}
+
+func (r *resolver) Events(ctx context.Context, args struct{ IDs []graphqlgo.ID }) ([]*eventResolver, error) {
+	ids, err := parseIDs("event", args.IDs)
+	if err != nil {
+		return nil, err
+	}
+
+	events, found, err := loadersFrom(ctx).events.loadAll(ctx, ids)
+	if err != nil {
+		return nil, queryError(err)
+	}
+
+	resolvers := make([]*eventResolver, len(events))
+	for i, event := range events {
+		if found[i] {
+			resolvers[i] = &eventResolver{event}
+		}
+	}
+
+	return resolvers, nil
+}
+
+// eventFilter is the EventFilter input, a ListEventsRequestFilter.
+type eventFilter struct {
+	SportID       *graphqlgo.ID
+	Status        *string
+	StartTimeFrom *graphqlgo.Time
+	StartTimeTo   *graphqlgo.Time
+	LocalDate     *string
+	TimeZone      *string
+}
+
+func (r *resolver) ListEvents(ctx context.Context, args struct {
+	Filter  *eventFilter
+	OrderBy *string
+}) ([]*eventResolver, error) {
+	req := &sports.ListEventsRequest{Filter: &sports.ListEventsRequestFilter{}, OrderBy: deref(args.OrderBy)}
+
+	if f := args.Filter; f != nil {
+		if f.SportID != nil {
+			id, err := parseID("sport", *f.SportID)
+			if err != nil {
+				return nil, err
+			}
+			req.Filter.SportId = &id
+		}
+
+		req.Filter.Status = deref(f.Status)
+		req.Filter.StartTimeFrom = timestamp(f.StartTimeFrom)
+		req.Filter.StartTimeTo = timestamp(f.StartTimeTo)
+		req.Filter.LocalDate = deref(f.LocalDate)
+		req.Filter.TimeZone = deref(f.TimeZone)
+	}
+
+	resp, err := r.sports.ListEvents(ctx, req)
+	if err != nil {
+		return nil, queryError(err)
+	}
+
+	events := loadersFrom(ctx).events
+
+	resolvers := make([]*eventResolver, 0, len(resp.Events))
+	for _, event := range resp.Events {
+		events.prime(event.Id, event)
+		resolvers = append(resolvers, &eventResolver{event})
+	}
+
+	return resolvers, nil
+}
+
+// raceResolver resolves the fields of a Race.
+type raceResolver struct {
+	race *racing.Race
+}
+
+func (r *raceResolver) ID() graphqlgo.ID        { return id(r.race.Id) }
+func (r *raceResolver) MeetingID() graphqlgo.ID { return id(r.race.MeetingId) }
+func (r *raceResolver) Name() string            { return r.race.Name }
+func (r *raceResolver) Number() int32           { return int32(r.race.Number) }
+func (r *raceResolver) Visible() bool           { return r.race.Visible }
+func (r *raceResolver) AdvertisedStartTime() *graphqlgo.Time {
+	return graphqlTime(r.race.AdvertisedStartTime)
+}
+func (r *raceResolver) Status() string       { return r.race.Status.String() }
+func (r *raceResolver) Category() string     { return r.race.Category.String() }
+func (r *raceResolver) Distance() int32      { return int32(r.race.Distance) }
+func (r *raceResolver) Class() string        { return r.race.Class }
+func (r *raceResolver) TrackSurface() string { return r.race.TrackSurface }
+func (r *raceResolver) PrizeMoney() float64  { return float64(r.race.PrizeMoney) }
+func (r *raceResolver) FieldSize() int32     { return int32(r.race.FieldSize) }
+func (r *raceResolver) Sequence() int32      { return int32(r.race.Sequence) }
+
+func (r *raceResolver) Meeting() *meetingResolver {
+	return &meetingResolver{id: r.race.MeetingId}
+}
+
+// meetingResolver resolves the fields of a Meeting, known by the races sharing its id.
+type meetingResolver struct {
+	id int64
+}
+
+func (m *meetingResolver) ID() graphqlgo.ID { return id(m.id) }
+
+func (m *meetingResolver) Races(ctx context.Context) ([]*raceResolver, error) {
+	races, _, err := loadersFrom(ctx).meetings.load(ctx, m.id)
+	if err != nil {
+		return nil, queryError(err)
+	}
+
+	resolvers := make([]*raceResolver, 0, len(races))
+	for _, race := range races {
+		resolvers = append(resolvers, &raceResolver{race})
+	}
+
+	return resolvers, nil
+}
+
+// eventResolver resolves the fields of an Event.
+type eventResolver struct {
+	event *sports.Event
+}
+
+func (e *eventResolver) ID() graphqlgo.ID             { return id(e.event.Id) }
+func (e *eventResolver) Name() string                 { return e.event.Name }
+func (e *eventResolver) VenueID() graphqlgo.ID        { return id(e.event.VenueId) }
+func (e *eventResolver) SportID() graphqlgo.ID        { return id(e.event.SportId) }
+func (e *eventResolver) ParticipantsID() graphqlgo.ID { return id(e.event.ParticipantsId) }
+func (e *eventResolver) AdvertisedStartTime() *graphqlgo.Time {
+	return graphqlTime(e.event.AdvertisedStartTime)
+}
+func (e *eventResolver) AdvertisedEndTime() *graphqlgo.Time {
+	return graphqlTime(e.event.AdvertisedEndTime)
+}
+func (e *eventResolver) Status() string      { return e.event.Status }
+func (e *eventResolver) ExternalRef() string { return e.event.ExternalRef }
+func (e *eventResolver) Sequence() int32     { return int32(e.event.Sequence) }
+
+// grpcError is the error of a service call, with its gRPC code in the extensions of the GraphQL error.
+type grpcError struct {
+	status *status.Status
+}
+
+func (e grpcError) Error() string {
+	return e.status.Message()
+}
+
+func (e grpcError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.status.Code().String()}
+}
+
+// queryError returns the error of a service call as a GraphQL error, or nil.
+func queryError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return grpcError{status.Convert(err)}
+}
+
+// parseID returns the int64 of the id of a kind of record.
+func parseID(kind string, gid graphqlgo.ID) (int64, error) {
+	id, err := strconv.ParseInt(string(gid), 10, 64)
+	if err != nil {
+		return 0, queryError(status.Errorf(codes.InvalidArgument, "invalid %s id %q", kind, gid))
+	}
+
+	return id, nil
+}
+
+// parseIDs returns the int64s of the ids of a kind of record.
+func parseIDs(kind string, gids []graphqlgo.ID) ([]int64, error) {
+	ids := make([]int64, 0, len(gids))
+	for _, gid := range gids {
+		id, err := parseID(kind, gid)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func id(id int64) graphqlgo.ID {
+	return graphqlgo.ID(strconv.FormatInt(id, 10))
+}
+
+func graphqlTime(ts *timestamppb.Timestamp) *graphqlgo.Time {
+	if ts == nil {
+		return nil
+	}
+
+	return &graphqlgo.Time{Time: ts.AsTime()}
+}
+
+func timestamp(t *graphqlgo.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+
+	return timestamppb.New(t.Time)
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
@@ -0,0 +1,96 @@
+package graphql
+
+// schema is the GraphQL schema of the gateway. Ids are strings, as the gateway writes the int64 ids of the list
+// routes, and times are RFC 3339.
+const schema = `
+schema {
+	query: Query
+}
+
+type Query {
+	# The race of the id, null when there is none.
+	race(id: ID!): Race
+	# The races of the ids in the same order, null for the ids without a race.
+	races(ids: [ID!]!): [Race]!
+	# The races of ListRaces.
+	listRaces(filter: RaceFilter, orderBy: String): [Race!]!
+	# The sports event of the id, null when there is none.
+	event(id: ID!): Event
+	# The sports events of the ids in the same order, null for the ids without an event.
+	events(ids: [ID!]!): [Event]!
+	# The sports events of ListEvents.
+	listEvents(filter: EventFilter, orderBy: String): [Event!]!
+}
+
+input RaceFilter {
+	meetingIds: [ID!]
+	visible: Boolean
+	startTimeFrom: Time
+	startTimeTo: Time
+	localDate: String
+	timeZone: String
+	categories: [RaceCategory!]
+}
+
+input EventFilter {
+	sportId: ID
+	status: String
+	startTimeFrom: Time
+	startTimeTo: Time
+	localDate: String
+	timeZone: String
+}
+
+enum RaceStatus {
+	CLOSED
+	OPEN
+}
+
+enum RaceCategory {
+	RACE_CATEGORY_UNSPECIFIED
+	THOROUGHBRED
+	HARNESS
+	GREYHOUND
+}
+
+type Race {
+	id: ID!
+	meetingId: ID!
+	meeting: Meeting!
+	name: String!
+	number: Int!
+	visible: Boolean!
+	advertisedStartTime: Time
+	status: RaceStatus!
+	category: RaceCategory!
+	# In metres.
+	distance: Int!
+	class: String!
+	trackSurface: String!
+	# In cents.
+	prizeMoney: Float!
+	fieldSize: Int!
+	sequence: Int!
+}
+
+type Meeting {
+	id: ID!
+	# The races of the meeting by number, hidden ones included.
+	races: [Race!]!
+}
+
+type Event {
+	id: ID!
+	name: String!
+	venueId: ID!
+	sportId: ID!
+	participantsId: ID!
+	advertisedStartTime: Time
+	advertisedEndTime: Time
+	status: String!
+	externalRef: String!
+	sequence: Int!
+}
+
+scalar Time
+`
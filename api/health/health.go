@@ -0,0 +1,119 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Backend is a gRPC service the gateway forwards requests to.
+type Backend struct {
+	// Name is the key used for the backend in the readiness report.
+	Name string
+	// Service is the fully qualified gRPC service name checked through the health protocol.
+	Service string
+	// Conn is the client connection to the backend.
+	Conn *grpc.ClientConn
+}
+
+// Checker serves the liveness and readiness endpoints of the gateway.
+type Checker struct {
+	backends     []Backend
+	timeout      time.Duration
+	shuttingDown int32
+}
+
+// report is the body returned by the health endpoints.
+type report struct {
+	Status   string            `json:"status"`
+	Backends map[string]string `json:"backends,omitempty"`
+}
+
+// NewChecker creates a new checker over the given backends.
+func NewChecker(timeout time.Duration, backends ...Backend) *Checker {
+	return &Checker{backends: backends, timeout: timeout}
+}
+
+// Healthz reports whether the gateway process is alive.
+func (c *Checker) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, http.StatusOK, report{Status: "OK"})
+}
+
+// Shutdown marks the gateway as not ready so that no new traffic is routed to it while draining.
+func (c *Checker) Shutdown() {
+	atomic.StoreInt32(&c.shuttingDown, 1)
+}
+
+// Readyz reports whether every backend is SERVING through the gRPC health protocol.
+func (c *Checker) Readyz(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&c.shuttingDown) == 1 {
+		writeReport(w, http.StatusServiceUnavailable, report{Status: "SHUTTING_DOWN"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), c.timeout)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		ready    = true
+		backends = make(map[string]string, len(c.backends))
+	)
+
+	for _, backend := range c.backends {
+		wg.Add(1)
+
+		go func(backend Backend) {
+			defer wg.Done()
+
+			status := check(ctx, backend)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			backends[backend.Name] = status.String()
+			if status != grpc_health_v1.HealthCheckResponse_SERVING {
+				ready = false
+			}
+		}(backend)
+	}
+
+	wg.Wait()
+
+	if !ready {
+		writeReport(w, http.StatusServiceUnavailable, report{Status: "NOT_READY", Backends: backends})
+		return
+	}
+
+	writeReport(w, http.StatusOK, report{Status: "READY", Backends: backends})
+}
+
+// check returns the serving status of a backend, or UNKNOWN if it cannot be reached.
+func check(ctx context.Context, backend Backend) grpc_health_v1.HealthCheckResponse_ServingStatus {
+	res, err := grpc_health_v1.NewHealthClient(backend.Conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{
+		Service: backend.Service,
+	})
+	if err != nil {
+		log.Warnf("health check of %s failed: %s", backend.Name, err)
+		return grpc_health_v1.HealthCheckResponse_UNKNOWN
+	}
+
+	return res.Status
+}
+
+func writeReport(w http.ResponseWriter, code int, r report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	if err := json.NewEncoder(w).Encode(r); err != nil {
+		log.Errorf("failed writing health report: %s", err)
+	}
+}
@@ -0,0 +1,284 @@
+// Package home serves the home page in one call: the next races, the live sports events and the upcoming featured
+// events. The sections are fetched concurrently, and a section whose service fails or is too slow is left empty
+// with an error, so that the home page still shows the others.
+package home
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"git.neds.sh/matty/entain/api/proto/sports"
+	"git.neds.sh/matty/entain/pkg/config"
+)
+
+// Path is the gateway route of the home page.
+const Path = "/v1/home"
+
+// Handler serves the home page from the racing and sports services.
+type Handler struct {
+	mux    *runtime.ServeMux
+	racing racing.RacingClient
+	sports sports.SportsClient
+	cfg    config.Home
+	// now tells the time the sections start from.
+	now func() time.Time
+}
+
+// raceSection is a list of races, or the error that left it empty.
+type raceSection struct {
+	Races []json.RawMessage `json:"races"`
+	Error *sectionError     `json:"error,omitempty"`
+}
+
+// eventSection is a list of sports events, or the error that left it empty.
+type eventSection struct {
+	Events []json.RawMessage `json:"events"`
+	Error  *sectionError     `json:"error,omitempty"`
+}
+
+// sectionError is the error of a section, written like the errors of the other gateway routes.
+type sectionError struct {
+	Code    int32  `json:"code"`
+	Message string `json:"message"`
+}
+
+// response is the body returned by the home page.
+type response struct {
+	NextRaces      raceSection  `json:"nextRaces"`
+	LiveEvents     eventSection `json:"liveEvents"`
+	FeaturedEvents eventSection `json:"featuredEvents"`
+}
+
+// NewHandler creates a new home page handler with the section settings of cfg, writing its records and errors like
+// the routes of mux.
+func NewHandler(mux *runtime.ServeMux, racingClient racing.RacingClient, sportsClient sports.SportsClient, cfg config.Home) *Handler {
+	return &Handler{mux: mux, racing: racingClient, sports: sportsClient, cfg: cfg, now: time.Now}
+}
+
+// Register adds the home page route to the mux.
+func (h *Handler) Register() error {
+	return h.mux.HandlePath(http.MethodGet, Path, h.Home)
+}
+
+// Home answers GET /v1/home with the sections of the home page:
+//
+//   - nextRaces, the visible races starting next,
+//   - liveEvents, the ONGOING sports events,
+//   - featuredEvents, the OPEN events of the featured sports starting next.
+//
+// Each section holds a races or events list, or an error with the gRPC code and message of the failed call. The
+// response is 200 as long as a section is filled, and the error of the first section otherwise.
+func (h *Handler) Home(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	_, outbound := runtime.MarshalerForRequest(h.mux, r)
+
+	// Forward the headers as the generated routes do, e.g. the trace context and Grpc-Timeout.
+	ctx, err := runtime.AnnotateContext(r.Context(), h.mux, r, "/home.Home", runtime.WithHTTPPathPattern(Path))
+	if err != nil {
+		runtime.HTTPError(ctx, h.mux, outbound, w, r, err)
+		return
+	}
+
+	now := h.now()
+
+	var (
+		wg                             sync.WaitGroup
+		races                          []*racing.Race
+		live, featured                 []*sports.Event
+		racesErr, liveErr, featuredErr error
+	)
+
+	wg.Add(3)
+
+	go func() {
+		defer wg.Done()
+		races, racesErr = h.nextRaces(ctx, now)
+	}()
+
+	go func() {
+		defer wg.Done()
+		live, liveErr = h.liveEvents(ctx)
+	}()
+
+	go func() {
+		defer wg.Done()
+		featured, featuredErr = h.featuredEvents(ctx, now)
+	}()
+
+	wg.Wait()
+
+	if racesErr != nil && liveErr != nil && featuredErr != nil {
+		runtime.HTTPError(ctx, h.mux, outbound, w, r, racesErr)
+		return
+	}
+
+	resp := response{
+		NextRaces:      raceSection{Error: newSectionError("next races", racesErr)},
+		LiveEvents:     eventSection{Error: newSectionError("live events", liveErr)},
+		FeaturedEvents: eventSection{Error: newSectionError("featured events", featuredErr)},
+	}
+
+	if resp.NextRaces.Races, err = marshalAll(outbound, races); err == nil {
+		if resp.LiveEvents.Events, err = marshalAll(outbound, live); err == nil {
+			resp.FeaturedEvents.Events, err = marshalAll(outbound, featured)
+		}
+	}
+	if err != nil {
+		runtime.HTTPError(ctx, h.mux, outbound, w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Errorf("failed writing home page: %s", err)
+	}
+}
+
+// nextRaces returns the visible races starting within the window, soonest first.
+func (h *Handler) nextRaces(ctx context.Context, now time.Time) ([]*racing.Race, error) {
+	ctx, cancel := context.WithTimeout(ctx, h.cfg.Timeout)
+	defer cancel()
+
+	visible := true
+
+	resp, err := h.racing.ListRaces(ctx, &racing.ListRacesRequest{
+		Filter: &racing.ListRacesRequestFilter{
+			Visible:       &visible,
+			StartTimeFrom: timestamppb.New(now),
+			StartTimeTo:   timestamppb.New(now.Add(h.cfg.Window)),
+		},
+		OrderBy: "advertised_start_time",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return truncate(resp.Races, h.cfg.NextRaces), nil
+}
+
+// liveEvents returns the events under way, earliest started first.
+func (h *Handler) liveEvents(ctx context.Context) ([]*sports.Event, error) {
+	ctx, cancel := context.WithTimeout(ctx, h.cfg.Timeout)
+	defer cancel()
+
+	resp, err := h.sports.ListEvents(ctx, &sports.ListEventsRequest{
+		Filter:  &sports.ListEventsRequestFilter{Status: "ONGOING"},
+		OrderBy: "advertised_start_time",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return truncate(resp.Events, h.cfg.LiveEvents), nil
+}
+
+// featuredEvents returns the open events of the featured sports starting within the window, soonest first. The
+// sports are asked concurrently, as the events are filtered by a single sport.
+func (h *Handler) featuredEvents(ctx context.Context, now time.Time) ([]*sports.Event, error) {
+	ctx, cancel := context.WithTimeout(ctx, h.cfg.Timeout)
+	defer cancel()
+
+	filters := []*sports.ListEventsRequestFilter{{}}
+	if len(h.cfg.FeaturedSports) > 0 {
+		filters = filters[:0]
+		for _, id := range h.cfg.FeaturedSports {
+			id := id
+			filters = append(filters, &sports.ListEventsRequestFilter{SportId: &id})
+		}
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		events []*sports.Event
+		errs   = make([]error, len(filters))
+	)
+
+	for i, filter := range filters {
+		filter.Status = "OPEN"
+		filter.StartTimeFrom = timestamppb.New(now)
+		filter.StartTimeTo = timestamppb.New(now.Add(h.cfg.Window))
+
+		wg.Add(1)
+		go func(i int, filter *sports.ListEventsRequestFilter) {
+			defer wg.Done()
+
+			resp, err := h.sports.ListEvents(ctx, &sports.ListEventsRequest{Filter: filter, OrderBy: "advertised_start_time"})
+			if err != nil {
+				errs[i] = err
+				return
+			}
+
+			mu.Lock()
+			events = append(events, resp.Events...)
+			mu.Unlock()
+		}(i, filter)
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		a, b := events[i].AdvertisedStartTime.AsTime(), events[j].AdvertisedStartTime.AsTime()
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+
+		return events[i].Id < events[j].Id
+	})
+
+	return truncate(events, h.cfg.FeaturedEvents), nil
+}
+
+// newSectionError logs the error of a section and returns it as the error of the section, or nil without error.
+func newSectionError(name string, err error) *sectionError {
+	if err == nil {
+		return nil
+	}
+
+	log.Warnf("home page %s left empty: %s", name, err)
+
+	st := status.Convert(err)
+
+	return &sectionError{Code: int32(st.Code()), Message: st.Message()}
+}
+
+// truncate keeps the first n records.
+func truncate[T any](records []T, n int) []T {
+	if len(records) > n {
+		return records[:n]
+	}
+
+	return records
+}
+
+// marshalAll writes the races or events as the other gateway routes do. The list is empty rather than nil, also for
+// the failed sections, so that clients can always range over it.
+func marshalAll[T proto.Message](m runtime.Marshaler, records []T) ([]json.RawMessage, error) {
+	raw := make([]json.RawMessage, 0, len(records))
+	for _, record := range records {
+		b, err := m.Marshal(record)
+		if err != nil {
+			return nil, err
+		}
+
+		raw = append(raw, b)
+	}
+
+	return raw, nil
+}
@@ -0,0 +1,203 @@
+package home
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"git.neds.sh/matty/entain/api/proto/sports"
+	"git.neds.sh/matty/entain/pkg/config"
+)
+
+// now is the time the home page of the tests is served at.
+var now = time.Date(2024, 5, 2, 10, 0, 0, 0, time.UTC)
+
+// fakeRacing answers ListRaces with its races or error.
+type fakeRacing struct {
+	racing.RacingClient
+	races []*racing.Race
+	err   error
+}
+
+func (f *fakeRacing) ListRaces(context.Context, *racing.ListRacesRequest, ...grpc.CallOption) (*racing.ListRacesResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	return &racing.ListRacesResponse{Races: f.races}, nil
+}
+
+// fakeSports answers ListEvents with the live events, or the featured events of the sport asked for, or their errors.
+type fakeSports struct {
+	sports.SportsClient
+	live        []*sports.Event
+	liveErr     error
+	featured    map[int64][]*sports.Event
+	featuredErr map[int64]error
+}
+
+func (f *fakeSports) ListEvents(_ context.Context, in *sports.ListEventsRequest, _ ...grpc.CallOption) (*sports.ListEventsResponse, error) {
+	if in.Filter.Status == "ONGOING" {
+		return &sports.ListEventsResponse{Events: f.live}, f.liveErr
+	}
+
+	sportID := in.Filter.GetSportId()
+	if err := f.featuredErr[sportID]; err != nil {
+		return nil, err
+	}
+
+	return &sports.ListEventsResponse{Events: f.featured[sportID]}, nil
+}
+
+func event(id, sportID int64, start time.Duration) *sports.Event {
+	return &sports.Event{Id: id, Name: "Event", SportId: sportID, AdvertisedStartTime: timestamppb.New(now.Add(start))}
+}
+
+// section is a section of the home page as written.
+type section struct {
+	Races  []struct{ ID string } `json:"races"`
+	Events []struct{ ID string } `json:"events"`
+	Error  *sectionError         `json:"error"`
+}
+
+// ids returns the ids of the races or events of the section.
+func (s section) ids() []string {
+	ids := []string{}
+	for _, record := range append(s.Races, s.Events...) {
+		ids = append(ids, record.ID)
+	}
+
+	return ids
+}
+
+func TestHome(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "racing is down")
+	timeout := status.Error(codes.DeadlineExceeded, "sports is too slow")
+
+	cfg := config.Home{NextRaces: 2, LiveEvents: 5, FeaturedEvents: 3, FeaturedSports: []int64{9, 4}, Window: time.Hour, Timeout: time.Second}
+
+	races := []*racing.Race{{Id: 1, Name: "Alpha"}, {Id: 2, Name: "Beta"}, {Id: 3, Name: "Gamma"}}
+	live := []*sports.Event{event(10, 9, -time.Hour)}
+	featured := map[int64][]*sports.Event{
+		9: {event(20, 9, 30*time.Minute), event(21, 9, 50*time.Minute)},
+		4: {event(30, 4, 10*time.Minute), event(31, 4, 40*time.Minute)},
+	}
+
+	for _, tc := range []struct {
+		name        string
+		racingErr   error
+		liveErr     error
+		featuredErr map[int64]error
+		wantStatus  int
+		// wantIDs and wantCodes are the ids and error code of the next races, live events and featured events.
+		wantIDs   [3][]string
+		wantCodes [3]codes.Code
+	}{
+		{
+			name:       "every section filled",
+			wantStatus: http.StatusOK,
+			wantIDs:    [3][]string{{"1", "2"}, {"10"}, {"30", "20", "31"}},
+		},
+		{
+			name:       "racing failing",
+			racingErr:  unavailable,
+			wantStatus: http.StatusOK,
+			wantIDs:    [3][]string{{}, {"10"}, {"30", "20", "31"}},
+			wantCodes:  [3]codes.Code{codes.Unavailable, codes.OK, codes.OK},
+		},
+		{
+			name:        "a featured sport failing",
+			featuredErr: map[int64]error{4: timeout},
+			wantStatus:  http.StatusOK,
+			wantIDs:     [3][]string{{"1", "2"}, {"10"}, {}},
+			wantCodes:   [3]codes.Code{codes.OK, codes.OK, codes.DeadlineExceeded},
+		},
+		{
+			name:        "only live events",
+			racingErr:   unavailable,
+			featuredErr: map[int64]error{9: timeout},
+			wantStatus:  http.StatusOK,
+			wantIDs:     [3][]string{{}, {"10"}, {}},
+			wantCodes:   [3]codes.Code{codes.Unavailable, codes.OK, codes.DeadlineExceeded},
+		},
+		{
+			name:        "every section failing",
+			racingErr:   unavailable,
+			liveErr:     timeout,
+			featuredErr: map[int64]error{9: timeout},
+			wantStatus:  http.StatusServiceUnavailable,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewHandler(runtime.NewServeMux(),
+				&fakeRacing{races: races, err: tc.racingErr},
+				&fakeSports{live: live, liveErr: tc.liveErr, featured: featured, featuredErr: tc.featuredErr},
+				cfg,
+			)
+			h.now = func() time.Time { return now }
+
+			if err := h.Register(); err != nil {
+				t.Fatal(err)
+			}
+
+			w := httptest.NewRecorder()
+			h.mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, Path, nil))
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("got status %d, want %d: %s", w.Code, tc.wantStatus, w.Body)
+			}
+			if w.Code != http.StatusOK {
+				var body struct{ Code codes.Code }
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Code != codes.Unavailable {
+					t.Errorf("got error %s, want the error of the next races", w.Body)
+				}
+				return
+			}
+
+			// The failed sections hold empty lists, never null.
+			var raw map[string]map[string]json.RawMessage
+			if err := json.Unmarshal(w.Body.Bytes(), &raw); err != nil {
+				t.Fatal(err)
+			}
+			for name, list := range map[string]string{"nextRaces": "races", "liveEvents": "events", "featuredEvents": "events"} {
+				if got := string(raw[name][list]); !strings.HasPrefix(got, "[") {
+					t.Errorf("got %s %s %s, want a list", name, list, got)
+				}
+			}
+
+			var resp struct {
+				NextRaces      section `json:"nextRaces"`
+				LiveEvents     section `json:"liveEvents"`
+				FeaturedEvents section `json:"featuredEvents"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+
+			for i, s := range []section{resp.NextRaces, resp.LiveEvents, resp.FeaturedEvents} {
+				if got := s.ids(); !slices.Equal(got, tc.wantIDs[i]) {
+					t.Errorf("section %d: got ids %v, want %v", i, got, tc.wantIDs[i])
+				}
+
+				switch {
+				case tc.wantCodes[i] == codes.OK && s.Error != nil:
+					t.Errorf("section %d: got error %+v, want none", i, s.Error)
+				case tc.wantCodes[i] != codes.OK && (s.Error == nil || codes.Code(s.Error.Code) != tc.wantCodes[i] || s.Error.Message == ""):
+					t.Errorf("section %d: got error %+v, want code %s", i, s.Error, tc.wantCodes[i])
+				}
+			}
+		})
+	}
+}
@@ -1,59 +1,325 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
 	"flag"
+	"fmt"
+	"io"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
+	"git.neds.sh/matty/entain/api/calendar"
+	"git.neds.sh/matty/entain/api/graphql"
+	"git.neds.sh/matty/entain/api/health"
+	"git.neds.sh/matty/entain/api/home"
 	"git.neds.sh/matty/entain/api/proto/racing"
 	"git.neds.sh/matty/entain/api/proto/sports"
+	"git.neds.sh/matty/entain/api/push"
+	"git.neds.sh/matty/entain/api/search"
+	"git.neds.sh/matty/entain/pkg/clock"
+	"git.neds.sh/matty/entain/pkg/config"
+	"git.neds.sh/matty/entain/pkg/shutdown"
+	"git.neds.sh/matty/entain/pkg/tlsconfig"
+	"git.neds.sh/matty/entain/pkg/tracing"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
 )
 
-var (
-	apiEndpoint        = flag.String("api-endpoint", "localhost:8000", "API endpoint")
-	grpcEndpointRacing = flag.String("grpc-endpoint-racing", "localhost:9000", "gRPC racing server endpoint")
-	grpcEndpointSports = flag.String("grpc-endpoint-sports", "localhost:7000", "gRPC sports server endpoint")
-)
+// readMaskMIME selects the marshaler of the responses to requests with a read mask. It leaves out the fields
+// the mask pruned instead of writing their zero values.
+const readMaskMIME = "application/x-read-mask+json"
+
+// maxReadMaskBodyBytes bounds the bodies read for a read mask.
+const maxReadMaskBodyBytes = 1 << 20
+
+// readMaskBodies are the POST routes binding their whole JSON body to a request with a read mask.
+var readMaskBodies = map[string]bool{
+	"/v1/list-races":  true,
+	"/v1/list-events": true,
+}
 
 func main() {
-	flag.Parse()
+	cfg, err := config.Load(config.ServiceAPI, os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("invalid configuration: %s", err)
+	}
 
-	if err := run(); err != nil {
+	if err := cfg.Log.Configure(); err != nil {
+		log.Fatalf("invalid configuration: %s", err)
+	}
+
+	if err := run(cfg); err != nil {
 		log.Fatalf("failed running api server: %s", err)
 	}
 }
 
-func run() error {
+func run(cfg config.Config) error {
 	ctx := context.Background()
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	mux := runtime.NewServeMux()
+	signalCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Init(cfg.TracingConfig())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Errorf("failed flushing traces: %s", err)
+		}
+	}()
+
+	muxOpts := []runtime.ServeMuxOption{
+		runtime.WithMarshalerOption(readMaskMIME, &runtime.JSONPb{
+			UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+		}),
+	}
+	if cfg.Dev.SimulatedTime {
+		log.Warnf("forwarding the %s header to the services, do not enable in production", clock.Header)
+		muxOpts = append(muxOpts, runtime.WithIncomingHeaderMatcher(simulatedTimeMatcher))
+	}
+
+	mux := runtime.NewServeMux(muxOpts...)
+
+	// The client stats handler injects the trace context of each request into the outgoing gRPC metadata.
+	opts := []grpc.DialOption{
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+	}
+
+	backendTLS, err := tlsconfig.NewClientConfig(ctx, cfg.Backends.TLS, cfg.TLS.ReloadInterval)
+	if err != nil {
+		return err
+	}
+	if backendTLS != nil {
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(backendTLS)))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+
+	serverTLS, err := tlsconfig.NewServerConfig(ctx, cfg.TLS)
+	if err != nil {
+		return err
+	}
+
+	racingConn, err := dialBackend("racing", cfg.Backends.Racing, racing.Racing_ServiceDesc.ServiceName, racingReads,
+		cfg.Backends.RacingPolicy, cfg.Backends.ReloadInterval, opts...)
+	if err != nil {
+		return err
+	}
+	defer racingConn.Close()
+
+	sportsConn, err := dialBackend("sports", cfg.Backends.Sports, sports.Sports_ServiceDesc.ServiceName, sportsReads,
+		cfg.Backends.SportsPolicy, cfg.Backends.ReloadInterval, opts...)
+	if err != nil {
+		return err
+	}
+	defer sportsConn.Close()
 
 	// Register Racing handler
-	if err := racing.RegisterRacingHandlerFromEndpoint(
-		ctx,
-		mux,
-		*grpcEndpointRacing,
-		[]grpc.DialOption{grpc.WithInsecure()},
-	); err != nil {
+	if err := racing.RegisterRacingHandler(ctx, mux, racingConn); err != nil {
 		return err
 	}
 
 	// Register Sports handler
-	if err := sports.RegisterSportsHandlerFromEndpoint(
-		ctx,
-		mux,
-		*grpcEndpointSports,
-		[]grpc.DialOption{grpc.WithInsecure()},
-	); err != nil {
+	if err := sports.RegisterSportsHandler(ctx, mux, sportsConn); err != nil {
+		return err
+	}
+
+	// Register the search across both services
+	if err := search.NewHandler(mux, racing.NewRacingClient(racingConn), sports.NewSportsClient(sportsConn)).Register(); err != nil {
+		return err
+	}
+
+	// Register the iCalendar feeds
+	if err := calendar.NewHandler(mux, racing.NewRacingClient(racingConn), sports.NewSportsClient(sportsConn)).Register(); err != nil {
+		return err
+	}
+
+	// Register the GraphQL queries over both services
+	if err := graphql.NewHandler(mux, racing.NewRacingClient(racingConn), sports.NewSportsClient(sportsConn)).Register(); err != nil {
+		return err
+	}
+
+	// Register the home page over both services
+	if err := home.NewHandler(mux, racing.NewRacingClient(racingConn), sports.NewSportsClient(sportsConn), cfg.Home).Register(); err != nil {
+		return err
+	}
+
+	// Register the change streams, polling the services while there are subscribers
+	hub := push.NewHub(mux, racing.NewRacingClient(racingConn), sports.NewSportsClient(sportsConn), cfg.Push)
+	if err := hub.Register(); err != nil {
+		return err
+	}
+	go hub.Run(ctx)
+
+	checker := health.NewChecker(
+		cfg.Health.Timeout,
+		health.Backend{Name: "racing", Service: racing.Racing_ServiceDesc.ServiceName, Conn: racingConn},
+		health.Backend{Name: "sports", Service: sports.Sports_ServiceDesc.ServiceName, Conn: sportsConn},
+	)
+
+	handler := http.NewServeMux()
+	handler.HandleFunc("/healthz", checker.Healthz)
+	handler.HandleFunc("/readyz", checker.Readyz)
+	// The states and counts of the circuit breakers, among the other expvars.
+	handler.Handle("/debug/vars", expvar.Handler())
+	// Extract the W3C trace context from the incoming HTTP headers and start a span per request.
+	handler.Handle("/", otelhttp.NewHandler(readMaskHandler(mux), "api"))
+
+	tracker := &shutdown.Tracker{}
+
+	server := &http.Server{
+		Addr:              cfg.Listen,
+		Handler:           tracker.Handler(handler),
+		ReadHeaderTimeout: cfg.Timeouts.ReadHeader,
+		IdleTimeout:       cfg.Timeouts.Idle,
+		TLSConfig:         serverTLS,
+	}
+	// The streams never end on their own, and the hijacked WebSocket connections are not waited for.
+	server.RegisterOnShutdown(hub.Close)
+
+	log.Infof("API server listening on: %s", cfg.Listen)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if serverTLS != nil {
+			// The certificates are provided by the TLS configuration.
+			serveErr <- server.ListenAndServeTLS("", "")
+			return
+		}
+
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
 		return err
+	case <-signalCtx.Done():
+	}
+
+	log.Info("received shutdown signal")
+
+	checker.Shutdown()
+
+	return shutdown.HTTP(server, tracker, cfg.Timeouts.Shutdown)
+}
+
+// simulatedTimeMatcher forwards the simulated time header as gRPC metadata, and the other headers as the default
+// matcher does.
+func simulatedTimeMatcher(key string) (string, bool) {
+	if http.CanonicalHeaderKey(key) == clock.Header {
+		return clock.MetadataKey, true
+	}
+
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+// readMaskHandler answers the requests setting a read mask, with fields= or in their JSON body, with partial
+// responses written by the readMaskMIME marshaler.
+func readMaskHandler(mux *runtime.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		masked := r.URL.Query().Has("fields")
+
+		if r.Method == http.MethodPost && readMaskBodies[r.URL.Path] {
+			bodyMasked, err := bindReadMaskQuery(w, r)
+			if err != nil {
+				_, outbound := runtime.MarshalerForRequest(mux, r)
+				runtime.HTTPError(r.Context(), mux, outbound, w, r, status.Error(codes.InvalidArgument, err.Error()))
+				return
+			}
+
+			masked = masked || bodyMasked
+		}
+
+		if masked {
+			r.Header.Set("Accept", readMaskMIME)
+		}
+
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// bindReadMaskQuery moves the fields= query of a request to a readMaskBodies route into its JSON body, as the
+// route does not bind query parameters, and reports whether the body sets a read mask. The body is read once, up to
+// maxReadMaskBodyBytes, and left to be read again. A malformed body is left for the gateway to reject.
+func bindReadMaskQuery(w http.ResponseWriter, r *http.Request) (bool, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxReadMaskBodyBytes))
+	if err != nil {
+		return false, fmt.Errorf("unreadable body: %s", err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	fields := make(map[string]json.RawMessage)
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return false, nil
+		}
+	}
+
+	_, bodyMasked := fields["fields"]
+	if _, ok := fields["read_mask"]; ok {
+		bodyMasked = true
+	}
+
+	query := r.URL.Query()
+	if !query.Has("fields") {
+		return bodyMasked, nil
+	}
+
+	if bodyMasked {
+		return false, errors.New("read mask set by both the fields query parameter and the body")
 	}
 
-	log.Infof("API server listening on: %s", *apiEndpoint)
+	if fields["fields"], err = json.Marshal(camelCasePaths(strings.Join(query["fields"], ","))); err != nil {
+		return false, err
+	}
+
+	if body, err = json.Marshal(fields); err != nil {
+		return false, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+
+	return true, nil
+}
+
+// camelCasePaths converts the snake_case field names of a fields= query to the lowerCamelCase of a FieldMask in JSON.
+func camelCasePaths(paths string) string {
+	var (
+		b     strings.Builder
+		upper bool
+	)
+
+	for _, r := range paths {
+		switch {
+		case r == '_':
+			upper = true
+		case upper:
+			b.WriteString(strings.ToUpper(string(r)))
+			upper = false
+		default:
+			b.WriteRune(r)
+		}
+	}
 
-	return http.ListenAndServe(*apiEndpoint, mux)
+	return b.String()
 }
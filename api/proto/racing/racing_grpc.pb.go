@@ -22,6 +22,19 @@ type RacingClient interface {
 	ListRaces(ctx context.Context, in *ListRacesRequest, opts ...grpc.CallOption) (*ListRacesResponse, error)
 	// GetRace returns the information of a race.
 	GetRace(ctx context.Context, in *GetRaceRequest, opts ...grpc.CallOption) (*GetRaceResponse, error)
+	// BatchGetRaces returns the races of the given ids, reporting the ids without a race.
+	BatchGetRaces(ctx context.Context, in *BatchGetRacesRequest, opts ...grpc.CallOption) (*BatchGetRacesResponse, error)
+	// Search returns the races whose name matches the query, most relevant first. The gateway serves it
+	// through /v1/search along with the sports events.
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	// ScratchRunner withdraws a runner from a race and notifies the subscribers to scratchings. It is not served by
+	// the gateway, which has no authentication.
+	ScratchRunner(ctx context.Context, in *ScratchRunnerRequest, opts ...grpc.CallOption) (*ScratchRunnerResponse, error)
+	// UnscratchRunner reinstates a scratched runner and notifies the subscribers to scratchings. It is not served by
+	// the gateway, which has no authentication.
+	UnscratchRunner(ctx context.Context, in *UnscratchRunnerRequest, opts ...grpc.CallOption) (*UnscratchRunnerResponse, error)
+	// ListScratchings returns the scratchings changed in a time range, oldest change first.
+	ListScratchings(ctx context.Context, in *ListScratchingsRequest, opts ...grpc.CallOption) (*ListScratchingsResponse, error)
 }
 
 type racingClient struct {
@@ -50,6 +63,51 @@ func (c *racingClient) GetRace(ctx context.Context, in *GetRaceRequest, opts ...
 	return out, nil
 }
 
+func (c *racingClient) BatchGetRaces(ctx context.Context, in *BatchGetRacesRequest, opts ...grpc.CallOption) (*BatchGetRacesResponse, error) {
+	out := new(BatchGetRacesResponse)
+	err := c.cc.Invoke(ctx, "/racing.Racing/BatchGetRaces", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, "/racing.Racing/Search", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) ScratchRunner(ctx context.Context, in *ScratchRunnerRequest, opts ...grpc.CallOption) (*ScratchRunnerResponse, error) {
+	out := new(ScratchRunnerResponse)
+	err := c.cc.Invoke(ctx, "/racing.Racing/ScratchRunner", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) UnscratchRunner(ctx context.Context, in *UnscratchRunnerRequest, opts ...grpc.CallOption) (*UnscratchRunnerResponse, error) {
+	out := new(UnscratchRunnerResponse)
+	err := c.cc.Invoke(ctx, "/racing.Racing/UnscratchRunner", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) ListScratchings(ctx context.Context, in *ListScratchingsRequest, opts ...grpc.CallOption) (*ListScratchingsResponse, error) {
+	out := new(ListScratchingsResponse)
+	err := c.cc.Invoke(ctx, "/racing.Racing/ListScratchings", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // RacingServer is the server API for Racing service.
 // All implementations must embed UnimplementedRacingServer
 // for forward compatibility
@@ -58,6 +116,19 @@ type RacingServer interface {
 	ListRaces(context.Context, *ListRacesRequest) (*ListRacesResponse, error)
 	// GetRace returns the information of a race.
 	GetRace(context.Context, *GetRaceRequest) (*GetRaceResponse, error)
+	// BatchGetRaces returns the races of the given ids, reporting the ids without a race.
+	BatchGetRaces(context.Context, *BatchGetRacesRequest) (*BatchGetRacesResponse, error)
+	// Search returns the races whose name matches the query, most relevant first. The gateway serves it
+	// through /v1/search along with the sports events.
+	Search(context.Context, *SearchRequest) (*SearchResponse, error)
+	// ScratchRunner withdraws a runner from a race and notifies the subscribers to scratchings. It is not served by
+	// the gateway, which has no authentication.
+	ScratchRunner(context.Context, *ScratchRunnerRequest) (*ScratchRunnerResponse, error)
+	// UnscratchRunner reinstates a scratched runner and notifies the subscribers to scratchings. It is not served by
+	// the gateway, which has no authentication.
+	UnscratchRunner(context.Context, *UnscratchRunnerRequest) (*UnscratchRunnerResponse, error)
+	// ListScratchings returns the scratchings changed in a time range, oldest change first.
+	ListScratchings(context.Context, *ListScratchingsRequest) (*ListScratchingsResponse, error)
 	mustEmbedUnimplementedRacingServer()
 }
 
@@ -71,6 +142,21 @@ func (UnimplementedRacingServer) ListRaces(context.Context, *ListRacesRequest) (
 func (UnimplementedRacingServer) GetRace(context.Context, *GetRaceRequest) (*GetRaceResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetRace not implemented")
 }
+func (UnimplementedRacingServer) BatchGetRaces(context.Context, *BatchGetRacesRequest) (*BatchGetRacesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchGetRaces not implemented")
+}
+func (UnimplementedRacingServer) Search(context.Context, *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedRacingServer) ScratchRunner(context.Context, *ScratchRunnerRequest) (*ScratchRunnerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ScratchRunner not implemented")
+}
+func (UnimplementedRacingServer) UnscratchRunner(context.Context, *UnscratchRunnerRequest) (*UnscratchRunnerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnscratchRunner not implemented")
+}
+func (UnimplementedRacingServer) ListScratchings(context.Context, *ListScratchingsRequest) (*ListScratchingsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListScratchings not implemented")
+}
 func (UnimplementedRacingServer) mustEmbedUnimplementedRacingServer() {}
 
 // UnsafeRacingServer may be embedded to opt out of forward compatibility for this service.
@@ -120,6 +206,96 @@ func _Racing_GetRace_Handler(srv interface{}, ctx context.Context, dec func(inte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Racing_BatchGetRaces_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchGetRacesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).BatchGetRaces(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/BatchGetRaces",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).BatchGetRaces(ctx, req.(*BatchGetRacesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/Search",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_ScratchRunner_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ScratchRunnerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).ScratchRunner(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/ScratchRunner",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).ScratchRunner(ctx, req.(*ScratchRunnerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_UnscratchRunner_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnscratchRunnerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).UnscratchRunner(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/UnscratchRunner",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).UnscratchRunner(ctx, req.(*UnscratchRunnerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_ListScratchings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListScratchingsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).ListScratchings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/ListScratchings",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).ListScratchings(ctx, req.(*ListScratchingsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Racing_ServiceDesc is the grpc.ServiceDesc for Racing service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -135,6 +311,26 @@ var Racing_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetRace",
 			Handler:    _Racing_GetRace_Handler,
 		},
+		{
+			MethodName: "BatchGetRaces",
+			Handler:    _Racing_BatchGetRaces_Handler,
+		},
+		{
+			MethodName: "Search",
+			Handler:    _Racing_Search_Handler,
+		},
+		{
+			MethodName: "ScratchRunner",
+			Handler:    _Racing_ScratchRunner_Handler,
+		},
+		{
+			MethodName: "UnscratchRunner",
+			Handler:    _Racing_UnscratchRunner_Handler,
+		},
+		{
+			MethodName: "ListScratchings",
+			Handler:    _Racing_ListScratchings_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "racing/racing.proto",
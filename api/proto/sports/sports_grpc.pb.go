@@ -22,6 +22,17 @@ type SportsClient interface {
 	ListEvents(ctx context.Context, in *ListEventsRequest, opts ...grpc.CallOption) (*ListEventsResponse, error)
 	// GetEvent returns a single sports event.
 	GetEvent(ctx context.Context, in *GetEventRequest, opts ...grpc.CallOption) (*GetEventResponse, error)
+	// BatchGetEvents returns the sports events of the given ids, reporting the ids without an event.
+	BatchGetEvents(ctx context.Context, in *BatchGetEventsRequest, opts ...grpc.CallOption) (*BatchGetEventsResponse, error)
+	// Search returns the sports events whose name matches the query, most relevant first. The gateway serves it
+	// through /v1/search along with the races.
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	// ImportEvents upserts the sports events of a CSV or JSON fixture list by external reference, reporting the rows
+	// rejected. Nothing is saved on a dry run. It is not served by the gateway, which has no authentication.
+	ImportEvents(ctx context.Context, in *ImportEventsRequest, opts ...grpc.CallOption) (*ImportEventsResponse, error)
+	// ExportEvents returns the sports events matching a filter as a CSV or JSON fixture list. It is not served by the
+	// gateway, which has no authentication.
+	ExportEvents(ctx context.Context, in *ExportEventsRequest, opts ...grpc.CallOption) (*ExportEventsResponse, error)
 }
 
 type sportsClient struct {
@@ -50,6 +61,42 @@ func (c *sportsClient) GetEvent(ctx context.Context, in *GetEventRequest, opts .
 	return out, nil
 }
 
+func (c *sportsClient) BatchGetEvents(ctx context.Context, in *BatchGetEventsRequest, opts ...grpc.CallOption) (*BatchGetEventsResponse, error) {
+	out := new(BatchGetEventsResponse)
+	err := c.cc.Invoke(ctx, "/sports.Sports/BatchGetEvents", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sportsClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, "/sports.Sports/Search", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sportsClient) ImportEvents(ctx context.Context, in *ImportEventsRequest, opts ...grpc.CallOption) (*ImportEventsResponse, error) {
+	out := new(ImportEventsResponse)
+	err := c.cc.Invoke(ctx, "/sports.Sports/ImportEvents", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sportsClient) ExportEvents(ctx context.Context, in *ExportEventsRequest, opts ...grpc.CallOption) (*ExportEventsResponse, error) {
+	out := new(ExportEventsResponse)
+	err := c.cc.Invoke(ctx, "/sports.Sports/ExportEvents", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // SportsServer is the server API for Sports service.
 // All implementations must embed UnimplementedSportsServer
 // for forward compatibility
@@ -58,6 +105,17 @@ type SportsServer interface {
 	ListEvents(context.Context, *ListEventsRequest) (*ListEventsResponse, error)
 	// GetEvent returns a single sports event.
 	GetEvent(context.Context, *GetEventRequest) (*GetEventResponse, error)
+	// BatchGetEvents returns the sports events of the given ids, reporting the ids without an event.
+	BatchGetEvents(context.Context, *BatchGetEventsRequest) (*BatchGetEventsResponse, error)
+	// Search returns the sports events whose name matches the query, most relevant first. The gateway serves it
+	// through /v1/search along with the races.
+	Search(context.Context, *SearchRequest) (*SearchResponse, error)
+	// ImportEvents upserts the sports events of a CSV or JSON fixture list by external reference, reporting the rows
+	// rejected. Nothing is saved on a dry run. It is not served by the gateway, which has no authentication.
+	ImportEvents(context.Context, *ImportEventsRequest) (*ImportEventsResponse, error)
+	// ExportEvents returns the sports events matching a filter as a CSV or JSON fixture list. It is not served by the
+	// gateway, which has no authentication.
+	ExportEvents(context.Context, *ExportEventsRequest) (*ExportEventsResponse, error)
 	mustEmbedUnimplementedSportsServer()
 }
 
@@ -71,6 +129,18 @@ func (UnimplementedSportsServer) ListEvents(context.Context, *ListEventsRequest)
 func (UnimplementedSportsServer) GetEvent(context.Context, *GetEventRequest) (*GetEventResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetEvent not implemented")
 }
+func (UnimplementedSportsServer) BatchGetEvents(context.Context, *BatchGetEventsRequest) (*BatchGetEventsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchGetEvents not implemented")
+}
+func (UnimplementedSportsServer) Search(context.Context, *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedSportsServer) ImportEvents(context.Context, *ImportEventsRequest) (*ImportEventsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ImportEvents not implemented")
+}
+func (UnimplementedSportsServer) ExportEvents(context.Context, *ExportEventsRequest) (*ExportEventsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExportEvents not implemented")
+}
 func (UnimplementedSportsServer) mustEmbedUnimplementedSportsServer() {}
 
 // UnsafeSportsServer may be embedded to opt out of forward compatibility for this service.
@@ -120,6 +190,78 @@ func _Sports_GetEvent_Handler(srv interface{}, ctx context.Context, dec func(int
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Sports_BatchGetEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchGetEventsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SportsServer).BatchGetEvents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sports.Sports/BatchGetEvents",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SportsServer).BatchGetEvents(ctx, req.(*BatchGetEventsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sports_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SportsServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sports.Sports/Search",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SportsServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sports_ImportEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImportEventsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SportsServer).ImportEvents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sports.Sports/ImportEvents",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SportsServer).ImportEvents(ctx, req.(*ImportEventsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sports_ExportEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExportEventsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SportsServer).ExportEvents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sports.Sports/ExportEvents",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SportsServer).ExportEvents(ctx, req.(*ExportEventsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Sports_ServiceDesc is the grpc.ServiceDesc for Sports service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -135,6 +277,22 @@ var Sports_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetEvent",
 			Handler:    _Sports_GetEvent_Handler,
 		},
+		{
+			MethodName: "BatchGetEvents",
+			Handler:    _Sports_BatchGetEvents_Handler,
+		},
+		{
+			MethodName: "Search",
+			Handler:    _Sports_Search_Handler,
+		},
+		{
+			MethodName: "ImportEvents",
+			Handler:    _Sports_ImportEvents_Handler,
+		},
+		{
+			MethodName: "ExportEvents",
+			Handler:    _Sports_ExportEvents_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "sports/sports.proto",
@@ -0,0 +1,118 @@
+package push
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"git.neds.sh/matty/entain/api/proto/racing"
+)
+
+// Filter selects the changes sent to a stream. The zero filter selects every change.
+type Filter struct {
+	// Types selects the races or the events, both when empty.
+	Types map[string]bool
+	// MeetingIDs and Categories select the races of the meetings and categories, all when empty.
+	MeetingIDs map[int64]bool
+	Categories map[racing.RaceCategory]bool
+	// SportIDs selects the events of the sports, all when empty.
+	SportIDs map[int64]bool
+}
+
+// ParseFilter reads a filter from the query parameters of a stream: type, meeting_id, category and sport_id, each
+// repeated or comma separated. Filtering on races only, meeting_id or category, implies type=race unless the type is
+// set, and on events only, sport_id, type=event.
+func ParseFilter(query url.Values) (Filter, error) {
+	var f Filter
+
+	for _, value := range values(query, "type") {
+		switch value {
+		case TypeRace, TypeEvent:
+			if f.Types == nil {
+				f.Types = make(map[string]bool)
+			}
+			f.Types[value] = true
+		default:
+			return Filter{}, fmt.Errorf("invalid type %q, expected race or event", value)
+		}
+	}
+
+	var err error
+	if f.MeetingIDs, err = ids(query, "meeting_id"); err != nil {
+		return Filter{}, err
+	}
+	if f.SportIDs, err = ids(query, "sport_id"); err != nil {
+		return Filter{}, err
+	}
+
+	for _, value := range values(query, "category") {
+		category, ok := racing.RaceCategory_value[strings.ToUpper(value)]
+		if !ok || category == int32(racing.RaceCategory_RACE_CATEGORY_UNSPECIFIED) {
+			return Filter{}, fmt.Errorf("invalid category %q, expected thoroughbred, harness or greyhound", value)
+		}
+
+		if f.Categories == nil {
+			f.Categories = make(map[racing.RaceCategory]bool)
+		}
+		f.Categories[racing.RaceCategory(category)] = true
+	}
+
+	if f.Types == nil {
+		races, events := len(f.MeetingIDs) > 0 || len(f.Categories) > 0, len(f.SportIDs) > 0
+		if races || events {
+			f.Types = map[string]bool{TypeRace: races, TypeEvent: events}
+		}
+	}
+
+	return f, nil
+}
+
+// Matches reports whether the change is selected by the filter.
+func (f Filter) Matches(c *Change) bool {
+	if len(f.Types) > 0 && !f.Types[c.Type] {
+		return false
+	}
+
+	switch {
+	case c.race != nil:
+		return (len(f.MeetingIDs) == 0 || f.MeetingIDs[c.race.MeetingId]) &&
+			(len(f.Categories) == 0 || f.Categories[c.race.Category])
+	case c.event != nil:
+		return len(f.SportIDs) == 0 || f.SportIDs[c.event.SportId]
+	}
+
+	return true
+}
+
+// values returns the values of a query parameter, split on commas.
+func values(query url.Values, name string) []string {
+	var values []string
+	for _, value := range query[name] {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+
+	return values
+}
+
+// ids returns the ids of a query parameter.
+func ids(query url.Values, name string) (map[int64]bool, error) {
+	var ids map[int64]bool
+	for _, value := range values(query, name) {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id < 1 {
+			return nil, fmt.Errorf("invalid %s %q", name, value)
+		}
+
+		if ids == nil {
+			ids = make(map[int64]bool)
+		}
+		ids[id] = true
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,83 @@
+package push
+
+import (
+	"net/url"
+	"slices"
+	"testing"
+
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"git.neds.sh/matty/entain/api/proto/sports"
+)
+
+func TestParseFilter(t *testing.T) {
+	for _, tc := range []struct {
+		query   string
+		wantErr bool
+	}{
+		{query: ""},
+		{query: "type=race&type=event"},
+		{query: "type=race,event&meeting_id=1,2&category=Harness&sport_id=3"},
+		{query: "type=races", wantErr: true},
+		{query: "meeting_id=0", wantErr: true},
+		{query: "meeting_id=one", wantErr: true},
+		{query: "sport_id=-1", wantErr: true},
+		{query: "category=race_category_unspecified", wantErr: true},
+		{query: "category=camel", wantErr: true},
+	} {
+		query, err := url.ParseQuery(tc.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := ParseFilter(query); (err != nil) != tc.wantErr {
+			t.Errorf("ParseFilter(%q): got error %v, want error %t", tc.query, err, tc.wantErr)
+		}
+	}
+}
+
+func TestFilterMatches(t *testing.T) {
+	raceChange := func(meetingID int64, category racing.RaceCategory) *Change {
+		return &Change{Type: TypeRace, race: &racing.Race{Id: 1, MeetingId: meetingID, Category: category}}
+	}
+	eventChange := func(sportID int64) *Change {
+		return &Change{Type: TypeEvent, event: &sports.Event{Id: 1, SportId: sportID}}
+	}
+
+	changes := map[string]*Change{
+		"thoroughbred race of meeting 1": raceChange(1, racing.RaceCategory_THOROUGHBRED),
+		"greyhound race of meeting 2":    raceChange(2, racing.RaceCategory_GREYHOUND),
+		"event of sport 9":               eventChange(9),
+		"event of sport 4":               eventChange(4),
+	}
+
+	for _, tc := range []struct {
+		query string
+		want  []string
+	}{
+		{query: "", want: []string{"thoroughbred race of meeting 1", "greyhound race of meeting 2", "event of sport 9", "event of sport 4"}},
+		{query: "type=race", want: []string{"thoroughbred race of meeting 1", "greyhound race of meeting 2"}},
+		{query: "type=event", want: []string{"event of sport 9", "event of sport 4"}},
+		{query: "meeting_id=2", want: []string{"greyhound race of meeting 2"}},
+		{query: "category=thoroughbred,harness", want: []string{"thoroughbred race of meeting 1"}},
+		{query: "meeting_id=2&category=thoroughbred"},
+		{query: "sport_id=9", want: []string{"event of sport 9"}},
+		{query: "meeting_id=1&sport_id=4", want: []string{"thoroughbred race of meeting 1", "event of sport 4"}},
+		{query: "type=race,event&sport_id=4", want: []string{"thoroughbred race of meeting 1", "greyhound race of meeting 2", "event of sport 4"}},
+	} {
+		query, err := url.ParseQuery(tc.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		filter, err := ParseFilter(query)
+		if err != nil {
+			t.Fatalf("ParseFilter(%q): %s", tc.query, err)
+		}
+
+		for name, c := range changes {
+			if got, want := filter.Matches(c), slices.Contains(tc.want, name); got != want {
+				t.Errorf("filter %q matches the %s: got %t, want %t", tc.query, name, got, want)
+			}
+		}
+	}
+}
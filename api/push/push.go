@@ -0,0 +1,290 @@
+// Package push streams the changes of the races and sports events to browsers, over Server-Sent Events or
+// WebSocket, as the services only answer requests.
+//
+// A hub polls ListRaces and ListEvents for the visible races and the events advertised to start within a window
+// around now, and compares each record with the previous poll. A race or event is created when it appears,
+// updated when any of its fields changes, its status included, and removed when it is no longer listed: hidden,
+// deleted or out of the window. Streams receive the changes matching their filter, with heartbeats while idle.
+//
+// Streams never miss a change silently: one whose client cannot keep up with the changes is closed after an
+// overflow notice, and the client should reload the lists before subscribing again.
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"git.neds.sh/matty/entain/api/proto/sports"
+	"git.neds.sh/matty/entain/pkg/config"
+	"git.neds.sh/matty/entain/pkg/pubsub"
+)
+
+const (
+	// TypeRace and TypeEvent are the types of the records changed.
+	TypeRace  = "race"
+	TypeEvent = "event"
+
+	// ActionCreated, ActionUpdated and ActionRemoved are the changes of a record.
+	ActionCreated = "created"
+	ActionUpdated = "updated"
+	ActionRemoved = "removed"
+
+	// pollTimeout bounds a poll of a service.
+	pollTimeout = 10 * time.Second
+)
+
+// Change is a race or event created, updated or removed.
+type Change struct {
+	// ID numbers the changes of the hub from 1.
+	ID     uint64          `json:"id"`
+	Type   string          `json:"type"`
+	Action string          `json:"action"`
+	Race   json.RawMessage `json:"race,omitempty"`
+	Event  json.RawMessage `json:"event,omitempty"`
+
+	race  *racing.Race
+	event *sports.Event
+	// data is the JSON of the change, encoded once for every stream.
+	data []byte
+}
+
+// Hub polls the services for changes and fans them out to the streams.
+type Hub struct {
+	mux       *runtime.ServeMux
+	racing    racing.RacingClient
+	sports    sports.SportsClient
+	cfg       config.Push
+	marshaler runtime.Marshaler
+	broker    *pubsub.Broker[*Change]
+
+	// lastID is the id of the last change, only used by the polling goroutine.
+	lastID uint64
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewHub creates a hub polling the services with the settings of cfg. The records and errors are written like the
+// routes of mux write them.
+func NewHub(mux *runtime.ServeMux, racingClient racing.RacingClient, sportsClient sports.SportsClient, cfg config.Push) *Hub {
+	// The marshaler of the requests without Accept header, as the records are encoded once for all streams.
+	_, marshaler := runtime.MarshalerForRequest(mux, &http.Request{Header: http.Header{}})
+
+	return &Hub{
+		mux:       mux,
+		racing:    racingClient,
+		sports:    sportsClient,
+		cfg:       cfg,
+		marshaler: marshaler,
+		broker:    pubsub.NewBroker[*Change]("push"),
+		done:      make(chan struct{}),
+	}
+}
+
+// Close ends the streams, for their clients to reconnect to another instance.
+func (h *Hub) Close() {
+	h.closeOnce.Do(func() { close(h.done) })
+}
+
+// watcher remembers the records of the last poll of a service. A nil snapshot has no poll to compare with yet.
+type watcher struct {
+	kind     string
+	list     func(ctx context.Context, from, to time.Time) ([]proto.Message, error)
+	snapshot map[int64]snapshotted
+	failing  bool
+}
+
+// snapshotted is a record as polled, with its encoding to compare with the next poll.
+type snapshotted struct {
+	record  proto.Message
+	encoded []byte
+}
+
+// Run polls the services every interval until the context is done, while there are streams. The first poll after
+// the streams were all closed only records the races and events, as there was no one to tell of the changes.
+func (h *Hub) Run(ctx context.Context) {
+	watchers := []*watcher{
+		{kind: TypeRace, list: h.listRaces},
+		{kind: TypeEvent, list: h.listEvents},
+	}
+
+	ticker := time.NewTicker(h.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, w := range watchers {
+			if h.broker.Len() == 0 {
+				w.snapshot = nil
+				continue
+			}
+
+			h.poll(ctx, w)
+		}
+	}
+}
+
+// poll lists the records of a watcher and publishes their changes since the previous poll.
+func (h *Hub) poll(ctx context.Context, w *watcher) {
+	now := time.Now()
+
+	ctx, cancel := context.WithTimeout(ctx, pollTimeout)
+	defer cancel()
+
+	records, err := w.list(ctx, now.Add(-h.cfg.Window), now.Add(h.cfg.Window))
+	if err != nil {
+		// The snapshot is kept, so that the changes are published once the service answers again.
+		if !w.failing {
+			log.Warnf("failed polling the %ss for changes: %s", w.kind, err)
+		}
+		w.failing = true
+		return
+	}
+
+	if w.failing {
+		log.Infof("polling the %ss for changes again", w.kind)
+	}
+	w.failing = false
+
+	snapshot := make(map[int64]snapshotted, len(records))
+	for _, record := range records {
+		encoded, err := proto.MarshalOptions{Deterministic: true}.Marshal(record)
+		if err != nil {
+			log.Errorf("failed encoding %s: %s", w.kind, err)
+			return
+		}
+
+		snapshot[recordID(record)] = snapshotted{record: record, encoded: encoded}
+	}
+
+	previous := w.snapshot
+	w.snapshot = snapshot
+
+	if previous == nil {
+		return
+	}
+
+	// The changes follow the order of the records listed, then of the ids of the removed ones.
+	for _, record := range records {
+		id := recordID(record)
+
+		old, ok := previous[id]
+		switch {
+		case !ok:
+			h.publish(w.kind, ActionCreated, record)
+		case !bytes.Equal(old.encoded, snapshot[id].encoded):
+			h.publish(w.kind, ActionUpdated, record)
+		}
+	}
+
+	var removed []int64
+	for id := range previous {
+		if _, ok := snapshot[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+	sort.Slice(removed, func(i, j int) bool { return removed[i] < removed[j] })
+
+	for _, id := range removed {
+		h.publish(w.kind, ActionRemoved, previous[id].record)
+	}
+}
+
+// publish sends a change of a record, as last polled, to the streams.
+func (h *Hub) publish(kind, action string, record proto.Message) {
+	encoded, err := h.marshaler.Marshal(record)
+	if err != nil {
+		log.Errorf("failed encoding %s: %s", kind, err)
+		return
+	}
+
+	h.lastID++
+	change := &Change{ID: h.lastID, Type: kind, Action: action}
+
+	switch record := record.(type) {
+	case *racing.Race:
+		change.Race, change.race = encoded, record
+	case *sports.Event:
+		change.Event, change.event = encoded, record
+	}
+
+	if change.data, err = json.Marshal(change); err != nil {
+		log.Errorf("failed encoding change: %s", err)
+		return
+	}
+
+	h.broker.Publish(change)
+}
+
+// listRaces lists the visible races starting in the window.
+func (h *Hub) listRaces(ctx context.Context, from, to time.Time) ([]proto.Message, error) {
+	visible := true
+
+	resp, err := h.racing.ListRaces(ctx, &racing.ListRacesRequest{
+		Filter: &racing.ListRacesRequestFilter{
+			Visible:       &visible,
+			StartTimeFrom: timestamppb.New(from),
+			StartTimeTo:   timestamppb.New(to),
+		},
+		OrderBy: "advertised_start_time",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]proto.Message, 0, len(resp.Races))
+	for _, race := range resp.Races {
+		records = append(records, race)
+	}
+
+	return records, nil
+}
+
+// listEvents lists the sports events starting in the window.
+func (h *Hub) listEvents(ctx context.Context, from, to time.Time) ([]proto.Message, error) {
+	resp, err := h.sports.ListEvents(ctx, &sports.ListEventsRequest{
+		Filter: &sports.ListEventsRequestFilter{
+			StartTimeFrom: timestamppb.New(from),
+			StartTimeTo:   timestamppb.New(to),
+		},
+		OrderBy: "advertised_start_time",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]proto.Message, 0, len(resp.Events))
+	for _, event := range resp.Events {
+		records = append(records, event)
+	}
+
+	return records, nil
+}
+
+// recordID returns the id of a race or event.
+func recordID(record proto.Message) int64 {
+	switch record := record.(type) {
+	case *racing.Race:
+		return record.Id
+	case *sports.Event:
+		return record.Id
+	}
+
+	return 0
+}
@@ -0,0 +1,206 @@
+package push
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"git.neds.sh/matty/entain/api/proto/sports"
+	"git.neds.sh/matty/entain/pkg/config"
+)
+
+// fakeRacing answers ListRaces with its races or error.
+type fakeRacing struct {
+	racing.RacingClient
+	races []*racing.Race
+	err   error
+}
+
+func (f *fakeRacing) ListRaces(context.Context, *racing.ListRacesRequest, ...grpc.CallOption) (*racing.ListRacesResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	return &racing.ListRacesResponse{Races: f.races}, nil
+}
+
+// fakeSports answers ListEvents with its events or error.
+type fakeSports struct {
+	sports.SportsClient
+	events []*sports.Event
+	err    error
+}
+
+func (f *fakeSports) ListEvents(context.Context, *sports.ListEventsRequest, ...grpc.CallOption) (*sports.ListEventsResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	return &sports.ListEventsResponse{Events: f.events}, nil
+}
+
+// newHub returns a hub of the fake clients with the stream settings.
+func newHub(racingClient *fakeRacing, sportsClient *fakeSports, heartbeat time.Duration, buffer int) *Hub {
+	return NewHub(runtime.NewServeMux(), racingClient, sportsClient, config.Push{
+		PollInterval: time.Hour,
+		Window:       time.Hour,
+		Heartbeat:    heartbeat,
+		Buffer:       buffer,
+	})
+}
+
+// race returns a race of a meeting, as listed by the racing service.
+func race(id, meetingID int64, name string) *racing.Race {
+	return &racing.Race{
+		Id:                  id,
+		MeetingId:           meetingID,
+		Name:                name,
+		Number:              id,
+		Visible:             true,
+		AdvertisedStartTime: timestamppb.New(time.Date(2024, 5, 2, 10, 0, 0, 0, time.UTC)),
+		Status:              racing.RaceStatus_OPEN,
+		Category:            racing.RaceCategory_THOROUGHBRED,
+	}
+}
+
+// describe returns the action and id of the changes, e.g. "created race 3".
+func describe(changes []*Change) []string {
+	described := make([]string, 0, len(changes))
+	for _, c := range changes {
+		var id int64
+		switch {
+		case c.race != nil:
+			id = c.race.Id
+		case c.event != nil:
+			id = c.event.Id
+		}
+
+		described = append(described, fmt.Sprintf("%s %s %d", c.Action, c.Type, id))
+	}
+
+	return described
+}
+
+// drain returns the changes received without waiting.
+func drain(changes <-chan *Change) []*Change {
+	var received []*Change
+	for {
+		select {
+		case c := <-changes:
+			received = append(received, c)
+		default:
+			return received
+		}
+	}
+}
+
+func TestPollRaces(t *testing.T) {
+	closed := race(2, 1, "Beta")
+	closed.Status = racing.RaceStatus_CLOSED
+
+	// The steps poll the same watcher in order, each comparing with the previous one.
+	racingClient := &fakeRacing{}
+	h := newHub(racingClient, &fakeSports{}, time.Hour, 10)
+	w := &watcher{kind: TypeRace, list: h.listRaces}
+
+	changes, cancel := h.broker.Subscribe(10)
+	defer cancel()
+
+	for _, step := range []struct {
+		name  string
+		races []*racing.Race
+		err   error
+		want  []string
+	}{
+		{name: "first poll only records", races: []*racing.Race{race(1, 1, "Alpha"), race(2, 1, "Beta")}},
+		{name: "unchanged", races: []*racing.Race{race(1, 1, "Alpha"), race(2, 1, "Beta")}},
+		{
+			name:  "renamed and created",
+			races: []*racing.Race{race(1, 1, "Alpha Cup"), race(2, 1, "Beta"), race(3, 2, "Gamma")},
+			want:  []string{"updated race 1", "created race 3"},
+		},
+		{
+			name:  "status changed",
+			races: []*racing.Race{race(1, 1, "Alpha Cup"), closed, race(3, 2, "Gamma")},
+			want:  []string{"updated race 2"},
+		},
+		{
+			name:  "removed",
+			races: []*racing.Race{closed},
+			want:  []string{"removed race 1", "removed race 3"},
+		},
+		{name: "failing service keeps the snapshot", err: errors.New("unavailable")},
+		{
+			name:  "changes while failing are published once it answers",
+			races: []*racing.Race{race(4, 2, "Delta")},
+			want:  []string{"created race 4", "removed race 2"},
+		},
+	} {
+		racingClient.races, racingClient.err = step.races, step.err
+
+		h.poll(context.Background(), w)
+
+		if got := describe(drain(changes)); !slices.Equal(got, step.want) {
+			t.Errorf("%s: got changes %q, want %q", step.name, got, step.want)
+		}
+	}
+}
+
+func TestPollEvents(t *testing.T) {
+	start := timestamppb.New(time.Date(2024, 5, 2, 10, 0, 0, 0, time.UTC))
+
+	sportsClient := &fakeSports{events: []*sports.Event{{Id: 1, Name: "Richmond v Carlton", SportId: 9, AdvertisedStartTime: start}}}
+	h := newHub(&fakeRacing{}, sportsClient, time.Hour, 10)
+	w := &watcher{kind: TypeEvent, list: h.listEvents}
+
+	changes, cancel := h.broker.Subscribe(10)
+	defer cancel()
+
+	h.poll(context.Background(), w)
+
+	sportsClient.events = []*sports.Event{{Id: 1, Name: "Richmond v Carlton", SportId: 9, AdvertisedStartTime: start, Status: "CLOSED"}}
+	h.poll(context.Background(), w)
+
+	sportsClient.events = nil
+	h.poll(context.Background(), w)
+
+	got := drain(changes)
+	if described := describe(got); !slices.Equal(described, []string{"updated event 1", "removed event 1"}) {
+		t.Fatalf("got changes %q", described)
+	}
+
+	// The changes are numbered from 1, and encode the record as last polled.
+	for i, c := range got {
+		if c.ID != uint64(i+1) {
+			t.Errorf("change %d has id %d", i, c.ID)
+		}
+
+		var decoded struct {
+			ID     uint64    `json:"id"`
+			Type   string    `json:"type"`
+			Action string    `json:"action"`
+			Race   *struct{} `json:"race"`
+			Event  struct {
+				ID     string `json:"id"`
+				Status string `json:"status"`
+			} `json:"event"`
+		}
+		if err := json.Unmarshal(c.data, &decoded); err != nil {
+			t.Fatalf("change %s: %s", c.data, err)
+		}
+
+		if decoded.ID != c.ID || decoded.Type != TypeEvent || decoded.Action != c.Action || decoded.Race != nil ||
+			decoded.Event.ID != "1" || decoded.Event.Status != "CLOSED" {
+			t.Errorf("got change %s", c.data)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package push
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"golang.org/x/net/websocket"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// SSEPath is the gateway route of the Server-Sent Events stream.
+	SSEPath = "/v1/changes/sse"
+	// WebSocketPath is the gateway route of the WebSocket stream.
+	WebSocketPath = "/v1/changes/ws"
+
+	// NoticeOverflow ends a stream whose client fell behind, and NoticeShutdown one whose gateway is shutting down.
+	NoticeOverflow = "overflow"
+	NoticeShutdown = "shutdown"
+
+	// writeTimeout bounds the write of a message to a client, so that a client which stopped reading is dropped.
+	writeTimeout = 10 * time.Second
+
+	// retryMillis is how long EventSource clients wait before reconnecting.
+	retryMillis = 3000
+)
+
+// stream writes the changes to a client.
+type stream interface {
+	change(c *Change) error
+	heartbeat() error
+	// notice tells the client why the stream ends.
+	notice(kind string) error
+}
+
+// Register adds the stream routes to the mux.
+func (h *Hub) Register() error {
+	if err := h.mux.HandlePath(http.MethodGet, SSEPath, h.SSE); err != nil {
+		return err
+	}
+
+	return h.mux.HandlePath(http.MethodGet, WebSocketPath, h.WebSocket)
+}
+
+// SSE answers GET /v1/changes/sse with a Server-Sent Events stream of the changes matching the filter of the query
+// parameters. Each change is an event of its type, race or event, with the change id and the change as JSON data.
+// Comments keep the idle stream open, and an overflow or shutdown event ends it.
+func (h *Hub) SSE(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	filter, ok := h.filter(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Keeps proxies such as nginx from buffering the stream.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	s := &sseStream{w: w, rc: http.NewResponseController(w)}
+	if err := s.write(fmt.Sprintf("retry: %d\n\n", retryMillis)); err != nil {
+		return
+	}
+
+	h.serve(r.Context().Done(), s, filter)
+}
+
+// WebSocket answers GET /v1/changes/ws with a WebSocket stream of the changes matching the filter of the query
+// parameters. Each change is a text message of its JSON, heartbeats are {"type":"heartbeat"} messages, and an
+// {"type":"overflow"} or {"type":"shutdown"} message ends the stream. Messages from the client are ignored.
+func (h *Hub) WebSocket(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	filter, ok := h.filter(w, r)
+	if !ok {
+		return
+	}
+
+	server := websocket.Server{
+		// Any origin may subscribe, like any may call the other routes of the gateway.
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(conn *websocket.Conn) {
+			// The hijacked connection outlives the request context, so the client is gone when it stops reading.
+			gone := make(chan struct{})
+			go func() {
+				defer close(gone)
+
+				var message string
+				for websocket.Message.Receive(conn, &message) == nil {
+				}
+			}()
+
+			h.serve(gone, &webSocketStream{conn: conn}, filter)
+		},
+	}
+
+	server.ServeHTTP(w, r)
+}
+
+// filter reads the filter of a stream request, writing the error when invalid.
+func (h *Hub) filter(w http.ResponseWriter, r *http.Request) (Filter, bool) {
+	filter, err := ParseFilter(r.URL.Query())
+	if err != nil {
+		_, outbound := runtime.MarshalerForRequest(h.mux, r)
+		runtime.HTTPError(r.Context(), h.mux, outbound, w, r, status.Error(codes.InvalidArgument, err.Error()))
+		return Filter{}, false
+	}
+
+	return filter, true
+}
+
+// serve writes the changes matching the filter to the stream until the client is gone, it cannot be written to, it
+// falls behind or the hub is closed. Heartbeats are written after each heartbeat interval without a change.
+func (h *Hub) serve(gone <-chan struct{}, s stream, filter Filter) {
+	changes, cancel := h.broker.SubscribeOrEvict(h.cfg.Buffer)
+	defer cancel()
+
+	heartbeat := time.NewTicker(h.cfg.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		var err error
+
+		select {
+		case <-gone:
+			return
+		case <-h.done:
+			s.notice(NoticeShutdown)
+			return
+		case c, ok := <-changes:
+			if !ok {
+				// Evicted by the broker, the client missed changes.
+				s.notice(NoticeOverflow)
+				return
+			}
+
+			if !filter.Matches(c) {
+				continue
+			}
+
+			err = s.change(c)
+		case <-heartbeat.C:
+			err = s.heartbeat()
+		}
+
+		if err != nil {
+			return
+		}
+		heartbeat.Reset(h.cfg.Heartbeat)
+	}
+}
+
+// sseStream writes the changes as Server-Sent Events.
+type sseStream struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+func (s *sseStream) change(c *Change) error {
+	return s.write(fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", c.ID, c.Type, c.data))
+}
+
+func (s *sseStream) heartbeat() error {
+	return s.write(": heartbeat\n\n")
+}
+
+func (s *sseStream) notice(kind string) error {
+	return s.write(fmt.Sprintf("event: %s\ndata: {\"type\":%q}\n\n", kind, kind))
+}
+
+// write sends a message to the client right away.
+func (s *sseStream) write(message string) error {
+	// Not every writer supports deadlines, the stream is then only dropped once the connection fails.
+	_ = s.rc.SetWriteDeadline(time.Now().Add(writeTimeout))
+
+	if _, err := s.w.Write([]byte(message)); err != nil {
+		return err
+	}
+
+	return s.rc.Flush()
+}
+
+// webSocketStream writes the changes as WebSocket text messages.
+type webSocketStream struct {
+	conn *websocket.Conn
+}
+
+func (s *webSocketStream) change(c *Change) error {
+	return s.write(string(c.data))
+}
+
+func (s *webSocketStream) heartbeat() error {
+	return s.write(`{"type":"heartbeat"}`)
+}
+
+func (s *webSocketStream) notice(kind string) error {
+	return s.write(fmt.Sprintf(`{"type":%q}`, kind))
+}
+
+func (s *webSocketStream) write(message string) error {
+	if err := s.conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return err
+	}
+
+	return websocket.Message.Send(s.conn, message)
+}
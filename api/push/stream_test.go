@@ -0,0 +1,263 @@
+package push
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/api/proto/racing"
+)
+
+// fakeStream records what serve writes, e.g. "change 1", "heartbeat" or "notice overflow". When release is set,
+// writing a change waits for it to be closed, like a client that stopped reading.
+type fakeStream struct {
+	writes  chan string
+	release chan struct{}
+}
+
+func newFakeStream() *fakeStream {
+	return &fakeStream{writes: make(chan string, 100)}
+}
+
+func (s *fakeStream) change(c *Change) error {
+	s.writes <- fmt.Sprintf("change %d", c.ID)
+	if s.release != nil {
+		<-s.release
+	}
+
+	return nil
+}
+
+func (s *fakeStream) heartbeat() error {
+	s.writes <- "heartbeat"
+	return nil
+}
+
+func (s *fakeStream) notice(kind string) error {
+	s.writes <- "notice " + kind
+	return nil
+}
+
+// next returns the next write of the stream, failing after a second without one.
+func (s *fakeStream) next(t *testing.T) string {
+	t.Helper()
+
+	select {
+	case w := <-s.writes:
+		return w
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for a write to the stream")
+		return ""
+	}
+}
+
+// start serves the stream in the background once subscribed, returning a channel closed when serve returns.
+func start(t *testing.T, h *Hub, s stream, gone <-chan struct{}, filter Filter) <-chan struct{} {
+	t.Helper()
+
+	subscribers := h.broker.Len()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.serve(gone, s, filter)
+	}()
+
+	for deadline := time.Now().Add(time.Second); h.broker.Len() == subscribers; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the stream to subscribe")
+		}
+	}
+
+	return done
+}
+
+// wait fails unless serve returns within a second.
+func wait(t *testing.T, done <-chan struct{}) {
+	t.Helper()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("serve did not return")
+	}
+}
+
+func TestServeFilters(t *testing.T) {
+	h := newHub(&fakeRacing{}, &fakeSports{}, time.Hour, 10)
+	s := newFakeStream()
+	gone := make(chan struct{})
+
+	done := start(t, h, s, gone, Filter{MeetingIDs: map[int64]bool{2: true}})
+
+	h.publish(TypeRace, ActionCreated, race(1, 1, "Alpha"))
+	h.publish(TypeRace, ActionCreated, race(2, 2, "Beta"))
+	h.publish(TypeRace, ActionRemoved, race(3, 1, "Gamma"))
+	h.publish(TypeRace, ActionUpdated, race(2, 2, "Beta Cup"))
+
+	for _, want := range []string{"change 2", "change 4"} {
+		if got := s.next(t); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+
+	close(gone)
+	wait(t, done)
+
+	if got := h.broker.Len(); got != 0 {
+		t.Errorf("got %d subscribers after the client left, want 0", got)
+	}
+	if len(s.writes) > 0 {
+		t.Errorf("got %q after the client left", <-s.writes)
+	}
+}
+
+func TestServeOverflow(t *testing.T) {
+	h := newHub(&fakeRacing{}, &fakeSports{}, time.Hour, 1)
+	s := newFakeStream()
+	s.release = make(chan struct{})
+
+	done := start(t, h, s, make(chan struct{}), Filter{})
+
+	// The stream blocks on the first change, the second fills its buffer and the third evicts it.
+	h.publish(TypeRace, ActionCreated, race(1, 1, "Alpha"))
+	if got := s.next(t); got != "change 1" {
+		t.Fatalf("got %q, want change 1", got)
+	}
+
+	h.publish(TypeRace, ActionCreated, race(2, 1, "Beta"))
+	h.publish(TypeRace, ActionCreated, race(3, 1, "Gamma"))
+	close(s.release)
+
+	// The buffered change is still written, then the overflow notice ends the stream.
+	for _, want := range []string{"change 2", "notice " + NoticeOverflow} {
+		if got := s.next(t); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+
+	wait(t, done)
+}
+
+func TestServeHeartbeat(t *testing.T) {
+	const heartbeat = 200 * time.Millisecond
+
+	h := newHub(&fakeRacing{}, &fakeSports{}, heartbeat, 10)
+	s := newFakeStream()
+	gone := make(chan struct{})
+	defer close(gone)
+
+	began := time.Now()
+	start(t, h, s, gone, Filter{})
+
+	// Each change restarts the heartbeat interval, so no heartbeat is written while changes keep coming.
+	for id := int64(1); id <= 6; id++ {
+		time.Sleep(heartbeat / 4)
+		h.publish(TypeRace, ActionCreated, race(id, 1, "Alpha"))
+
+		if got, want := s.next(t), fmt.Sprintf("change %d", id); got != want {
+			t.Fatalf("got %q after %s, want %q", got, time.Since(began), want)
+		}
+	}
+
+	idle := time.Now()
+	if got := s.next(t); got != "heartbeat" {
+		t.Fatalf("got %q, want heartbeat", got)
+	}
+	if elapsed := time.Since(idle); elapsed < heartbeat/2 {
+		t.Errorf("heartbeat written %s after the last change, want about %s", elapsed, heartbeat)
+	}
+
+	if got := s.next(t); got != "heartbeat" {
+		t.Errorf("got %q, want another heartbeat while idle", got)
+	}
+}
+
+func TestServeShutdown(t *testing.T) {
+	h := newHub(&fakeRacing{}, &fakeSports{}, time.Hour, 10)
+	s := newFakeStream()
+
+	done := start(t, h, s, make(chan struct{}), Filter{})
+
+	h.Close()
+	h.Close()
+
+	if got := s.next(t); got != "notice "+NoticeShutdown {
+		t.Errorf("got %q, want the shutdown notice", got)
+	}
+
+	wait(t, done)
+}
+
+func TestSSE(t *testing.T) {
+	h := newHub(&fakeRacing{}, &fakeSports{}, time.Hour, 10)
+	if err := h.Register(); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(h.mux)
+	defer server.Close()
+	defer h.Close()
+
+	resp, err := http.Get(server.URL + SSEPath + "?category=thoroughbred")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("got Content-Type %q, want text/event-stream", got)
+	}
+
+	body := bufio.NewReader(resp.Body)
+	read := func(want string) {
+		t.Helper()
+
+		line, err := body.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line = strings.TrimSuffix(line, "\n"); !strings.HasPrefix(line, want) {
+			t.Errorf("got line %q, want %q", line, want)
+		}
+	}
+
+	read("retry: 3000")
+	read("")
+
+	for h.broker.Len() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	harness := race(2, 1, "Beta")
+	harness.Category = racing.RaceCategory_HARNESS
+
+	h.publish(TypeRace, ActionCreated, harness)
+	h.publish(TypeRace, ActionUpdated, race(1, 1, "Alpha"))
+
+	read("id: 2")
+	read("event: race")
+	read(`data: {"id":2,"type":"race","action":"updated","race":{"id":"1",`)
+	read("")
+}
+
+func TestSSEInvalidFilter(t *testing.T) {
+	h := newHub(&fakeRacing{}, &fakeSports{}, time.Hour, 10)
+	if err := h.Register(); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	h.mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, SSEPath+"?sport_id=football", nil))
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("got status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if got := h.broker.Len(); got != 0 {
+		t.Errorf("got %d subscribers, want none", got)
+	}
+}
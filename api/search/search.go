@@ -0,0 +1,174 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"git.neds.sh/matty/entain/api/proto/sports"
+	pkgsearch "git.neds.sh/matty/entain/pkg/search"
+)
+
+// Path is the gateway route of the search.
+const Path = "/v1/search"
+
+// Handler serves the search across the races and the sports events. It asks both services concurrently and merges
+// their results by relevance.
+type Handler struct {
+	mux    *runtime.ServeMux
+	racing racing.RacingClient
+	sports sports.SportsClient
+}
+
+// result is a race or a sports event matching the search.
+type result struct {
+	Type    string          `json:"type"`
+	Score   float64         `json:"score"`
+	Snippet string          `json:"snippet"`
+	Race    json.RawMessage `json:"race,omitempty"`
+	Event   json.RawMessage `json:"event,omitempty"`
+}
+
+// response is the body returned by the search.
+type response struct {
+	Results []result `json:"results"`
+}
+
+// NewHandler creates a new search handler writing its responses and errors like the routes of mux.
+func NewHandler(mux *runtime.ServeMux, racingClient racing.RacingClient, sportsClient sports.SportsClient) *Handler {
+	return &Handler{mux: mux, racing: racingClient, sports: sportsClient}
+}
+
+// Register adds the search route to the mux.
+func (h *Handler) Register() error {
+	return h.mux.HandlePath(http.MethodGet, Path, h.Search)
+}
+
+// Search answers GET /v1/search?q=<query>&limit=<limit> with at most limit races and sports events, most
+// relevant first. It fails when either service does, so that results are never silently missing.
+func (h *Handler) Search(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	_, outbound := runtime.MarshalerForRequest(h.mux, r)
+
+	// Forward the headers as the generated routes do, e.g. the trace context and Grpc-Timeout.
+	ctx, err := runtime.AnnotateContext(r.Context(), h.mux, r, "/search.Search", runtime.WithHTTPPathPattern(Path))
+	if err != nil {
+		runtime.HTTPError(ctx, h.mux, outbound, w, r, err)
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+
+	var limit int32
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			runtime.HTTPError(ctx, h.mux, outbound, w, r, status.Errorf(codes.InvalidArgument, "invalid limit: %s", value))
+			return
+		}
+
+		limit = int32(parsed)
+	}
+
+	results, err := h.search(ctx, outbound, query, limit)
+	if err != nil {
+		runtime.HTTPError(ctx, h.mux, outbound, w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	// Keep the <mark> tags of the snippets readable.
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+
+	if err := encoder.Encode(response{Results: results}); err != nil {
+		log.Errorf("failed writing search results: %s", err)
+	}
+}
+
+// search asks both services concurrently and merges their results by descending score, keeping at most limit.
+func (h *Handler) search(ctx context.Context, m runtime.Marshaler, query string, limit int32) ([]result, error) {
+	var (
+		wg                   sync.WaitGroup
+		raceRes              *racing.SearchResponse
+		eventRes             *sports.SearchResponse
+		racingErr, sportsErr error
+	)
+
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		raceRes, racingErr = h.racing.Search(ctx, &racing.SearchRequest{Query: query, Limit: limit})
+	}()
+
+	go func() {
+		defer wg.Done()
+		eventRes, sportsErr = h.sports.Search(ctx, &sports.SearchRequest{Query: query, Limit: limit})
+	}()
+
+	wg.Wait()
+
+	if racingErr != nil {
+		return nil, racingErr
+	}
+
+	if sportsErr != nil {
+		return nil, sportsErr
+	}
+
+	results := make([]result, 0, len(raceRes.Results)+len(eventRes.Results))
+
+	for _, r := range raceRes.Results {
+		race, err := marshal(m, r.Race)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, result{Type: "race", Score: r.Score, Snippet: r.Snippet, Race: race})
+	}
+
+	for _, e := range eventRes.Results {
+		event, err := marshal(m, e.Event)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, result{Type: "event", Score: e.Score, Snippet: e.Snippet, Event: event})
+	}
+
+	// The races come first among equal scores, as each service already orders its own results.
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	if limit == 0 {
+		limit = pkgsearch.DefaultLimit
+	}
+
+	if len(results) > int(limit) {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// marshal writes a race or an event as the other gateway routes do.
+func marshal(m runtime.Marshaler, msg proto.Message) (json.RawMessage, error) {
+	b, err := m.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.RawMessage(b), nil
+}
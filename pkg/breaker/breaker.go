@@ -0,0 +1,234 @@
+// Package breaker fails the calls to a gRPC service right away while the service is failing, instead of having every
+// caller wait for its timeout, and lets the service recover without the load of the retries.
+//
+// A breaker is closed while the calls succeed. It opens after a number of consecutive failed calls, and fails the
+// calls with UNAVAILABLE for the open timeout. It is then half-open: a single probe call goes through, closing the
+// breaker when it succeeds and opening it again when it fails, while the other calls keep failing.
+//
+// Calls fail when the service is unreachable, overloaded or broken: UNAVAILABLE, DEADLINE_EXCEEDED,
+// RESOURCE_EXHAUSTED or INTERNAL. The other errors are answers of the service, and calls cancelled by their caller
+// do not count.
+//
+// The state changes are logged, and published with the counts of the calls in the circuit_breakers expvar.
+package breaker
+
+import (
+	"context"
+	"expvar"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// State is the state of a breaker.
+type State int
+
+const (
+	// Closed lets the calls through.
+	Closed State = iota
+	// Open fails the calls.
+	Open
+	// HalfOpen lets a single probe call through.
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+
+	return "unknown"
+}
+
+// outcome is the outcome of a call for the breaker.
+type outcome int
+
+const (
+	success outcome = iota
+	failure
+	ignored
+)
+
+// healthPrefix is the prefix of the health check methods, which always go through so that they report the service
+// as it is.
+const healthPrefix = "/grpc.health.v1.Health/"
+
+// metrics holds the metrics of every breaker, by name.
+var metrics = expvar.NewMap("circuit_breakers")
+
+// Breaker guards the calls to a service.
+type Breaker struct {
+	name        string
+	failures    int
+	openTimeout time.Duration
+	now         func() time.Time
+
+	mu          sync.Mutex
+	state       State
+	consecutive int
+	openedAt    time.Time
+	probing     bool
+
+	transitions, rejected, failed expvar.Int
+}
+
+// New creates a closed breaker opening after the given consecutive failures for the open timeout. The name identifies
+// the breaker in the logs and metrics.
+func New(name string, failures int, openTimeout time.Duration) *Breaker {
+	b := &Breaker{name: name, failures: failures, openTimeout: openTimeout, now: time.Now}
+
+	m := &expvar.Map{}
+	m.Set("state", expvar.Func(func() interface{} { return b.State().String() }))
+	m.Set("transitions", &b.transitions)
+	m.Set("rejected", &b.rejected)
+	m.Set("failed", &b.failed)
+	metrics.Set(name, m)
+
+	return b
+}
+
+// State returns the state of the breaker. An open breaker whose timeout is over is reported half-open.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == Open && b.now().Sub(b.openedAt) >= b.openTimeout {
+		return HalfOpen
+	}
+
+	return b.state
+}
+
+// UnaryClientInterceptor guards the unary calls of a client connection, but its health checks.
+func (b *Breaker) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if strings.HasPrefix(method, healthPrefix) {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		probe, ok := b.allow()
+		if !ok {
+			b.rejected.Add(1)
+			return status.Errorf(codes.Unavailable, "%s circuit breaker is open", b.name)
+		}
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		b.done(probe, classify(err), err)
+
+		return err
+	}
+}
+
+// allow reports whether a call may go through, and whether it is the probe of the half-open breaker.
+func (b *Breaker) allow() (probe, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Closed:
+		return false, true
+	case Open:
+		if b.now().Sub(b.openedAt) < b.openTimeout {
+			return false, false
+		}
+
+		b.transition(HalfOpen)
+		log.Infof("%s circuit breaker is half-open, probing the service", b.name)
+	}
+
+	if b.probing {
+		return false, false
+	}
+	b.probing = true
+
+	return true, true
+}
+
+// done records the outcome of a call let through.
+func (b *Breaker) done(probe bool, o outcome, err error) {
+	if o == failure {
+		b.failed.Add(1)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe {
+		b.probing = false
+
+		switch o {
+		case success:
+			b.consecutive = 0
+			b.transition(Closed)
+			log.Infof("%s circuit breaker is closed, the service recovered", b.name)
+		case failure:
+			b.open()
+			log.Warnf("%s circuit breaker probe failed, open for %s: %s", b.name, b.openTimeout, err)
+		}
+
+		return
+	}
+
+	// Calls let through before the breaker opened may end after.
+	if b.state != Closed {
+		return
+	}
+
+	switch o {
+	case success:
+		b.consecutive = 0
+	case failure:
+		b.consecutive++
+		if b.consecutive >= b.failures {
+			log.Warnf("%s circuit breaker opened for %s after %d consecutive failures: %s", b.name, b.openTimeout, b.consecutive, err)
+			b.open()
+		}
+	}
+}
+
+// open opens the breaker from now. It must be called with the lock held.
+func (b *Breaker) open() {
+	b.openedAt = b.now()
+	b.consecutive = 0
+	b.transition(Open)
+}
+
+// transition changes the state of the breaker. It must be called with the lock held.
+func (b *Breaker) transition(s State) {
+	if b.state != s {
+		b.state = s
+		b.transitions.Add(1)
+	}
+}
+
+// classify returns the outcome of a call from its error.
+func classify(err error) outcome {
+	if err == nil {
+		return success
+	}
+
+	code := status.Code(err)
+	if code == codes.Unknown {
+		// Interceptors may return the errors of the context as they are.
+		code = status.FromContextError(err).Code()
+	}
+
+	switch code {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Internal:
+		return failure
+	case codes.Canceled:
+		return ignored
+	}
+
+	return success
+}
@@ -0,0 +1,225 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	method      = "/racing.Racing/ListRaces"
+	failures    = 3
+	openTimeout = 10 * time.Second
+)
+
+var errUnavailable = status.Error(codes.Unavailable, "connection refused")
+
+// testBreaker is a breaker telling the time of a clock the test moves, with an interceptor calling it.
+type testBreaker struct {
+	*Breaker
+	t         *testing.T
+	now       time.Time
+	intercept grpc.UnaryClientInterceptor
+}
+
+func newTestBreaker(t *testing.T) *testBreaker {
+	tb := &testBreaker{t: t, now: time.Date(2024, time.May, 1, 12, 0, 0, 0, time.UTC)}
+	tb.Breaker = New(t.Name(), failures, openTimeout)
+	tb.Breaker.now = func() time.Time { return tb.now }
+	tb.intercept = tb.UnaryClientInterceptor()
+
+	return tb
+}
+
+// call makes a call through the breaker to a service answering err, and reports whether the service was called.
+func (tb *testBreaker) call(err error) (called bool, got error) {
+	got = tb.intercept(context.Background(), method, nil, nil, nil,
+		func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+			called = true
+			return err
+		})
+
+	return called, got
+}
+
+// mustCall makes a call the breaker must let through.
+func (tb *testBreaker) mustCall(err error) {
+	tb.t.Helper()
+
+	if called, _ := tb.call(err); !called {
+		tb.t.Fatalf("call rejected by the %s breaker", tb.State())
+	}
+}
+
+// mustReject makes a call the breaker must fail with UNAVAILABLE without calling the service.
+func (tb *testBreaker) mustReject() {
+	tb.t.Helper()
+
+	called, err := tb.call(nil)
+	if called {
+		tb.t.Fatalf("call let through by the %s breaker", tb.State())
+	}
+
+	if status.Code(err) != codes.Unavailable {
+		tb.t.Fatalf("rejected call failed with %v, want UNAVAILABLE", err)
+	}
+}
+
+func (tb *testBreaker) wantState(want State) {
+	tb.t.Helper()
+
+	if got := tb.State(); got != want {
+		tb.t.Fatalf("state = %s, want %s", got, want)
+	}
+}
+
+// trip opens the breaker with consecutive failures.
+func (tb *testBreaker) trip() {
+	tb.t.Helper()
+
+	for i := 0; i < failures; i++ {
+		tb.mustCall(errUnavailable)
+	}
+	tb.wantState(Open)
+}
+
+func TestClosedUntilConsecutiveFailures(t *testing.T) {
+	tb := newTestBreaker(t)
+
+	tb.mustCall(errUnavailable)
+	tb.mustCall(errUnavailable)
+	tb.mustCall(nil)
+	tb.wantState(Closed)
+
+	// Answers of the service are not failures.
+	tb.mustCall(errUnavailable)
+	tb.mustCall(status.Error(codes.NotFound, "race not found"))
+	tb.mustCall(errUnavailable)
+	tb.mustCall(errUnavailable)
+	tb.wantState(Closed)
+
+	tb.mustCall(status.Error(codes.DeadlineExceeded, "deadline exceeded"))
+	tb.wantState(Open)
+}
+
+func TestOpenRejectsUntilTimeout(t *testing.T) {
+	tb := newTestBreaker(t)
+	tb.trip()
+
+	tb.mustReject()
+
+	tb.now = tb.now.Add(openTimeout - time.Nanosecond)
+	tb.mustReject()
+	tb.wantState(Open)
+
+	tb.now = tb.now.Add(time.Nanosecond)
+	tb.wantState(HalfOpen)
+}
+
+func TestHealthChecksBypassOpenBreaker(t *testing.T) {
+	tb := newTestBreaker(t)
+	tb.trip()
+
+	called := false
+	err := tb.intercept(context.Background(), healthPrefix+"Check", nil, nil, nil,
+		func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+			called = true
+			return nil
+		})
+	if !called || err != nil {
+		t.Fatalf("health check called: %t, error: %v", called, err)
+	}
+}
+
+func TestHalfOpenProbeCloses(t *testing.T) {
+	tb := newTestBreaker(t)
+	tb.trip()
+	tb.now = tb.now.Add(openTimeout)
+
+	tb.mustCall(nil)
+	tb.wantState(Closed)
+
+	// The failures counted before opening are forgotten.
+	tb.mustCall(errUnavailable)
+	tb.mustCall(errUnavailable)
+	tb.wantState(Closed)
+}
+
+func TestHalfOpenProbeFailureReopens(t *testing.T) {
+	tb := newTestBreaker(t)
+	tb.trip()
+	tb.now = tb.now.Add(openTimeout)
+
+	tb.mustCall(errUnavailable)
+	tb.wantState(Open)
+	tb.mustReject()
+
+	// The open timeout starts over from the failed probe.
+	tb.now = tb.now.Add(openTimeout - time.Second)
+	tb.mustReject()
+
+	tb.now = tb.now.Add(time.Second)
+	tb.mustCall(nil)
+	tb.wantState(Closed)
+}
+
+func TestHalfOpenLetsASingleProbeThrough(t *testing.T) {
+	tb := newTestBreaker(t)
+	tb.trip()
+	tb.now = tb.now.Add(openTimeout)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- tb.intercept(context.Background(), method, nil, nil, nil,
+			func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+				close(started)
+				<-release
+				return nil
+			})
+	}()
+
+	<-started
+	for i := 0; i < 5; i++ {
+		tb.mustReject()
+	}
+	tb.wantState(HalfOpen)
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("probe failed: %v", err)
+	}
+	tb.wantState(Closed)
+}
+
+func TestCanceledCallsAreIgnored(t *testing.T) {
+	for _, canceled := range []error{
+		status.Error(codes.Canceled, "context canceled"),
+		context.Canceled,
+		errors.Join(errors.New("list races"), context.Canceled),
+	} {
+		t.Run(canceled.Error(), func(t *testing.T) {
+			tb := newTestBreaker(t)
+
+			// A canceled call neither counts as a failure nor resets the count.
+			tb.mustCall(errUnavailable)
+			tb.mustCall(errUnavailable)
+			tb.mustCall(canceled)
+			tb.wantState(Closed)
+			tb.mustCall(errUnavailable)
+			tb.wantState(Open)
+
+			// A canceled probe leaves the breaker half-open for the next call to probe.
+			tb.now = tb.now.Add(openTimeout)
+			tb.mustCall(canceled)
+			tb.wantState(HalfOpen)
+			tb.mustCall(nil)
+			tb.wantState(Closed)
+		})
+	}
+}
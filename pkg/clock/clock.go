@@ -0,0 +1,72 @@
+package clock
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// Header is the HTTP header carrying the RFC3339 instant a request is answered as of.
+	Header = "X-Simulated-Time"
+	// MetadataKey is the gRPC metadata key carrying the simulated time.
+	MetadataKey = "x-simulated-time"
+)
+
+// Clock tells the current time. The repositories use it instead of time.Now so that statuses can be
+// tested and replayed as of any instant.
+type Clock interface {
+	Now() time.Time
+}
+
+// System is the wall clock.
+var System Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// Fixed is a clock stopped at the given instant.
+type Fixed time.Time
+
+// Now returns the instant of the clock.
+func (f Fixed) Now() time.Time { return time.Time(f) }
+
+type simulatedTimeKey struct{}
+
+// WithSimulatedTime returns a context in which FromContext tells the given time.
+func WithSimulatedTime(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, simulatedTimeKey{}, t)
+}
+
+// FromContext returns a clock stopped at the simulated time of the context, or c when the context has none.
+func FromContext(ctx context.Context, c Clock) Clock {
+	if t, ok := ctx.Value(simulatedTimeKey{}).(time.Time); ok {
+		return Fixed(t)
+	}
+
+	return c
+}
+
+// UnaryServerInterceptor reads the simulated time of the incoming metadata into the request context.
+// It is meant for development and QA only, so that the APIs can be queried as of a given instant.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+
+		if values := md.Get(MetadataKey); len(values) > 0 {
+			t, err := time.Parse(time.RFC3339, values[0])
+			if err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid %s: %s", Header, err)
+			}
+
+			ctx = WithSimulatedTime(ctx, t)
+		}
+
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"flag"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"git.neds.sh/matty/entain/pkg/tlsconfig"
+)
+
+var (
+	out   = flag.String("out", "certs", "Directory the certificates are written to")
+	hosts = flag.String("hosts", "localhost,127.0.0.1", "Comma separated hosts the server certificate is valid for")
+)
+
+func main() {
+	flag.Parse()
+
+	if err := tlsconfig.GenerateDevCA(*out, strings.Split(*hosts, ",")); err != nil {
+		log.Fatalf("failed generating dev CA: %s", err)
+	}
+
+	log.Infof("dev CA written to: %s", *out)
+}
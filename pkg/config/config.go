@@ -0,0 +1,518 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"git.neds.sh/matty/entain/pkg/endpoint"
+	"git.neds.sh/matty/entain/pkg/tracing"
+)
+
+const (
+	// ServiceAPI is the name of the API gateway.
+	ServiceAPI = "api"
+	// ServiceRacing is the name of the racing service.
+	ServiceRacing = "racing"
+	// ServiceSports is the name of the sports service.
+	ServiceSports = "sports"
+)
+
+// Config holds the settings of a service.
+type Config struct {
+	// Service is the name of the service the configuration was loaded for.
+	Service string `yaml:"-" toml:"-"`
+
+	// Listen is the address the server listens on.
+	Listen string `yaml:"listen" toml:"listen"`
+	// Backends holds the addresses of the gRPC services, used by the API gateway only.
+	Backends Backends `yaml:"backends" toml:"backends"`
+	// Database holds the database settings of the racing and sports services.
+	Database Database `yaml:"database" toml:"database"`
+	// Seed holds the dummy data settings of the racing and sports services.
+	Seed Seed `yaml:"seed" toml:"seed"`
+	// Ingest holds the feed ingestion settings of the racing service.
+	Ingest Ingest `yaml:"ingest" toml:"ingest"`
+	// Push holds the change stream settings of the API gateway.
+	Push Push `yaml:"push" toml:"push"`
+	// Home holds the home page settings of the API gateway.
+	Home Home `yaml:"home" toml:"home"`
+	// Log holds the logger settings.
+	Log Log `yaml:"log" toml:"log"`
+	// TLS holds the certificates used by the servers.
+	TLS TLS `yaml:"tls" toml:"tls"`
+	// Health holds the health check settings.
+	Health Health `yaml:"health" toml:"health"`
+	// Timeouts holds the server timeouts.
+	Timeouts Timeouts `yaml:"timeouts" toml:"timeouts"`
+	// Tracing holds the OpenTelemetry settings.
+	Tracing Tracing `yaml:"tracing" toml:"tracing"`
+	// Dev holds the development only settings, which must stay disabled in production.
+	Dev Dev `yaml:"dev" toml:"dev"`
+}
+
+// Backends holds the addresses of the gRPC services.
+type Backends struct {
+	// Racing and Sports are the endpoints of the services: an address, a comma separated list of addresses,
+	// dns:///<name:port> or file:///<path> of a file listing the addresses.
+	Racing string `yaml:"racing" toml:"racing"`
+	Sports string `yaml:"sports" toml:"sports"`
+	// ReloadInterval is the time between checks of the endpoint files for changes.
+	ReloadInterval time.Duration `yaml:"reload_interval" toml:"reload_interval"`
+	// RacingPolicy and SportsPolicy govern the calls to each service.
+	RacingPolicy BackendPolicy `yaml:"racing_policy" toml:"racing_policy"`
+	SportsPolicy BackendPolicy `yaml:"sports_policy" toml:"sports_policy"`
+	// TLS holds the settings used to dial the gRPC services.
+	TLS ClientTLS `yaml:"tls" toml:"tls"`
+}
+
+// Balancers are the load balancing policies of the calls to the replicas of a service.
+const (
+	// BalancerRoundRobin sends the calls to the healthy replicas in turn.
+	BalancerRoundRobin = "round_robin"
+	// BalancerLeastRequest sends a call to the replica with the fewer calls in flight of two picked at random.
+	BalancerLeastRequest = "least_request"
+)
+
+// BackendPolicy holds the balancing, timeout, retries and circuit breaker of the calls to a gRPC service.
+type BackendPolicy struct {
+	// Balancer is BalancerRoundRobin or BalancerLeastRequest.
+	Balancer string `yaml:"balancer" toml:"balancer"`
+	// Timeout bounds each call, retries included, unless the deadline of the caller is sooner.
+	Timeout time.Duration `yaml:"timeout" toml:"timeout"`
+	// MaxAttempts is the most attempts of the read-only calls failing with UNAVAILABLE, from 1, no retry, to 5.
+	MaxAttempts int `yaml:"max_attempts" toml:"max_attempts"`
+	// InitialBackoff and MaxBackoff bound the random wait before a retry, which doubles after each attempt.
+	InitialBackoff time.Duration `yaml:"initial_backoff" toml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff" toml:"max_backoff"`
+	// BreakerFailures is the number of consecutive failed calls opening the circuit breaker.
+	BreakerFailures int `yaml:"breaker_failures" toml:"breaker_failures"`
+	// BreakerOpenTimeout is how long the open circuit breaker fails the calls before letting a probe through.
+	BreakerOpenTimeout time.Duration `yaml:"breaker_open_timeout" toml:"breaker_open_timeout"`
+}
+
+// MemoryDSN selects the in-memory repositories instead of a SQL database.
+const MemoryDSN = "memory://"
+
+// Database holds the database settings.
+type Database struct {
+	// DSN is the data source name passed to the SQL driver, or MemoryDSN.
+	DSN string `yaml:"dsn" toml:"dsn"`
+	// AutoMigrate applies the pending schema migrations on startup.
+	AutoMigrate bool `yaml:"auto_migrate" toml:"auto_migrate"`
+}
+
+// Seed holds the dummy data settings.
+type Seed struct {
+	// Enabled inserts dummy rows into the database on startup.
+	Enabled bool `yaml:"enabled" toml:"enabled"`
+	// RandomSeed makes the dummy rows reproducible. A random seed is picked and logged when it is 0.
+	RandomSeed int64 `yaml:"random_seed" toml:"random_seed"`
+	// Count is the number of dummy rows generated.
+	Count int `yaml:"count" toml:"count"`
+	// Meetings is the number of race meetings the dummy races are spread over.
+	Meetings int `yaml:"meetings" toml:"meetings"`
+	// Anchor is the RFC3339 time the dummy rows are scheduled around, now when empty.
+	Anchor string `yaml:"anchor" toml:"anchor"`
+	// Reset deletes the existing rows before seeding.
+	Reset bool `yaml:"reset" toml:"reset"`
+}
+
+// Ingest holds the feed ingestion settings.
+type Ingest struct {
+	// Dir is the drop directory watched for feed files, none when empty.
+	Dir string `yaml:"dir" toml:"dir"`
+	// Interval is the time between scans of the drop directory.
+	Interval time.Duration `yaml:"interval" toml:"interval"`
+}
+
+// Push holds the change stream settings.
+type Push struct {
+	// PollInterval is the time between polls of the services for changes.
+	PollInterval time.Duration `yaml:"poll_interval" toml:"poll_interval"`
+	// Window is how far before and after now the races and events are watched, by advertised start time.
+	Window time.Duration `yaml:"window" toml:"window"`
+	// Heartbeat is the time between heartbeats sent on idle streams.
+	Heartbeat time.Duration `yaml:"heartbeat" toml:"heartbeat"`
+	// Buffer is the number of changes queued for a stream before it is closed as too slow.
+	Buffer int `yaml:"buffer" toml:"buffer"`
+}
+
+// Home holds the home page settings.
+type Home struct {
+	// NextRaces, LiveEvents and FeaturedEvents are the most races or events of each section of the home page.
+	NextRaces      int `yaml:"next_races" toml:"next_races"`
+	LiveEvents     int `yaml:"live_events" toml:"live_events"`
+	FeaturedEvents int `yaml:"featured_events" toml:"featured_events"`
+	// FeaturedSports are the ids of the sports whose events are featured, every sport when empty.
+	FeaturedSports []int64 `yaml:"featured_sports" toml:"featured_sports"`
+	// Window is how far ahead the next races and featured events are looked for.
+	Window time.Duration `yaml:"window" toml:"window"`
+	// Timeout bounds the calls to the services, after which their sections are left empty.
+	Timeout time.Duration `yaml:"timeout" toml:"timeout"`
+}
+
+// Log holds the logger settings.
+type Log struct {
+	// Level is one of the logrus levels, e.g. debug, info or warn.
+	Level string `yaml:"level" toml:"level"`
+	// Format is either text or json.
+	Format string `yaml:"format" toml:"format"`
+}
+
+// TLS holds the certificates used by the servers.
+type TLS struct {
+	// CertFile is the PEM encoded certificate of the server.
+	CertFile string `yaml:"cert_file" toml:"cert_file"`
+	// KeyFile is the PEM encoded private key of the server.
+	KeyFile string `yaml:"key_file" toml:"key_file"`
+	// CAFile is the PEM encoded certificate authority used to verify peers.
+	CAFile string `yaml:"ca_file" toml:"ca_file"`
+	// ClientAuth requires and verifies client certificates against CAFile.
+	ClientAuth bool `yaml:"client_auth" toml:"client_auth"`
+	// ReloadInterval is the time between checks of the files for changes.
+	ReloadInterval time.Duration `yaml:"reload_interval" toml:"reload_interval"`
+}
+
+// ClientTLS holds the settings used to dial a server over TLS.
+type ClientTLS struct {
+	// Enabled dials the server over TLS instead of plaintext.
+	Enabled bool `yaml:"enabled" toml:"enabled"`
+	// CAFile is the PEM encoded certificate authority used to verify the server. The system roots are used when empty.
+	CAFile string `yaml:"ca_file" toml:"ca_file"`
+	// CertFile is the PEM encoded client certificate presented for mutual TLS.
+	CertFile string `yaml:"cert_file" toml:"cert_file"`
+	// KeyFile is the PEM encoded private key of the client certificate.
+	KeyFile string `yaml:"key_file" toml:"key_file"`
+	// ServerName overrides the name used to verify the server certificate.
+	ServerName string `yaml:"server_name" toml:"server_name"`
+}
+
+// Health holds the health check settings.
+type Health struct {
+	// Interval is the time between database pings of the racing and sports services.
+	Interval time.Duration `yaml:"interval" toml:"interval"`
+	// Timeout bounds the backend health checks of the API gateway.
+	Timeout time.Duration `yaml:"timeout" toml:"timeout"`
+}
+
+// Timeouts holds the server timeouts.
+type Timeouts struct {
+	// Shutdown is the time allowed for in-flight requests to complete on shutdown.
+	Shutdown time.Duration `yaml:"shutdown" toml:"shutdown"`
+	// ReadHeader is the time allowed to read the headers of an HTTP request.
+	ReadHeader time.Duration `yaml:"read_header" toml:"read_header"`
+	// Idle is the time an idle HTTP keep-alive connection is kept open.
+	Idle time.Duration `yaml:"idle" toml:"idle"`
+	// Request is the longest time a gRPC request may run, including its database queries.
+	Request time.Duration `yaml:"request" toml:"request"`
+	// Bulk is the longest time a bulk gRPC request may run, such as a fixture import or export.
+	Bulk time.Duration `yaml:"bulk" toml:"bulk"`
+}
+
+// Tracing holds the OpenTelemetry settings.
+type Tracing struct {
+	// Exporter is one of none, stdout or file.
+	Exporter string `yaml:"exporter" toml:"exporter"`
+	// File is the path the spans are written to when using the file exporter.
+	File string `yaml:"file" toml:"file"`
+}
+
+// Dev holds the development only settings.
+type Dev struct {
+	// SimulatedTime answers the requests carrying an X-Simulated-Time header or metadata as of that instant.
+	SimulatedTime bool `yaml:"simulated_time" toml:"simulated_time"`
+}
+
+// validate checks the policy of the named backend.
+func (p BackendPolicy) validate(backend string) []error {
+	var errs []error
+
+	if p.Balancer != BalancerRoundRobin && p.Balancer != BalancerLeastRequest {
+		errs = append(errs, fmt.Errorf("%s balancer must be %s or %s, got %q", backend, BalancerRoundRobin, BalancerLeastRequest, p.Balancer))
+	}
+
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"timeout", p.Timeout},
+		{"initial backoff", p.InitialBackoff},
+		{"max backoff", p.MaxBackoff},
+		{"breaker open timeout", p.BreakerOpenTimeout},
+	} {
+		if d.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s %s must be positive, got %s", backend, d.name, d.value))
+		}
+	}
+	if p.MaxBackoff < p.InitialBackoff {
+		errs = append(errs, fmt.Errorf("%s max backoff %s is shorter than the initial backoff %s", backend, p.MaxBackoff, p.InitialBackoff))
+	}
+	// gRPC caps the attempts at 5.
+	if p.MaxAttempts < 1 || p.MaxAttempts > 5 {
+		errs = append(errs, fmt.Errorf("%s max attempts must be between 1 and 5, got %d", backend, p.MaxAttempts))
+	}
+	if p.BreakerFailures < 1 {
+		errs = append(errs, fmt.Errorf("%s breaker failures must be positive, got %d", backend, p.BreakerFailures))
+	}
+
+	return errs
+}
+
+// Default returns the default configuration of a service.
+func Default(service string) Config {
+	cfg := Config{
+		Service: service,
+		Log: Log{
+			Level:  "info",
+			Format: "text",
+		},
+		Health: Health{
+			Interval: 5 * time.Second,
+			Timeout:  2 * time.Second,
+		},
+		Timeouts: Timeouts{
+			Shutdown:   10 * time.Second,
+			ReadHeader: 5 * time.Second,
+			Idle:       2 * time.Minute,
+			Request:    5 * time.Second,
+			Bulk:       5 * time.Minute,
+		},
+		TLS: TLS{
+			ReloadInterval: 10 * time.Second,
+		},
+		Tracing: Tracing{
+			Exporter: tracing.ExporterNone,
+			File:     service + "-traces.json",
+		},
+	}
+
+	switch service {
+	case ServiceAPI:
+		cfg.Listen = "localhost:8000"
+		policy := BackendPolicy{
+			Balancer:           BalancerRoundRobin,
+			Timeout:            5 * time.Second,
+			MaxAttempts:        3,
+			InitialBackoff:     100 * time.Millisecond,
+			MaxBackoff:         time.Second,
+			BreakerFailures:    5,
+			BreakerOpenTimeout: 10 * time.Second,
+		}
+		cfg.Backends = Backends{
+			Racing:         "localhost:9000",
+			Sports:         "localhost:7000",
+			ReloadInterval: 5 * time.Second,
+			RacingPolicy:   policy,
+			SportsPolicy:   policy,
+		}
+		cfg.Push = Push{
+			PollInterval: 2 * time.Second,
+			Window:       24 * time.Hour,
+			Heartbeat:    15 * time.Second,
+			Buffer:       256,
+		}
+		cfg.Home = Home{
+			NextRaces:      10,
+			LiveEvents:     10,
+			FeaturedEvents: 10,
+			Window:         24 * time.Hour,
+			Timeout:        2 * time.Second,
+		}
+	case ServiceRacing:
+		cfg.Listen = "localhost:9000"
+		cfg.Database.DSN = "./db/racing.db"
+		cfg.Seed.Enabled = true
+		cfg.Seed.Count = 100
+		cfg.Seed.Meetings = 10
+		cfg.Ingest.Interval = 10 * time.Second
+	case ServiceSports:
+		cfg.Listen = "localhost:7000"
+		cfg.Database.DSN = "./db/sports.db"
+		cfg.Seed.Enabled = true
+		cfg.Seed.Count = 100
+	}
+
+	return cfg
+}
+
+// Validate checks that the configuration can be used to start the service.
+func (c Config) Validate() error {
+	var errs []error
+
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		errs = append(errs, fmt.Errorf("invalid listen address %q: %w", c.Listen, err))
+	}
+
+	if c.Service == ServiceAPI {
+		if len(c.Backends.Racing) == 0 {
+			errs = append(errs, errors.New("racing backend address is required"))
+		}
+		if len(c.Backends.Sports) == 0 {
+			errs = append(errs, errors.New("sports backend address is required"))
+		}
+		if c.Backends.ReloadInterval <= 0 {
+			errs = append(errs, fmt.Errorf("backend reload interval must be positive, got %s", c.Backends.ReloadInterval))
+		}
+		errs = append(errs, c.Backends.RacingPolicy.validate("racing")...)
+		errs = append(errs, c.Backends.SportsPolicy.validate("sports")...)
+		for _, d := range []struct {
+			name  string
+			value time.Duration
+		}{
+			{"poll interval", c.Push.PollInterval},
+			{"window", c.Push.Window},
+			{"heartbeat", c.Push.Heartbeat},
+		} {
+			if d.value <= 0 {
+				errs = append(errs, fmt.Errorf("push %s must be positive, got %s", d.name, d.value))
+			}
+		}
+		if c.Push.Buffer < 1 {
+			errs = append(errs, fmt.Errorf("push buffer must be positive, got %d", c.Push.Buffer))
+		}
+		for _, n := range []struct {
+			name  string
+			value int
+		}{
+			{"next races", c.Home.NextRaces},
+			{"live events", c.Home.LiveEvents},
+			{"featured events", c.Home.FeaturedEvents},
+		} {
+			if n.value < 1 {
+				errs = append(errs, fmt.Errorf("home %s must be positive, got %d", n.name, n.value))
+			}
+		}
+		for _, id := range c.Home.FeaturedSports {
+			if id < 1 {
+				errs = append(errs, fmt.Errorf("home featured sport id must be positive, got %d", id))
+			}
+		}
+		if c.Home.Window <= 0 {
+			errs = append(errs, fmt.Errorf("home window must be positive, got %s", c.Home.Window))
+		}
+		if c.Home.Timeout <= 0 {
+			errs = append(errs, fmt.Errorf("home timeout must be positive, got %s", c.Home.Timeout))
+		}
+	} else {
+		if len(c.Database.DSN) == 0 {
+			errs = append(errs, errors.New("database DSN is required"))
+		}
+		if c.Seed.Count < 0 {
+			errs = append(errs, fmt.Errorf("seed count must not be negative, got %d", c.Seed.Count))
+		}
+		if c.Service == ServiceRacing && c.Seed.Meetings < 1 {
+			errs = append(errs, fmt.Errorf("seed meetings must be positive, got %d", c.Seed.Meetings))
+		}
+		if _, err := c.Seed.AnchorTime(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid seed anchor: %w", err))
+		}
+		if len(c.Ingest.Dir) != 0 {
+			if c.Database.InMemory() {
+				errs = append(errs, errors.New("feed ingestion needs a SQL database, not the in-memory repository"))
+			}
+			if c.Ingest.Interval <= 0 {
+				errs = append(errs, fmt.Errorf("ingest interval must be positive, got %s", c.Ingest.Interval))
+			}
+		}
+	}
+
+	if _, err := log.ParseLevel(c.Log.Level); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Log.Format != "text" && c.Log.Format != "json" {
+		errs = append(errs, fmt.Errorf("invalid log format %q: choose either `text` or `json`", c.Log.Format))
+	}
+
+	if (len(c.TLS.CertFile) == 0) != (len(c.TLS.KeyFile) == 0) {
+		errs = append(errs, errors.New("both the TLS certificate and key files are required"))
+	}
+	if c.TLS.ClientAuth && len(c.TLS.CAFile) == 0 {
+		errs = append(errs, errors.New("a TLS CA file is required to verify client certificates"))
+	}
+	if (len(c.Backends.TLS.CertFile) == 0) != (len(c.Backends.TLS.KeyFile) == 0) {
+		errs = append(errs, errors.New("both the backend TLS certificate and key files are required"))
+	}
+	if c.Service == ServiceAPI && c.Backends.TLS.Enabled && len(c.Backends.TLS.ServerName) == 0 {
+		for _, backend := range []struct{ name, endpoint string }{
+			{"racing", c.Backends.Racing},
+			{"sports", c.Backends.Sports},
+		} {
+			if !endpoint.NamesServer(backend.endpoint) {
+				errs = append(errs, fmt.Errorf("a backend TLS server name is required to verify the %s replicas of %q", backend.name, backend.endpoint))
+			}
+		}
+	}
+
+	for _, timeout := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"health check interval", c.Health.Interval},
+		{"health check timeout", c.Health.Timeout},
+		{"shutdown timeout", c.Timeouts.Shutdown},
+		{"request timeout", c.Timeouts.Request},
+		{"bulk request timeout", c.Timeouts.Bulk},
+		{"read header timeout", c.Timeouts.ReadHeader},
+		{"idle timeout", c.Timeouts.Idle},
+		{"TLS reload interval", c.TLS.ReloadInterval},
+	} {
+		if timeout.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive, got %s", timeout.name, timeout.value))
+		}
+	}
+
+	switch c.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterStdout:
+	case tracing.ExporterFile:
+		if len(c.Tracing.File) == 0 {
+			errs = append(errs, errors.New("trace file is required for the file exporter"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown trace exporter %q", c.Tracing.Exporter))
+	}
+
+	return errors.Join(errs...)
+}
+
+// InMemory reports whether the DSN selects the in-memory repositories, whose data is lost on exit.
+func (d Database) InMemory() bool {
+	return d.DSN == MemoryDSN
+}
+
+// AnchorTime returns the time the dummy rows are scheduled around.
+func (s Seed) AnchorTime() (time.Time, error) {
+	if len(s.Anchor) == 0 {
+		return time.Now(), nil
+	}
+
+	return time.Parse(time.RFC3339, s.Anchor)
+}
+
+// Configure applies the logger settings to the standard logrus logger.
+func (l Log) Configure() error {
+	level, err := log.ParseLevel(l.Level)
+	if err != nil {
+		return err
+	}
+
+	log.SetLevel(level)
+
+	if l.Format == "json" {
+		log.SetFormatter(&log.JSONFormatter{})
+	}
+
+	return nil
+}
+
+// TracingConfig returns the options passed to tracing.Init.
+func (c Config) TracingConfig() tracing.Config {
+	return tracing.Config{
+		ServiceName: c.Service,
+		Exporter:    c.Tracing.Exporter,
+		File:        c.Tracing.File,
+	}
+}
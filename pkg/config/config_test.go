@@ -0,0 +1,208 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"git.neds.sh/matty/entain/pkg/tracing"
+)
+
+func TestValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		service string
+		change  func(c *Config)
+		// wantErr is part of the error expected, none when empty.
+		wantErr string
+	}{
+		{name: "racing defaults", service: ServiceRacing},
+		{name: "sports defaults", service: ServiceSports},
+		{name: "api defaults", service: ServiceAPI},
+		{
+			name:    "listen address without port",
+			service: ServiceRacing,
+			change:  func(c *Config) { c.Listen = "localhost" },
+			wantErr: `invalid listen address "localhost"`,
+		},
+		{
+			name:    "no database",
+			service: ServiceSports,
+			change:  func(c *Config) { c.Database.DSN = "" },
+			wantErr: "database DSN is required",
+		},
+		{
+			name:    "negative seed count",
+			service: ServiceSports,
+			change:  func(c *Config) { c.Seed.Count = -1 },
+			wantErr: "seed count must not be negative",
+		},
+		{
+			name:    "no meetings",
+			service: ServiceRacing,
+			change:  func(c *Config) { c.Seed.Meetings = 0 },
+			wantErr: "seed meetings must be positive",
+		},
+		{
+			name:    "seed anchor not RFC3339",
+			service: ServiceRacing,
+			change:  func(c *Config) { c.Seed.Anchor = "2024-05-02 10:00" },
+			wantErr: "invalid seed anchor",
+		},
+		{
+			name:    "feed ingestion in memory",
+			service: ServiceRacing,
+			change:  func(c *Config) { c.Ingest.Dir, c.Database.DSN = "feeds", MemoryDSN },
+			wantErr: "feed ingestion needs a SQL database",
+		},
+		{
+			name:    "no ingest interval",
+			service: ServiceRacing,
+			change:  func(c *Config) { c.Ingest.Dir, c.Ingest.Interval = "feeds", 0 },
+			wantErr: "ingest interval must be positive",
+		},
+		{
+			name:    "no racing backend",
+			service: ServiceAPI,
+			change:  func(c *Config) { c.Backends.Racing = "" },
+			wantErr: "racing backend address is required",
+		},
+		{
+			name:    "unknown balancer",
+			service: ServiceAPI,
+			change:  func(c *Config) { c.Backends.SportsPolicy.Balancer = "random" },
+			wantErr: `sports balancer must be round_robin or least_request, got "random"`,
+		},
+		{
+			name:    "too many attempts",
+			service: ServiceAPI,
+			change:  func(c *Config) { c.Backends.RacingPolicy.MaxAttempts = 6 },
+			wantErr: "racing max attempts must be between 1 and 5",
+		},
+		{
+			name:    "max backoff shorter than the initial one",
+			service: ServiceAPI,
+			change:  func(c *Config) { c.Backends.RacingPolicy.MaxBackoff = c.Backends.RacingPolicy.InitialBackoff / 2 },
+			wantErr: "racing max backoff 50ms is shorter than the initial backoff 100ms",
+		},
+		{
+			name:    "no push buffer",
+			service: ServiceAPI,
+			change:  func(c *Config) { c.Push.Buffer = 0 },
+			wantErr: "push buffer must be positive",
+		},
+		{
+			name:    "no next races",
+			service: ServiceAPI,
+			change:  func(c *Config) { c.Home.NextRaces = 0 },
+			wantErr: "home next races must be positive",
+		},
+		{
+			name:    "featured sport without id",
+			service: ServiceAPI,
+			change:  func(c *Config) { c.Home.FeaturedSports = []int64{9, 0} },
+			wantErr: "home featured sport id must be positive, got 0",
+		},
+		{
+			name:    "unknown log level",
+			service: ServiceRacing,
+			change:  func(c *Config) { c.Log.Level = "loud" },
+			wantErr: `not a valid logrus Level: "loud"`,
+		},
+		{
+			name:    "TLS certificate without key",
+			service: ServiceRacing,
+			change:  func(c *Config) { c.TLS.CertFile = "server.pem" },
+			wantErr: "both the TLS certificate and key files are required",
+		},
+		{
+			name:    "client authentication without CA",
+			service: ServiceRacing,
+			change:  func(c *Config) { c.TLS.ClientAuth = true },
+			wantErr: "a TLS CA file is required to verify client certificates",
+		},
+		{
+			name:    "backend TLS key without certificate",
+			service: ServiceAPI,
+			change:  func(c *Config) { c.Backends.TLS.KeyFile = "client-key.pem" },
+			wantErr: "both the backend TLS certificate and key files are required",
+		},
+		{
+			name:    "no shutdown timeout",
+			service: ServiceSports,
+			change:  func(c *Config) { c.Timeouts.Shutdown = 0 },
+			wantErr: "shutdown timeout must be positive",
+		},
+		{
+			name:    "unknown trace exporter",
+			service: ServiceRacing,
+			change:  func(c *Config) { c.Tracing.Exporter = "jaeger" },
+			wantErr: `unknown trace exporter "jaeger"`,
+		},
+		{
+			name:    "file trace exporter without file",
+			service: ServiceRacing,
+			change:  func(c *Config) { c.Tracing.Exporter, c.Tracing.File = tracing.ExporterFile, "" },
+			wantErr: "trace file is required for the file exporter",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := Default(tc.service)
+			if tc.change != nil {
+				tc.change(&cfg)
+			}
+
+			checkError(t, cfg.Validate(), tc.wantErr)
+		})
+	}
+}
+
+func TestValidateBackendServerName(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		racing     string
+		serverName string
+		// wantErr is part of the error expected, none when empty.
+		wantErr string
+	}{
+		{name: "single address", racing: "racing.internal:9000"},
+		{name: "DNS name", racing: "dns:///racing.internal:9000"},
+		{
+			name:    "list of replicas",
+			racing:  "10.0.0.1:9000,10.0.0.2:9000",
+			wantErr: `a backend TLS server name is required to verify the racing replicas of "10.0.0.1:9000,10.0.0.2:9000"`,
+		},
+		{
+			name:    "file of replicas",
+			racing:  "file:///etc/entain/racing.txt",
+			wantErr: `a backend TLS server name is required to verify the racing replicas of "file:///etc/entain/racing.txt"`,
+		},
+		{name: "list of replicas with a server name", racing: "10.0.0.1:9000,10.0.0.2:9000", serverName: "racing.internal"},
+		{name: "file of replicas with a server name", racing: "file:///etc/entain/racing.txt", serverName: "racing.internal"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := Default(ServiceAPI)
+			cfg.Backends.Racing = tc.racing
+			cfg.Backends.TLS.Enabled = true
+			cfg.Backends.TLS.CAFile = "ca.pem"
+			cfg.Backends.TLS.ServerName = tc.serverName
+
+			checkError(t, cfg.Validate(), tc.wantErr)
+
+			// Without TLS there is no certificate to verify.
+			cfg.Backends.TLS.Enabled = false
+			checkError(t, cfg.Validate(), "")
+		})
+	}
+}
+
+// checkError fails unless err contains wantErr, or is nil when wantErr is empty.
+func checkError(t *testing.T, err error, wantErr string) {
+	t.Helper()
+
+	switch {
+	case wantErr == "" && err != nil:
+		t.Errorf("got error %v, want none", err)
+	case wantErr != "" && (err == nil || !strings.Contains(err.Error(), wantErr)):
+		t.Errorf("got error %v, want %q", err, wantErr)
+	}
+}
@@ -0,0 +1,211 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Load builds the configuration of a service by layering, from lowest to highest precedence:
+// the defaults, the YAML or TOML file given by -config or <SERVICE>_CONFIG, the <SERVICE>_* environment
+// variables and the command line flags. The environment variable of a flag is its upper-cased name
+// prefixed by the service, e.g. -db-dsn is RACING_DB_DSN for the racing service.
+func Load(service string, args []string) (Config, error) {
+	cfg := Default(service)
+
+	fs := flag.NewFlagSet(service, flag.ContinueOnError)
+	path := fs.String("config", "", "Path of the YAML or TOML configuration file")
+	cfg.register(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	// Remember the flags given on the command line so they can be applied again over the file and environment.
+	explicit := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = f.Value.String()
+	})
+
+	if len(*path) == 0 {
+		*path = os.Getenv(envName(service, "config"))
+	}
+
+	if len(*path) != 0 {
+		if err := cfg.loadFile(*path); err != nil {
+			return cfg, err
+		}
+	}
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		value, ok := os.LookupEnv(envName(service, f.Name))
+		if !ok || err != nil {
+			return
+		}
+
+		if setErr := fs.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid value %q for %s: %w", value, envName(service, f.Name), setErr)
+		}
+	})
+	if err != nil {
+		return cfg, err
+	}
+
+	for name, value := range explicit {
+		if err := fs.Set(name, value); err != nil {
+			return cfg, err
+		}
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// register binds the flags of the service to the configuration fields.
+func (c *Config) register(fs *flag.FlagSet) {
+	if c.Service == ServiceAPI {
+		fs.StringVar(&c.Listen, "api-endpoint", c.Listen, "API endpoint")
+		fs.StringVar(&c.Backends.Racing, "grpc-endpoint-racing", c.Backends.Racing, "gRPC racing server endpoint: an address, a comma separated list, dns:///<name:port> or file:///<path>")
+		fs.StringVar(&c.Backends.Sports, "grpc-endpoint-sports", c.Backends.Sports, "gRPC sports server endpoint: an address, a comma separated list, dns:///<name:port> or file:///<path>")
+		fs.DurationVar(&c.Backends.ReloadInterval, "backend-reload-interval", c.Backends.ReloadInterval, "Interval between checks of the file:/// endpoints for changes")
+		c.Backends.RacingPolicy.register(fs, "racing")
+		c.Backends.SportsPolicy.register(fs, "sports")
+		fs.DurationVar(&c.Health.Timeout, "health-check-timeout", c.Health.Timeout, "Timeout of the backend health checks")
+		fs.DurationVar(&c.Timeouts.ReadHeader, "read-header-timeout", c.Timeouts.ReadHeader, "Time allowed to read the headers of an HTTP request")
+		fs.DurationVar(&c.Timeouts.Idle, "idle-timeout", c.Timeouts.Idle, "Time an idle HTTP keep-alive connection is kept open")
+		fs.BoolVar(&c.Backends.TLS.Enabled, "backend-tls", c.Backends.TLS.Enabled, "Dial the gRPC servers over TLS")
+		fs.StringVar(&c.Backends.TLS.CAFile, "backend-tls-ca", c.Backends.TLS.CAFile, "PEM encoded certificate authority used to verify the gRPC servers")
+		fs.StringVar(&c.Backends.TLS.CertFile, "backend-tls-cert", c.Backends.TLS.CertFile, "PEM encoded client certificate presented to the gRPC servers")
+		fs.StringVar(&c.Backends.TLS.KeyFile, "backend-tls-key", c.Backends.TLS.KeyFile, "PEM encoded private key of the client certificate")
+		fs.StringVar(&c.Backends.TLS.ServerName, "backend-tls-server-name", c.Backends.TLS.ServerName, "Name used to verify the gRPC server certificates")
+		fs.DurationVar(&c.Push.PollInterval, "push-poll-interval", c.Push.PollInterval, "Interval between polls of the services for the change streams")
+		fs.DurationVar(&c.Push.Window, "push-window", c.Push.Window, "How far before and after now the change streams watch the advertised start times")
+		fs.DurationVar(&c.Push.Heartbeat, "push-heartbeat", c.Push.Heartbeat, "Interval between heartbeats on idle change streams")
+		fs.IntVar(&c.Push.Buffer, "push-buffer", c.Push.Buffer, "Changes queued for a change stream before it is closed as too slow")
+		fs.IntVar(&c.Home.NextRaces, "home-next-races", c.Home.NextRaces, "Most races of the next races section of the home page")
+		fs.IntVar(&c.Home.LiveEvents, "home-live-events", c.Home.LiveEvents, "Most events of the live events section of the home page")
+		fs.IntVar(&c.Home.FeaturedEvents, "home-featured-events", c.Home.FeaturedEvents, "Most events of the featured events section of the home page")
+		fs.Var((*idsValue)(&c.Home.FeaturedSports), "home-featured-sports", "Comma separated ids of the sports featured on the home page, every sport when empty")
+		fs.DurationVar(&c.Home.Window, "home-window", c.Home.Window, "How far ahead the home page looks for the next races and featured events")
+		fs.DurationVar(&c.Home.Timeout, "home-timeout", c.Home.Timeout, "Timeout of the service calls of each home page section")
+	} else {
+		fs.StringVar(&c.Listen, "grpc-endpoint", c.Listen, "gRPC server endpoint")
+		fs.StringVar(&c.Database.DSN, "db-dsn", c.Database.DSN, "Database data source name, or "+MemoryDSN+" for an in-memory repository")
+		fs.BoolVar(&c.Database.AutoMigrate, "auto-migrate", c.Database.AutoMigrate, "Apply the pending schema migrations on startup")
+		fs.BoolVar(&c.Seed.Enabled, "seed", c.Seed.Enabled, "Insert dummy data into the database on startup")
+		fs.Int64Var(&c.Seed.RandomSeed, "seed-random", c.Seed.RandomSeed, "Random seed of the dummy data, 0 picks one")
+		fs.IntVar(&c.Seed.Count, "seed-count", c.Seed.Count, "Number of dummy rows generated")
+		fs.StringVar(&c.Seed.Anchor, "seed-anchor", c.Seed.Anchor, "RFC3339 time the dummy data is scheduled around, now when empty")
+		fs.BoolVar(&c.Seed.Reset, "seed-reset", c.Seed.Reset, "Delete the existing rows before seeding")
+		if c.Service == ServiceRacing {
+			fs.IntVar(&c.Seed.Meetings, "seed-meetings", c.Seed.Meetings, "Number of race meetings the dummy races are spread over")
+			fs.StringVar(&c.Ingest.Dir, "ingest-dir", c.Ingest.Dir, "Drop directory watched for JSON and XML feed files, none when empty")
+			fs.DurationVar(&c.Ingest.Interval, "ingest-interval", c.Ingest.Interval, "Interval between scans of the feed drop directory")
+		}
+		fs.DurationVar(&c.Health.Interval, "health-check-interval", c.Health.Interval, "Interval between database health checks")
+		fs.DurationVar(&c.Timeouts.Request, "request-timeout", c.Timeouts.Request, "Longest time a gRPC request may run, shorter caller deadlines are kept")
+		if c.Service == ServiceSports {
+			fs.DurationVar(&c.Timeouts.Bulk, "bulk-request-timeout", c.Timeouts.Bulk, "Longest time a fixture import or export may run, shorter caller deadlines are kept")
+		}
+	}
+
+	fs.StringVar(&c.Log.Level, "log-level", c.Log.Level, "Log level: debug, info, warn or error")
+	fs.StringVar(&c.Log.Format, "log-format", c.Log.Format, "Log format: text or json")
+	fs.StringVar(&c.TLS.CertFile, "tls-cert", c.TLS.CertFile, "PEM encoded server certificate")
+	fs.StringVar(&c.TLS.KeyFile, "tls-key", c.TLS.KeyFile, "PEM encoded server private key")
+	fs.StringVar(&c.TLS.CAFile, "tls-ca", c.TLS.CAFile, "PEM encoded certificate authority used to verify peers")
+	fs.BoolVar(&c.TLS.ClientAuth, "tls-client-auth", c.TLS.ClientAuth, "Require and verify client certificates")
+	fs.DurationVar(&c.TLS.ReloadInterval, "tls-reload-interval", c.TLS.ReloadInterval, "Interval between checks of the TLS files for changes")
+	fs.DurationVar(&c.Timeouts.Shutdown, "shutdown-timeout", c.Timeouts.Shutdown, "Time allowed for in-flight requests to complete on shutdown")
+	fs.StringVar(&c.Tracing.Exporter, "trace-exporter", c.Tracing.Exporter, "Trace exporter: none, or OTLP/JSON lines to stdout or file")
+	fs.StringVar(&c.Tracing.File, "trace-file", c.Tracing.File, "File the OTLP/JSON spans are appended to when using the file trace exporter")
+	fs.BoolVar(&c.Dev.SimulatedTime, "dev-simulated-time", c.Dev.SimulatedTime, "Development only: answer requests as of their X-Simulated-Time header")
+}
+
+// loadFile decodes the YAML or TOML file over the configuration, based on the file extension.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(c)
+	case ".toml":
+		var md toml.MetaData
+		md, err = toml.Decode(string(data), c)
+		if err == nil && len(md.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown keys: %v", md.Undecoded())
+		}
+	default:
+		return fmt.Errorf("unsupported config file extension %q: use .yaml, .yml or .toml", ext)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed loading config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// envName returns the environment variable of a flag, e.g. RACING_DB_DSN for -db-dsn.
+func envName(service, flagName string) string {
+	return strings.ToUpper(service + "_" + strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// register binds the flags of the policy of the named backend, prefixed by its name.
+func (p *BackendPolicy) register(fs *flag.FlagSet, backend string) {
+	fs.StringVar(&p.Balancer, backend+"-balancer", p.Balancer, "Balancing of the calls across the "+backend+" replicas: "+BalancerRoundRobin+" or "+BalancerLeastRequest)
+	fs.DurationVar(&p.Timeout, backend+"-timeout", p.Timeout, "Timeout of the calls to the "+backend+" service, retries included")
+	fs.IntVar(&p.MaxAttempts, backend+"-max-attempts", p.MaxAttempts, "Most attempts of the read-only calls to the "+backend+" service failing with UNAVAILABLE, 1 to 5")
+	fs.DurationVar(&p.InitialBackoff, backend+"-initial-backoff", p.InitialBackoff, "Longest wait before the first retry of a call to the "+backend+" service")
+	fs.DurationVar(&p.MaxBackoff, backend+"-max-backoff", p.MaxBackoff, "Longest wait before a retry of a call to the "+backend+" service")
+	fs.IntVar(&p.BreakerFailures, backend+"-breaker-failures", p.BreakerFailures, "Consecutive failed calls opening the circuit breaker of the "+backend+" service")
+	fs.DurationVar(&p.BreakerOpenTimeout, backend+"-breaker-open-timeout", p.BreakerOpenTimeout, "Time the circuit breaker of the "+backend+" service stays open before a probe call")
+}
+
+// idsValue is a flag of comma separated ids. Setting it replaces the ids, so that it can be set again over the file.
+type idsValue []int64
+
+func (v *idsValue) String() string {
+	if v == nil {
+		return ""
+	}
+
+	ids := make([]string, 0, len(*v))
+	for _, id := range *v {
+		ids = append(ids, strconv.FormatInt(id, 10))
+	}
+
+	return strings.Join(ids, ",")
+}
+
+func (v *idsValue) Set(value string) error {
+	var ids []int64
+	for _, field := range strings.Split(value, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+
+		id, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid id %q", field)
+		}
+
+		ids = append(ids, id)
+	}
+
+	*v = ids
+
+	return nil
+}
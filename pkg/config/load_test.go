@@ -0,0 +1,228 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFile writes a configuration file in a temporary directory and returns its path.
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	yamlFile := writeFile(t, "racing.yaml", "database:\n  dsn: file.db\nseed:\n  count: 20\nlog:\n  level: warn\n")
+	tomlFile := writeFile(t, "racing.toml", "[database]\ndsn = \"file.db\"\n\n[seed]\ncount = 20\n\n[log]\nlevel = \"warn\"\n")
+
+	for _, tc := range []struct {
+		name string
+		env  map[string]string
+		args []string
+		// wantDSN, wantCount and wantLevel are the settings of the database, seed count and log level.
+		wantDSN   string
+		wantCount int
+		wantLevel string
+	}{
+		{
+			name:      "defaults",
+			wantDSN:   "./db/racing.db",
+			wantCount: 100,
+			wantLevel: "info",
+		},
+		{
+			name:      "YAML file over the defaults",
+			args:      []string{"-config", yamlFile},
+			wantDSN:   "file.db",
+			wantCount: 20,
+			wantLevel: "warn",
+		},
+		{
+			name:      "TOML file over the defaults",
+			args:      []string{"-config", tomlFile},
+			wantDSN:   "file.db",
+			wantCount: 20,
+			wantLevel: "warn",
+		},
+		{
+			name:      "file of the environment",
+			env:       map[string]string{"RACING_CONFIG": yamlFile},
+			wantDSN:   "file.db",
+			wantCount: 20,
+			wantLevel: "warn",
+		},
+		{
+			name:      "environment over the file",
+			env:       map[string]string{"RACING_DB_DSN": "env.db", "RACING_LOG_LEVEL": "error"},
+			args:      []string{"-config", yamlFile},
+			wantDSN:   "env.db",
+			wantCount: 20,
+			wantLevel: "error",
+		},
+		{
+			name:      "flags over the environment and the file",
+			env:       map[string]string{"RACING_DB_DSN": "env.db", "RACING_SEED_COUNT": "30"},
+			args:      []string{"-db-dsn", "flag.db", "-config", yamlFile},
+			wantDSN:   "flag.db",
+			wantCount: 30,
+			wantLevel: "warn",
+		},
+		{
+			name:      "flag set to the default over the environment",
+			env:       map[string]string{"RACING_CONFIG": yamlFile, "RACING_LOG_LEVEL": "error"},
+			args:      []string{"-log-level", "info"},
+			wantDSN:   "file.db",
+			wantCount: 20,
+			wantLevel: "info",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for name, value := range tc.env {
+				t.Setenv(name, value)
+			}
+
+			cfg, err := Load(ServiceRacing, tc.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if cfg.Database.DSN != tc.wantDSN || cfg.Seed.Count != tc.wantCount || cfg.Log.Level != tc.wantLevel {
+				t.Errorf("got DSN %q, seed count %d and log level %q, want %q, %d and %q",
+					cfg.Database.DSN, cfg.Seed.Count, cfg.Log.Level, tc.wantDSN, tc.wantCount, tc.wantLevel)
+			}
+		})
+	}
+}
+
+func TestLoadFeaturedSports(t *testing.T) {
+	file := writeFile(t, "api.yaml", "home:\n  featured_sports: [1, 2]\n  window: 12h\n")
+
+	for _, tc := range []struct {
+		name string
+		env  map[string]string
+		args []string
+		want []int64
+	}{
+		{name: "file", args: []string{"-config", file}, want: []int64{1, 2}},
+		{name: "environment replaces the file", env: map[string]string{"API_HOME_FEATURED_SPORTS": "4, 5"}, args: []string{"-config", file}, want: []int64{4, 5}},
+		{name: "flag replaces the environment", env: map[string]string{"API_HOME_FEATURED_SPORTS": "4,5"}, args: []string{"-config", file, "-home-featured-sports", "3"}, want: []int64{3}},
+		{name: "empty flag features every sport", args: []string{"-config", file, "-home-featured-sports", ""}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for name, value := range tc.env {
+				t.Setenv(name, value)
+			}
+
+			cfg, err := Load(ServiceAPI, tc.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.Equal(cfg.Home.FeaturedSports, tc.want) {
+				t.Errorf("got featured sports %v, want %v", cfg.Home.FeaturedSports, tc.want)
+			}
+			if cfg.Home.Window != 12*time.Hour {
+				t.Errorf("got home window %s, want the 12h of the file", cfg.Home.Window)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		file    string
+		content string
+		env     map[string]string
+		args    []string
+		wantErr string
+	}{
+		{
+			name:    "unknown YAML key",
+			file:    "racing.yaml",
+			content: "databse:\n  dsn: file.db\n",
+			wantErr: "field databse not found",
+		},
+		{
+			name:    "unknown nested YAML key",
+			file:    "racing.yaml",
+			content: "database:\n  dns: file.db\n",
+			wantErr: "field dns not found",
+		},
+		{
+			name:    "unknown TOML key",
+			file:    "racing.toml",
+			content: "[database]\ndns = \"file.db\"\n",
+			wantErr: "unknown keys: [database.dns]",
+		},
+		{
+			name:    "unsupported file extension",
+			file:    "racing.json",
+			content: "{}",
+			wantErr: `unsupported config file extension ".json"`,
+		},
+		{
+			name:    "invalid YAML value",
+			file:    "racing.yaml",
+			content: "seed:\n  count: many\n",
+			wantErr: "failed loading config file",
+		},
+		{
+			name:    "invalid TOML value",
+			file:    "racing.toml",
+			content: "[health]\ninterval = \"soon\"\n",
+			wantErr: "failed loading config file",
+		},
+		{
+			name:    "missing file",
+			args:    []string{"-config", filepath.Join(os.TempDir(), "missing", "racing.yaml")},
+			wantErr: "no such file",
+		},
+		{
+			name:    "invalid environment value",
+			env:     map[string]string{"RACING_SEED_COUNT": "many"},
+			wantErr: `invalid value "many" for RACING_SEED_COUNT`,
+		},
+		{
+			name:    "invalid flag value",
+			args:    []string{"-seed-count", "many"},
+			wantErr: `invalid value "many" for flag -seed-count`,
+		},
+		{
+			name:    "unknown flag",
+			args:    []string{"-grpc-endpoint-racing", "localhost:9000"},
+			wantErr: "flag provided but not defined",
+		},
+		{
+			name:    "invalid setting",
+			env:     map[string]string{"RACING_LOG_FORMAT": "xml"},
+			wantErr: `invalid log format "xml"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for name, value := range tc.env {
+				t.Setenv(name, value)
+			}
+
+			args := tc.args
+			if tc.file != "" {
+				args = append([]string{"-config", writeFile(t, tc.file, tc.content)}, args...)
+			}
+
+			_, err := Load(ServiceRacing, args)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("got error %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
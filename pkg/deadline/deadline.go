@@ -0,0 +1,34 @@
+package deadline
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor bounds every RPC by the timeout, or by the timeout of its full method name in methods when
+// it has one, keeping the deadline of the caller when it is sooner. When the context of the RPC expires or is
+// cancelled the handler error is replaced by DeadlineExceeded or Canceled, so that callers can tell timeouts apart
+// from failures.
+func UnaryServerInterceptor(timeout time.Duration, methods map[string]time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		methodTimeout, ok := methods[info.FullMethod]
+		if !ok {
+			methodTimeout = timeout
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, methodTimeout)
+		defer cancel()
+
+		resp, err := handler(ctx, req)
+		if err != nil && ctx.Err() != nil {
+			log.Warnf("%s stopped: %s", info.FullMethod, ctx.Err())
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+
+		return resp, err
+	}
+}
@@ -0,0 +1,103 @@
+package dialect
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Dialect holds the differences between the SQL databases supported by the repositories.
+// Queries are written with ? placeholders and portable SQL, then rebound for the database.
+type Dialect interface {
+	// Name is the database system reported in traces, e.g. sqlite or postgresql.
+	Name() string
+	// Driver is the database/sql driver name.
+	Driver() string
+	// Rebind replaces the ? placeholders of the query with the placeholders of the database.
+	Rebind(query string) string
+	// Time converts a time into a query argument comparable with the stored timestamps.
+	Time(t time.Time) interface{}
+	// ResyncID returns the statement moving the id generator of the table past the ids inserted explicitly,
+	// or an empty string when the database generates ids from the highest one anyway.
+	ResyncID(table string) string
+}
+
+var (
+	// SQLite stores timestamps as RFC3339 text in UTC so they compare and sort as strings.
+	SQLite Dialect = sqlite{}
+	// Postgres stores timestamps as TIMESTAMPTZ.
+	Postgres Dialect = postgres{}
+)
+
+// Parse returns the dialect of a DSN and the data source passed to its driver.
+// postgres:// and postgresql:// URLs select PostgreSQL, anything else is an SQLite file,
+// optionally prefixed by sqlite://.
+func Parse(dsn string) (Dialect, string) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return Postgres, dsn
+	}
+
+	return SQLite, strings.TrimPrefix(dsn, "sqlite://")
+}
+
+// Open opens the database of a DSN and returns its dialect.
+func Open(dsn string) (*sql.DB, Dialect, error) {
+	d, dataSource := Parse(dsn)
+
+	db, err := sql.Open(d.Driver(), dataSource)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return db, d, nil
+}
+
+type sqlite struct{}
+
+func (sqlite) Name() string { return "sqlite" }
+
+func (sqlite) Driver() string { return "sqlite3" }
+
+func (sqlite) Rebind(query string) string { return query }
+
+func (sqlite) Time(t time.Time) interface{} { return t.UTC().Format(time.RFC3339) }
+
+// ResyncID returns nothing to run, as an INTEGER PRIMARY KEY inserted without a value takes the highest one plus one.
+func (sqlite) ResyncID(string) string { return "" }
+
+type postgres struct{}
+
+func (postgres) Name() string { return "postgresql" }
+
+func (postgres) Driver() string { return "postgres" }
+
+// Rebind numbers the placeholders as $1, $2 and so on.
+func (postgres) Rebind(query string) string {
+	var (
+		b strings.Builder
+		n int
+	)
+
+	for _, r := range query {
+		if r != '?' {
+			b.WriteRune(r)
+			continue
+		}
+
+		n++
+		b.WriteString("$" + strconv.Itoa(n))
+	}
+
+	return b.String()
+}
+
+func (postgres) Time(t time.Time) interface{} { return t }
+
+// ResyncID restarts the identity sequence of the id column after the highest id of the table.
+func (postgres) ResyncID(table string) string {
+	return `SELECT setval(pg_get_serial_sequence('` + table + `', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM ` + table
+}
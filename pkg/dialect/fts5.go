@@ -0,0 +1,6 @@
+//go:build sqlite_fts5 || fts5
+
+package dialect
+
+// FTS5 reports whether the SQLite driver was built with the FTS5 full-text search of the search indexes.
+const FTS5 = true
@@ -0,0 +1,103 @@
+// Package endpoint resolves the addresses of the replicas of a gRPC service, for the client connection to balance
+// its calls across them. An endpoint is one of:
+//
+//   - host:port, a single address,
+//   - host1:port,host2:port, a static list of addresses,
+//   - dns:///host:port, the addresses of a DNS name, resolved again when connections fail,
+//   - file:///path, the addresses listed in a file, one host:port per line, read again when the file changes.
+//
+// Blank lines and lines starting with # are ignored in the files. A file that cannot be read or lists no valid
+// address keeps the addresses read before, so that a file being rewritten does not drop the replicas.
+package endpoint
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+)
+
+const (
+	// FileScheme is the scheme of the endpoints listed in a file.
+	FileScheme = "file"
+	// DNSScheme is the scheme of the endpoints resolved by DNS.
+	DNSScheme = "dns"
+
+	// staticScheme is the scheme of the static lists of addresses.
+	staticScheme = "static"
+)
+
+// Resolve returns the dial target and options of an endpoint. The name identifies the service in the logs, and the
+// files are checked for changes every reload interval.
+func Resolve(name, endpoint string, reload time.Duration) (string, []grpc.DialOption, error) {
+	switch {
+	case strings.HasPrefix(endpoint, DNSScheme+":"):
+		return endpoint, nil, nil
+	case strings.HasPrefix(endpoint, FileScheme+"://"):
+		path := strings.TrimPrefix(endpoint, FileScheme+"://")
+		if path == "" {
+			return "", nil, fmt.Errorf("missing file path of %s endpoint %q", name, endpoint)
+		}
+
+		b := &fileBuilder{name: name, path: path, interval: reload}
+
+		return FileScheme + ":///", []grpc.DialOption{grpc.WithResolvers(b)}, nil
+	case strings.Contains(endpoint, ","):
+		addresses, err := parseAddresses(strings.Split(endpoint, ","))
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid %s endpoint: %w", name, err)
+		}
+
+		r := manual.NewBuilderWithScheme(staticScheme)
+		r.InitialState(state(addresses))
+
+		return staticScheme + ":///", []grpc.DialOption{grpc.WithResolvers(r)}, nil
+	}
+
+	return endpoint, nil, nil
+}
+
+// NamesServer reports whether the dial target of an endpoint carries the host name of the server, which TLS verifies
+// the certificate against. The lists and files of addresses dial a target without host, shared by the replicas.
+func NamesServer(endpoint string) bool {
+	return strings.HasPrefix(endpoint, DNSScheme+":") ||
+		!strings.HasPrefix(endpoint, FileScheme+"://") && !strings.Contains(endpoint, ",")
+}
+
+// parseAddresses returns the host:port addresses of the lines, skipping the blank and comment lines.
+func parseAddresses(lines []string) ([]string, error) {
+	var addresses []string
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if _, _, err := net.SplitHostPort(line); err != nil {
+			return nil, fmt.Errorf("invalid address %q: %w", line, err)
+		}
+
+		addresses = append(addresses, line)
+	}
+
+	if len(addresses) == 0 {
+		return nil, errors.New("no address")
+	}
+
+	return addresses, nil
+}
+
+// state returns the resolver state of the addresses.
+func state(addresses []string) resolver.State {
+	s := resolver.State{}
+	for _, address := range addresses {
+		s.Addresses = append(s.Addresses, resolver.Address{Addr: address})
+	}
+
+	return s
+}
@@ -0,0 +1,67 @@
+package endpoint
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestResolve(t *testing.T) {
+	for _, tc := range []struct {
+		endpoint string
+		target   string
+		options  int
+		invalid  bool
+	}{
+		{endpoint: "localhost:9000", target: "localhost:9000"},
+		{endpoint: "dns:///racing:9000", target: "dns:///racing:9000"},
+		{endpoint: "dns:racing:9000", target: "dns:racing:9000"},
+		{endpoint: "racing-1:9000,racing-2:9000", target: "static:///", options: 1},
+		{endpoint: "racing-1:9000, racing-2:9000,", target: "static:///", options: 1},
+		{endpoint: "file:///etc/racing/backends", target: "file:///", options: 1},
+		{endpoint: "file://", invalid: true},
+		{endpoint: "racing-1:9000,racing-2", invalid: true},
+		{endpoint: ",", invalid: true},
+	} {
+		t.Run(tc.endpoint, func(t *testing.T) {
+			target, options, err := Resolve("racing", tc.endpoint, time.Second)
+			if tc.invalid {
+				if err == nil {
+					t.Fatalf("Resolve() = %q, want an error", target)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Resolve() failed: %v", err)
+			}
+
+			if target != tc.target || len(options) != tc.options {
+				t.Errorf("Resolve() = %q with %d options, want %q with %d", target, len(options), tc.target, tc.options)
+			}
+		})
+	}
+}
+
+func TestParseAddresses(t *testing.T) {
+	addresses, err := parseAddresses([]string{
+		"# racing replicas",
+		"racing-1:9000",
+		"",
+		"  racing-2:9000  ",
+		"[::1]:9000",
+	})
+	if err != nil {
+		t.Fatalf("parseAddresses() failed: %v", err)
+	}
+
+	if want := []string{"racing-1:9000", "racing-2:9000", "[::1]:9000"}; !slices.Equal(addresses, want) {
+		t.Errorf("parseAddresses() = %q, want %q", addresses, want)
+	}
+
+	for _, lines := range [][]string{nil, {"", "# none"}, {"racing-1:9000", "racing-2"}} {
+		if addresses, err := parseAddresses(lines); err == nil {
+			t.Errorf("parseAddresses(%q) = %q, want an error", lines, addresses)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package endpoint
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/resolver"
+)
+
+// fileBuilder builds the resolvers of the addresses listed in a file.
+type fileBuilder struct {
+	name     string
+	path     string
+	interval time.Duration
+}
+
+func (b *fileBuilder) Scheme() string {
+	return FileScheme
+}
+
+// Build reads the addresses of the file, failing the dial when there are none, and watches the file for changes.
+func (b *fileBuilder) Build(_ resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	addresses, err := readAddresses(b.path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &fileResolver{
+		fileBuilder: b,
+		cc:          cc,
+		addresses:   addresses,
+		reload:      make(chan struct{}, 1),
+		done:        make(chan struct{}),
+	}
+
+	if err := cc.UpdateState(state(addresses)); err != nil {
+		log.Warnf("%s backends rejected: %s", b.name, err)
+	}
+	log.Infof("%s backends from %s: %s", b.name, b.path, strings.Join(addresses, ", "))
+
+	go r.watch()
+
+	return r, nil
+}
+
+// fileResolver updates the addresses of a client connection when its file changes.
+type fileResolver struct {
+	*fileBuilder
+	cc resolver.ClientConn
+
+	// addresses are the addresses last read, only used by the watching goroutine once built.
+	addresses []string
+	failing   bool
+
+	reload    chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// ResolveNow reads the file again, as the connection lost a replica.
+func (r *fileResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.reload <- struct{}{}:
+	default:
+	}
+}
+
+func (r *fileResolver) Close() {
+	r.closeOnce.Do(func() { close(r.done) })
+}
+
+// watch reads the file every interval, or when asked to, until the resolver is closed.
+func (r *fileResolver) watch() {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+		case <-r.reload:
+		}
+
+		addresses, err := readAddresses(r.path)
+		if err != nil {
+			if !r.failing {
+				log.Warnf("failed reading %s backends, keeping %s: %s", r.name, strings.Join(r.addresses, ", "), err)
+			}
+			r.failing = true
+			continue
+		}
+		r.failing = false
+
+		if slices.Equal(addresses, r.addresses) {
+			continue
+		}
+
+		log.Infof("%s backends changed: %s", r.name, strings.Join(addresses, ", "))
+		r.addresses = addresses
+
+		if err := r.cc.UpdateState(state(addresses)); err != nil {
+			log.Warnf("%s backends rejected: %s", r.name, err)
+		}
+	}
+}
+
+// readAddresses returns the addresses listed in a file.
+func readAddresses(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses, err := parseAddresses(strings.Split(string(data), "\n"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return addresses, nil
+}
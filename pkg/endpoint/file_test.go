@@ -0,0 +1,135 @@
+package endpoint
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/resolver"
+)
+
+const interval = 10 * time.Millisecond
+
+// fakeClientConn records the states the resolver updates.
+type fakeClientConn struct {
+	resolver.ClientConn
+	states chan []string
+}
+
+func (cc *fakeClientConn) UpdateState(s resolver.State) error {
+	var addresses []string
+	for _, address := range s.Addresses {
+		addresses = append(addresses, address.Addr)
+	}
+	cc.states <- addresses
+
+	return nil
+}
+
+func (cc *fakeClientConn) wantState(t *testing.T, want ...string) {
+	t.Helper()
+
+	select {
+	case got := <-cc.states:
+		if !slices.Equal(got, want) {
+			t.Fatalf("addresses = %q, want %q", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("addresses not updated, want %q", want)
+	}
+}
+
+// wantNoState checks that the addresses are not updated over several reloads.
+func (cc *fakeClientConn) wantNoState(t *testing.T) {
+	t.Helper()
+
+	select {
+	case got := <-cc.states:
+		t.Fatalf("addresses updated to %q", got)
+	case <-time.After(10 * interval):
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func build(t *testing.T, path string) (*fakeClientConn, error) {
+	t.Helper()
+
+	cc := &fakeClientConn{states: make(chan []string, 10)}
+	b := &fileBuilder{name: "racing", path: path, interval: interval}
+
+	r, err := b.Build(resolver.Target{}, cc, resolver.BuildOptions{})
+	if err == nil {
+		t.Cleanup(r.Close)
+	}
+
+	return cc, err
+}
+
+func TestFileResolverReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backends")
+	writeFile(t, path, "# racing replicas\nracing-1:9000\nracing-2:9000\n")
+
+	cc, err := build(t, path)
+	if err != nil {
+		t.Fatalf("Build() failed: %v", err)
+	}
+	cc.wantState(t, "racing-1:9000", "racing-2:9000")
+
+	// An unchanged file does not update the addresses.
+	cc.wantNoState(t)
+
+	writeFile(t, path, "racing-2:9000\nracing-3:9000\n")
+	cc.wantState(t, "racing-2:9000", "racing-3:9000")
+}
+
+func TestFileResolverKeepsAddressesOfInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backends")
+	writeFile(t, path, "racing-1:9000\n")
+
+	cc, err := build(t, path)
+	if err != nil {
+		t.Fatalf("Build() failed: %v", err)
+	}
+	cc.wantState(t, "racing-1:9000")
+
+	for _, invalid := range []string{"", "# being rewritten\n", "racing-1:9000\nracing-2\n"} {
+		writeFile(t, path, invalid)
+		cc.wantNoState(t)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	cc.wantNoState(t)
+
+	// The addresses kept are those read before the file became invalid.
+	writeFile(t, path, "racing-1:9000\n")
+	cc.wantNoState(t)
+
+	writeFile(t, path, "racing-2:9000\n")
+	cc.wantState(t, "racing-2:9000")
+}
+
+func TestFileResolverBuildFailsWithoutAddresses(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := build(t, filepath.Join(dir, "missing")); err == nil {
+		t.Error("Build() of a missing file succeeded")
+	}
+
+	path := filepath.Join(dir, "empty")
+	writeFile(t, path, "# no replicas\n")
+
+	if _, err := build(t, path); err == nil {
+		t.Error("Build() of a file without address succeeded")
+	}
+}
@@ -0,0 +1,67 @@
+package fieldmask
+
+import (
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// Paths checks the paths of the mask against the top-level fields of msg and returns them as proto field names.
+// Paths may also be given by their JSON names, e.g. advertisedStartTime. A nil or empty mask selects every field
+// and returns nil paths. Unknown fields fail with InvalidArgument.
+func Paths(mask *fieldmaskpb.FieldMask, msg proto.Message) ([]string, error) {
+	if len(mask.GetPaths()) == 0 {
+		return nil, nil
+	}
+
+	fields := msg.ProtoReflect().Descriptor().Fields()
+
+	paths := make([]string, 0, len(mask.GetPaths()))
+	for _, path := range mask.GetPaths() {
+		field := fields.ByName(protoreflect.Name(path))
+		if field == nil {
+			field = fields.ByJSONName(path)
+		}
+
+		if field == nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid read mask: unknown field %s", path)
+		}
+
+		paths = append(paths, string(field.Name()))
+	}
+
+	return paths, nil
+}
+
+// Selects reports whether the paths select the named field. Nil paths select every field.
+func Selects(paths []string, name string) bool {
+	if paths == nil {
+		return true
+	}
+
+	for _, path := range paths {
+		if path == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Prune clears the fields of msg which the paths do not select.
+func Prune(msg proto.Message, paths []string) {
+	if paths == nil {
+		return
+	}
+
+	m := msg.ProtoReflect()
+	m.Range(func(field protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		if !Selects(paths, string(field.Name())) {
+			m.Clear(field)
+		}
+
+		return true
+	})
+}
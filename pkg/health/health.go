@@ -0,0 +1,91 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Pinger checks the connection to a dependency, such as a *sql.DB.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Server implements grpc.health.v1.Health for a set of gRPC services.
+// All services report NOT_SERVING until SetServing is called.
+type Server struct {
+	*health.Server
+
+	services []string
+
+	mu      sync.Mutex
+	serving bool
+}
+
+// NewServer creates a new health server for the given service names.
+func NewServer(services ...string) *Server {
+	s := &Server{
+		Server:   health.NewServer(),
+		services: append([]string{""}, services...),
+	}
+
+	for _, service := range s.services {
+		s.Server.SetServingStatus(service, grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	}
+
+	return s
+}
+
+// SetServing updates the status of all services, logging any change.
+func (s *Server) SetServing(serving bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := grpc_health_v1.HealthCheckResponse_NOT_SERVING
+	if serving {
+		status = grpc_health_v1.HealthCheckResponse_SERVING
+	}
+
+	if serving != s.serving {
+		log.Infof("health status changed to %s", status)
+	}
+	s.serving = serving
+
+	for _, service := range s.services {
+		s.Server.SetServingStatus(service, status)
+	}
+}
+
+// Monitor pings the dependency every interval and reports NOT_SERVING while the ping fails.
+// It blocks until the context is cancelled.
+func (s *Server) Monitor(ctx context.Context, pinger Pinger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.check(ctx, pinger, interval)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check runs a single ping bounded by the given timeout.
+func (s *Server) check(ctx context.Context, pinger Pinger, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := pinger.PingContext(ctx)
+	if err != nil {
+		log.Errorf("health check ping failed: %s", err)
+	}
+
+	s.SetServing(err == nil)
+}
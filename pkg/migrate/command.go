@@ -0,0 +1,70 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Usage describes the arguments accepted by Command.
+const Usage = "migrate <up|down [steps]|status> [flags]"
+
+// ParseArgs splits the arguments of the migrate subcommand into the action, its steps and the remaining flags.
+func ParseArgs(args []string) (action string, steps int, flags []string, err error) {
+	if len(args) == 0 {
+		return "", 0, nil, fmt.Errorf("missing migrate action, usage: %s", Usage)
+	}
+
+	action, flags, steps = args[0], args[1:], 1
+
+	switch action {
+	case "up", "status":
+	case "down":
+		if len(flags) > 0 {
+			if n, err := strconv.Atoi(flags[0]); err == nil {
+				if n < 1 {
+					return "", 0, nil, fmt.Errorf("invalid number of steps: %d", n)
+				}
+				steps, flags = n, flags[1:]
+			}
+		}
+	default:
+		return "", 0, nil, fmt.Errorf("unknown migrate action %q, usage: %s", action, Usage)
+	}
+
+	return action, steps, flags, nil
+}
+
+// Command runs a migrate action parsed by ParseArgs.
+func Command(ctx context.Context, m *Migrator, action string, steps int) error {
+	switch action {
+	case "up":
+		applied, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+
+		log.Infof("applied %d migrations", applied)
+	case "down":
+		reverted, err := m.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+
+		log.Infof("reverted %d migrations", reverted)
+	case "status":
+	default:
+		return fmt.Errorf("unknown migrate action %q, usage: %s", action, Usage)
+	}
+
+	version, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("database schema is at version %d, latest migration is %d", version, m.Latest())
+
+	return nil
+}
@@ -0,0 +1,264 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"git.neds.sh/matty/entain/pkg/dialect"
+)
+
+// ErrOutdated is returned by Check when the database schema is behind the migrations.
+var ErrOutdated = errors.New("database schema is outdated")
+
+// fileName matches migration files such as 0001_create_races.up.sql.
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a numbered schema change with the SQL to apply and revert it.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Migrator applies migrations to a database, tracking them in the schema_migrations table.
+type Migrator struct {
+	db         *sql.DB
+	dialect    dialect.Dialect
+	migrations []Migration
+}
+
+// New creates a migrator for the migration files found at the root of fsys.
+func New(db *sql.DB, d dialect.Dialect, fsys fs.FS) (*Migrator, error) {
+	migrations, err := load(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, dialect: d, migrations: migrations}, nil
+}
+
+// Latest returns the version of the most recent migration.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the version of the last migration applied to the database, or 0 if none was.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	if err := m.createTable(ctx); err != nil {
+		return 0, err
+	}
+
+	var version sql.NullInt64
+	if err := m.db.QueryRowContext(ctx, `SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, err
+	}
+
+	return int(version.Int64), nil
+}
+
+// Check returns ErrOutdated if migrations are pending, or an error if the database is ahead of them.
+func (m *Migrator) Check(ctx context.Context) error {
+	version, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	switch latest := m.Latest(); {
+	case version < latest:
+		return fmt.Errorf("%w: at version %d, expected %d", ErrOutdated, version, latest)
+	case version > latest:
+		return fmt.Errorf("database schema version %d is newer than the latest migration %d", version, latest)
+	}
+
+	return nil
+}
+
+// Up applies all pending migrations and returns how many were applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	version, err := m.Version(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for _, migration := range m.migrations {
+		if migration.Version <= version {
+			continue
+		}
+
+		if err := m.apply(ctx, migration.Up, `INSERT INTO schema_migrations(version, name, applied_at) VALUES (?,?,?)`,
+			migration.Version, migration.Name, m.dialect.Time(time.Now())); err != nil {
+			return applied, fmt.Errorf("failed applying migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+
+		applied++
+	}
+
+	return applied, nil
+}
+
+// Down reverts the given number of most recent migrations and returns how many were reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	version, err := m.Version(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	reverted := 0
+	for i := len(m.migrations) - 1; i >= 0 && reverted < steps; i-- {
+		migration := m.migrations[i]
+		if migration.Version > version {
+			continue
+		}
+
+		if err := m.apply(ctx, migration.Down, `DELETE FROM schema_migrations WHERE version=?`, migration.Version); err != nil {
+			return reverted, fmt.Errorf("failed reverting migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+
+		reverted++
+	}
+
+	return reverted, nil
+}
+
+// apply runs the migration script and records it in a single transaction.
+func (m *Migrator) apply(ctx context.Context, script, record string, args ...interface{}) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, m.dialect.Rebind(record), args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m *Migrator) createTable(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, name TEXT, applied_at TIMESTAMP)`)
+
+	return err
+}
+
+// load reads and orders the migration files, making sure every version has both an up and a down script.
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+
+	for _, entry := range entries {
+		matches := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || matches == nil {
+			continue
+		}
+
+		version, err := strconv.Atoi(matches[1])
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = migration
+		} else if migration.Name != matches[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names: %s and %s", version, migration.Name, matches[2])
+		}
+
+		if matches[3] == "up" {
+			migration.Up = string(data)
+		} else {
+			migration.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if len(migration.Up) == 0 || len(migration.Down) == 0 {
+			return nil, fmt.Errorf("migration %d_%s requires both an up and a down file", migration.Version, migration.Name)
+		}
+
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Override returns the migration files of fsys, with the files of overrides replacing those of the same name.
+func Override(fsys, overrides fs.FS) fs.FS {
+	return override{base: fsys, overrides: overrides}
+}
+
+// override is the file system returned by Override.
+type override struct {
+	base, overrides fs.FS
+}
+
+func (o override) Open(name string) (fs.File, error) {
+	if name != "." {
+		if file, err := o.overrides.Open(name); err == nil {
+			return file, nil
+		}
+	}
+
+	return o.base.Open(name)
+}
+
+func (o override) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := fs.ReadDir(o.base, name)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides, err := fs.ReadDir(o.overrides, name)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]fs.DirEntry, len(entries)+len(overrides))
+	for _, entry := range append(entries, overrides...) {
+		byName[entry.Name()] = entry
+	}
+
+	merged := make([]fs.DirEntry, 0, len(byName))
+	for _, entry := range byName {
+		merged = append(merged, entry)
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].Name() < merged[j].Name()
+	})
+
+	return merged, nil
+}
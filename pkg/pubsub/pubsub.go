@@ -0,0 +1,98 @@
+package pubsub
+
+import (
+	"sync"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Broker fans the messages published out to every subscriber of the same process. Publishing never blocks:
+// a subscriber whose buffer is full misses the message, or is evicted, so that a slow subscriber cannot hold up the
+// publisher or the other subscribers.
+type Broker[T any] struct {
+	name        string
+	mu          sync.RWMutex
+	subscribers map[*subscriber[T]]struct{}
+}
+
+type subscriber[T any] struct {
+	messages chan T
+	dropped  atomic.Uint64
+	// evict unsubscribes the subscriber instead of dropping a message when its buffer is full.
+	evict  bool
+	cancel func()
+}
+
+// NewBroker creates a broker without subscribers. The name identifies the broker in the logs.
+func NewBroker[T any](name string) *Broker[T] {
+	return &Broker[T]{name: name, subscribers: make(map[*subscriber[T]]struct{})}
+}
+
+// Subscribe returns a channel receiving the messages published from now on, holding up to buffer messages not yet
+// received. Calling cancel unsubscribes and closes the channel.
+func (b *Broker[T]) Subscribe(buffer int) (messages <-chan T, cancel func()) {
+	return b.subscribe(buffer, false)
+}
+
+// SubscribeOrEvict is like Subscribe, except that the subscriber is unsubscribed when its buffer is full instead of
+// missing the message. Its channel is then closed once the messages buffered are received, so that a subscriber
+// which must not miss any message can tell that it fell behind and start over.
+func (b *Broker[T]) SubscribeOrEvict(buffer int) (messages <-chan T, cancel func()) {
+	return b.subscribe(buffer, true)
+}
+
+func (b *Broker[T]) subscribe(buffer int, evict bool) (<-chan T, func()) {
+	s := &subscriber[T]{messages: make(chan T, buffer), evict: evict}
+
+	b.mu.Lock()
+	b.subscribers[s] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	s.cancel = func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, s)
+			b.mu.Unlock()
+
+			close(s.messages)
+		})
+	}
+
+	return s.messages, s.cancel
+}
+
+// Len returns the number of subscribers.
+func (b *Broker[T]) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return len(b.subscribers)
+}
+
+// Publish sends the message to every subscriber with room left in its buffer, and evicts the subscribers of
+// SubscribeOrEvict without.
+func (b *Broker[T]) Publish(message T) {
+	var evicted []*subscriber[T]
+
+	b.mu.RLock()
+	for s := range b.subscribers {
+		select {
+		case s.messages <- message:
+		default:
+			if s.evict {
+				evicted = append(evicted, s)
+				continue
+			}
+
+			log.Warnf("%s subscriber is too slow, dropped %d messages", b.name, s.dropped.Add(1))
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, s := range evicted {
+		log.Warnf("%s subscriber is too slow, evicted", b.name)
+		s.cancel()
+	}
+}
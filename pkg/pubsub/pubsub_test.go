@@ -0,0 +1,119 @@
+package pubsub
+
+import (
+	"slices"
+	"testing"
+)
+
+// receive returns the messages received from the channel until it is empty, and whether it is still open.
+func receive(messages <-chan int) (received []int, open bool) {
+	for {
+		select {
+		case m, ok := <-messages:
+			if !ok {
+				return received, false
+			}
+			received = append(received, m)
+		default:
+			return received, true
+		}
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	b := NewBroker[int]("test")
+
+	fast, cancelFast := b.Subscribe(10)
+	defer cancelFast()
+	slow, cancelSlow := b.Subscribe(1)
+	defer cancelSlow()
+
+	for m := 1; m <= 3; m++ {
+		b.Publish(m)
+	}
+
+	if got, open := receive(fast); !slices.Equal(got, []int{1, 2, 3}) || !open {
+		t.Errorf("fast subscriber got %v, open %t, want [1 2 3] and open", got, open)
+	}
+
+	// The slow subscriber misses the messages published while its buffer was full, but stays subscribed.
+	if got, open := receive(slow); !slices.Equal(got, []int{1}) || !open {
+		t.Errorf("slow subscriber got %v, open %t, want [1] and open", got, open)
+	}
+
+	b.Publish(4)
+
+	if got, _ := receive(slow); !slices.Equal(got, []int{4}) {
+		t.Errorf("slow subscriber got %v after catching up, want [4]", got)
+	}
+
+	if got := b.Len(); got != 2 {
+		t.Errorf("got %d subscribers, want 2", got)
+	}
+}
+
+func TestSubscribeOrEvict(t *testing.T) {
+	b := NewBroker[int]("test")
+
+	other, cancelOther := b.Subscribe(10)
+	defer cancelOther()
+	evicted, cancelEvicted := b.SubscribeOrEvict(2)
+
+	for m := 1; m <= 4; m++ {
+		b.Publish(m)
+	}
+
+	// The messages buffered before the eviction are received, then the channel is closed.
+	if got, open := receive(evicted); !slices.Equal(got, []int{1, 2}) || open {
+		t.Errorf("evicted subscriber got %v, open %t, want [1 2] and closed", got, open)
+	}
+
+	if got, open := receive(other); !slices.Equal(got, []int{1, 2, 3, 4}) || !open {
+		t.Errorf("other subscriber got %v, open %t, want [1 2 3 4] and open", got, open)
+	}
+
+	if got := b.Len(); got != 1 {
+		t.Errorf("got %d subscribers after the eviction, want 1", got)
+	}
+
+	// Cancelling an evicted subscriber does nothing.
+	cancelEvicted()
+
+	if got := b.Len(); got != 1 {
+		t.Errorf("got %d subscribers after cancelling the evicted one, want 1", got)
+	}
+}
+
+func TestSubscribeOrEvictKeepsUp(t *testing.T) {
+	b := NewBroker[int]("test")
+
+	messages, cancel := b.SubscribeOrEvict(1)
+	defer cancel()
+
+	for m := 1; m <= 3; m++ {
+		b.Publish(m)
+
+		if got, open := receive(messages); !slices.Equal(got, []int{m}) || !open {
+			t.Errorf("got %v, open %t, want [%d] and open", got, open, m)
+		}
+	}
+}
+
+func TestCancel(t *testing.T) {
+	b := NewBroker[int]("test")
+
+	messages, cancel := b.Subscribe(1)
+	cancel()
+	cancel()
+
+	if _, open := receive(messages); open {
+		t.Error("channel still open after cancel")
+	}
+
+	if got := b.Len(); got != 0 {
+		t.Errorf("got %d subscribers after cancel, want 0", got)
+	}
+
+	// Publishing without subscribers does nothing.
+	b.Publish(1)
+}
@@ -0,0 +1,163 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// MarkStart and MarkEnd surround the matched words of a snippet.
+	MarkStart = "<mark>"
+	MarkEnd   = "</mark>"
+
+	// HighlightStart and HighlightEnd surround the matched words highlighted by the database. They are runes of the
+	// private use area rather than the marks, so that Snippet can escape the name around them.
+	HighlightStart = "\uE000"
+	HighlightEnd   = "\uE001"
+
+	// DefaultLimit is the number of results returned when the request sets none.
+	DefaultLimit = 20
+	// MaxLimit is the most results a request may ask for.
+	MaxLimit = 100
+)
+
+// ErrNoFTS5 is returned by the searches of SQLite repositories when the binary was built without
+// -tags sqlite_fts5, as their search indexes use FTS5.
+var ErrNoFTS5 = status.Error(codes.Unimplemented, "SQLite search needs FTS5: build with -tags sqlite_fts5")
+
+// Terms splits a search query into lower case words of letters and digits, dropping any punctuation so that
+// user input never reaches the database as query syntax. It fails with InvalidArgument when no word is left.
+func Terms(query string) ([]string, error) {
+	terms := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	if len(terms) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "empty search query")
+	}
+
+	return terms, nil
+}
+
+// Limit returns the number of results to return for the requested limit, DefaultLimit when unset.
+// It fails with InvalidArgument outside of 0 to MaxLimit.
+func Limit(limit int32) (int, error) {
+	switch {
+	case limit == 0:
+		return DefaultLimit, nil
+	case limit < 0 || limit > MaxLimit:
+		return 0, status.Errorf(codes.InvalidArgument, "invalid limit: %d, must be between 1 and %d", limit, MaxLimit)
+	}
+
+	return int(limit), nil
+}
+
+// FTS5Query returns the SQLite FTS5 query matching the documents with a word starting with each of the terms.
+func FTS5Query(terms []string) string {
+	quoted := make([]string, 0, len(terms))
+	for _, term := range terms {
+		quoted = append(quoted, `"`+term+`"*`)
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// TSQuery returns the PostgreSQL tsquery matching the documents with a word starting with each of the terms.
+func TSQuery(terms []string) string {
+	prefixes := make([]string, 0, len(terms))
+	for _, term := range terms {
+		prefixes = append(prefixes, term+":*")
+	}
+
+	return strings.Join(prefixes, " & ")
+}
+
+// Snippet returns the name highlighted by the database between HighlightStart and HighlightEnd as HTML: the name
+// is escaped and the matched words are between MarkStart and MarkEnd. The marks stay balanced whatever the name holds.
+func Snippet(highlighted string) string {
+	var b strings.Builder
+	open := false
+	for {
+		i := strings.IndexAny(highlighted, HighlightStart+HighlightEnd)
+		if i < 0 {
+			break
+		}
+
+		b.WriteString(html.EscapeString(highlighted[:i]))
+		if start := strings.HasPrefix(highlighted[i:], HighlightStart); start != open {
+			if open = start; open {
+				b.WriteString(MarkStart)
+			} else {
+				b.WriteString(MarkEnd)
+			}
+		}
+		highlighted = highlighted[i+len(HighlightStart):]
+	}
+	b.WriteString(html.EscapeString(highlighted))
+
+	if open {
+		b.WriteString(MarkEnd)
+	}
+
+	return b.String()
+}
+
+// Match scores a text against the terms like the database indexes do: every term must start a word of the text.
+// The score is the share of the words of the text matched, and the snippet is the escaped text with the matched
+// words marked, as returned by Snippet. ok is false when a term matches no word.
+func Match(text string, terms []string) (score float64, snippet string, ok bool) {
+	type word struct{ start, end int }
+
+	var words []word
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord && start >= 0:
+			words = append(words, word{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, word{start, len(text)})
+	}
+
+	matched := make([]bool, len(words))
+	for _, term := range terms {
+		found := false
+		for i, w := range words {
+			if strings.HasPrefix(strings.ToLower(text[w.start:w.end]), term) {
+				matched[i] = true
+				found = true
+			}
+		}
+
+		if !found {
+			return 0, "", false
+		}
+	}
+
+	var b strings.Builder
+	count, last := 0, 0
+	for i, w := range words {
+		if !matched[i] {
+			continue
+		}
+
+		count++
+		b.WriteString(text[last:w.start])
+		b.WriteString(HighlightStart)
+		b.WriteString(text[w.start:w.end])
+		b.WriteString(HighlightEnd)
+		last = w.end
+	}
+	b.WriteString(text[last:])
+
+	return float64(count) / float64(len(words)), Snippet(b.String()), true
+}
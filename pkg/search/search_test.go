@@ -0,0 +1,42 @@
+package search
+
+import "testing"
+
+func TestSnippet(t *testing.T) {
+	for _, tc := range []struct {
+		highlighted string
+		want        string
+	}{
+		{highlighted: "Tigers", want: "Tigers"},
+		{highlighted: "\uE000Tigers\uE001 Den \uE000Cup\uE001", want: "<mark>Tigers</mark> Den <mark>Cup</mark>"},
+		{highlighted: "Fish & \uE000Chips\uE001 <img src=x onerror=alert(1)>", want: "Fish &amp; <mark>Chips</mark> &lt;img src=x onerror=alert(1)&gt;"},
+		{highlighted: "\"Bob's\" \uE000<Cup>\uE001", want: "&#34;Bob&#39;s&#34; <mark>&lt;Cup&gt;</mark>"},
+		// Names holding the highlight runes themselves still give balanced marks.
+		{highlighted: "\uE001A \uE000\uE000B\uE001\uE001 \uE000C", want: "A <mark>B</mark> <mark>C</mark>"},
+	} {
+		if got := Snippet(tc.highlighted); got != tc.want {
+			t.Errorf("Snippet(%q) = %q, want %q", tc.highlighted, got, tc.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	for _, tc := range []struct {
+		text    string
+		terms   []string
+		score   float64
+		snippet string
+		ok      bool
+	}{
+		{text: "Tigers Den Cup", terms: []string{"tig"}, score: 1.0 / 3, snippet: "<mark>Tigers</mark> Den Cup", ok: true},
+		{text: "Tigers Den Cup", terms: []string{"cup", "tigers"}, score: 2.0 / 3, snippet: "<mark>Tigers</mark> Den <mark>Cup</mark>", ok: true},
+		{text: "Fish & Chips <b>", terms: []string{"chips"}, score: 1.0 / 3, snippet: "Fish &amp; <mark>Chips</mark> &lt;b&gt;", ok: true},
+		{text: "Tigers Den Cup", terms: []string{"tigers", "lions"}},
+		{text: "Tigers", terms: []string{"gers"}},
+	} {
+		score, snippet, ok := Match(tc.text, tc.terms)
+		if score != tc.score || snippet != tc.snippet || ok != tc.ok {
+			t.Errorf("Match(%q, %q) = %v, %q, %t, want %v, %q, %t", tc.text, tc.terms, score, snippet, ok, tc.score, tc.snippet, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+)
+
+// Tracker counts the requests handled by a server so the drain can be summarised.
+type Tracker struct {
+	inFlight int64
+	total    int64
+}
+
+// UnaryServerInterceptor tracks every unary RPC handled by a gRPC server.
+func (t *Tracker) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		t.start()
+		defer t.done()
+
+		return handler(ctx, req)
+	}
+}
+
+// Handler tracks every request handled by an HTTP server.
+func (t *Tracker) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.start()
+		defer t.done()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// InFlight returns the number of requests currently being handled.
+func (t *Tracker) InFlight() int64 {
+	return atomic.LoadInt64(&t.inFlight)
+}
+
+func (t *Tracker) start() {
+	atomic.AddInt64(&t.inFlight, 1)
+	atomic.AddInt64(&t.total, 1)
+}
+
+func (t *Tracker) done() {
+	atomic.AddInt64(&t.inFlight, -1)
+}
+
+// GRPC gracefully stops the server, waiting for in-flight RPCs up to the timeout before forcing it to stop.
+func GRPC(server *grpc.Server, tracker *Tracker, timeout time.Duration) {
+	began, inFlight := time.Now(), tracker.InFlight()
+	log.Infof("draining gRPC server with %d in-flight requests", inFlight)
+
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		logSummary(tracker, inFlight, began, false)
+	case <-time.After(timeout):
+		server.Stop()
+		logSummary(tracker, inFlight, began, true)
+	}
+}
+
+// HTTP gracefully shuts down the server, waiting for in-flight requests up to the timeout before closing it.
+func HTTP(server *http.Server, tracker *Tracker, timeout time.Duration) error {
+	began, inFlight := time.Now(), tracker.InFlight()
+	log.Infof("draining HTTP server with %d in-flight requests", inFlight)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := server.Shutdown(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		logSummary(tracker, inFlight, began, true)
+		return server.Close()
+	}
+
+	logSummary(tracker, inFlight, began, false)
+
+	return err
+}
+
+func logSummary(tracker *Tracker, inFlight int64, began time.Time, forced bool) {
+	fields := log.Fields{
+		"in_flight": inFlight,
+		"cut":       tracker.InFlight(),
+		"handled":   atomic.LoadInt64(&tracker.total),
+		"duration":  time.Since(began).String(),
+	}
+
+	if forced {
+		log.WithFields(fields).Warn("drain deadline exceeded, remaining requests were cut")
+		return
+	}
+
+	log.WithFields(fields).Info("drain complete")
+}
@@ -0,0 +1,76 @@
+package timewindow
+
+import (
+	"time"
+	// Embed the time zone database so that local dates resolve on hosts without one.
+	_ "time/tzdata"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// DateLayout is the layout of local dates.
+const DateLayout = "2006-01-02"
+
+// Window is the range of instants [From, To). A zero bound leaves that side open.
+type Window struct {
+	From time.Time
+	To   time.Time
+}
+
+// New returns the window of the instants from from until to that fall on the local date in the IANA time zone,
+// UTC when empty. Every argument is optional. Malformed dates, unknown time zones and ranges ending before they
+// start fail with InvalidArgument.
+func New(from, to *timestamppb.Timestamp, localDate, timeZone string) (Window, error) {
+	var w Window
+
+	if from != nil {
+		w.From = from.AsTime()
+	}
+
+	if to != nil {
+		w.To = to.AsTime()
+	}
+
+	if !w.From.IsZero() && !w.To.IsZero() && w.To.Before(w.From) {
+		return Window{}, status.Errorf(codes.InvalidArgument, "invalid time range: %s is before %s",
+			w.To.Format(time.RFC3339), w.From.Format(time.RFC3339))
+	}
+
+	if localDate == "" {
+		if timeZone != "" {
+			return Window{}, status.Error(codes.InvalidArgument, "time_zone is only used with local_date")
+		}
+
+		return w, nil
+	}
+
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return Window{}, status.Errorf(codes.InvalidArgument, "invalid time_zone: %s", timeZone)
+	}
+
+	date, err := time.ParseInLocation(DateLayout, localDate, loc)
+	if err != nil {
+		return Window{}, status.Errorf(codes.InvalidArgument, "invalid local_date: %s, expected YYYY-MM-DD", localDate)
+	}
+
+	// The next midnight rather than 24 hours later, as days are shorter or longer when daylight saving changes.
+	start, end := date, date.AddDate(0, 0, 1)
+
+	if w.From.IsZero() || start.After(w.From) {
+		w.From = start
+	}
+
+	if w.To.IsZero() || end.Before(w.To) {
+		w.To = end
+	}
+
+	return w, nil
+}
+
+// Contains reports whether t falls in the window.
+func (w Window) Contains(t time.Time) bool {
+	return (w.From.IsZero() || !t.Before(w.From)) && (w.To.IsZero() || t.Before(w.To))
+}
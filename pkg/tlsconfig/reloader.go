@@ -0,0 +1,180 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Reloader holds a certificate and a CA pool loaded from PEM files, reloading them when the files change.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	pool     *x509.CertPool
+	modTimes map[string]time.Time
+}
+
+// NewReloader loads the given files. The certificate or the CA may be left empty.
+func NewReloader(certFile, keyFile, caFile string) (*Reloader, error) {
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+	}
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Watch checks the files for changes every interval until the context is cancelled.
+// A failed reload is logged and the previously loaded files are kept.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		changed, err := r.changed()
+		if err != nil {
+			log.Errorf("failed checking TLS files: %s", err)
+			continue
+		}
+		if !changed {
+			continue
+		}
+
+		if err := r.load(); err != nil {
+			log.Errorf("failed reloading TLS files: %s", err)
+			continue
+		}
+
+		log.Info("reloaded TLS certificates")
+	}
+}
+
+// GetCertificate returns the current certificate, for use in tls.Config of a server.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.certificate()
+}
+
+// GetClientCertificate returns the current certificate, for use in tls.Config of a client.
+func (r *Reloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.certificate()
+}
+
+// CertPool returns the current CA pool, or nil if no CA file is configured.
+func (r *Reloader) CertPool() *x509.CertPool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.pool
+}
+
+func (r *Reloader) certificate() (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.cert == nil {
+		// An empty certificate tells the peer that no certificate is available.
+		return &tls.Certificate{}, nil
+	}
+
+	return r.cert, nil
+}
+
+// load reads all the files and swaps them in at once.
+func (r *Reloader) load() error {
+	var (
+		cert *tls.Certificate
+		pool *x509.CertPool
+	)
+
+	modTimes, err := r.stat()
+	if err != nil {
+		return err
+	}
+
+	if len(r.certFile) != 0 {
+		c, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			return err
+		}
+
+		cert = &c
+	}
+
+	if len(r.caFile) != 0 {
+		data, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return err
+		}
+
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no certificates found in %s", r.caFile)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cert, r.pool, r.modTimes = cert, pool, modTimes
+
+	return nil
+}
+
+// changed reports whether any file was modified since the last load.
+func (r *Reloader) changed() (bool, error) {
+	modTimes, err := r.stat()
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for file, modTime := range modTimes {
+		if !modTime.Equal(r.modTimes[file]) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *Reloader) stat() (map[string]time.Time, error) {
+	modTimes := make(map[string]time.Time)
+
+	for _, file := range []string{r.certFile, r.keyFile, r.caFile} {
+		if len(file) == 0 {
+			continue
+		}
+
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+
+		modTimes[file] = info.ModTime()
+	}
+
+	return modTimes, nil
+}
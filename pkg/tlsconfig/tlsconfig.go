@@ -0,0 +1,110 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"time"
+
+	"git.neds.sh/matty/entain/pkg/config"
+)
+
+// NewServerConfig returns the TLS configuration of a server, or nil when no certificate is configured.
+// When client authentication is enabled, clients must present a certificate signed by the CA.
+// The files are reloaded on change until the context is cancelled.
+func NewServerConfig(ctx context.Context, cfg config.TLS) (*tls.Config, error) {
+	if len(cfg.CertFile) == 0 {
+		return nil, nil
+	}
+
+	caFile := ""
+	if cfg.ClientAuth {
+		caFile = cfg.CAFile
+	}
+
+	reloader, err := NewReloader(cfg.CertFile, cfg.KeyFile, caFile)
+	if err != nil {
+		return nil, err
+	}
+
+	go reloader.Watch(ctx, cfg.ReloadInterval)
+
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+		// The handshake configuration below is cloned from this one rather than from the copies
+		// credentials.NewTLS and net/http add their protocols to, so advertise them here for ALPN.
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+
+	if cfg.ClientAuth {
+		// Build the configuration for each handshake so that a reloaded CA is picked up.
+		tlsConfig.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			handshake := tlsConfig.Clone()
+			handshake.GetConfigForClient = nil
+			handshake.ClientAuth = tls.RequireAndVerifyClientCert
+			handshake.ClientCAs = reloader.CertPool()
+
+			return handshake, nil
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// NewClientConfig returns the TLS configuration used to dial the backends, or nil when TLS is disabled.
+// The client certificate, if any, is presented for mutual TLS. The files are reloaded on change until
+// the context is cancelled.
+func NewClientConfig(ctx context.Context, cfg config.ClientTLS, reloadInterval time.Duration) (*tls.Config, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	reloader, err := NewReloader(cfg.CertFile, cfg.KeyFile, cfg.CAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	go reloader.Watch(ctx, reloadInterval)
+
+	tlsConfig := &tls.Config{
+		MinVersion:           tls.VersionTLS12,
+		ServerName:           cfg.ServerName,
+		GetClientCertificate: reloader.GetClientCertificate,
+	}
+
+	if len(cfg.CAFile) != 0 {
+		// The default verification uses a fixed pool, so verify against the reloaded CA instead.
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyConnection = verifyWith(reloader)
+	}
+
+	return tlsConfig, nil
+}
+
+// verifyWith verifies the server certificate chain against the current pool of the reloader.
+func verifyWith(reloader *Reloader) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		if len(cs.PeerCertificates) == 0 {
+			return errors.New("server did not present a certificate")
+		}
+		// Without a name any certificate signed by the CA would do, whichever server presents it.
+		if len(cs.ServerName) == 0 {
+			return errors.New("no server name to verify the server certificate against")
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, cert := range cs.PeerCertificates[1:] {
+			intermediates.AddCert(cert)
+		}
+
+		_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+			Roots:         reloader.CertPool(),
+			DNSName:       cs.ServerName,
+			Intermediates: intermediates,
+		})
+
+		return err
+	}
+}
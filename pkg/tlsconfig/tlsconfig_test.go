@@ -0,0 +1,100 @@
+package tlsconfig
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"git.neds.sh/matty/entain/pkg/config"
+	"git.neds.sh/matty/entain/pkg/endpoint"
+)
+
+// serve starts a health server presenting a certificate for racing.internal, returning its address and the CA file.
+func serve(t *testing.T) (string, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := GenerateDevCA(dir, []string{"racing.internal"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	serverTLS, err := NewServerConfig(ctx, config.TLS{
+		CertFile:       filepath.Join(dir, "server.pem"),
+		KeyFile:        filepath.Join(dir, "server-key.pem"),
+		ReloadInterval: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(serverTLS)))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	return lis.Addr().String(), filepath.Join(dir, "ca.pem")
+}
+
+func TestClientVerifiesServerNameOfReplicas(t *testing.T) {
+	address, caFile := serve(t)
+
+	for _, tc := range []struct {
+		name       string
+		endpoint   string
+		serverName string
+		ok         bool
+	}{
+		{name: "list with the server name", endpoint: address + "," + address, serverName: "racing.internal", ok: true},
+		{name: "list with a wrong server name", endpoint: address + "," + address, serverName: "sports.internal"},
+		{name: "list without server name", endpoint: address + "," + address},
+		{name: "address not in the certificate", endpoint: address},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			clientTLS, err := NewClientConfig(ctx, config.ClientTLS{
+				Enabled:    true,
+				CAFile:     caFile,
+				ServerName: tc.serverName,
+			}, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			target, opts, err := endpoint.Resolve("racing", tc.endpoint, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			conn, err := grpc.DialContext(ctx, target, append(opts, grpc.WithTransportCredentials(credentials.NewTLS(clientTLS)))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+			if tc.ok && err != nil {
+				t.Fatalf("health check failed: %v", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("health check succeeded over an unverified connection")
+			}
+		})
+	}
+}
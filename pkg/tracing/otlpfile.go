@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// idFields are the OTLP fields holding trace and span ids, which OTLP/JSON encodes as hex rather than the
+// base64 of the standard protobuf JSON mapping.
+var idFields = map[string]bool{"traceId": true, "spanId": true, "parentSpanId": true}
+
+// fileClient is an OTLP trace client writing each batch of spans as a line of OTLP/JSON, the format read by
+// the OpenTelemetry Collector otlpjsonfile receiver.
+type fileClient struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+var _ otlptrace.Client = (*fileClient)(nil)
+
+func (c *fileClient) Start(context.Context) error { return nil }
+
+func (c *fileClient) Stop(context.Context) error { return nil }
+
+// UploadTraces writes the spans as a single TracesData JSON object followed by a newline.
+func (c *fileClient) UploadTraces(_ context.Context, spans []*tracepb.ResourceSpans) error {
+	data, err := protojson.MarshalOptions{UseEnumNumbers: true}.Marshal(&tracepb.TracesData{ResourceSpans: spans})
+	if err != nil {
+		return err
+	}
+
+	// Round trip through a generic value to rewrite the ids as hex.
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	if data, err = json.Marshal(hexIDs(v)); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, err = c.w.Write(append(data, '\n'))
+
+	return err
+}
+
+// hexIDs replaces the base64 ids found anywhere in the decoded JSON value with their hex encoding.
+func hexIDs(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if s, ok := value.(string); ok && idFields[key] {
+				if id, err := base64.StdEncoding.DecodeString(s); err == nil {
+					v[key] = hex.EncodeToString(id)
+				}
+
+				continue
+			}
+
+			v[key] = hexIDs(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = hexIDs(value)
+		}
+	}
+
+	return v
+}
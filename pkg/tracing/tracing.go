@@ -0,0 +1,119 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// ExporterNone disables the export of spans.
+	ExporterNone = "none"
+	// ExporterStdout writes spans as OTLP/JSON lines to stdout.
+	ExporterStdout = "stdout"
+	// ExporterFile writes spans as OTLP/JSON lines to the configured file.
+	ExporterFile = "file"
+)
+
+// Config holds the tracing options of a service.
+type Config struct {
+	// ServiceName is reported as the service.name resource attribute.
+	ServiceName string
+	// Exporter is one of ExporterNone, ExporterStdout or ExporterFile.
+	Exporter string
+	// File is the path the spans are written to when using ExporterFile.
+	File string
+}
+
+// Init installs the global tracer provider and the W3C trace context propagator.
+// The returned function flushes pending spans and must be called before the service exits.
+func Init(cfg Config) (func(context.Context) error, error) {
+	// Always propagate the trace context, even if this service does not export spans itself.
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var (
+		w           io.Writer
+		closeWriter = func() error { return nil }
+	)
+
+	switch cfg.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		w = os.Stdout
+	case ExporterFile:
+		if len(cfg.File) == 0 {
+			return nil, fmt.Errorf("trace file is required for the %s exporter", ExporterFile)
+		}
+
+		f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+
+		w, closeWriter = f, f.Close
+	default:
+		return nil, fmt.Errorf("unknown trace exporter: %s", cfg.Exporter)
+	}
+
+	exporter, err := otlptrace.New(context.Background(), &fileClient{w: w})
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewSchemaless(attribute.String("service.name", cfg.ServiceName)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		if err := provider.Shutdown(ctx); err != nil {
+			return err
+		}
+
+		return closeWriter()
+	}, nil
+}
+
+// StartQuerySpan starts a span around a SQL query issued by a repository to the given database system.
+func StartQuerySpan(ctx context.Context, tracer trace.Tracer, system, name, query string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", system),
+			attribute.String("db.statement", query),
+		),
+	)
+}
+
+// EndSpan records the error, if any, and ends the span.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
@@ -1,45 +1,226 @@
 package db
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
 	"time"
 
+	"google.golang.org/protobuf/types/known/timestamppb"
 	"syreclabs.com/go/faker"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
 )
 
-func (r *racesRepo) seed() error {
-	statement, err := r.db.Prepare(`CREATE TABLE IF NOT EXISTS races (id INTEGER PRIMARY KEY, meeting_id INTEGER, name TEXT, number INTEGER, visible INTEGER, advertised_start_time DATETIME)`)
-	if err == nil {
-		_, err = statement.Exec()
+// categoryProfile describes the races of a racing code, so that generated races look like real ones.
+type categoryProfile struct {
+	// weight is the share of the meetings run under the code, out of 100.
+	weight int
+	// distances are the usual race lengths in metres.
+	distances []int64
+	// classes are the usual grades from lowest to highest.
+	classes []string
+	// surfaces are the usual track surfaces.
+	surfaces []string
+	// minPrize and maxPrize bound the prize money in dollars.
+	minPrize, maxPrize int
+	// minField and maxField bound the number of runners.
+	minField, maxField int
+}
+
+// categoryProfiles holds the profile of each race category.
+var categoryProfiles = map[racing.RaceCategory]categoryProfile{
+	racing.RaceCategory_THOROUGHBRED: {
+		weight:    50,
+		distances: []int64{1000, 1100, 1200, 1400, 1600, 2000, 2400, 3200},
+		classes:   []string{"Maiden", "Class 1", "Benchmark 64", "Benchmark 78", "Listed", "Group 3", "Group 2", "Group 1"},
+		surfaces:  []string{"Turf", "Synthetic", "Dirt"},
+		minPrize:  20000, maxPrize: 3000000,
+		minField: 6, maxField: 24,
+	},
+	racing.RaceCategory_HARNESS: {
+		weight:    25,
+		distances: []int64{1609, 1720, 2138, 2240, 2650},
+		classes:   []string{"C0", "C1", "C2", "C3", "C4", "Free For All"},
+		surfaces:  []string{"Sand", "Crushed Rock"},
+		minPrize:  5000, maxPrize: 500000,
+		minField: 5, maxField: 14,
+	},
+	racing.RaceCategory_GREYHOUND: {
+		weight:    25,
+		distances: []int64{300, 342, 400, 450, 515, 520, 595, 715},
+		classes:   []string{"Maiden", "Grade 5", "Grade 4", "Grade 3", "Grade 2", "Grade 1"},
+		surfaces:  []string{"Sand", "Grass"},
+		minPrize:  1000, maxPrize: 50000,
+		minField: 4, maxField: 8,
+	},
+}
+
+// SeedOptions controls the dummy races generated when seeding a repository.
+type SeedOptions struct {
+	// RandomSeed makes the generated races reproducible.
+	RandomSeed int64
+	// Count is the number of races generated.
+	Count int
+	// Meetings is the number of meetings the races are spread over.
+	Meetings int
+	// Anchor is the time the meetings are scheduled around.
+	Anchor time.Time
+	// Reset deletes the existing races, with their meetings, runners, results and scratchings, before seeding.
+	Reset bool
+}
+
+// GenerateRaces returns the dummy races described by the options. The same options always generate the same races.
+// Races are spread evenly over the meetings, numbered from 1 in each meeting and run every 20 to 40 minutes from
+// a meeting start between a day before and two days after the anchor. About 80% of them are visible.
+// Each meeting runs under one category, and its races get the distances, classes, surfaces, prize money and
+// field sizes usual for that category.
+func GenerateRaces(opts SeedOptions) []*racing.Race {
+	if opts.Count <= 0 || opts.Meetings <= 0 {
+		return nil
 	}
 
-	for i := 1; i <= 100; i++ {
-		statement, err = r.db.Prepare(`INSERT OR IGNORE INTO races(id, meeting_id, name, number, visible, advertised_start_time) VALUES (?,?,?,?,?,?)`)
-		if err == nil {
-			_, err = statement.Exec(
-				i,
-				faker.Number().Between(1, 10),
-				faker.Team().Name(),
-				faker.Number().Between(1, 12),
-				faker.Number().Between(0, 1),
-				faker.Time().Between(time.Now().AddDate(0, 0, -1), time.Now().AddDate(0, 0, 2)).Format(time.RFC3339),
-			)
+	faker.Seed(opts.RandomSeed)
+
+	anchor := opts.Anchor.UTC().Truncate(time.Minute)
+	perMeeting := int64((opts.Count + opts.Meetings - 1) / opts.Meetings)
+
+	races := make([]*racing.Race, 0, opts.Count)
+
+	for meeting := int64(1); len(races) < opts.Count; meeting++ {
+		start := faker.Time().Between(anchor.AddDate(0, 0, -1), anchor.AddDate(0, 0, 2)).Truncate(time.Minute)
+		category := randomCategory()
+		profile := categoryProfiles[category]
+
+		for number := int64(1); number <= perMeeting && len(races) < opts.Count; number++ {
+			races = append(races, &racing.Race{
+				Id:                  int64(len(races) + 1),
+				MeetingId:           meeting,
+				Name:                faker.Team().Name(),
+				Number:              number,
+				Visible:             faker.RandomInt(1, 10) <= 8,
+				AdvertisedStartTime: timestamppb.New(start),
+				Category:            category,
+				Distance:            profile.distances[faker.RandomInt(0, len(profile.distances)-1)],
+				Class:               profile.classes[faker.RandomInt(0, len(profile.classes)-1)],
+				TrackSurface:        profile.surfaces[faker.RandomInt(0, len(profile.surfaces)-1)],
+				PrizeMoney:          int64(faker.RandomInt(profile.minPrize/100, profile.maxPrize/100)) * 100 * 100,
+				FieldSize:           int64(faker.RandomInt(profile.minField, profile.maxField)),
+			})
+
+			start = start.Add(time.Duration(faker.RandomInt(20, 40)) * time.Minute)
 		}
 	}
 
-	err = r.setFutureStartTime(100)
+	return races
+}
+
+// randomCategory picks a race category following the weights of the category profiles.
+func randomCategory() racing.RaceCategory {
+	pick := faker.RandomInt(1, 100)
 
-	return err
+	// Iterate in enum order, as map order would make the generated races differ between runs.
+	for category := racing.RaceCategory_THOROUGHBRED; category <= racing.RaceCategory_GREYHOUND; category++ {
+		if pick <= categoryProfiles[category].weight {
+			return category
+		}
+
+		pick -= categoryProfiles[category].weight
+	}
+
+	return racing.RaceCategory_THOROUGHBRED
 }
 
-// Set advertised_start_time of the specified id to a future time value to show the status as OPEN.
-func (r *racesRepo) setFutureStartTime(id int) error {
-	var additionalHour time.Duration = (60 * time.Duration(id))
-	futureTime := time.Now().Add(additionalHour + time.Hour)
+// seedRaces inserts the dummy races in a single transaction, keeping existing races with the same ids unless resetting.
+func (r *racesRepo) seedRaces(ctx context.Context) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
 
-	statement, err := r.db.Prepare(`UPDATE races SET advertised_start_time=? where id=?`)
+	if r.seed.Reset {
+		for _, table := range []string{"results", "runners", "meetings", "scratchings"} {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM `+table); err != nil {
+				return err
+			}
+		}
 
-	if err == nil {
-		_, err = statement.Exec(futureTime, id)
+		if _, err := tx.ExecContext(ctx, `DELETE FROM races`); err != nil {
+			return err
+		}
 	}
-	return err
+
+	statement, err := tx.PrepareContext(ctx, r.dialect.Rebind(`INSERT INTO races(id, meeting_id, name, number, visible, advertised_start_time, category, distance, class, track_surface, prize_money, field_size) VALUES (?,?,?,?,?,?,?,?,?,?,?,?) ON CONFLICT DO NOTHING`))
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	races := GenerateRaces(*r.seed)
+
+	for _, race := range races {
+		_, err = statement.ExecContext(
+			ctx,
+			race.Id,
+			race.MeetingId,
+			race.Name,
+			race.Number,
+			race.Visible,
+			r.dialect.Time(race.AdvertisedStartTime.AsTime()),
+			int32(race.Category),
+			race.Distance,
+			race.Class,
+			race.TrackSurface,
+			race.PrizeMoney,
+			race.FieldSize,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := r.seedMeetings(ctx, tx, races); err != nil {
+		return err
+	}
+
+	// The feeds insert meetings and races under generated ids, which must skip the seeded ones.
+	for _, table := range []string{"meetings", "races"} {
+		if resync := r.dialect.ResyncID(table); resync != "" {
+			if _, err := tx.ExecContext(ctx, resync); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// seedMeetings inserts a meetings row for each meeting of the dummy races, dated like its first race, so that the
+// meetings of the feeds are numbered after them.
+func (r *racesRepo) seedMeetings(ctx context.Context, tx *sql.Tx, races []*racing.Race) error {
+	statement, err := tx.PrepareContext(ctx, r.dialect.Rebind(`INSERT INTO meetings(id, external_id, name, venue, date, category) VALUES (?,?,?,?,?,?) ON CONFLICT DO NOTHING`))
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	seeded := make(map[int64]bool)
+	for _, race := range races {
+		if seeded[race.MeetingId] {
+			continue
+		}
+		seeded[race.MeetingId] = true
+
+		date := race.AdvertisedStartTime.AsTime().Format("2006-01-02")
+		if _, err := statement.ExecContext(ctx, race.MeetingId, fmt.Sprintf("seed-%d", race.MeetingId), "", "", date, int32(race.Category)); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,640 @@
+// Package dbtest is a conformance suite for RacesRepo implementations. Like testing/fstest it reports
+// mismatches as an error, so the same suite can be run by the tests of every implementation.
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"git.neds.sh/matty/entain/pkg/clock"
+	"git.neds.sh/matty/entain/pkg/dialect"
+	"git.neds.sh/matty/entain/pkg/fieldmask"
+	"git.neds.sh/matty/entain/pkg/migrate"
+	"git.neds.sh/matty/entain/pkg/search"
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+// NewRepo creates the repository under test holding exactly the given races and telling the time with clk.
+type NewRepo func(ctx context.Context, clk clock.Clock, races []*racing.Race) (db.RacesRepo, error)
+
+// Now is the time the fixtures are scheduled around and the clock of the repository under test is stopped at.
+var Now = time.Date(2024, time.May, 1, 12, 0, 0, 0, time.UTC)
+
+// Races returns the fixture races of the suite. Start times are relative to now, so the statuses are known.
+func Races(now time.Time) []*racing.Race {
+	now = now.UTC().Truncate(time.Second)
+
+	race := func(id, meetingID int64, name string, number int64, visible bool, start time.Duration) *racing.Race {
+		return &racing.Race{
+			Id:                  id,
+			MeetingId:           meetingID,
+			Name:                name,
+			Number:              number,
+			Visible:             visible,
+			AdvertisedStartTime: timestamppb.New(now.Add(start)),
+		}
+	}
+
+	races := []*racing.Race{
+		race(1, 1, "Alpha", 3, true, -2*time.Hour),
+		race(2, 1, "Bravo", 1, false, time.Hour),
+		race(3, 2, "Charlie", 5, true, 3*time.Hour),
+		race(4, 2, "Delta", 2, false, -time.Hour),
+		race(5, 3, "Echo", 6, true, 2*time.Hour),
+		race(6, 4, "Foxtrot", 4, true, -3*time.Hour),
+	}
+
+	// Meetings 1 and 4 are thoroughbred, 2 harness and 3 greyhound.
+	categorise := func(r *racing.Race, category racing.RaceCategory, distance int64, class, surface string, prize, field int64) {
+		r.Category, r.Distance, r.Class, r.TrackSurface, r.PrizeMoney, r.FieldSize = category, distance, class, surface, prize, field
+	}
+	categorise(races[0], racing.RaceCategory_THOROUGHBRED, 1200, "Maiden", "Turf", 3500000, 14)
+	categorise(races[1], racing.RaceCategory_THOROUGHBRED, 2400, "Group 1", "Turf", 500000000, 24)
+	categorise(races[2], racing.RaceCategory_HARNESS, 2138, "C2", "Sand", 1200000, 12)
+	categorise(races[3], racing.RaceCategory_HARNESS, 1609, "Free For All", "Sand", 5000000, 9)
+	categorise(races[4], racing.RaceCategory_GREYHOUND, 515, "Grade 5", "Sand", 250000, 8)
+	categorise(races[5], racing.RaceCategory_THOROUGHBRED, 1000, "Benchmark 64", "Synthetic", 2000000, 10)
+
+	return races
+}
+
+// listCase is a List call with the races expected back, in order when ordered is set.
+// The call is made with a simulated time that far after Now when simulate is set.
+type listCase struct {
+	name     string
+	filter   *racing.ListRacesRequestFilter
+	orderBy  string
+	simulate time.Duration
+	want     []int64
+	ordered  bool
+}
+
+// TestRacesRepo loads the fixture races with newRepo and checks that filters, ordering, statuses, simulated times,
+// timestamps and scratchings behave the same as in every other implementation. It returns all the mismatches found.
+func TestRacesRepo(ctx context.Context, newRepo NewRepo) error {
+	fixtures := Races(Now)
+
+	repo, err := newRepo(ctx, clock.Fixed(Now), fixtures)
+	if err != nil {
+		return fmt.Errorf("failed creating repository: %w", err)
+	}
+
+	if err := repo.Init(ctx); err != nil {
+		return fmt.Errorf("failed initialising repository: %w", err)
+	}
+
+	visible, hidden := true, false
+	at := func(d time.Duration) *timestamppb.Timestamp { return timestamppb.New(Now.Add(d)) }
+
+	cases := []listCase{
+		{name: "no filter", want: []int64{1, 2, 3, 4, 5, 6}},
+		{name: "empty filter", filter: &racing.ListRacesRequestFilter{}, want: []int64{1, 2, 3, 4, 5, 6}},
+		{name: "meeting ids", filter: &racing.ListRacesRequestFilter{MeetingIds: []int64{1, 2}}, want: []int64{1, 2, 3, 4}},
+		{name: "unknown meeting id", filter: &racing.ListRacesRequestFilter{MeetingIds: []int64{9}}},
+		{name: "visible", filter: &racing.ListRacesRequestFilter{Visible: &visible}, want: []int64{1, 3, 5, 6}},
+		{name: "hidden", filter: &racing.ListRacesRequestFilter{Visible: &hidden}, want: []int64{2, 4}},
+		{name: "meeting ids and hidden", filter: &racing.ListRacesRequestFilter{MeetingIds: []int64{2}, Visible: &hidden}, want: []int64{4}},
+		{name: "order by start", orderBy: "advertised_start_time", want: []int64{6, 1, 4, 2, 5, 3}, ordered: true},
+		{name: "order by start desc", orderBy: "advertised_start_time desc", want: []int64{3, 5, 2, 4, 1, 6}, ordered: true},
+		{name: "order by number desc", orderBy: " number DESC ", want: []int64{5, 3, 6, 1, 4, 2}, ordered: true},
+		{name: "visible ordered by start", filter: &racing.ListRacesRequestFilter{Visible: &visible}, orderBy: "advertised_start_time asc", want: []int64{6, 1, 5, 3}, ordered: true},
+		{name: "simulated time", simulate: 150 * time.Minute, want: []int64{1, 2, 3, 4, 5, 6}},
+		{name: "simulated time in the past", simulate: -150 * time.Minute, orderBy: "advertised_start_time", want: []int64{6, 1, 4, 2, 5, 3}, ordered: true},
+		{name: "start time from", filter: &racing.ListRacesRequestFilter{StartTimeFrom: at(0)}, want: []int64{2, 3, 5}},
+		{name: "start time to", filter: &racing.ListRacesRequestFilter{StartTimeTo: at(0)}, want: []int64{1, 4, 6}},
+		{name: "start time range", filter: &racing.ListRacesRequestFilter{StartTimeFrom: at(-time.Hour), StartTimeTo: at(2 * time.Hour)}, want: []int64{2, 4}},
+		{name: "local date in UTC", filter: &racing.ListRacesRequestFilter{LocalDate: "2024-05-01"}, want: []int64{1, 2, 3, 4, 5, 6}},
+		{name: "local date in Melbourne", filter: &racing.ListRacesRequestFilter{LocalDate: "2024-05-01", TimeZone: "Australia/Melbourne"}, want: []int64{1, 2, 4, 6}},
+		{name: "next local date in Melbourne", filter: &racing.ListRacesRequestFilter{LocalDate: "2024-05-02", TimeZone: "Australia/Melbourne"}, want: []int64{3, 5}},
+		{name: "local date and start time from", filter: &racing.ListRacesRequestFilter{LocalDate: "2024-05-01", TimeZone: "Australia/Melbourne", StartTimeFrom: at(0)}, want: []int64{2}},
+		{name: "greyhound", filter: &racing.ListRacesRequestFilter{Categories: []racing.RaceCategory{racing.RaceCategory_GREYHOUND}}, want: []int64{5}},
+		{name: "thoroughbred and harness", filter: &racing.ListRacesRequestFilter{Categories: []racing.RaceCategory{racing.RaceCategory_THOROUGHBRED, racing.RaceCategory_HARNESS}}, want: []int64{1, 2, 3, 4, 6}},
+		{name: "thoroughbred and visible", filter: &racing.ListRacesRequestFilter{Categories: []racing.RaceCategory{racing.RaceCategory_THOROUGHBRED}, Visible: &visible}, want: []int64{1, 6}},
+		{name: "uncategorised", filter: &racing.ListRacesRequestFilter{Categories: []racing.RaceCategory{racing.RaceCategory_RACE_CATEGORY_UNSPECIFIED}}},
+		{name: "order by distance", orderBy: "distance", want: []int64{5, 6, 1, 4, 3, 2}, ordered: true},
+		{name: "order by prize money desc", orderBy: "prize_money desc", want: []int64{2, 4, 1, 6, 3, 5}, ordered: true},
+		{name: "local date and visible", filter: &racing.ListRacesRequestFilter{LocalDate: "2024-05-02", TimeZone: "Australia/Melbourne", Visible: &visible}, want: []int64{3, 5}},
+	}
+
+	byID := make(map[int64]*racing.Race, len(fixtures))
+	for _, race := range fixtures {
+		byID[race.Id] = race
+	}
+
+	var errs []error
+
+	for _, c := range cases {
+		listCtx, at := ctx, Now
+		if c.simulate != 0 {
+			at = Now.Add(c.simulate)
+			listCtx = clock.WithSimulatedTime(ctx, at)
+		}
+
+		races, err := repo.List(listCtx, c.filter, c.orderBy, nil)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("List %s: %w", c.name, err))
+			continue
+		}
+
+		if err := compareIDs(ids(races), c.want, c.ordered); err != nil {
+			errs = append(errs, fmt.Errorf("List %s: %w", c.name, err))
+		}
+
+		for _, race := range races {
+			if want, ok := byID[race.Id]; ok {
+				if err := compareRace(race, want, at); err != nil {
+					errs = append(errs, fmt.Errorf("List %s: %w", c.name, err))
+				}
+			}
+		}
+	}
+
+	for _, filter := range []*racing.ListRacesRequestFilter{
+		{StartTimeFrom: at(time.Hour), StartTimeTo: at(-time.Hour)},
+		{LocalDate: "2024-13-01"},
+		{LocalDate: "01/05/2024"},
+		{LocalDate: "2024-05-01", TimeZone: "Mars/Olympus_Mons"},
+		{TimeZone: "Australia/Melbourne"},
+	} {
+		if _, err := repo.List(ctx, filter, "", nil); err == nil {
+			errs = append(errs, fmt.Errorf("List with filter %v: expected an error", filter))
+		}
+	}
+
+	for _, orderBy := range []string{"unknown_field", "number sideways", "number desc extra"} {
+		if _, err := repo.List(ctx, nil, orderBy, nil); err == nil {
+			errs = append(errs, fmt.Errorf("List ordered by %q: expected an error", orderBy))
+		}
+	}
+
+	for _, at := range []time.Time{Now, Now.Add(150 * time.Minute)} {
+		getCtx := ctx
+		if !at.Equal(Now) {
+			getCtx = clock.WithSimulatedTime(ctx, at)
+		}
+
+		for _, want := range fixtures {
+			race, err := repo.GetRaceByID(getCtx, want.Id, nil)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("GetRaceByID %d at %s: %w", want.Id, at, err))
+				continue
+			}
+
+			if err := compareRace(race, want, at); err != nil {
+				errs = append(errs, fmt.Errorf("GetRaceByID %d at %s: %w", want.Id, at, err))
+			}
+		}
+	}
+
+	if _, err := repo.GetRaceByID(ctx, 999, nil); err == nil {
+		errs = append(errs, errors.New("GetRaceByID 999: expected an error for a missing race"))
+	}
+
+	errs = append(errs, testReadMasks(ctx, repo, fixtures)...)
+
+	races, err := repo.GetRacesByIDs(ctx, []int64{5, 999, 1})
+	if err != nil {
+		errs = append(errs, fmt.Errorf("GetRacesByIDs: %w", err))
+	} else {
+		if err := compareIDs(ids(races), []int64{1, 5}, false); err != nil {
+			errs = append(errs, fmt.Errorf("GetRacesByIDs: %w", err))
+		}
+
+		for _, race := range races {
+			if want, ok := byID[race.Id]; ok {
+				if err := compareRace(race, want, Now); err != nil {
+					errs = append(errs, fmt.Errorf("GetRacesByIDs: %w", err))
+				}
+			}
+		}
+	}
+
+	if races, err := repo.GetRacesByIDs(ctx, nil); err != nil || len(races) != 0 {
+		errs = append(errs, fmt.Errorf("GetRacesByIDs without ids: got %v, %v, want no races", ids(races), err))
+	}
+
+	errs = append(errs, testScratchings(ctx, repo)...)
+
+	// Search runs last as it loads its own races.
+	errs = append(errs, testSearch(ctx, newRepo)...)
+
+	return errors.Join(errs...)
+}
+
+// testReadMasks checks that List and GetRaceByID return only the fields of a read mask, given by proto or JSON names,
+// and reject unknown fields.
+func testReadMasks(ctx context.Context, repo db.RacesRepo, fixtures []*racing.Race) []error {
+	var errs []error
+
+	for _, paths := range [][]string{
+		{"name", "advertisedStartTime"},
+		{"id", "status"},
+		{"meeting_id", "number", "visible"},
+		{"category", "distance", "class", "trackSurface", "prize_money", "fieldSize"},
+	} {
+		mask := &fieldmaskpb.FieldMask{Paths: paths}
+
+		races, err := repo.List(ctx, nil, "id", mask)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("List with read mask %v: %w", paths, err))
+			continue
+		}
+
+		if len(races) != len(fixtures) {
+			errs = append(errs, fmt.Errorf("List with read mask %v: got %d races, want %d", paths, len(races), len(fixtures)))
+			continue
+		}
+
+		for i, want := range fixtures {
+			if err := compareMasked(races[i], want, mask); err != nil {
+				errs = append(errs, fmt.Errorf("List with read mask %v: %w", paths, err))
+			}
+
+			race, err := repo.GetRaceByID(ctx, want.Id, mask)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("GetRaceByID %d with read mask %v: %w", want.Id, paths, err))
+				continue
+			}
+
+			if err := compareMasked(race, want, mask); err != nil {
+				errs = append(errs, fmt.Errorf("GetRaceByID %d with read mask %v: %w", want.Id, paths, err))
+			}
+		}
+	}
+
+	unknown := &fieldmaskpb.FieldMask{Paths: []string{"id", "unknown_field"}}
+
+	if _, err := repo.List(ctx, nil, "", unknown); err == nil {
+		errs = append(errs, errors.New("List with an unknown read mask field: expected an error"))
+	}
+
+	if _, err := repo.GetRaceByID(ctx, fixtures[0].Id, unknown); err == nil {
+		errs = append(errs, errors.New("GetRaceByID with an unknown read mask field: expected an error"))
+	}
+
+	return errs
+}
+
+// scratchingCase is a ListScratchings call with the scratchings expected back in order, as race/runner pairs.
+type scratchingCase struct {
+	name   string
+	filter *racing.ListScratchingsRequestFilter
+	want   []string
+}
+
+// testScratchings scratches and reinstates runners of the fixtures, checking the times, the errors of invalid
+// changes and the filters of ListScratchings. It leaves the races untouched.
+func testScratchings(ctx context.Context, repo db.RacesRepo) []error {
+	var errs []error
+
+	check := func(op string, got *racing.Scratching, err error, want *racing.Scratching) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", op, err))
+			return
+		}
+
+		if !proto.Equal(got, want) {
+			errs = append(errs, fmt.Errorf("%s: got %v, want %v", op, got, want))
+		}
+	}
+
+	at := func(d time.Duration) *timestamppb.Timestamp { return timestamppb.New(Now.Add(d)) }
+
+	scratching := func(raceID, meetingID, runner int64, deduction float64, scratchedAt, unscratchedAt *timestamppb.Timestamp) *racing.Scratching {
+		s := &racing.Scratching{
+			RaceId:        raceID,
+			MeetingId:     meetingID,
+			RunnerNumber:  runner,
+			Scratched:     unscratchedAt == nil,
+			Deduction:     deduction,
+			ScratchedAt:   scratchedAt,
+			UnscratchedAt: unscratchedAt,
+			UpdatedAt:     scratchedAt,
+		}
+		if unscratchedAt != nil {
+			s.UpdatedAt = unscratchedAt
+		}
+
+		return s
+	}
+
+	// A zero time is the time of the repository clock, and times are kept to the second.
+	got, err := repo.Scratch(ctx, 1, 3, 12.5, time.Time{})
+	check("Scratch 1/3", got, err, scratching(1, 1, 3, 12.5, at(0), nil))
+
+	got, err = repo.Scratch(ctx, 5, 2, 0, Now.Add(10*time.Minute+500*time.Millisecond))
+	check("Scratch 5/2", got, err, scratching(5, 3, 2, 0, at(10*time.Minute), nil))
+
+	got, err = repo.Scratch(ctx, 3, 1, 5.5, Now.Add(20*time.Minute))
+	check("Scratch 3/1", got, err, scratching(3, 2, 1, 5.5, at(20*time.Minute), nil))
+
+	got, err = repo.Unscratch(clock.WithSimulatedTime(ctx, Now.Add(30*time.Minute)), 1, 3, time.Time{})
+	check("Unscratch 1/3", got, err, scratching(1, 1, 3, 12.5, at(0), at(30*time.Minute)))
+
+	for _, c := range []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "Scratch of a scratched runner", err: scratchErr(repo.Scratch(ctx, 5, 2, 10, time.Time{})), want: db.ErrAlreadyScratched},
+		{name: "Scratch of a missing race", err: scratchErr(repo.Scratch(ctx, 999, 1, 0, time.Time{})), want: db.ErrRaceNotFound},
+		{name: "Scratch beyond the field", err: scratchErr(repo.Scratch(ctx, 5, 9, 0, time.Time{})), want: db.ErrRunnerNotFound},
+		{name: "Scratch of runner 0", err: scratchErr(repo.Scratch(ctx, 5, 0, 0, time.Time{})), want: db.ErrRunnerNotFound},
+		{name: "Unscratch of a reinstated runner", err: scratchErr(repo.Unscratch(ctx, 1, 3, time.Time{})), want: db.ErrNotScratched},
+		{name: "Unscratch of a runner never scratched", err: scratchErr(repo.Unscratch(ctx, 2, 1, time.Time{})), want: db.ErrNotScratched},
+	} {
+		if !errors.Is(c.err, c.want) {
+			errs = append(errs, fmt.Errorf("%s: got error %v, want %v", c.name, c.err, c.want))
+		}
+	}
+
+	scratched, reinstated := true, false
+
+	cases := []scratchingCase{
+		{name: "no filter", want: []string{"5/2", "3/1", "1/3"}},
+		{name: "meeting ids", filter: &racing.ListScratchingsRequestFilter{MeetingIds: []int64{1, 2}}, want: []string{"3/1", "1/3"}},
+		{name: "race ids", filter: &racing.ListScratchingsRequestFilter{RaceIds: []int64{5, 1}}, want: []string{"5/2", "1/3"}},
+		{name: "scratched", filter: &racing.ListScratchingsRequestFilter{Scratched: &scratched}, want: []string{"5/2", "3/1"}},
+		{name: "reinstated", filter: &racing.ListScratchingsRequestFilter{Scratched: &reinstated}, want: []string{"1/3"}},
+		{name: "changed from", filter: &racing.ListScratchingsRequestFilter{ChangedFrom: at(20 * time.Minute)}, want: []string{"3/1", "1/3"}},
+		{name: "changed to", filter: &racing.ListScratchingsRequestFilter{ChangedTo: at(20 * time.Minute)}, want: []string{"5/2"}},
+		{name: "unknown meeting id", filter: &racing.ListScratchingsRequestFilter{MeetingIds: []int64{9}}},
+	}
+
+	for _, c := range cases {
+		scratchings, err := repo.ListScratchings(ctx, c.filter)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("ListScratchings %s: %w", c.name, err))
+			continue
+		}
+
+		if err := compareRunners(scratchings, c.want); err != nil {
+			errs = append(errs, fmt.Errorf("ListScratchings %s: %w", c.name, err))
+		}
+	}
+
+	invalid := &racing.ListScratchingsRequestFilter{ChangedFrom: at(time.Hour), ChangedTo: at(0)}
+	if _, err := repo.ListScratchings(ctx, invalid); err == nil {
+		errs = append(errs, errors.New("ListScratchings with a time range ending before it starts: expected an error"))
+	}
+
+	// Scratching a reinstated runner again replaces its scratching.
+	got, err = repo.Scratch(ctx, 1, 3, 20, Now.Add(40*time.Minute))
+	check("Scratch 1/3 again", got, err, scratching(1, 1, 3, 20, at(40*time.Minute), nil))
+
+	scratchings, err := repo.ListScratchings(ctx, &racing.ListScratchingsRequestFilter{RaceIds: []int64{1}})
+	if err != nil {
+		errs = append(errs, fmt.Errorf("ListScratchings after scratching again: %w", err))
+	} else if len(scratchings) != 1 {
+		errs = append(errs, fmt.Errorf("ListScratchings after scratching again: got %v, want one scratching", scratchings))
+	} else {
+		check("ListScratchings after scratching again", scratchings[0], nil, scratching(1, 1, 3, 20, at(40*time.Minute), nil))
+	}
+
+	return errs
+}
+
+// scratchErr keeps the error of a Scratch or Unscratch call.
+func scratchErr(_ *racing.Scratching, err error) error {
+	return err
+}
+
+// compareRunners checks the race/runner pairs of the scratchings against the expected ones, in order.
+func compareRunners(scratchings []*racing.Scratching, want []string) error {
+	got := make([]string, 0, len(scratchings))
+	for _, s := range scratchings {
+		got = append(got, fmt.Sprintf("%d/%d", s.RaceId, s.RunnerNumber))
+	}
+
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		return fmt.Errorf("got scratchings %v, want %v", got, want)
+	}
+
+	return nil
+}
+
+// searchCase is a Search call with the races expected back in order and the snippet of the first one.
+type searchCase struct {
+	query   string
+	limit   int
+	want    []int64
+	snippet string
+}
+
+// testSearch loads races with multi-word names and checks that Search matches word prefixes whatever their case
+// and punctuation, ranks the closer matches first and highlights the matched words of the escaped name.
+func testSearch(ctx context.Context, newRepo NewRepo) []error {
+	fixtures := Races(Now)
+	for i, name := range []string{"Tigers Den Cup", "Tigers", "Lions", "Eagles", "Hawks Handicap", "Fish & Chips <img src=x onerror=alert(1)> Sprint"} {
+		fixtures[i].Name = name
+	}
+
+	repo, err := newRepo(ctx, clock.Fixed(Now), fixtures)
+	if err != nil {
+		return []error{fmt.Errorf("failed creating search repository: %w", err)}
+	}
+
+	byID := make(map[int64]*racing.Race, len(fixtures))
+	for _, race := range fixtures {
+		byID[race.Id] = race
+	}
+
+	var errs []error
+
+	for _, c := range []searchCase{
+		{query: "tigers", limit: 10, want: []int64{2, 1}, snippet: "<mark>Tigers</mark>"},
+		{query: "TIG", limit: 1, want: []int64{2}, snippet: "<mark>Tigers</mark>"},
+		{query: "tigers cup", limit: 10, want: []int64{1}, snippet: "<mark>Tigers</mark> Den <mark>Cup</mark>"},
+		{query: `"hand*" OR lions`, limit: 10},
+		{query: "hand-", limit: 10, want: []int64{5}, snippet: "Hawks <mark>Handicap</mark>"},
+		{query: "chips", limit: 10, want: []int64{6}, snippet: "Fish &amp; <mark>Chips</mark> &lt;img src=x onerror=alert(1)&gt; Sprint"},
+		{query: "zebras", limit: 10},
+	} {
+		results, err := repo.Search(ctx, c.query, c.limit)
+		if errors.Is(err, search.ErrNoFTS5) && !dialect.FTS5 {
+			// SQLite without FTS5 has no index to search.
+			return nil
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Search %q: %w", c.query, err))
+			continue
+		}
+
+		got := make([]int64, 0, len(results))
+		for _, result := range results {
+			got = append(got, result.Race.Id)
+
+			if err := compareRace(result.Race, byID[result.Race.Id], Now); err != nil {
+				errs = append(errs, fmt.Errorf("Search %q: %w", c.query, err))
+			}
+		}
+
+		if err := compareIDs(got, c.want, true); err != nil {
+			errs = append(errs, fmt.Errorf("Search %q: %w", c.query, err))
+			continue
+		}
+
+		if len(results) > 0 && results[0].Snippet != c.snippet {
+			errs = append(errs, fmt.Errorf("Search %q: got snippet %q, want %q", c.query, results[0].Snippet, c.snippet))
+		}
+	}
+
+	for _, query := range []string{"", " -*- "} {
+		if _, err := repo.Search(ctx, query, 10); err == nil {
+			errs = append(errs, fmt.Errorf("Search %q: expected an error for an empty query", query))
+		}
+	}
+
+	return errs
+}
+
+// SQL returns a NewRepo which migrates the database, replaces its races with the given ones, dropping the meetings,
+// runners, results and scratchings, and wraps it in the SQL repository.
+func SQL(sqlDB *sql.DB, d dialect.Dialect) NewRepo {
+	return func(ctx context.Context, clk clock.Clock, races []*racing.Race) (_ db.RacesRepo, err error) {
+		migrator, err := migrate.New(sqlDB, d, db.Migrations(d))
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := migrator.Up(ctx); err != nil {
+			return nil, err
+		}
+
+		tx, err := sqlDB.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err != nil {
+				tx.Rollback()
+			}
+		}()
+
+		for _, table := range []string{"scratchings", "results", "runners", "races", "meetings"} {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM `+table); err != nil {
+				return nil, err
+			}
+		}
+
+		insert := d.Rebind(`INSERT INTO races(id, meeting_id, name, number, visible, advertised_start_time, category, distance, class, track_surface, prize_money, field_size) VALUES (?,?,?,?,?,?,?,?,?,?,?,?)`)
+		for _, race := range races {
+			if _, err := tx.ExecContext(ctx, insert, race.Id, race.MeetingId, race.Name, race.Number, race.Visible,
+				d.Time(race.AdvertisedStartTime.AsTime()), int32(race.Category), race.Distance, race.Class, race.TrackSurface,
+				race.PrizeMoney, race.FieldSize); err != nil {
+				return nil, err
+			}
+		}
+
+		// The feeds insert races under generated ids, which must skip the ones inserted above.
+		if resync := d.ResyncID("races"); resync != "" {
+			if _, err := tx.ExecContext(ctx, resync); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+
+		return db.NewRacesRepo(sqlDB, d, clk, nil), nil
+	}
+}
+
+// Memory returns a NewRepo which creates an in-memory repository holding the given races.
+func Memory() NewRepo {
+	return func(ctx context.Context, clk clock.Clock, races []*racing.Race) (db.RacesRepo, error) {
+		return db.NewMemoryRacesRepo(races, clk, nil), nil
+	}
+}
+
+// compareRace checks the fields of a race returned by the repository against its fixture, with its status at the given time.
+func compareRace(got, want *racing.Race, at time.Time) error {
+	status := racing.RaceStatus_CLOSED
+	if want.AdvertisedStartTime.AsTime().After(at) {
+		status = racing.RaceStatus_OPEN
+	}
+
+	switch {
+	case got.MeetingId != want.MeetingId || got.Name != want.Name || got.Number != want.Number || got.Visible != want.Visible:
+		return fmt.Errorf("race %d: got %v, want %v", want.Id, got, want)
+	case got.Category != want.Category || got.Distance != want.Distance || got.Class != want.Class ||
+		got.TrackSurface != want.TrackSurface || got.PrizeMoney != want.PrizeMoney || got.FieldSize != want.FieldSize ||
+		got.Sequence != want.Sequence:
+		return fmt.Errorf("race %d: got %v, want %v", want.Id, got, want)
+	case got.AdvertisedStartTime == nil || !got.AdvertisedStartTime.AsTime().Equal(want.AdvertisedStartTime.AsTime()):
+		return fmt.Errorf("race %d: got start %v, want %v", want.Id, got.AdvertisedStartTime.AsTime(), want.AdvertisedStartTime.AsTime())
+	case got.Status != status:
+		return fmt.Errorf("race %d: got status %s, want %s", want.Id, got.Status, status)
+	}
+
+	return nil
+}
+
+// compareMasked checks a race returned with a read mask against its fixture with its status at Now,
+// pruned to the fields of the mask.
+func compareMasked(got, want *racing.Race, mask *fieldmaskpb.FieldMask) error {
+	paths, err := fieldmask.Paths(mask, want)
+	if err != nil {
+		return err
+	}
+
+	want = proto.Clone(want).(*racing.Race)
+	if want.AdvertisedStartTime.AsTime().After(Now) {
+		want.Status = racing.RaceStatus_OPEN
+	}
+	fieldmask.Prune(want, paths)
+
+	if !proto.Equal(got, want) {
+		return fmt.Errorf("got %v, want %v", got, want)
+	}
+
+	return nil
+}
+
+func ids(races []*racing.Race) []int64 {
+	ids := make([]int64, 0, len(races))
+	for _, race := range races {
+		ids = append(ids, race.Id)
+	}
+
+	return ids
+}
+
+// compareIDs checks the returned ids against the expected ones, ignoring their order unless ordered is set.
+func compareIDs(got, want []int64, ordered bool) error {
+	if len(got) != len(want) {
+		return fmt.Errorf("got races %v, want %v", got, want)
+	}
+
+	if ordered {
+		for i := range got {
+			if got[i] != want[i] {
+				return fmt.Errorf("got races %v, want %v", got, want)
+			}
+		}
+
+		return nil
+	}
+
+	seen := make(map[int64]bool, len(got))
+	for _, id := range got {
+		seen[id] = true
+	}
+
+	for _, id := range want {
+		if !seen[id] {
+			return fmt.Errorf("got races %v, want %v", got, want)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"git.neds.sh/matty/entain/pkg/clock"
+	"git.neds.sh/matty/entain/pkg/dialect"
+	"git.neds.sh/matty/entain/pkg/pubsub"
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/ingest"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+// feedCase is a feed saved by the store, with the counts, rejections and scratching events expected, and the
+// sequence and scratched runner numbers of the race of the feed after it.
+type feedCase struct {
+	name      string
+	feed      *ingest.Feed
+	counts    ingest.Counts
+	rejected  []string
+	events    []string
+	sequence  int64
+	scratched []int64
+}
+
+// TestFeedStore saves feeds with the SQL feed store and checks that they upsert by external id, reject the records
+// referencing unknown ones, count the moves of the races in their sequence and go through the scratchings. Saving a
+// feed again must change nothing. It returns all the mismatches found.
+func TestFeedStore(ctx context.Context, sqlDB *sql.DB, d dialect.Dialect) error {
+	repo, err := SQL(sqlDB, d)(ctx, clock.Fixed(Now), nil)
+	if err != nil {
+		return fmt.Errorf("failed creating repository: %w", err)
+	}
+
+	broker := pubsub.NewBroker[*racing.ScratchingEvent]("scratchings")
+	events, cancel := broker.Subscribe(64)
+	defer cancel()
+
+	store := db.NewFeedStore(sqlDB, d, broker)
+
+	feed := func(start string, scratched bool) *ingest.Feed {
+		return &ingest.Feed{
+			Meetings: []ingest.Meeting{
+				{ID: "FLM", Name: "Flemington", Venue: "Flemington Racecourse", Date: "2024-05-01", Category: "thoroughbred"},
+			},
+			Races: []ingest.Race{
+				{ID: "FLM-R1", Meeting: "FLM", Name: "Maiden Plate", Number: 1, StartTime: start, Distance: 1200},
+				{ID: "CFD-R1", Meeting: "CFD", Name: "Caulfield Cup", Number: 1, StartTime: start},
+			},
+			Runners: []ingest.Runner{
+				{ID: "FLM-R1-1", Race: "FLM-R1", Number: 1, Name: "Fast Lane"},
+				{ID: "FLM-R1-2", Race: "FLM-R1", Number: 2, Name: "Slow Coach"},
+				{ID: "FLM-R1-3", Race: "FLM-R1", Number: 3, Name: "Late Mail", Scratched: scratched},
+				{ID: "FLM-R1-9", Race: "FLM-R1", Number: 9, Name: "Wide Out", Scratched: true},
+				{ID: "CFD-R1-1", Race: "CFD-R1", Number: 1, Name: "Stayer"},
+			},
+			Results: []ingest.Result{
+				{Race: "FLM-R1", Runner: "FLM-R1-2", Position: 1},
+				{Race: "FLM-R1", Runner: "FLM-R1-7", Position: 2},
+			},
+		}
+	}
+
+	counts := ingest.Counts{Meetings: 1, Races: 1, Runners: 4, Results: 1}
+	rejected := []string{
+		"race CFD-R1: unknown meeting CFD",
+		"result FLM-R1/FLM-R1-7: unknown runner FLM-R1-7",
+		"runner CFD-R1-1: unknown race CFD-R1",
+		"scratching FLM-R1-9: runner number 9 is outside the field of race FLM-R1",
+	}
+
+	var errs []error
+
+	for _, c := range []feedCase{
+		{name: "new feed", feed: feed("2024-05-01T02:30:00Z", true), counts: counts, rejected: rejected, events: []string{"SCRATCHED 3"}, scratched: []int64{3}},
+		{name: "same feed", feed: feed("2024-05-01T02:30:00Z", true), counts: counts, rejected: rejected, scratched: []int64{3}},
+		{name: "moved race", feed: feed("2024-05-01T03:00:00Z", true), counts: counts, rejected: rejected, sequence: 1, scratched: []int64{3}},
+		{name: "reinstated runner", feed: feed("2024-05-01T03:00:00Z", false), counts: counts, rejected: rejected, events: []string{"UNSCRATCHED 3"}, sequence: 1},
+	} {
+		valid, invalid := ingest.Validate(c.feed)
+		if len(invalid) != 0 {
+			errs = append(errs, fmt.Errorf("feed %s: invalid records %v", c.name, invalid))
+			continue
+		}
+
+		counts, rejections, err := store.Upsert(ctx, valid)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("feed %s: %w", c.name, err))
+			continue
+		}
+
+		if counts != c.counts {
+			errs = append(errs, fmt.Errorf("feed %s: got counts %+v, want %+v", c.name, counts, c.counts))
+		}
+
+		var got []string
+		for _, r := range rejections {
+			got = append(got, r.Record+" "+r.ID+": "+r.Reason)
+		}
+		sort.Strings(got)
+		if strings.Join(got, "\n") != strings.Join(c.rejected, "\n") {
+			errs = append(errs, fmt.Errorf("feed %s: got rejections %q, want %q", c.name, got, c.rejected))
+		}
+
+		got = nil
+		for len(events) > 0 {
+			event := <-events
+			got = append(got, fmt.Sprintf("%s %d", event.Type, event.Scratching.RunnerNumber))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(c.events) {
+			errs = append(errs, fmt.Errorf("feed %s: got scratching events %v, want %v", c.name, got, c.events))
+		}
+
+		errs = append(errs, checkFeedRace(ctx, repo, c)...)
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkFeedRace checks that the race of the feed is the only one, keeping its id, with the values of the feed.
+func checkFeedRace(ctx context.Context, repo db.RacesRepo, c feedCase) []error {
+	races, err := repo.List(ctx, nil, "", nil)
+	if err != nil {
+		return []error{fmt.Errorf("feed %s: %w", c.name, err)}
+	}
+	if len(races) != 1 {
+		return []error{fmt.Errorf("feed %s: got %d races, want 1", c.name, len(races))}
+	}
+
+	race, want := races[0], c.feed.Races[0]
+
+	var errs []error
+
+	if race.Name != want.Name || race.Number != want.Number || race.Distance != want.Distance ||
+		race.Category != racing.RaceCategory_THOROUGHBRED || race.FieldSize != 4 || !race.Visible {
+		errs = append(errs, fmt.Errorf("feed %s: got race %v, want %+v in a field of 4", c.name, race, want))
+	}
+	if got := race.AdvertisedStartTime.AsTime().Format("2006-01-02T15:04:05Z07:00"); got != want.StartTime {
+		errs = append(errs, fmt.Errorf("feed %s: got start %s, want %s", c.name, got, want.StartTime))
+	}
+	if race.Sequence != c.sequence {
+		errs = append(errs, fmt.Errorf("feed %s: got sequence %d, want %d", c.name, race.Sequence, c.sequence))
+	}
+
+	scratched := true
+	scratchings, err := repo.ListScratchings(ctx, &racing.ListScratchingsRequestFilter{RaceIds: []int64{race.Id}, Scratched: &scratched})
+	if err != nil {
+		return append(errs, fmt.Errorf("feed %s: %w", c.name, err))
+	}
+
+	var numbers []int64
+	for _, s := range scratchings {
+		numbers = append(numbers, s.RunnerNumber)
+	}
+	if fmt.Sprint(numbers) != fmt.Sprint(c.scratched) {
+		errs = append(errs, fmt.Errorf("feed %s: got scratched runners %v, want %v", c.name, numbers, c.scratched))
+	}
+
+	return errs
+}
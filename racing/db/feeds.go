@@ -0,0 +1,374 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"git.neds.sh/matty/entain/pkg/clock"
+	"git.neds.sh/matty/entain/pkg/dialect"
+	"git.neds.sh/matty/entain/pkg/pubsub"
+	"git.neds.sh/matty/entain/pkg/tracing"
+	"git.neds.sh/matty/entain/racing/ingest"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+// feedStore saves the ingested feeds to the races database. Meetings, races and runners keep their own ids,
+// generated by the database, and are matched to the feed records by external id. Runners scratched or reinstated
+// by a feed go through the scratchings like the ScratchRunner and UnscratchRunner RPCs.
+type feedStore struct {
+	db          *sql.DB
+	dialect     dialect.Dialect
+	scratchings *pubsub.Broker[*racing.ScratchingEvent]
+}
+
+// NewFeedStore creates a store saving the ingested feeds to a database of the given dialect. The scratching
+// events of the feeds are published to the broker once saved, unless it is nil.
+func NewFeedStore(db *sql.DB, d dialect.Dialect, scratchings *pubsub.Broker[*racing.ScratchingEvent]) ingest.Store {
+	return &feedStore{db: db, dialect: d, scratchings: scratchings}
+}
+
+// feedTx holds the state of a feed being saved: its transaction, the ids of the external ids already resolved
+// and the scratching events to publish once committed.
+type feedTx struct {
+	store *feedStore
+	tx    *sql.Tx
+
+	meetings   map[string]int64
+	categories map[string]racing.RaceCategory
+	races      map[string]int64
+	runners    map[string]runnerRef
+	events     []*racing.ScratchingEvent
+}
+
+// runnerRef is a runner and the race it runs in.
+type runnerRef struct {
+	id, raceID int64
+}
+
+// Upsert saves the records of a validated feed in a single transaction.
+func (s *feedStore) Upsert(ctx context.Context, feed *ingest.Feed) (counts ingest.Counts, rejections []ingest.Rejection, err error) {
+	ctx, span := tracer.Start(ctx, "feedStore.Upsert")
+	defer func() { tracing.EndSpan(span, err) }()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return counts, nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	t := &feedTx{
+		store:      s,
+		tx:         tx,
+		meetings:   make(map[string]int64),
+		categories: make(map[string]racing.RaceCategory),
+		races:      make(map[string]int64),
+		runners:    make(map[string]runnerRef),
+	}
+
+	for _, m := range feed.Meetings {
+		if err := t.upsertMeeting(ctx, m); err != nil {
+			return counts, nil, fmt.Errorf("meeting %s: %w", m.ID, err)
+		}
+		counts.Meetings++
+	}
+
+	for _, r := range feed.Races {
+		ok, err := t.upsertRace(ctx, r)
+		if err != nil {
+			return counts, nil, fmt.Errorf("race %s: %w", r.ID, err)
+		}
+
+		if !ok {
+			rejections = append(rejections, ingest.Rejection{Record: "race", ID: r.ID, Reason: "unknown meeting " + r.Meeting})
+			continue
+		}
+		counts.Races++
+	}
+
+	fielded := make(map[int64]bool)
+	var runners []ingest.Runner
+	for _, r := range feed.Runners {
+		raceID, ok, err := t.upsertRunner(ctx, r)
+		if err != nil {
+			return counts, nil, fmt.Errorf("runner %s: %w", r.ID, err)
+		}
+
+		if !ok {
+			rejections = append(rejections, ingest.Rejection{Record: "runner", ID: r.ID, Reason: "unknown race " + r.Race})
+			continue
+		}
+		counts.Runners++
+		fielded[raceID] = true
+		runners = append(runners, r)
+	}
+
+	// The field size of the races follows their runners, scratched ones included as they keep their number.
+	for raceID := range fielded {
+		if _, err := tx.ExecContext(ctx, s.dialect.Rebind(`UPDATE races SET field_size = (SELECT COUNT(*) FROM runners WHERE runners.race_id = races.id) WHERE id = ?`), raceID); err != nil {
+			return counts, nil, err
+		}
+	}
+
+	// Scratch once the field sizes are known, as a runner must be in the field of its race.
+	at := scratchingTime(ctx, clock.System, time.Time{})
+	for _, r := range runners {
+		reason, err := t.syncScratching(ctx, r, at)
+		if err != nil {
+			return counts, nil, fmt.Errorf("runner %s: %w", r.ID, err)
+		}
+
+		if reason != "" {
+			rejections = append(rejections, ingest.Rejection{Record: "scratching", ID: r.ID, Reason: reason})
+		}
+	}
+
+	for _, r := range feed.Results {
+		reason, err := t.upsertResult(ctx, r)
+		if err != nil {
+			return counts, nil, fmt.Errorf("result %s: %w", r.ID(), err)
+		}
+
+		if reason != "" {
+			rejections = append(rejections, ingest.Rejection{Record: "result", ID: r.ID(), Reason: reason})
+			continue
+		}
+		counts.Results++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return counts, nil, err
+	}
+
+	if s.scratchings != nil {
+		for _, event := range t.events {
+			s.scratchings.Publish(event)
+		}
+	}
+
+	return counts, rejections, nil
+}
+
+// upsertMeeting saves a meeting, and its category to its races.
+func (t *feedTx) upsertMeeting(ctx context.Context, m ingest.Meeting) error {
+	category := racing.RaceCategory(racing.RaceCategory_value[m.Category])
+
+	id, err := t.upsert(ctx, "meetings", m.ID,
+		[]string{"name", "venue", "date", "category"},
+		[]interface{}{m.Name, m.Venue, m.Date, int32(category)})
+	if err != nil {
+		return err
+	}
+
+	if _, err := t.tx.ExecContext(ctx, t.store.dialect.Rebind(`UPDATE races SET category = ? WHERE meeting_id = ?`), int32(category), id); err != nil {
+		return err
+	}
+
+	t.meetings[m.ID] = id
+	t.categories[m.ID] = category
+
+	return nil
+}
+
+// upsertRace saves a race under its meeting, reporting false when the meeting is unknown.
+func (t *feedTx) upsertRace(ctx context.Context, r ingest.Race) (bool, error) {
+	meetingID, ok := t.meetings[r.Meeting]
+	category := t.categories[r.Meeting]
+
+	if !ok {
+		var raw int32
+		row := t.tx.QueryRowContext(ctx, t.store.dialect.Rebind(`SELECT id, category FROM meetings WHERE external_id = ?`), r.Meeting)
+		if err := row.Scan(&meetingID, &raw); err != nil {
+			if err == sql.ErrNoRows {
+				return false, nil
+			}
+
+			return false, err
+		}
+
+		category = racing.RaceCategory(raw)
+		t.meetings[r.Meeting], t.categories[r.Meeting] = meetingID, category
+	}
+
+	// The start time was validated with the feed.
+	start, err := time.Parse(time.RFC3339, r.StartTime)
+	if err != nil {
+		return false, err
+	}
+
+	visible := r.Visible == nil || *r.Visible
+
+	// Calendars pick up a moved race by its higher sequence.
+	bump := `UPDATE races SET sequence = sequence + 1 WHERE external_id = ? AND advertised_start_time <> ?`
+	if _, err := t.tx.ExecContext(ctx, t.store.dialect.Rebind(bump), r.ID, t.store.dialect.Time(start)); err != nil {
+		return false, err
+	}
+
+	id, err := t.upsert(ctx, "races", r.ID,
+		[]string{"meeting_id", "name", "number", "visible", "advertised_start_time", "category", "distance", "class", "track_surface", "prize_money"},
+		[]interface{}{meetingID, r.Name, r.Number, visible, t.store.dialect.Time(start), int32(category), r.Distance, r.Class, r.TrackSurface, r.PrizeMoney})
+	if err != nil {
+		return false, err
+	}
+
+	t.races[r.ID] = id
+
+	return true, nil
+}
+
+// upsertRunner saves a runner in its race and returns the race id, reporting false when the race is unknown.
+func (t *feedTx) upsertRunner(ctx context.Context, r ingest.Runner) (int64, bool, error) {
+	raceID, ok, err := t.raceID(ctx, r.Race)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+
+	// The scratched flag is left to syncScratching, along with the scratchings.
+	id, err := t.upsert(ctx, "runners", r.ID,
+		[]string{"race_id", "number", "name", "barrier", "jockey", "trainer", "weight"},
+		[]interface{}{raceID, r.Number, r.Name, r.Barrier, r.Jockey, r.Trainer, r.Weight})
+	if err != nil {
+		return 0, false, err
+	}
+
+	t.runners[r.ID] = runnerRef{id: id, raceID: raceID}
+
+	return raceID, true, nil
+}
+
+// syncScratching scratches or reinstates a saved runner when the feed changes whether it is scratched, and keeps
+// the scratching event to publish. It returns the reason to reject the scratching when the runner number is outside
+// the field of its race.
+func (t *feedTx) syncScratching(ctx context.Context, r ingest.Runner, at time.Time) (string, error) {
+	raceID := t.runners[r.ID].raceID
+
+	var scratched bool
+	row := t.tx.QueryRowContext(ctx, t.store.dialect.Rebind(`SELECT COUNT(*) > 0 FROM scratchings WHERE race_id = ? AND runner_number = ? AND unscratched_at IS NULL`), raceID, r.Number)
+	if err := row.Scan(&scratched); err != nil {
+		return "", err
+	}
+
+	var (
+		event = &racing.ScratchingEvent{Type: racing.ScratchingEventType_SCRATCHED}
+		err   error
+	)
+
+	switch {
+	case r.Scratched && !scratched:
+		event.Scratching, err = scratch(ctx, t.tx, t.store.dialect, raceID, r.Number, 0, at)
+	case !r.Scratched && scratched:
+		event.Type = racing.ScratchingEventType_UNSCRATCHED
+		event.Scratching, err = unscratch(ctx, t.tx, t.store.dialect, raceID, r.Number, at)
+	default:
+		// Still flag the runners saved before the feeds went through the scratchings.
+		return "", flagRunner(ctx, t.tx, t.store.dialect, raceID, r.Number, scratched)
+	}
+	if errors.Is(err, ErrRunnerNotFound) {
+		return fmt.Sprintf("runner number %d is outside the field of race %s", r.Number, r.Race), nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	t.events = append(t.events, event)
+
+	return "", nil
+}
+
+// upsertResult saves the result of a runner, returning the reason to reject it when its race or runner is unknown
+// or the runner is in another race.
+func (t *feedTx) upsertResult(ctx context.Context, r ingest.Result) (string, error) {
+	raceID, ok, err := t.raceID(ctx, r.Race)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "unknown race " + r.Race, nil
+	}
+
+	runner, ok := t.runners[r.Runner]
+	if !ok {
+		row := t.tx.QueryRowContext(ctx, t.store.dialect.Rebind(`SELECT id, race_id FROM runners WHERE external_id = ?`), r.Runner)
+		if err := row.Scan(&runner.id, &runner.raceID); err != nil {
+			if err == sql.ErrNoRows {
+				return "unknown runner " + r.Runner, nil
+			}
+
+			return "", err
+		}
+
+		t.runners[r.Runner] = runner
+	}
+
+	if runner.raceID != raceID {
+		return fmt.Sprintf("runner %s is not in race %s", r.Runner, r.Race), nil
+	}
+
+	_, err = t.tx.ExecContext(ctx, t.store.dialect.Rebind(`
+		INSERT INTO results(race_id, runner_id, position, margin) VALUES (?, ?, ?, ?)
+		ON CONFLICT (race_id, runner_id) DO UPDATE SET position = excluded.position, margin = excluded.margin
+	`), raceID, runner.id, r.Position, r.Margin)
+
+	return "", err
+}
+
+// raceID returns the id of the race with the external id, reporting false when there is none.
+func (t *feedTx) raceID(ctx context.Context, externalID string) (int64, bool, error) {
+	if id, ok := t.races[externalID]; ok {
+		return id, true, nil
+	}
+
+	var id int64
+	row := t.tx.QueryRowContext(ctx, t.store.dialect.Rebind(`SELECT id FROM races WHERE external_id = ?`), externalID)
+	if err := row.Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, false, nil
+		}
+
+		return 0, false, err
+	}
+
+	t.races[externalID] = id
+
+	return id, true, nil
+}
+
+// upsert updates the columns of the row of the table with the external id, or inserts the row under the id the
+// database generates, and returns the id of the row.
+func (t *feedTx) upsert(ctx context.Context, table, externalID string, columns []string, values []interface{}) (int64, error) {
+	var id int64
+
+	row := t.tx.QueryRowContext(ctx, t.store.dialect.Rebind(`SELECT id FROM `+table+` WHERE external_id = ?`), externalID)
+	switch err := row.Scan(&id); err {
+	case nil:
+		assignments := make([]string, 0, len(columns))
+		for _, column := range columns {
+			assignments = append(assignments, column+" = ?")
+		}
+
+		query := `UPDATE ` + table + ` SET ` + strings.Join(assignments, ", ") + ` WHERE id = ?`
+		if _, err := t.tx.ExecContext(ctx, t.store.dialect.Rebind(query), append(values, id)...); err != nil {
+			return 0, err
+		}
+
+		return id, nil
+	case sql.ErrNoRows:
+	default:
+		return 0, err
+	}
+
+	query := `INSERT INTO ` + table + `(external_id, ` + strings.Join(columns, ", ") + `) VALUES (?` +
+		strings.Repeat(", ?", len(columns)) + `) RETURNING id`
+	if err := t.tx.QueryRowContext(ctx, t.store.dialect.Rebind(query), append([]interface{}{externalID}, values...)...).Scan(&id); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
@@ -0,0 +1,442 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"git.neds.sh/matty/entain/pkg/clock"
+	"git.neds.sh/matty/entain/pkg/fieldmask"
+	"git.neds.sh/matty/entain/pkg/search"
+	"git.neds.sh/matty/entain/pkg/timewindow"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+type memoryRacesRepo struct {
+	mu          sync.RWMutex
+	races       map[int64]*racing.Race
+	scratchings map[runnerKey]*racing.Scratching
+	clock       clock.Clock
+	seed        *SeedOptions
+	init        sync.Once
+}
+
+// runnerKey identifies a runner by its race and number.
+type runnerKey struct {
+	raceID, runnerNumber int64
+}
+
+// NewMemoryRacesRepo creates a races repository holding the given races in memory, for tests and demos.
+// It filters and orders races and computes their statuses like the SQL repository, and inserts dummy races on Init
+// when seed is set.
+func NewMemoryRacesRepo(races []*racing.Race, clk clock.Clock, seed *SeedOptions) RacesRepo {
+	r := &memoryRacesRepo{
+		races:       make(map[int64]*racing.Race, len(races)),
+		scratchings: make(map[runnerKey]*racing.Scratching),
+		clock:       clk,
+		seed:        seed,
+	}
+
+	for _, race := range races {
+		r.races[race.Id] = proto.Clone(race).(*racing.Race)
+	}
+
+	return r
+}
+
+// Init adds the dummy races when seeding, keeping existing races with the same ids unless resetting.
+func (r *memoryRacesRepo) Init(ctx context.Context) error {
+	r.init.Do(func() {
+		if r.seed == nil {
+			return
+		}
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		if r.seed.Reset {
+			r.races = make(map[int64]*racing.Race)
+			r.scratchings = make(map[runnerKey]*racing.Scratching)
+		}
+
+		for _, race := range GenerateRaces(*r.seed) {
+			if _, ok := r.races[race.Id]; !ok {
+				r.races[race.Id] = race
+			}
+		}
+	})
+
+	return nil
+}
+
+// GetRaceByID will return the information of a given race id.
+func (r *memoryRacesRepo) GetRaceByID(ctx context.Context, id int64, mask *fieldmaskpb.FieldMask) (*racing.Race, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	paths, err := fieldmask.Paths(mask, &racing.Race{})
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	race, ok := r.races[id]
+	if !ok {
+		return nil, ErrRaceNotFound
+	}
+
+	race = withStatus(race, clock.FromContext(ctx, r.clock).Now())
+	fieldmask.Prune(race, paths)
+
+	return race, nil
+}
+
+// GetRacesByIDs will return the races of the given ids, in no particular order.
+func (r *memoryRacesRepo) GetRacesByIDs(ctx context.Context, ids []int64) ([]*racing.Race, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	now := clock.FromContext(ctx, r.clock).Now()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var races []*racing.Race
+	for _, id := range ids {
+		if race, ok := r.races[id]; ok {
+			races = append(races, withStatus(race, now))
+		}
+	}
+
+	return races, nil
+}
+
+// Search will return the races whose name matches the query, scored by the share of the name matched.
+func (r *memoryRacesRepo) Search(ctx context.Context, query string, limit int) ([]*racing.SearchResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	terms, err := search.Terms(query)
+	if err != nil {
+		return nil, err
+	}
+
+	now := clock.FromContext(ctx, r.clock).Now()
+
+	r.mu.RLock()
+	var results []*racing.SearchResult
+	for _, race := range r.races {
+		if score, snippet, ok := search.Match(race.Name, terms); ok {
+			results = append(results, &racing.SearchResult{Race: withStatus(race, now), Score: score, Snippet: snippet})
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+
+		return results[i].Race.Id < results[j].Race.Id
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// List returns the races matching the filter, sorted like the ORDER BY clause of the SQL repository.
+func (r *memoryRacesRepo) List(ctx context.Context, filter *racing.ListRacesRequestFilter, orderBy string, mask *fieldmaskpb.FieldMask) ([]*racing.Race, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	paths, err := fieldmask.Paths(mask, &racing.Race{})
+	if err != nil {
+		return nil, err
+	}
+
+	column, order, err := parseOrderBy(orderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	var window timewindow.Window
+	if filter != nil {
+		if window, err = timewindow.New(filter.StartTimeFrom, filter.StartTimeTo, filter.LocalDate, filter.TimeZone); err != nil {
+			return nil, err
+		}
+	}
+
+	now := clock.FromContext(ctx, r.clock).Now()
+
+	r.mu.RLock()
+	var races []*racing.Race
+	for _, race := range r.races {
+		if matchesFilter(race, filter, window) {
+			races = append(races, withStatus(race, now))
+		}
+	}
+	r.mu.RUnlock()
+
+	// Without ORDER BY the SQL repository returns the races in id order.
+	sort.Slice(races, func(i, j int) bool {
+		return races[i].Id < races[j].Id
+	})
+
+	if len(column) != 0 {
+		less, err := raceLess(column)
+		if err != nil {
+			return nil, err
+		}
+
+		sort.SliceStable(races, func(i, j int) bool {
+			if order == "DESC" {
+				return less(races[j], races[i])
+			}
+
+			return less(races[i], races[j])
+		})
+	}
+
+	// Fields are pruned once sorted, since the order may depend on fields left out of the mask.
+	for _, race := range races {
+		fieldmask.Prune(race, paths)
+	}
+
+	return races, nil
+}
+
+// Scratch withdraws a runner from a race, storing the time to the second like the SQL repository.
+func (r *memoryRacesRepo) Scratch(ctx context.Context, raceID, runnerNumber int64, deduction float64, at time.Time) (*racing.Scratching, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	at = scratchingTime(ctx, r.clock, at)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	race, ok := r.races[raceID]
+	if !ok {
+		return nil, ErrRaceNotFound
+	}
+
+	if !inField(runnerNumber, race.FieldSize) {
+		return nil, ErrRunnerNotFound
+	}
+
+	key := runnerKey{raceID, runnerNumber}
+	if scratching, ok := r.scratchings[key]; ok && scratching.Scratched {
+		return nil, ErrAlreadyScratched
+	}
+
+	scratching := &racing.Scratching{
+		RaceId:       raceID,
+		MeetingId:    race.MeetingId,
+		RunnerNumber: runnerNumber,
+		Scratched:    true,
+		Deduction:    deduction,
+		ScratchedAt:  timestamppb.New(at),
+		UpdatedAt:    timestamppb.New(at),
+	}
+	r.scratchings[key] = scratching
+
+	return proto.Clone(scratching).(*racing.Scratching), nil
+}
+
+// Unscratch reinstates a scratched runner, storing the time to the second like the SQL repository.
+func (r *memoryRacesRepo) Unscratch(ctx context.Context, raceID, runnerNumber int64, at time.Time) (*racing.Scratching, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	at = scratchingTime(ctx, r.clock, at)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	scratching, ok := r.scratchings[runnerKey{raceID, runnerNumber}]
+	if !ok || !scratching.Scratched {
+		return nil, ErrNotScratched
+	}
+
+	scratching.Scratched = false
+	scratching.UnscratchedAt = timestamppb.New(at)
+	scratching.UpdatedAt = timestamppb.New(at)
+
+	return proto.Clone(scratching).(*racing.Scratching), nil
+}
+
+// ListScratchings will return the scratchings matching the filter, sorted like the SQL repository.
+func (r *memoryRacesRepo) ListScratchings(ctx context.Context, filter *racing.ListScratchingsRequestFilter) ([]*racing.Scratching, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var window timewindow.Window
+	if filter != nil {
+		var err error
+		if window, err = timewindow.New(filter.ChangedFrom, filter.ChangedTo, "", ""); err != nil {
+			return nil, err
+		}
+	}
+
+	r.mu.RLock()
+	var scratchings []*racing.Scratching
+	for _, scratching := range r.scratchings {
+		// The SQL repository joins the races, leaving out the scratchings of deleted races.
+		if _, ok := r.races[scratching.RaceId]; ok && matchesScratchingFilter(scratching, filter, window) {
+			scratchings = append(scratchings, proto.Clone(scratching).(*racing.Scratching))
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(scratchings, func(i, j int) bool {
+		a, b := scratchings[i], scratchings[j]
+		if !a.UpdatedAt.AsTime().Equal(b.UpdatedAt.AsTime()) {
+			return a.UpdatedAt.AsTime().Before(b.UpdatedAt.AsTime())
+		}
+
+		if a.RaceId != b.RaceId {
+			return a.RaceId < b.RaceId
+		}
+
+		return a.RunnerNumber < b.RunnerNumber
+	})
+
+	return scratchings, nil
+}
+
+// matchesScratchingFilter reports whether the scratching satisfies every condition of the filter, changed in the
+// window of its time filters.
+func matchesScratchingFilter(scratching *racing.Scratching, filter *racing.ListScratchingsRequestFilter, window timewindow.Window) bool {
+	if filter == nil {
+		return true
+	}
+
+	if len(filter.MeetingIds) > 0 && !containsID(filter.MeetingIds, scratching.MeetingId) {
+		return false
+	}
+
+	if len(filter.RaceIds) > 0 && !containsID(filter.RaceIds, scratching.RaceId) {
+		return false
+	}
+
+	if filter.Scratched != nil && scratching.Scratched != *filter.Scratched {
+		return false
+	}
+
+	return window.Contains(scratching.UpdatedAt.AsTime())
+}
+
+// containsID reports whether the id is one of ids.
+func containsID(ids []int64, id int64) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesFilter reports whether the race satisfies every condition of the filter, starting in the window of its
+// time filters.
+func matchesFilter(race *racing.Race, filter *racing.ListRacesRequestFilter, window timewindow.Window) bool {
+	if filter == nil {
+		return true
+	}
+
+	if len(filter.MeetingIds) > 0 {
+		found := false
+		for _, meetingID := range filter.MeetingIds {
+			if race.MeetingId == meetingID {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if filter.Visible != nil && race.Visible != *filter.Visible {
+		return false
+	}
+
+	if len(filter.Categories) > 0 {
+		found := false
+		for _, category := range filter.Categories {
+			if race.Category == category {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return window.Contains(race.AdvertisedStartTime.AsTime())
+}
+
+// raceLess returns the comparison of races on a column, matching how the databases sort it.
+func raceLess(column string) (func(a, b *racing.Race) bool, error) {
+	switch column {
+	case "id":
+		return func(a, b *racing.Race) bool { return a.Id < b.Id }, nil
+	case "meeting_id":
+		return func(a, b *racing.Race) bool { return a.MeetingId < b.MeetingId }, nil
+	case "name":
+		return func(a, b *racing.Race) bool { return a.Name < b.Name }, nil
+	case "number":
+		return func(a, b *racing.Race) bool { return a.Number < b.Number }, nil
+	case "visible":
+		return func(a, b *racing.Race) bool { return !a.Visible && b.Visible }, nil
+	case "advertised_start_time":
+		return func(a, b *racing.Race) bool {
+			return a.AdvertisedStartTime.AsTime().Before(b.AdvertisedStartTime.AsTime())
+		}, nil
+	case "category":
+		return func(a, b *racing.Race) bool { return a.Category < b.Category }, nil
+	case "distance":
+		return func(a, b *racing.Race) bool { return a.Distance < b.Distance }, nil
+	case "class":
+		return func(a, b *racing.Race) bool { return a.Class < b.Class }, nil
+	case "track_surface":
+		return func(a, b *racing.Race) bool { return a.TrackSurface < b.TrackSurface }, nil
+	case "prize_money":
+		return func(a, b *racing.Race) bool { return a.PrizeMoney < b.PrizeMoney }, nil
+	case "field_size":
+		return func(a, b *racing.Race) bool { return a.FieldSize < b.FieldSize }, nil
+	case "sequence":
+		return func(a, b *racing.Race) bool { return a.Sequence < b.Sequence }, nil
+	}
+
+	return nil, fmt.Errorf("unable to sort by the column: %s", column)
+}
+
+// withStatus returns a copy of the race with its status at the given time.
+func withStatus(race *racing.Race, now time.Time) *racing.Race {
+	race = proto.Clone(race).(*racing.Race)
+	race.Status = getRaceStatus(race.AdvertisedStartTime.AsTime(), now)
+
+	return race
+}
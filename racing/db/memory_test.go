@@ -0,0 +1,14 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+
+	"git.neds.sh/matty/entain/racing/db/dbtest"
+)
+
+func TestMemoryRacesRepo(t *testing.T) {
+	if err := dbtest.TestRacesRepo(context.Background(), dbtest.Memory()); err != nil {
+		t.Error(err)
+	}
+}
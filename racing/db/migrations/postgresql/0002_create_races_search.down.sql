@@ -0,0 +1 @@
+DROP INDEX races_search;
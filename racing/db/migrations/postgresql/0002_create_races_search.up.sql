@@ -0,0 +1 @@
+CREATE INDEX races_search ON races USING GIN (to_tsvector('simple', name));
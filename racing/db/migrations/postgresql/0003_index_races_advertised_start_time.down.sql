@@ -0,0 +1 @@
+DROP INDEX races_advertised_start_time;
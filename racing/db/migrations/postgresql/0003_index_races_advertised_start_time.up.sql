@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS races_advertised_start_time ON races (advertised_start_time);
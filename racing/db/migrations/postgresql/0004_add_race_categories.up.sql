@@ -0,0 +1,7 @@
+ALTER TABLE races ADD COLUMN category INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE races ADD COLUMN distance BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE races ADD COLUMN class TEXT NOT NULL DEFAULT '';
+ALTER TABLE races ADD COLUMN track_surface TEXT NOT NULL DEFAULT '';
+ALTER TABLE races ADD COLUMN prize_money BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE races ADD COLUMN field_size BIGINT NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS races_category ON races (category);
@@ -0,0 +1,2 @@
+CREATE TABLE IF NOT EXISTS scratchings (race_id BIGINT NOT NULL, runner_number BIGINT NOT NULL, deduction DOUBLE PRECISION NOT NULL DEFAULT 0, scratched_at TIMESTAMPTZ NOT NULL, unscratched_at TIMESTAMPTZ, updated_at TIMESTAMPTZ NOT NULL, PRIMARY KEY (race_id, runner_number));
+CREATE INDEX IF NOT EXISTS scratchings_updated_at ON scratchings (updated_at);
@@ -0,0 +1,6 @@
+DROP TABLE results;
+DROP INDEX runners_race_id;
+DROP TABLE runners;
+DROP INDEX races_external_id;
+ALTER TABLE races DROP COLUMN external_id;
+DROP TABLE meetings;
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS meetings (id BIGINT PRIMARY KEY, external_id TEXT NOT NULL UNIQUE, name TEXT NOT NULL, venue TEXT NOT NULL DEFAULT '', date DATE NOT NULL, category INTEGER NOT NULL DEFAULT 0);
+ALTER TABLE races ADD COLUMN external_id TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS races_external_id ON races (external_id);
+CREATE TABLE IF NOT EXISTS runners (id BIGINT PRIMARY KEY, external_id TEXT NOT NULL UNIQUE, race_id BIGINT NOT NULL, number BIGINT NOT NULL, name TEXT NOT NULL, barrier BIGINT NOT NULL DEFAULT 0, jockey TEXT NOT NULL DEFAULT '', trainer TEXT NOT NULL DEFAULT '', weight DOUBLE PRECISION NOT NULL DEFAULT 0, scratched BOOLEAN NOT NULL DEFAULT FALSE);
+CREATE INDEX IF NOT EXISTS runners_race_id ON runners (race_id);
+CREATE TABLE IF NOT EXISTS results (race_id BIGINT NOT NULL, runner_id BIGINT NOT NULL, position BIGINT NOT NULL, margin DOUBLE PRECISION NOT NULL DEFAULT 0, PRIMARY KEY (race_id, runner_id));
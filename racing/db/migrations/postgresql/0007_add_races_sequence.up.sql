@@ -0,0 +1 @@
+ALTER TABLE races ADD COLUMN sequence BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+ALTER TABLE runners ALTER COLUMN id DROP IDENTITY;
+ALTER TABLE races ALTER COLUMN id DROP IDENTITY;
+ALTER TABLE meetings ALTER COLUMN id DROP IDENTITY;
+DELETE FROM meetings WHERE external_id LIKE 'seed-%';
@@ -0,0 +1,13 @@
+-- The feeds insert meetings, races and runners without an id, so that PostgreSQL generates it.
+-- Seeded races have meeting ids without a meetings row: add one so that the generated meeting ids skip them.
+INSERT INTO meetings(id, external_id, name, venue, date, category)
+SELECT meeting_id, 'seed-' || meeting_id, '', '', (MIN(advertised_start_time) AT TIME ZONE 'UTC')::date, MIN(category)
+FROM races
+WHERE meeting_id IS NOT NULL AND meeting_id NOT IN (SELECT id FROM meetings)
+GROUP BY meeting_id;
+ALTER TABLE meetings ALTER COLUMN id ADD GENERATED BY DEFAULT AS IDENTITY;
+ALTER TABLE races ALTER COLUMN id ADD GENERATED BY DEFAULT AS IDENTITY;
+ALTER TABLE runners ALTER COLUMN id ADD GENERATED BY DEFAULT AS IDENTITY;
+SELECT setval(pg_get_serial_sequence('meetings', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM meetings;
+SELECT setval(pg_get_serial_sequence('races', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM races;
+SELECT setval(pg_get_serial_sequence('runners', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM runners;
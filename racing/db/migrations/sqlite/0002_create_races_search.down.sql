@@ -0,0 +1,4 @@
+DROP TRIGGER races_search_update;
+DROP TRIGGER races_search_delete;
+DROP TRIGGER races_search_insert;
+DROP TABLE races_search;
@@ -0,0 +1,12 @@
+CREATE VIRTUAL TABLE races_search USING fts5(name, content='races', content_rowid='id');
+CREATE TRIGGER races_search_insert AFTER INSERT ON races BEGIN
+  INSERT INTO races_search(rowid, name) VALUES (new.id, new.name);
+END;
+CREATE TRIGGER races_search_delete AFTER DELETE ON races BEGIN
+  INSERT INTO races_search(races_search, rowid, name) VALUES ('delete', old.id, old.name);
+END;
+CREATE TRIGGER races_search_update AFTER UPDATE ON races BEGIN
+  INSERT INTO races_search(races_search, rowid, name) VALUES ('delete', old.id, old.name);
+  INSERT INTO races_search(rowid, name) VALUES (new.id, new.name);
+END;
+INSERT INTO races_search(races_search) VALUES ('rebuild');
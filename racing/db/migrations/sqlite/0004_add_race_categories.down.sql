@@ -0,0 +1,7 @@
+DROP INDEX races_category;
+ALTER TABLE races DROP COLUMN field_size;
+ALTER TABLE races DROP COLUMN prize_money;
+ALTER TABLE races DROP COLUMN track_surface;
+ALTER TABLE races DROP COLUMN class;
+ALTER TABLE races DROP COLUMN distance;
+ALTER TABLE races DROP COLUMN category;
@@ -0,0 +1,2 @@
+DROP INDEX scratchings_updated_at;
+DROP TABLE scratchings;
@@ -0,0 +1,2 @@
+CREATE TABLE IF NOT EXISTS scratchings (race_id INTEGER NOT NULL, runner_number INTEGER NOT NULL, deduction REAL NOT NULL DEFAULT 0, scratched_at DATETIME NOT NULL, unscratched_at DATETIME, updated_at DATETIME NOT NULL, PRIMARY KEY (race_id, runner_number));
+CREATE INDEX IF NOT EXISTS scratchings_updated_at ON scratchings (updated_at);
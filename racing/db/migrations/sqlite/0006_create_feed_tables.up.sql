@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS meetings (id INTEGER PRIMARY KEY, external_id TEXT NOT NULL UNIQUE, name TEXT NOT NULL, venue TEXT NOT NULL DEFAULT '', date TEXT NOT NULL, category INTEGER NOT NULL DEFAULT 0);
+ALTER TABLE races ADD COLUMN external_id TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS races_external_id ON races (external_id);
+CREATE TABLE IF NOT EXISTS runners (id INTEGER PRIMARY KEY, external_id TEXT NOT NULL UNIQUE, race_id INTEGER NOT NULL, number INTEGER NOT NULL, name TEXT NOT NULL, barrier INTEGER NOT NULL DEFAULT 0, jockey TEXT NOT NULL DEFAULT '', trainer TEXT NOT NULL DEFAULT '', weight REAL NOT NULL DEFAULT 0, scratched INTEGER NOT NULL DEFAULT 0);
+CREATE INDEX IF NOT EXISTS runners_race_id ON runners (race_id);
+CREATE TABLE IF NOT EXISTS results (race_id INTEGER NOT NULL, runner_id INTEGER NOT NULL, position INTEGER NOT NULL, margin REAL NOT NULL DEFAULT 0, PRIMARY KEY (race_id, runner_id));
@@ -0,0 +1 @@
+ALTER TABLE races ADD COLUMN sequence INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+-- Rows stored before the repositories wrote UTC keep their original offset, e.g. +10:00, and would compare
+-- and sort wrongly against the UTC RFC3339 arguments of the queries.
+UPDATE races SET advertised_start_time = strftime('%Y-%m-%dT%H:%M:%SZ', advertised_start_time) WHERE advertised_start_time NOT LIKE '%Z';
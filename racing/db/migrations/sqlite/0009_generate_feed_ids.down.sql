@@ -0,0 +1 @@
+DELETE FROM meetings WHERE external_id LIKE 'seed-%';
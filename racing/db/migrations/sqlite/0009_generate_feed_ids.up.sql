@@ -0,0 +1,7 @@
+-- The feeds insert meetings, races and runners without an id, so that SQLite generates it from the highest one.
+-- Seeded races have meeting ids without a meetings row: add one so that the generated meeting ids skip them.
+INSERT INTO meetings(id, external_id, name, venue, date, category)
+SELECT meeting_id, 'seed-' || meeting_id, '', '', MIN(date(advertised_start_time)), MIN(category)
+FROM races
+WHERE meeting_id IS NOT NULL AND meeting_id NOT IN (SELECT id FROM meetings)
+GROUP BY meeting_id;
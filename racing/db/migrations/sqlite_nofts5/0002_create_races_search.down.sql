@@ -0,0 +1,2 @@
+-- SQLite built without FTS5 has no races_search index to drop.
+SELECT 1;
@@ -1,20 +1,68 @@
 package db
 
+import "git.neds.sh/matty/entain/pkg/dialect"
+
 const (
 	racesList = "list"
+
+	scratchingsList      = "list"
+	scratchingsScratch   = "scratch"
+	scratchingsUnscratch = "unscratch"
 )
 
 func getRaceQueries() map[string]string {
 	return map[string]string{
 		racesList: `
-			SELECT 
-				id, 
-				meeting_id, 
-				name, 
-				number, 
-				visible, 
-				advertised_start_time 
+			SELECT %s
+			FROM races
+		`,
+	}
+}
+
+// getRaceSearchQueries returns the query ranking the races matching a search, by dialect name.
+// The columns of the races are followed by the score, higher being more relevant, and the highlighted name.
+func getRaceSearchQueries() map[string]string {
+	return map[string]string{
+		dialect.SQLite.Name(): `
+			SELECT %s, -bm25(races_search) AS score, highlight(races_search, 0, ?, ?)
+			FROM races_search
+			JOIN races ON races.id = races_search.rowid
+			WHERE races_search MATCH ?
+			ORDER BY score DESC, races.id
+			LIMIT ?
+		`,
+		dialect.Postgres.Name(): `
+			SELECT %s, ts_rank(to_tsvector('simple', races.name), to_tsquery('simple', ?), 1) AS score,
+				ts_headline('simple', races.name, to_tsquery('simple', ?), ?)
 			FROM races
+			WHERE to_tsvector('simple', races.name) @@ to_tsquery('simple', ?)
+			ORDER BY score DESC, races.id
+			LIMIT ?
+		`,
+	}
+}
+
+func getScratchingQueries() map[string]string {
+	return map[string]string{
+		scratchingsList: `
+			SELECT scratchings.race_id, races.meeting_id, scratchings.runner_number, scratchings.deduction,
+				scratchings.scratched_at, scratchings.unscratched_at, scratchings.updated_at
+			FROM scratchings
+			JOIN races ON races.id = scratchings.race_id
+		`,
+		// A scratching is only replaced once its runner has been reinstated.
+		scratchingsScratch: `
+			INSERT INTO scratchings(race_id, runner_number, deduction, scratched_at, unscratched_at, updated_at)
+			VALUES (?, ?, ?, ?, NULL, ?)
+			ON CONFLICT (race_id, runner_number) DO UPDATE
+			SET deduction = excluded.deduction, scratched_at = excluded.scratched_at, unscratched_at = NULL,
+				updated_at = excluded.updated_at
+			WHERE scratchings.unscratched_at IS NOT NULL
+		`,
+		scratchingsUnscratch: `
+			UPDATE scratchings
+			SET unscratched_at = ?, updated_at = ?
+			WHERE race_id = ? AND runner_number = ? AND unscratched_at IS NULL
 		`,
 	}
 }
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"reflect"
@@ -9,112 +10,258 @@ import (
 	"time"
 
 	"github.com/golang/protobuf/ptypes"
-	_ "github.com/mattn/go-sqlite3"
-
+	"go.opentelemetry.io/otel"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+
+	"git.neds.sh/matty/entain/pkg/clock"
+	"git.neds.sh/matty/entain/pkg/dialect"
+	"git.neds.sh/matty/entain/pkg/fieldmask"
+	"git.neds.sh/matty/entain/pkg/search"
+	"git.neds.sh/matty/entain/pkg/timewindow"
+	"git.neds.sh/matty/entain/pkg/tracing"
 	"git.neds.sh/matty/entain/racing/proto/racing"
 )
 
+var tracer = otel.Tracer("git.neds.sh/matty/entain/racing/db")
+
+// raceColumns are the columns of the races table, named like the Race fields they hold.
+var raceColumns = []string{
+	"id", "meeting_id", "name", "number", "visible", "advertised_start_time",
+	"category", "distance", "class", "track_surface", "prize_money", "field_size", "sequence",
+}
+
 // RacesRepo provides repository access to races.
 type RacesRepo interface {
 	// Init will initialise our races repository.
-	Init() error
+	Init(ctx context.Context) error
+
+	// List will return a list of races, with only the fields of the mask when it is set.
+	List(ctx context.Context, filter *racing.ListRacesRequestFilter, orderBy string, mask *fieldmaskpb.FieldMask) ([]*racing.Race, error)
+
+	// GetRaceByID will return the information of a given race id, with only the fields of the mask when it is set.
+	GetRaceByID(ctx context.Context, id int64, mask *fieldmaskpb.FieldMask) (*racing.Race, error)
+
+	// GetRacesByIDs will return the races of the given ids in a single query, in no particular order.
+	// Ids without a race are left out.
+	GetRacesByIDs(ctx context.Context, ids []int64) ([]*racing.Race, error)
+
+	// Search will return at most limit races whose name has a word starting with each word of the query,
+	// most relevant first.
+	Search(ctx context.Context, query string, limit int) ([]*racing.SearchResult, error)
+
+	// Scratch withdraws a runner from a race at the given time, now when zero, with the deduction percentage,
+	// and returns its scratching. It fails with ErrRaceNotFound, ErrRunnerNotFound or ErrAlreadyScratched.
+	Scratch(ctx context.Context, raceID, runnerNumber int64, deduction float64, at time.Time) (*racing.Scratching, error)
 
-	// List will return a list of races.
-	List(filter *racing.ListRacesRequestFilter, orderBy string) ([]*racing.Race, error)
+	// Unscratch reinstates a scratched runner at the given time, now when zero, and returns its scratching.
+	// It fails with ErrNotScratched when the runner is not scratched.
+	Unscratch(ctx context.Context, raceID, runnerNumber int64, at time.Time) (*racing.Scratching, error)
 
-	// GetRaceByID will return the information of a given race id.
-	GetRaceByID(id int64) (*racing.Race, error)
+	// ListScratchings will return the scratchings matching the filter, by time of their last change.
+	ListScratchings(ctx context.Context, filter *racing.ListScratchingsRequestFilter) ([]*racing.Scratching, error)
 }
 
 type racesRepo struct {
-	db   *sql.DB
-	init sync.Once
+	db      *sql.DB
+	dialect dialect.Dialect
+	clock   clock.Clock
+	seed    *SeedOptions
+	init    sync.Once
 }
 
-// NewRacesRepo creates a new races repository.
-func NewRacesRepo(db *sql.DB) RacesRepo {
-	return &racesRepo{db: db}
+// NewRacesRepo creates a new races repository on a database of the given dialect, computing the race statuses
+// with clk unless the request context carries a simulated time. It inserts dummy races on Init when seed is set.
+func NewRacesRepo(db *sql.DB, d dialect.Dialect, clk clock.Clock, seed *SeedOptions) RacesRepo {
+	return &racesRepo{db: db, dialect: d, clock: clk, seed: seed}
 }
 
-// Init prepares the race repository dummy data.
-func (r *racesRepo) Init() error {
+// Init prepares the race repository dummy data. The schema is created by the migrations.
+func (r *racesRepo) Init(ctx context.Context) error {
 	var err error
 
 	r.init.Do(func() {
+		if r.seed == nil {
+			return
+		}
+
 		// For test/example purposes, we seed the DB with some dummy races.
-		err = r.seed()
+		err = r.seedRaces(ctx)
 	})
 
 	return err
 }
 
 // GetRaceById will return the information of a given race id.
-func (r *racesRepo) GetRaceByID(id int64) (*racing.Race, error) {
-	var race racing.Race
-	var advertisedStart time.Time
+func (r *racesRepo) GetRaceByID(ctx context.Context, id int64, mask *fieldmaskpb.FieldMask) (_ *racing.Race, err error) {
+	paths, err := fieldmask.Paths(mask, &racing.Race{})
+	if err != nil {
+		return nil, err
+	}
+
+	columns := selectColumns(paths)
 
-	row := r.db.QueryRow(`SELECT id, 
-	meeting_id, 
-	name, 
-	number, 
-	visible, 
-	advertised_start_time 
-	FROM races where id=?`, id)
+	query := fmt.Sprintf(getRaceQueries()[racesList], strings.Join(columns, ", ")) + " WHERE id = ?"
 
-	if err := row.Scan(&race.Id, &race.MeetingId, &race.Name, &race.Number, &race.Visible, &advertisedStart); err != nil {
+	ctx, span := tracing.StartQuerySpan(ctx, tracer, r.dialect.Name(), "racesRepo.GetRaceByID", query)
+	defer func() { tracing.EndSpan(span, err) }()
+
+	row := r.db.QueryRowContext(ctx, r.dialect.Rebind(query), id)
+
+	race, err := scanRace(row.Scan, columns, paths, clock.FromContext(ctx, r.clock).Now())
+	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("race not found")
+			return nil, ErrRaceNotFound
 		}
 
 		return nil, err
 	}
 
-	ts, err := ptypes.TimestampProto(advertisedStart)
+	return race, nil
+}
+
+// GetRacesByIDs will return the races of the given ids in a single query, in no particular order.
+func (r *racesRepo) GetRacesByIDs(ctx context.Context, ids []int64) (_ []*racing.Race, err error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	query := fmt.Sprintf(getRaceQueries()[racesList], strings.Join(raceColumns, ", ")) +
+		" WHERE id IN (" + strings.Repeat("?,", len(ids)-1) + "?)"
+
+	args := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	ctx, span := tracing.StartQuerySpan(ctx, tracer, r.dialect.Name(), "racesRepo.GetRacesByIDs", query)
+	defer func() { tracing.EndSpan(span, err) }()
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.Rebind(query), args...)
 	if err != nil {
 		return nil, err
 	}
 
-	race.AdvertisedStartTime = ts
+	return r.scanRaces(rows, raceColumns, nil, clock.FromContext(ctx, r.clock).Now())
+}
 
-	race.Status = getRaceStatus(advertisedStart)
+// Search will return the races matching the query from the full-text index of their names, most relevant first.
+// SQLite builds without FTS5 have no index and fail with search.ErrNoFTS5.
+func (r *racesRepo) Search(ctx context.Context, query string, limit int) (_ []*racing.SearchResult, err error) {
+	if r.dialect.Name() == dialect.SQLite.Name() && !dialect.FTS5 {
+		return nil, search.ErrNoFTS5
+	}
 
-	return &race, nil
+	terms, err := search.Terms(query)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]string, 0, len(raceColumns))
+	for _, column := range raceColumns {
+		columns = append(columns, "races."+column)
+	}
+
+	statement := fmt.Sprintf(getRaceSearchQueries()[r.dialect.Name()], strings.Join(columns, ", "))
+
+	var args []interface{}
+	if r.dialect.Name() == dialect.Postgres.Name() {
+		tsQuery := search.TSQuery(terms)
+		headline := "StartSel=" + search.HighlightStart + ", StopSel=" + search.HighlightEnd + ", HighlightAll=true"
+		args = []interface{}{tsQuery, tsQuery, headline, tsQuery, limit}
+	} else {
+		args = []interface{}{search.HighlightStart, search.HighlightEnd, search.FTS5Query(terms), limit}
+	}
+
+	ctx, span := tracing.StartQuerySpan(ctx, tracer, r.dialect.Name(), "racesRepo.Search", statement)
+	defer func() { tracing.EndSpan(span, err) }()
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.Rebind(statement), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := rows.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	now := clock.FromContext(ctx, r.clock).Now()
+
+	var results []*racing.SearchResult
+
+	for rows.Next() {
+		result := &racing.SearchResult{}
+
+		scan := func(dest ...interface{}) error {
+			return rows.Scan(append(dest, &result.Score, &result.Snippet)...)
+		}
+
+		if result.Race, err = scanRace(scan, raceColumns, nil, now); err != nil {
+			return nil, err
+		}
+		result.Snippet = search.Snippet(result.Snippet)
+
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
 }
 
 // List performs the requested parameters and returns the final list of races.
-func (r *racesRepo) List(filter *racing.ListRacesRequestFilter, orderBy string) ([]*racing.Race, error) {
+func (r *racesRepo) List(ctx context.Context, filter *racing.ListRacesRequestFilter, orderBy string, mask *fieldmaskpb.FieldMask) (_ []*racing.Race, err error) {
 	var (
-		err   error
 		query string
 		args  []interface{}
 	)
 
-	query = getRaceQueries()[racesList]
+	paths, err := fieldmask.Paths(mask, &racing.Race{})
+	if err != nil {
+		return nil, err
+	}
+
+	columns := selectColumns(paths)
 
-	query, args = r.applyFilter(query, filter)
+	query = fmt.Sprintf(getRaceQueries()[racesList], strings.Join(columns, ", "))
+
+	query, args, err = r.applyFilter(query, filter)
+	if err != nil {
+		return nil, err
+	}
 
 	query, err = r.applyOrderBy(query, orderBy)
 	if err != nil {
 		return nil, err
 	}
 
-	rows, err := r.db.Query(query, args...)
+	ctx, span := tracing.StartQuerySpan(ctx, tracer, r.dialect.Name(), "racesRepo.List", query)
+	defer func() { tracing.EndSpan(span, err) }()
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.Rebind(query), args...)
 	if err != nil {
 		return nil, err
 	}
 
-	return r.scanRaces(rows)
+	return r.scanRaces(rows, columns, paths, clock.FromContext(ctx, r.clock).Now())
 }
 
 // applyFilter processes the filters included in the request.
-func (r *racesRepo) applyFilter(query string, filter *racing.ListRacesRequestFilter) (string, []interface{}) {
+func (r *racesRepo) applyFilter(query string, filter *racing.ListRacesRequestFilter) (string, []interface{}, error) {
 	var (
 		clauses []string
 		args    []interface{}
 	)
 
 	if filter == nil {
-		return query, args
+		return query, args, nil
+	}
+
+	window, err := timewindow.New(filter.StartTimeFrom, filter.StartTimeTo, filter.LocalDate, filter.TimeZone)
+	if err != nil {
+		return "", nil, err
 	}
 
 	if len(filter.MeetingIds) > 0 {
@@ -126,26 +273,58 @@ func (r *racesRepo) applyFilter(query string, filter *racing.ListRacesRequestFil
 	}
 
 	if filter.Visible != nil {
-		// Handle the boolean implementation for SQLite3
-		condition := "NOT visible"
-		if *filter.Visible {
-			condition = "visible"
+		clauses = append(clauses, "visible = ?")
+		args = append(args, *filter.Visible)
+	}
+
+	if len(filter.Categories) > 0 {
+		clauses = append(clauses, "category IN ("+strings.Repeat("?,", len(filter.Categories)-1)+"?)")
+
+		for _, category := range filter.Categories {
+			args = append(args, int32(category))
 		}
-		clauses = append(clauses, condition)
+	}
+
+	if !window.From.IsZero() {
+		clauses = append(clauses, "advertised_start_time >= ?")
+		args = append(args, r.dialect.Time(window.From))
+	}
+
+	if !window.To.IsZero() {
+		clauses = append(clauses, "advertised_start_time < ?")
+		args = append(args, r.dialect.Time(window.To))
 	}
 
 	if len(clauses) != 0 {
 		query += " WHERE " + strings.Join(clauses, " AND ")
 	}
 
-	return query, args
+	return query, args, nil
 }
 
 // applyOrderBy adds the ORDER BY clause in the query based on the requested sorting option.
 func (r *racesRepo) applyOrderBy(query string, orderBy string) (string, error) {
+	column, order, err := parseOrderBy(orderBy)
+	if err != nil || len(column) == 0 {
+		return query, err
+	}
+
+	clause := column
+	if len(order) != 0 {
+		clause += " " + order
+	}
+
+	query += " ORDER BY " + clause
+
+	return query, nil
+}
+
+// parseOrderBy splits the requested sorting option into a column name and an optional ASC or DESC order.
+// The column is empty when no sorting was requested.
+func parseOrderBy(orderBy string) (column, order string, err error) {
 	// Return immediately when orderBy is empty.
 	if len(orderBy) == 0 {
-		return query, nil
+		return "", "", nil
 	}
 
 	orderBy = strings.TrimSpace(orderBy)
@@ -157,40 +336,117 @@ func (r *racesRepo) applyOrderBy(query string, orderBy string) (string, error) {
 
 	// Return invalid value error if the length of params is more than 2. Field name and asc/desc are the only values accepted.
 	if paramsLength > 2 {
-		return query, fmt.Errorf("invalid orderBy value: %s Format is `fieldName desc`", orderBy)
+		return "", "", fmt.Errorf("invalid orderBy value: %s Format is `fieldName desc`", orderBy)
 	}
 
 	// Get the corresponding column name.
-	clause := getRaceColumnName(fieldName)
+	column = getRaceColumnName(fieldName)
 
-	if len(clause) == 0 {
-		return query, fmt.Errorf("unable to find the field name: %s", fieldName)
+	if len(column) == 0 {
+		return "", "", fmt.Errorf("unable to find the field name: %s", fieldName)
 	}
 
 	// Check if asc or desc is provided.
 	if paramsLength == 2 {
-		order := strings.ToUpper(params[1])
+		order = strings.ToUpper(params[1])
 		if order != "ASC" && order != "DESC" {
-			return query, fmt.Errorf("invalid sort order: %s. Choose either `asc` or `desc`", params[1])
+			return "", "", fmt.Errorf("invalid sort order: %s. Choose either `asc` or `desc`", params[1])
 		}
-		clause += " " + order
 	}
 
-	query += " ORDER BY " + clause
+	return column, order, nil
+}
 
-	return query, nil
+// selectColumns returns the columns holding the fields of the paths, along with the advertised start time
+// when the status is computed from it.
+func selectColumns(paths []string) []string {
+	var columns []string
+
+	for _, column := range raceColumns {
+		if fieldmask.Selects(paths, column) || column == "advertised_start_time" && fieldmask.Selects(paths, "status") {
+			columns = append(columns, column)
+		}
+	}
+
+	return columns
+}
+
+// scanRace copies the columns of a row into a race with its status at the given time, keeping only the fields
+// of the paths.
+func scanRace(scan func(dest ...interface{}) error, columns []string, paths []string, now time.Time) (*racing.Race, error) {
+	var race racing.Race
+	var advertisedStart time.Time
+
+	dest := make([]interface{}, len(columns))
+	for i, column := range columns {
+		switch column {
+		case "id":
+			dest[i] = &race.Id
+		case "meeting_id":
+			dest[i] = &race.MeetingId
+		case "name":
+			dest[i] = &race.Name
+		case "number":
+			dest[i] = &race.Number
+		case "visible":
+			dest[i] = &race.Visible
+		case "advertised_start_time":
+			dest[i] = &advertisedStart
+		case "category":
+			dest[i] = &race.Category
+		case "distance":
+			dest[i] = &race.Distance
+		case "class":
+			dest[i] = &race.Class
+		case "track_surface":
+			dest[i] = &race.TrackSurface
+		case "prize_money":
+			dest[i] = &race.PrizeMoney
+		case "field_size":
+			dest[i] = &race.FieldSize
+		case "sequence":
+			dest[i] = &race.Sequence
+		}
+	}
+
+	if err := scan(dest...); err != nil {
+		return nil, err
+	}
+
+	if fieldmask.Selects(paths, "advertised_start_time") {
+		ts, err := ptypes.TimestampProto(advertisedStart)
+		if err != nil {
+			return nil, err
+		}
+
+		race.AdvertisedStartTime = ts
+	}
+
+	if fieldmask.Selects(paths, "status") {
+		race.Status = getRaceStatus(advertisedStart, now)
+	}
+
+	return &race, nil
 }
 
+// scanRaces copies the rows into races and closes them, returning any error met while iterating.
 func (r *racesRepo) scanRaces(
 	rows *sql.Rows,
-) ([]*racing.Race, error) {
+	columns []string,
+	paths []string,
+	now time.Time,
+) (_ []*racing.Race, err error) {
+	defer func() {
+		if closeErr := rows.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
 	var races []*racing.Race
 
 	for rows.Next() {
-		var race racing.Race
-		var advertisedStart time.Time
-
-		if err := rows.Scan(&race.Id, &race.MeetingId, &race.Name, &race.Number, &race.Visible, &advertisedStart); err != nil {
+		race, err := scanRace(rows.Scan, columns, paths, now)
+		if err != nil {
 			if err == sql.ErrNoRows {
 				return nil, nil
 			}
@@ -198,16 +454,11 @@ func (r *racesRepo) scanRaces(
 			return nil, err
 		}
 
-		ts, err := ptypes.TimestampProto(advertisedStart)
-		if err != nil {
-			return nil, err
-		}
-
-		race.AdvertisedStartTime = ts
-
-		race.Status = getRaceStatus(advertisedStart)
+		races = append(races, race)
+	}
 
-		races = append(races, &race)
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
 	return races, nil
@@ -232,11 +483,11 @@ func getRaceColumnName(value string) string {
 	return columnName
 }
 
-// getRaceStatus gets the correct status of the race: OPEN for future race and CLOSED for past race.
-func getRaceStatus(advertisedStart time.Time) racing.RaceStatus {
+// getRaceStatus gets the correct status of the race at the given time: OPEN for future race and CLOSED for past race.
+func getRaceStatus(advertisedStart, now time.Time) racing.RaceStatus {
 	status := racing.RaceStatus_CLOSED
 
-	if advertisedStart.After(time.Now()) {
+	if advertisedStart.After(now) {
 		status = racing.RaceStatus_OPEN
 	}
 
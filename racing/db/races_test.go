@@ -0,0 +1,44 @@
+package db_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"git.neds.sh/matty/entain/pkg/dialect"
+	"git.neds.sh/matty/entain/racing/db/dbtest"
+)
+
+// postgresDSNEnv names the variable holding the DSN of a PostgreSQL database the conformance suite may wipe.
+const postgresDSNEnv = "RACING_TEST_POSTGRES_DSN"
+
+func TestRacesRepoSQLite(t *testing.T) {
+	testRacesRepoSQL(t, filepath.Join(t.TempDir(), "racing.db"))
+}
+
+func TestRacesRepoPostgres(t *testing.T) {
+	dsn := os.Getenv(postgresDSNEnv)
+	if len(dsn) == 0 {
+		t.Skipf("%s is not set", postgresDSNEnv)
+	}
+
+	testRacesRepoSQL(t, dsn)
+}
+
+// testRacesRepoSQL runs the conformance suite against the SQL repository and the feed store on the database of the DSN.
+func testRacesRepoSQL(t *testing.T, dsn string) {
+	sqlDB, d, err := dialect.Open(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := dbtest.TestRacesRepo(context.Background(), dbtest.SQL(sqlDB, d)); err != nil {
+		t.Error(err)
+	}
+
+	if err := dbtest.TestFeedStore(context.Background(), sqlDB, d); err != nil {
+		t.Error(err)
+	}
+}
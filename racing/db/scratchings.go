@@ -0,0 +1,293 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"git.neds.sh/matty/entain/pkg/clock"
+	"git.neds.sh/matty/entain/pkg/dialect"
+	"git.neds.sh/matty/entain/pkg/timewindow"
+	"git.neds.sh/matty/entain/pkg/tracing"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+var (
+	// ErrRaceNotFound is returned for a race id without a race.
+	ErrRaceNotFound = errors.New("race not found")
+	// ErrRunnerNotFound is returned for a runner number outside of the field of the race.
+	ErrRunnerNotFound = errors.New("runner not found")
+	// ErrAlreadyScratched is returned when scratching a runner that is already scratched.
+	ErrAlreadyScratched = errors.New("runner already scratched")
+	// ErrNotScratched is returned when reinstating a runner that is not scratched.
+	ErrNotScratched = errors.New("runner not scratched")
+)
+
+// Scratch withdraws a runner from a race in a single transaction. Times are stored to the second.
+func (r *racesRepo) Scratch(ctx context.Context, raceID, runnerNumber int64, deduction float64, at time.Time) (_ *racing.Scratching, err error) {
+	at = scratchingTime(ctx, r.clock, at)
+
+	ctx, span := tracing.StartQuerySpan(ctx, tracer, r.dialect.Name(), "racesRepo.Scratch", getScratchingQueries()[scratchingsScratch])
+	defer func() { tracing.EndSpan(span, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	scratching, err := scratch(ctx, tx, r.dialect, raceID, runnerNumber, deduction, at)
+	if err != nil {
+		return nil, err
+	}
+
+	return scratching, tx.Commit()
+}
+
+// Unscratch reinstates a scratched runner in a single transaction. Times are stored to the second.
+func (r *racesRepo) Unscratch(ctx context.Context, raceID, runnerNumber int64, at time.Time) (_ *racing.Scratching, err error) {
+	at = scratchingTime(ctx, r.clock, at)
+
+	ctx, span := tracing.StartQuerySpan(ctx, tracer, r.dialect.Name(), "racesRepo.Unscratch", getScratchingQueries()[scratchingsUnscratch])
+	defer func() { tracing.EndSpan(span, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	scratching, err := unscratch(ctx, tx, r.dialect, raceID, runnerNumber, at)
+	if err != nil {
+		return nil, err
+	}
+
+	return scratching, tx.Commit()
+}
+
+// scratch withdraws a runner from a race within the transaction, for the repository and the feeds alike, and
+// flags the runner of the feeds, if any, as scratched.
+func scratch(ctx context.Context, tx *sql.Tx, d dialect.Dialect, raceID, runnerNumber int64, deduction float64, at time.Time) (*racing.Scratching, error) {
+	var fieldSize int64
+	row := tx.QueryRowContext(ctx, d.Rebind(`SELECT field_size FROM races WHERE id = ?`), raceID)
+	if err := row.Scan(&fieldSize); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrRaceNotFound
+		}
+
+		return nil, err
+	}
+
+	if !inField(runnerNumber, fieldSize) {
+		return nil, ErrRunnerNotFound
+	}
+
+	result, err := tx.ExecContext(ctx, d.Rebind(getScratchingQueries()[scratchingsScratch]),
+		raceID, runnerNumber, deduction, d.Time(at), d.Time(at))
+	if err != nil {
+		return nil, err
+	}
+
+	// The insert leaves the row untouched when the runner is still scratched.
+	if affected, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if affected == 0 {
+		return nil, ErrAlreadyScratched
+	}
+
+	if err := flagRunner(ctx, tx, d, raceID, runnerNumber, true); err != nil {
+		return nil, err
+	}
+
+	return getScratching(ctx, tx, d, raceID, runnerNumber)
+}
+
+// unscratch reinstates a scratched runner within the transaction, for the repository and the feeds alike, and
+// clears the scratched flag of the runner of the feeds, if any.
+func unscratch(ctx context.Context, tx *sql.Tx, d dialect.Dialect, raceID, runnerNumber int64, at time.Time) (*racing.Scratching, error) {
+	result, err := tx.ExecContext(ctx, d.Rebind(getScratchingQueries()[scratchingsUnscratch]), d.Time(at), d.Time(at), raceID, runnerNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	if affected, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if affected == 0 {
+		return nil, ErrNotScratched
+	}
+
+	if err := flagRunner(ctx, tx, d, raceID, runnerNumber, false); err != nil {
+		return nil, err
+	}
+
+	return getScratching(ctx, tx, d, raceID, runnerNumber)
+}
+
+// flagRunner keeps the scratched flag of the runners ingested from the feeds in line with the scratchings.
+func flagRunner(ctx context.Context, tx *sql.Tx, d dialect.Dialect, raceID, runnerNumber int64, scratched bool) error {
+	_, err := tx.ExecContext(ctx, d.Rebind(`UPDATE runners SET scratched = ? WHERE race_id = ? AND number = ?`), scratched, raceID, runnerNumber)
+
+	return err
+}
+
+// ListScratchings will return the scratchings matching the filter, by time of their last change.
+func (r *racesRepo) ListScratchings(ctx context.Context, filter *racing.ListScratchingsRequestFilter) (_ []*racing.Scratching, err error) {
+	query, args, err := r.applyScratchingFilter(getScratchingQueries()[scratchingsList], filter)
+	if err != nil {
+		return nil, err
+	}
+
+	query += " ORDER BY scratchings.updated_at, scratchings.race_id, scratchings.runner_number"
+
+	ctx, span := tracing.StartQuerySpan(ctx, tracer, r.dialect.Name(), "racesRepo.ListScratchings", query)
+	defer func() { tracing.EndSpan(span, err) }()
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := rows.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	var scratchings []*racing.Scratching
+
+	for rows.Next() {
+		scratching, err := scanScratching(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+
+		scratchings = append(scratchings, scratching)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return scratchings, nil
+}
+
+// applyScratchingFilter processes the filters of a ListScratchings request.
+func (r *racesRepo) applyScratchingFilter(query string, filter *racing.ListScratchingsRequestFilter) (string, []interface{}, error) {
+	var (
+		clauses []string
+		args    []interface{}
+	)
+
+	if filter == nil {
+		return query, args, nil
+	}
+
+	window, err := timewindow.New(filter.ChangedFrom, filter.ChangedTo, "", "")
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(filter.MeetingIds) > 0 {
+		clauses = append(clauses, "races.meeting_id IN ("+strings.Repeat("?,", len(filter.MeetingIds)-1)+"?)")
+
+		for _, meetingID := range filter.MeetingIds {
+			args = append(args, meetingID)
+		}
+	}
+
+	if len(filter.RaceIds) > 0 {
+		clauses = append(clauses, "scratchings.race_id IN ("+strings.Repeat("?,", len(filter.RaceIds)-1)+"?)")
+
+		for _, raceID := range filter.RaceIds {
+			args = append(args, raceID)
+		}
+	}
+
+	if filter.Scratched != nil {
+		if *filter.Scratched {
+			clauses = append(clauses, "scratchings.unscratched_at IS NULL")
+		} else {
+			clauses = append(clauses, "scratchings.unscratched_at IS NOT NULL")
+		}
+	}
+
+	if !window.From.IsZero() {
+		clauses = append(clauses, "scratchings.updated_at >= ?")
+		args = append(args, r.dialect.Time(window.From))
+	}
+
+	if !window.To.IsZero() {
+		clauses = append(clauses, "scratchings.updated_at < ?")
+		args = append(args, r.dialect.Time(window.To))
+	}
+
+	if len(clauses) != 0 {
+		query += " WHERE " + strings.Join(clauses, " AND ")
+	}
+
+	return query, args, nil
+}
+
+// getScratching reads back the scratching of a runner within the transaction that changed it.
+func getScratching(ctx context.Context, tx *sql.Tx, d dialect.Dialect, raceID, runnerNumber int64) (*racing.Scratching, error) {
+	query := getScratchingQueries()[scratchingsList] + " WHERE scratchings.race_id = ? AND scratchings.runner_number = ?"
+
+	scratching, err := scanScratching(tx.QueryRowContext(ctx, d.Rebind(query), raceID, runnerNumber).Scan)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading scratching: %w", err)
+	}
+
+	return scratching, nil
+}
+
+// scanScratching copies the columns of the scratchingsList query into a scratching.
+func scanScratching(scan func(dest ...interface{}) error) (*racing.Scratching, error) {
+	var (
+		scratching             racing.Scratching
+		scratchedAt, updatedAt time.Time
+		unscratchedAt          sql.NullTime
+	)
+
+	if err := scan(&scratching.RaceId, &scratching.MeetingId, &scratching.RunnerNumber, &scratching.Deduction,
+		&scratchedAt, &unscratchedAt, &updatedAt); err != nil {
+		return nil, err
+	}
+
+	scratching.Scratched = !unscratchedAt.Valid
+	scratching.ScratchedAt = timestamppb.New(scratchedAt)
+	scratching.UpdatedAt = timestamppb.New(updatedAt)
+
+	if unscratchedAt.Valid {
+		scratching.UnscratchedAt = timestamppb.New(unscratchedAt.Time)
+	}
+
+	return &scratching, nil
+}
+
+// scratchingTime returns the time a runner is scratched or reinstated at, now when at is zero, to the second
+// as stored by the databases.
+func scratchingTime(ctx context.Context, clk clock.Clock, at time.Time) time.Time {
+	if at.IsZero() {
+		at = clock.FromContext(ctx, clk).Now()
+	}
+
+	return at.UTC().Truncate(time.Second)
+}
+
+// inField reports whether a runner number is in the field of a race. Any positive number is, when the field size
+// is not known.
+func inField(runnerNumber, fieldSize int64) bool {
+	return runnerNumber >= 1 && (fieldSize == 0 || runnerNumber <= fieldSize)
+}
@@ -0,0 +1,69 @@
+package db_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"git.neds.sh/matty/entain/pkg/clock"
+	"git.neds.sh/matty/entain/pkg/dialect"
+	"git.neds.sh/matty/entain/racing/db/dbtest"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+func TestRaceStatus(t *testing.T) {
+	start := dbtest.Now
+
+	cases := []struct {
+		name string
+		now  time.Time
+		want racing.RaceStatus
+	}{
+		{name: "a minute before the start", now: start.Add(-time.Minute), want: racing.RaceStatus_OPEN},
+		{name: "a second before the start", now: start.Add(-time.Second), want: racing.RaceStatus_OPEN},
+		{name: "at the start", now: start, want: racing.RaceStatus_CLOSED},
+		{name: "a second after the start", now: start.Add(time.Second), want: racing.RaceStatus_CLOSED},
+	}
+
+	for name, newRepo := range newRepos(t) {
+		for _, c := range cases {
+			t.Run(name+"/"+c.name, func(t *testing.T) {
+				ctx := context.Background()
+
+				race := &racing.Race{Id: 1, MeetingId: 1, Name: "Alpha", Number: 1, Visible: true, AdvertisedStartTime: timestamppb.New(start)}
+
+				repo, err := newRepo(ctx, clock.Fixed(c.now), []*racing.Race{race})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				got, err := repo.GetRaceByID(ctx, race.Id, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if got.Status != c.want {
+					t.Errorf("status at %s = %s, want %s", c.now.Format(time.RFC3339), got.Status, c.want)
+				}
+			})
+		}
+	}
+}
+
+// newRepos returns the repositories under test by name: the in-memory one and SQLite.
+func newRepos(t *testing.T) map[string]dbtest.NewRepo {
+	repos := map[string]dbtest.NewRepo{"memory": dbtest.Memory()}
+
+	sqlDB, d, err := dialect.Open(filepath.Join(t.TempDir(), "racing.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	repos["sqlite"] = dbtest.SQL(sqlDB, d)
+
+	return repos
+}
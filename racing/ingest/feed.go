@@ -0,0 +1,148 @@
+// Package ingest loads the official meetings, races, runners and results from vendor feed files into the racing
+// database.
+//
+// A feed is a JSON or XML file holding lists of records which reference each other by their external ids, the ids
+// given by the vendor. A record may reference a record of the same file or of a file ingested before. Ingesting
+// a record inserts it, or updates the record with the same external id, so that a file can be ingested any number
+// of times. Invalid records are rejected and reported without stopping the other records.
+//
+// In JSON:
+//
+//	{
+//	  "meetings": [{"id": "FLM-20240501", "name": "Flemington", "venue": "Flemington Racecourse",
+//	                "date": "2024-05-01", "category": "thoroughbred"}],
+//	  "races":    [{"id": "FLM-20240501-R1", "meeting": "FLM-20240501", "name": "Maiden Plate", "number": 1,
+//	                "start_time": "2024-05-01T02:30:00Z", "distance": 1200, "class": "Maiden",
+//	                "track_surface": "Turf", "prize_money": 3500000, "visible": true}],
+//	  "runners":  [{"id": "FLM-20240501-R1-1", "race": "FLM-20240501-R1", "number": 1, "name": "Fast Lane",
+//	                "barrier": 4, "jockey": "J. Smith", "trainer": "T. Jones", "weight": 58.5, "scratched": false}],
+//	  "results":  [{"race": "FLM-20240501-R1", "runner": "FLM-20240501-R1-1", "position": 1, "margin": 0}]
+//	}
+//
+// In XML, the ids and references are attributes and the other fields elements of the same names:
+//
+//	<feed>
+//	  <meeting id="FLM-20240501"><name>Flemington</name><date>2024-05-01</date>...</meeting>
+//	  <race id="FLM-20240501-R1" meeting="FLM-20240501"><name>Maiden Plate</name><number>1</number>...</race>
+//	  <runner id="FLM-20240501-R1-1" race="FLM-20240501-R1"><number>1</number>...</runner>
+//	  <result race="FLM-20240501-R1" runner="FLM-20240501-R1-1"><position>1</position></result>
+//	</feed>
+//
+// Dates are YYYY-MM-DD, start times RFC3339, distances in metres, prize money in cents, weights in kilograms and
+// margins in lengths. The category is thoroughbred, harness or greyhound, and visible defaults to true.
+package ingest
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Format is the encoding of a feed file.
+type Format string
+
+const (
+	// JSON feeds have the .json extension.
+	JSON Format = "json"
+	// XML feeds have the .xml extension.
+	XML Format = "xml"
+)
+
+// FormatOf returns the format of a feed file from its extension.
+func FormatOf(path string) (Format, error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		return JSON, nil
+	case ".xml":
+		return XML, nil
+	default:
+		return "", fmt.Errorf("unsupported feed file extension %q: use .json or .xml", ext)
+	}
+}
+
+// Feed is the content of a feed file.
+type Feed struct {
+	XMLName  xml.Name  `json:"-" xml:"feed"`
+	Meetings []Meeting `json:"meetings" xml:"meeting"`
+	Races    []Race    `json:"races" xml:"race"`
+	Runners  []Runner  `json:"runners" xml:"runner"`
+	Results  []Result  `json:"results" xml:"result"`
+}
+
+// Meeting is a race meeting, whose races all run under its category.
+type Meeting struct {
+	ID       string `json:"id" xml:"id,attr"`
+	Name     string `json:"name" xml:"name"`
+	Venue    string `json:"venue" xml:"venue"`
+	Date     string `json:"date" xml:"date"`
+	Category string `json:"category" xml:"category"`
+}
+
+// Race is a race of a meeting.
+type Race struct {
+	ID           string `json:"id" xml:"id,attr"`
+	Meeting      string `json:"meeting" xml:"meeting,attr"`
+	Name         string `json:"name" xml:"name"`
+	Number       int64  `json:"number" xml:"number"`
+	StartTime    string `json:"start_time" xml:"start_time"`
+	Distance     int64  `json:"distance" xml:"distance"`
+	Class        string `json:"class" xml:"class"`
+	TrackSurface string `json:"track_surface" xml:"track_surface"`
+	PrizeMoney   int64  `json:"prize_money" xml:"prize_money"`
+	Visible      *bool  `json:"visible" xml:"visible"`
+}
+
+// Runner is a horse or greyhound entered in a race.
+type Runner struct {
+	ID        string  `json:"id" xml:"id,attr"`
+	Race      string  `json:"race" xml:"race,attr"`
+	Number    int64   `json:"number" xml:"number"`
+	Name      string  `json:"name" xml:"name"`
+	Barrier   int64   `json:"barrier" xml:"barrier"`
+	Jockey    string  `json:"jockey" xml:"jockey"`
+	Trainer   string  `json:"trainer" xml:"trainer"`
+	Weight    float64 `json:"weight" xml:"weight"`
+	Scratched bool    `json:"scratched" xml:"scratched"`
+}
+
+// Result is the finishing position of a runner. It is identified by its race and runner.
+type Result struct {
+	Race     string  `json:"race" xml:"race,attr"`
+	Runner   string  `json:"runner" xml:"runner,attr"`
+	Position int64   `json:"position" xml:"position"`
+	Margin   float64 `json:"margin" xml:"margin"`
+}
+
+// ID returns the identifier of the result in reports, its race and runner external ids.
+func (r Result) ID() string {
+	return r.Race + "/" + r.Runner
+}
+
+// ErrInvalidFeed is returned for a feed which cannot be decoded, which ingesting it again would not fix.
+var ErrInvalidFeed = errors.New("invalid feed")
+
+// Parse decodes a feed. Unknown JSON fields are rejected, so that misspelt fields are not silently dropped.
+func Parse(r io.Reader, format Format) (*Feed, error) {
+	var feed Feed
+
+	switch format {
+	case JSON:
+		dec := json.NewDecoder(r)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&feed); err != nil {
+			return nil, fmt.Errorf("%w: malformed JSON: %w", ErrInvalidFeed, err)
+		}
+	case XML:
+		if err := xml.NewDecoder(r).Decode(&feed); err != nil {
+			return nil, fmt.Errorf("%w: malformed XML: %w", ErrInvalidFeed, err)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unsupported format %q", ErrInvalidFeed, format)
+	}
+
+	return &feed, nil
+}
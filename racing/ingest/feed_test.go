@@ -0,0 +1,119 @@
+package ingest
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFormatOf(t *testing.T) {
+	for _, tc := range []struct {
+		path   string
+		format Format
+	}{
+		{path: "drop/flemington.json", format: JSON},
+		{path: "drop/SANDOWN.XML", format: XML},
+		{path: "drop/flemington.csv"},
+		{path: "drop/flemington"},
+	} {
+		format, err := FormatOf(tc.path)
+		if format != tc.format || (err == nil) != (tc.format != "") {
+			t.Errorf("FormatOf(%q) = %q, %v, want %q", tc.path, format, err, tc.format)
+		}
+	}
+}
+
+func TestParseExamples(t *testing.T) {
+	for _, tc := range []struct {
+		path                              string
+		meetings, races, runners, results int
+	}{
+		{path: "examples/flemington.json", meetings: 1, races: 2, runners: 4, results: 2},
+		{path: "examples/sandown.xml", meetings: 1, races: 1, runners: 2, results: 1},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			f, err := os.Open(tc.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			format, err := FormatOf(tc.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			feed, err := Parse(f, format)
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+
+			if len(feed.Meetings) != tc.meetings || len(feed.Races) != tc.races || len(feed.Runners) != tc.runners || len(feed.Results) != tc.results {
+				t.Errorf("parsed %d meetings, %d races, %d runners and %d results, want %d, %d, %d and %d",
+					len(feed.Meetings), len(feed.Races), len(feed.Runners), len(feed.Results), tc.meetings, tc.races, tc.runners, tc.results)
+			}
+
+			if valid, rejections := Validate(feed); len(rejections) != 0 || len(valid.Runners) != tc.runners {
+				t.Errorf("Validate() rejected %v", rejections)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		format  Format
+		feed    string
+		want    Feed
+		invalid bool
+	}{
+		{
+			name:   "JSON",
+			format: JSON,
+			feed: `{"races": [{"id": "R1", "meeting": "M1", "name": "Cup", "number": 1, "start_time": "2024-05-01T02:30:00Z", "visible": false}],
+				"runners": [{"id": "R1-1", "race": "R1", "number": 1, "name": "Fast Lane", "weight": 58.5, "scratched": true}]}`,
+			want: Feed{
+				Races:   []Race{{ID: "R1", Meeting: "M1", Name: "Cup", Number: 1, StartTime: "2024-05-01T02:30:00Z", Visible: new(bool)}},
+				Runners: []Runner{{ID: "R1-1", Race: "R1", Number: 1, Name: "Fast Lane", Weight: 58.5, Scratched: true}},
+			},
+		},
+		{
+			name:   "XML",
+			format: XML,
+			feed: `<feed><race id="R1" meeting="M1"><name>Cup</name><number>1</number><start_time>2024-05-01T02:30:00Z</start_time></race>
+				<result race="R1" runner="R1-1"><position>1</position><margin>0.5</margin></result></feed>`,
+			want: Feed{
+				Races:   []Race{{ID: "R1", Meeting: "M1", Name: "Cup", Number: 1, StartTime: "2024-05-01T02:30:00Z"}},
+				Results: []Result{{Race: "R1", Runner: "R1-1", Position: 1, Margin: 0.5}},
+			},
+		},
+		{name: "misspelt JSON field", format: JSON, feed: `{"races": [{"id": "R1", "startTime": "2024-05-01T02:30:00Z"}]}`, invalid: true},
+		{name: "truncated JSON", format: JSON, feed: `{"races": [`, invalid: true},
+		{name: "JSON number as string", format: JSON, feed: `{"races": [{"id": "R1", "number": "1"}]}`, invalid: true},
+		{name: "truncated XML", format: XML, feed: `<feed><race id="R1">`, invalid: true},
+		{name: "XML number as text", format: XML, feed: `<feed><race id="R1"><number>one</number></race></feed>`, invalid: true},
+		{name: "unsupported format", format: "csv", feed: `id,name`, invalid: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			feed, err := Parse(strings.NewReader(tc.feed), tc.format)
+			if tc.invalid {
+				if !errors.Is(err, ErrInvalidFeed) {
+					t.Fatalf("Parse() = %v, want an ErrInvalidFeed", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+
+			feed.XMLName = tc.want.XMLName
+			if !reflect.DeepEqual(feed, &tc.want) {
+				t.Errorf("Parse() = %+v, want %+v", feed, &tc.want)
+			}
+		})
+	}
+}
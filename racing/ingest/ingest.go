@@ -0,0 +1,118 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Store saves the records of validated feeds.
+type Store interface {
+	// Upsert inserts or updates the records of a validated feed by external id in a single transaction. Records
+	// referencing an external id found neither in the feed nor in the store are rejected. It returns the number
+	// of records upserted and the rejections.
+	Upsert(ctx context.Context, feed *Feed) (Counts, []Rejection, error)
+}
+
+// Counts holds a number of records of each kind.
+type Counts struct {
+	Meetings int `json:"meetings"`
+	Races    int `json:"races"`
+	Runners  int `json:"runners"`
+	Results  int `json:"results"`
+}
+
+// Report is the outcome of ingesting a feed.
+type Report struct {
+	// File is the name of the feed file, empty when not read from a file.
+	File string `json:"file,omitempty"`
+	// Upserted counts the records inserted or updated.
+	Upserted Counts `json:"upserted"`
+	// Rejected lists the records left out.
+	Rejected []Rejection `json:"rejected"`
+	// Error is why the whole feed failed, when it did. Nothing is saved then.
+	Error string `json:"error,omitempty"`
+}
+
+// Ingester validates feeds and saves their valid records.
+type Ingester struct {
+	store Store
+	// mu runs one ingestion at a time, so that the feeds of a process are saved in the order they arrive.
+	mu sync.Mutex
+}
+
+// NewIngester creates an ingester saving the feeds to the store.
+func NewIngester(store Store) *Ingester {
+	return &Ingester{store: store}
+}
+
+// Ingest parses, validates and saves a feed. The error reports a feed that could not be parsed or saved, in which
+// case nothing is saved. Invalid records are only reported.
+func (i *Ingester) Ingest(ctx context.Context, r io.Reader, format Format) (Report, error) {
+	report := Report{Rejected: []Rejection{}}
+
+	feed, err := Parse(r, format)
+	if err != nil {
+		report.Error = err.Error()
+		return report, err
+	}
+
+	valid, rejections := Validate(feed)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	counts, unresolved, err := i.store.Upsert(ctx, valid)
+	if err != nil {
+		report.Error = err.Error()
+		return report, err
+	}
+
+	report.Upserted = counts
+	report.Rejected = append(append(report.Rejected, rejections...), unresolved...)
+
+	return report, nil
+}
+
+// IngestFile ingests the feed file at path, in the format of its extension.
+func (i *Ingester) IngestFile(ctx context.Context, path string) (Report, error) {
+	report := Report{File: filepath.Base(path), Rejected: []Rejection{}}
+
+	format, err := FormatOf(path)
+	if err != nil {
+		report.Error = err.Error()
+		return report, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		report.Error = err.Error()
+		return report, err
+	}
+	defer f.Close()
+
+	report, err = i.Ingest(ctx, f, format)
+	report.File = filepath.Base(path)
+
+	return report, err
+}
+
+// Usage is the usage of the ingest subcommand.
+const Usage = "ingest <file>... [flags]"
+
+// ParseArgs splits the arguments of the ingest subcommand into the feed files and the remaining flags.
+func ParseArgs(args []string) (files, flags []string, err error) {
+	for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		files, args = append(files, args[0]), args[1:]
+	}
+
+	if len(files) == 0 {
+		return nil, nil, fmt.Errorf("missing feed files, usage: %s", Usage)
+	}
+
+	return files, args, nil
+}
@@ -0,0 +1,44 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestIngest(t *testing.T) {
+	feed := `{"meetings": [{"id": "M1", "name": "Flemington", "date": "2024-05-01", "category": "thoroughbred"},
+		{"id": "M2", "name": "Caulfield", "date": "2024-05-01", "category": "camel"}]}`
+
+	report, err := NewIngester(&fakeStore{}).Ingest(context.Background(), strings.NewReader(feed), JSON)
+	if err != nil {
+		t.Fatalf("Ingest() failed: %v", err)
+	}
+
+	if report.Upserted.Meetings != 1 || len(report.Rejected) != 1 || report.Rejected[0].ID != "M2" || report.Error != "" {
+		t.Errorf("Ingest() reported %+v, want 1 meeting upserted and M2 rejected", report)
+	}
+
+	// A feed which could not be saved reports nothing upserted.
+	report, err = NewIngester(&fakeStore{err: errors.New("database is locked")}).Ingest(context.Background(), strings.NewReader(feed), JSON)
+	if err == nil || errors.Is(err, ErrInvalidFeed) || report.Error != "database is locked" || report.Upserted != (Counts{}) {
+		t.Errorf("Ingest() = %+v, %v, want the store error", report, err)
+	}
+
+	report, err = NewIngester(&fakeStore{}).Ingest(context.Background(), strings.NewReader(`{"meetings": `), JSON)
+	if !errors.Is(err, ErrInvalidFeed) || report.Error == "" || report.Rejected == nil {
+		t.Errorf("Ingest() = %+v, %v, want an ErrInvalidFeed", report, err)
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	files, flags, err := ParseArgs([]string{"a.json", "b.xml", "-db-dsn", "racing.db"})
+	if err != nil || strings.Join(files, ",") != "a.json,b.xml" || strings.Join(flags, ",") != "-db-dsn,racing.db" {
+		t.Errorf("ParseArgs() = %q, %q, %v", files, flags, err)
+	}
+
+	if _, _, err := ParseArgs([]string{"-db-dsn", "racing.db"}); err == nil {
+		t.Error("ParseArgs() without files succeeded")
+	}
+}
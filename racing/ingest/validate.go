@@ -0,0 +1,182 @@
+package ingest
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+const (
+	// DateLayout is the layout of meeting dates.
+	DateLayout = "2006-01-02"
+
+	// maxRunnerNumber is the highest saddlecloth or box number accepted.
+	maxRunnerNumber = 99
+)
+
+// Rejection reports a record left out of the ingestion.
+type Rejection struct {
+	// Record is the kind of record: meeting, race, runner or result.
+	Record string `json:"record"`
+	// ID is the external id of the record, race/runner for results.
+	ID string `json:"id"`
+	// Reason explains why the record was rejected.
+	Reason string `json:"reason"`
+}
+
+// validator collects the rejections of a feed and remembers the ids rejected, so that the records referencing
+// them are rejected too.
+type validator struct {
+	rejections []Rejection
+	rejected   map[string]map[string]bool
+}
+
+func (v *validator) reject(record, id, format string, args ...interface{}) {
+	v.rejections = append(v.rejections, Rejection{Record: record, ID: id, Reason: fmt.Sprintf(format, args...)})
+
+	if v.rejected[record] == nil {
+		v.rejected[record] = make(map[string]bool)
+	}
+	v.rejected[record][id] = true
+}
+
+// duplicate rejects a record repeating the id of an earlier one. The earlier record is kept, along with the records
+// referencing the id.
+func (v *validator) duplicate(record, id string) {
+	v.rejections = append(v.rejections, Rejection{Record: record, ID: id, Reason: "duplicate id"})
+}
+
+// Validate checks the records of a feed on their own and against the other records of the file. It returns the
+// feed of the valid records, with the categories as RaceCategory names, and the rejections. References to records
+// missing from the file are left to the store to resolve.
+func Validate(feed *Feed) (*Feed, []Rejection) {
+	v := &validator{rejected: make(map[string]map[string]bool)}
+	valid := &Feed{}
+
+	seen := make(map[string]bool)
+	for _, m := range feed.Meetings {
+		category := racing.RaceCategory(racing.RaceCategory_value[strings.ToUpper(m.Category)])
+
+		switch {
+		case m.ID == "":
+			v.reject("meeting", m.ID, "missing id")
+		case seen[m.ID]:
+			v.duplicate("meeting", m.ID)
+			continue
+		case m.Name == "":
+			v.reject("meeting", m.ID, "missing name")
+		case !validDate(m.Date):
+			v.reject("meeting", m.ID, "invalid date %q, expected YYYY-MM-DD", m.Date)
+		case category == racing.RaceCategory_RACE_CATEGORY_UNSPECIFIED:
+			v.reject("meeting", m.ID, "invalid category %q, expected thoroughbred, harness or greyhound", m.Category)
+		default:
+			m.Category = category.String()
+			valid.Meetings = append(valid.Meetings, m)
+		}
+
+		seen[m.ID] = true
+	}
+
+	seen = make(map[string]bool)
+	for _, r := range feed.Races {
+		switch {
+		case r.ID == "":
+			v.reject("race", r.ID, "missing id")
+		case seen[r.ID]:
+			v.duplicate("race", r.ID)
+			continue
+		case r.Meeting == "":
+			v.reject("race", r.ID, "missing meeting")
+		case v.rejected["meeting"][r.Meeting]:
+			v.reject("race", r.ID, "meeting %s was rejected", r.Meeting)
+		case r.Name == "":
+			v.reject("race", r.ID, "missing name")
+		case r.Number < 1:
+			v.reject("race", r.ID, "invalid number %d", r.Number)
+		case !validTime(r.StartTime):
+			v.reject("race", r.ID, "invalid start_time %q, expected RFC3339", r.StartTime)
+		case r.Distance < 0:
+			v.reject("race", r.ID, "invalid distance %d", r.Distance)
+		case r.PrizeMoney < 0:
+			v.reject("race", r.ID, "invalid prize_money %d", r.PrizeMoney)
+		default:
+			valid.Races = append(valid.Races, r)
+		}
+
+		seen[r.ID] = true
+	}
+
+	seen = make(map[string]bool)
+	runnerRaces := make(map[string]string)
+	numbers := make(map[string]bool)
+	for _, r := range feed.Runners {
+		number := fmt.Sprintf("%s/%d", r.Race, r.Number)
+
+		switch {
+		case r.ID == "":
+			v.reject("runner", r.ID, "missing id")
+		case seen[r.ID]:
+			v.duplicate("runner", r.ID)
+			continue
+		case r.Race == "":
+			v.reject("runner", r.ID, "missing race")
+		case v.rejected["race"][r.Race]:
+			v.reject("runner", r.ID, "race %s was rejected", r.Race)
+		case r.Number < 1 || r.Number > maxRunnerNumber:
+			v.reject("runner", r.ID, "invalid number %d, expected 1 to %d", r.Number, maxRunnerNumber)
+		case numbers[number]:
+			v.reject("runner", r.ID, "duplicate number %d in race %s", r.Number, r.Race)
+		case r.Name == "":
+			v.reject("runner", r.ID, "missing name")
+		case r.Barrier < 0:
+			v.reject("runner", r.ID, "invalid barrier %d", r.Barrier)
+		case r.Weight < 0:
+			v.reject("runner", r.ID, "invalid weight %v", r.Weight)
+		default:
+			numbers[number] = true
+			valid.Runners = append(valid.Runners, r)
+		}
+
+		seen[r.ID] = true
+		runnerRaces[r.ID] = r.Race
+	}
+
+	seen = make(map[string]bool)
+	for _, r := range feed.Results {
+		switch {
+		case r.Race == "" || r.Runner == "":
+			v.reject("result", r.ID(), "missing race or runner")
+		case seen[r.ID()]:
+			v.duplicate("result", r.ID())
+			continue
+		case v.rejected["race"][r.Race]:
+			v.reject("result", r.ID(), "race %s was rejected", r.Race)
+		case v.rejected["runner"][r.Runner]:
+			v.reject("result", r.ID(), "runner %s was rejected", r.Runner)
+		case runnerRaces[r.Runner] != "" && runnerRaces[r.Runner] != r.Race:
+			v.reject("result", r.ID(), "runner %s is not in race %s", r.Runner, r.Race)
+		case r.Position < 1:
+			v.reject("result", r.ID(), "invalid position %d", r.Position)
+		case r.Margin < 0:
+			v.reject("result", r.ID(), "invalid margin %v", r.Margin)
+		default:
+			valid.Results = append(valid.Results, r)
+		}
+
+		seen[r.ID()] = true
+	}
+
+	return valid, v.rejections
+}
+
+func validDate(date string) bool {
+	_, err := time.Parse(DateLayout, date)
+	return err == nil
+}
+
+func validTime(t string) bool {
+	_, err := time.Parse(time.RFC3339, t)
+	return err == nil
+}
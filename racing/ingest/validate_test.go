@@ -0,0 +1,136 @@
+package ingest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testFeed returns a feed of valid records, a meeting with a race of two runners and a result.
+func testFeed() *Feed {
+	return &Feed{
+		Meetings: []Meeting{{ID: "M1", Name: "Flemington", Date: "2024-05-01", Category: "Thoroughbred"}},
+		Races:    []Race{{ID: "R1", Meeting: "M1", Name: "Maiden Plate", Number: 1, StartTime: "2024-05-01T02:30:00Z"}},
+		Runners: []Runner{
+			{ID: "R1-1", Race: "R1", Number: 1, Name: "Fast Lane"},
+			{ID: "R1-2", Race: "R1", Number: 2, Name: "Slow Coach"},
+		},
+		Results: []Result{{Race: "R1", Runner: "R1-1", Position: 1}},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		change func(*Feed)
+		// rejected are the rejections as "record id: reason".
+		rejected []string
+		// valid counts the meetings, races, runners and results kept.
+		valid [4]int
+	}{
+		{name: "valid", change: func(*Feed) {}, valid: [4]int{1, 1, 2, 1}},
+		{
+			name:     "meeting without id",
+			change:   func(f *Feed) { f.Meetings[0].ID = "" },
+			rejected: []string{"meeting : missing id"},
+			valid:    [4]int{0, 1, 2, 1},
+		},
+		{
+			name:     "invalid meeting date rejects its races, runners and results",
+			change:   func(f *Feed) { f.Meetings[0].Date = "01/05/2024" },
+			rejected: []string{`meeting M1: invalid date "01/05/2024", expected YYYY-MM-DD`, "race R1: meeting M1 was rejected", "runner R1-1: race R1 was rejected", "runner R1-2: race R1 was rejected", "result R1/R1-1: race R1 was rejected"},
+		},
+		{
+			name:     "unknown category",
+			change:   func(f *Feed) { f.Meetings[0].Category = "camel" },
+			rejected: []string{`meeting M1: invalid category "camel", expected thoroughbred, harness or greyhound`, "race R1: meeting M1 was rejected", "runner R1-1: race R1 was rejected", "runner R1-2: race R1 was rejected", "result R1/R1-1: race R1 was rejected"},
+		},
+		{
+			name:     "duplicate meeting keeps the first",
+			change:   func(f *Feed) { f.Meetings = append(f.Meetings, Meeting{ID: "M1"}) },
+			rejected: []string{"meeting M1: duplicate id"},
+			valid:    [4]int{1, 1, 2, 1},
+		},
+		{
+			name:     "race start not RFC3339",
+			change:   func(f *Feed) { f.Races[0].StartTime = "2024-05-01 02:30" },
+			rejected: []string{`race R1: invalid start_time "2024-05-01 02:30", expected RFC3339`, "runner R1-1: race R1 was rejected", "runner R1-2: race R1 was rejected", "result R1/R1-1: race R1 was rejected"},
+			valid:    [4]int{1, 0, 0, 0},
+		},
+		{
+			name:   "race of a meeting outside the feed is left to the store",
+			change: func(f *Feed) { f.Races[0].Meeting = "M0" },
+			valid:  [4]int{1, 1, 2, 1},
+		},
+		{
+			name:     "negative prize money",
+			change:   func(f *Feed) { f.Races[0].PrizeMoney = -1 },
+			rejected: []string{"race R1: invalid prize_money -1", "runner R1-1: race R1 was rejected", "runner R1-2: race R1 was rejected", "result R1/R1-1: race R1 was rejected"},
+			valid:    [4]int{1, 0, 0, 0},
+		},
+		{
+			name:     "runner number out of range",
+			change:   func(f *Feed) { f.Runners[1].Number = 100 },
+			rejected: []string{"runner R1-2: invalid number 100, expected 1 to 99"},
+			valid:    [4]int{1, 1, 1, 1},
+		},
+		{
+			name:     "runner number taken",
+			change:   func(f *Feed) { f.Runners[1].Number = 1 },
+			rejected: []string{"runner R1-2: duplicate number 1 in race R1"},
+			valid:    [4]int{1, 1, 1, 1},
+		},
+		{
+			name:     "rejected runner rejects its result",
+			change:   func(f *Feed) { f.Runners[0].Weight = -58 },
+			rejected: []string{"runner R1-1: invalid weight -58", "result R1/R1-1: runner R1-1 was rejected"},
+			valid:    [4]int{1, 1, 1, 0},
+		},
+		{
+			name: "result of a runner of another race",
+			change: func(f *Feed) {
+				f.Races = append(f.Races, Race{ID: "R2", Meeting: "M1", Name: "Handicap", Number: 2, StartTime: "2024-05-01T03:05:00Z"})
+				f.Results[0].Race = "R2"
+			},
+			rejected: []string{"result R2/R1-1: runner R1-1 is not in race R2"},
+			valid:    [4]int{1, 2, 2, 0},
+		},
+		{
+			name:     "duplicate result",
+			change:   func(f *Feed) { f.Results = append(f.Results, Result{Race: "R1", Runner: "R1-1", Position: 2}) },
+			rejected: []string{"result R1/R1-1: duplicate id"},
+			valid:    [4]int{1, 1, 2, 1},
+		},
+		{
+			name:     "invalid position",
+			change:   func(f *Feed) { f.Results[0].Position = 0 },
+			rejected: []string{"result R1/R1-1: invalid position 0"},
+			valid:    [4]int{1, 1, 2, 0},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			feed := testFeed()
+			tc.change(feed)
+
+			valid, rejections := Validate(feed)
+
+			var got []string
+			for _, r := range rejections {
+				got = append(got, fmt.Sprintf("%s %s: %s", r.Record, r.ID, r.Reason))
+			}
+			if strings.Join(got, "\n") != strings.Join(tc.rejected, "\n") {
+				t.Errorf("Validate() rejected %q, want %q", got, tc.rejected)
+			}
+
+			if counts := [4]int{len(valid.Meetings), len(valid.Races), len(valid.Runners), len(valid.Results)}; counts != tc.valid {
+				t.Errorf("Validate() kept %v records, want %v", counts, tc.valid)
+			}
+
+			for _, m := range valid.Meetings {
+				if m.Category != "THOROUGHBRED" {
+					t.Errorf("Validate() kept category %q, want THOROUGHBRED", m.Category)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ProcessedDir is the subdirectory of the drop directory the ingested files are moved to.
+	ProcessedDir = "processed"
+	// FailedDir is the subdirectory of the drop directory the files which could not be ingested are moved to.
+	FailedDir = "failed"
+)
+
+// Watch ingests the .json and .xml files of the drop directory every interval until the context is done. Each file
+// is moved once ingested to the processed subdirectory, or to the failed one when it could not be parsed, along with
+// its report as <name>.report.json. The moved files are prefixed with the time of the ingestion so that files
+// dropped again under the same name are kept apart. A file which could not be saved, e.g. on shutdown or while the
+// database is down, is left in place for the next tick to ingest it again along with the files after it.
+//
+// Files are picked up as soon as they are listed, so they should be written under another extension, or in another
+// directory of the same file system, then renamed into the drop directory.
+func (i *Ingester) Watch(ctx context.Context, dir string, interval time.Duration) error {
+	for _, sub := range []string{ProcessedDir, FailedDir} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("watching %s for feed files every %s", dir, interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := i.ingestDir(ctx, dir); err != nil {
+			log.Errorf("failed listing feed files: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// ingestDir ingests the feed files of the directory in name order.
+func (i *Ingester) ingestDir(ctx context.Context, dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
+			if _, err := FormatOf(entry.Name()); err == nil {
+				files = append(files, entry.Name())
+			}
+		}
+	}
+	sort.Strings(files)
+
+	for _, name := range files {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		report, err := i.IngestFile(ctx, filepath.Join(dir, name))
+
+		dest := ProcessedDir
+		switch {
+		case err == nil:
+			LogReport(report)
+		case errors.Is(err, ErrInvalidFeed):
+			dest = FailedDir
+			log.Errorf("failed ingesting feed %s: %s", name, err)
+		case ctx.Err() != nil:
+			return nil
+		default:
+			// Later files may update the same records, so they wait for this one to be saved.
+			log.Errorf("failed ingesting feed %s, retrying on the next tick: %s", name, err)
+			return nil
+		}
+
+		if err := archive(dir, dest, name, report); err != nil {
+			// Left in place, the file is ingested again on the next tick, upserting the same records.
+			return err
+		}
+	}
+
+	return nil
+}
+
+// archive moves a feed file to the dest subdirectory and writes its report next to it.
+func archive(dir, dest, name string, report Report) error {
+	archived := filepath.Join(dir, dest, time.Now().UTC().Format("20060102T150405Z")+"-"+name)
+
+	if err := os.Rename(filepath.Join(dir, name), archived); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(archived+".report.json", append(data, '\n'), 0o644)
+}
+
+// LogReport logs the counts and rejections of an ingestion.
+func LogReport(report Report) {
+	u := report.Upserted
+	log.Infof("ingested feed %s: upserted %d meetings, %d races, %d runners and %d results, rejected %d records",
+		report.File, u.Meetings, u.Races, u.Runners, u.Results, len(report.Rejected))
+
+	for _, r := range report.Rejected {
+		log.Warnf("feed %s: rejected %s %s: %s", report.File, r.Record, r.ID, r.Reason)
+	}
+}
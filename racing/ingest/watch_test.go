@@ -0,0 +1,158 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// fakeStore saves nothing, answering the upserts with err. It calls cancel, when set, during the upserts.
+type fakeStore struct {
+	err     error
+	cancel  context.CancelFunc
+	upserts int
+}
+
+func (s *fakeStore) Upsert(ctx context.Context, feed *Feed) (Counts, []Rejection, error) {
+	s.upserts++
+	if s.cancel != nil {
+		s.cancel()
+	}
+	if s.err != nil {
+		return Counts{}, nil, s.err
+	}
+
+	return Counts{Meetings: len(feed.Meetings)}, nil, ctx.Err()
+}
+
+const validFeed = `{"meetings": [{"id": "FLM-20240501", "name": "Flemington", "venue": "Flemington Racecourse",
+	"date": "2024-05-01", "category": "thoroughbred"}]}`
+
+// dropDir returns a drop directory holding the files, with its subdirectories.
+func dropDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for _, sub := range []string{ProcessedDir, FailedDir} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+// feedsIn returns the names of the feed files of a directory, without the time prefix of the archived ones.
+func feedsIn(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasSuffix(name, ".report.json") {
+			continue
+		}
+		if i := strings.Index(name, "Z-"); i >= 0 {
+			name = name[i+2:]
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func wantFeeds(t *testing.T, dir string, want ...string) {
+	t.Helper()
+
+	if got := feedsIn(t, dir); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("%s holds %q, want %q", filepath.Base(dir), got, want)
+	}
+}
+
+func TestIngestDirArchivesFeeds(t *testing.T) {
+	dir := dropDir(t, map[string]string{
+		"a.json":  validFeed,
+		"b.json":  `{"meetings": [`,
+		"c.xml":   `<feed><meeting id="SAN-20240501"><name>Sandown</name></meeting></feed>`,
+		"d.txt":   "not a feed",
+		".e.json": validFeed,
+	})
+
+	store := &fakeStore{}
+	if err := NewIngester(store).ingestDir(context.Background(), dir); err != nil {
+		t.Fatalf("ingestDir() failed: %v", err)
+	}
+
+	wantFeeds(t, dir, ".e.json", "d.txt")
+	wantFeeds(t, filepath.Join(dir, ProcessedDir), "a.json", "c.xml")
+	wantFeeds(t, filepath.Join(dir, FailedDir), "b.json")
+
+	reports, err := filepath.Glob(filepath.Join(dir, FailedDir, "*-b.json.report.json"))
+	if err != nil || len(reports) != 1 {
+		t.Fatalf("report of b.json: %q, %v", reports, err)
+	}
+	if report, _ := os.ReadFile(reports[0]); !strings.Contains(string(report), `"error": "invalid feed: malformed JSON`) {
+		t.Errorf("report of b.json = %s", report)
+	}
+}
+
+func TestIngestDirRetriesUnsavedFeeds(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		err      error
+		shutdown bool
+	}{
+		{name: "store error", err: errors.New("database is locked")},
+		{name: "shutdown", shutdown: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := dropDir(t, map[string]string{"a.json": validFeed, "b.json": validFeed})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			store := &fakeStore{err: tc.err}
+			if tc.shutdown {
+				store.cancel = cancel
+			}
+
+			if err := NewIngester(store).ingestDir(ctx, dir); err != nil {
+				t.Fatalf("ingestDir() failed: %v", err)
+			}
+
+			// The files after the one left in place wait for it.
+			if store.upserts != 1 {
+				t.Errorf("upserted %d feeds, want 1", store.upserts)
+			}
+
+			wantFeeds(t, dir, "a.json", "b.json")
+			wantFeeds(t, filepath.Join(dir, ProcessedDir))
+			wantFeeds(t, filepath.Join(dir, FailedDir))
+
+			// Once saved, the feeds are archived on the next tick.
+			store.err, store.cancel = nil, nil
+			if err := NewIngester(store).ingestDir(context.Background(), dir); err != nil {
+				t.Fatalf("ingestDir() failed: %v", err)
+			}
+
+			wantFeeds(t, dir)
+			wantFeeds(t, filepath.Join(dir, ProcessedDir), "a.json", "b.json")
+		})
+	}
+}
@@ -1,58 +1,398 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"flag"
+	"fmt"
+	"git.neds.sh/matty/entain/pkg/clock"
+	"git.neds.sh/matty/entain/pkg/config"
+	"git.neds.sh/matty/entain/pkg/deadline"
+	"git.neds.sh/matty/entain/pkg/dialect"
+	"git.neds.sh/matty/entain/pkg/health"
+	"git.neds.sh/matty/entain/pkg/migrate"
+	"git.neds.sh/matty/entain/pkg/pubsub"
+	"git.neds.sh/matty/entain/pkg/shutdown"
+	"git.neds.sh/matty/entain/pkg/tlsconfig"
+	"git.neds.sh/matty/entain/pkg/tracing"
 	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/ingest"
 	"git.neds.sh/matty/entain/racing/proto/racing"
 	"git.neds.sh/matty/entain/racing/service"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health/grpc_health_v1"
 	"net"
-)
-
-var (
-	grpcEndpoint = flag.String("grpc-endpoint", "localhost:9000", "gRPC server endpoint")
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
-	flag.Parse()
+	args := os.Args[1:]
+
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := runMigrate(args[1:]); err != nil {
+			log.Fatalf("failed running migrations: %s", err)
+		}
+		return
+	}
+
+	if len(args) > 0 && args[0] == "ingest" {
+		if err := runIngest(args[1:]); err != nil {
+			log.Fatalf("failed ingesting feeds: %s", err)
+		}
+		return
+	}
+
+	if len(args) > 0 && args[0] == "seed" {
+		if err := runSeed(args[1:]); err != nil {
+			log.Fatalf("failed seeding database: %s", err)
+		}
+		return
+	}
+
+	cfg, err := config.Load(config.ServiceRacing, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("invalid configuration: %s", err)
+	}
+
+	if err := cfg.Log.Configure(); err != nil {
+		log.Fatalf("invalid configuration: %s", err)
+	}
 
-	if err := run(); err != nil {
+	if err := run(cfg); err != nil {
 		log.Fatalf("failed running grpc server: %s", err)
 	}
 }
 
-func run() error {
-	conn, err := net.Listen("tcp", ":9000")
+func run(cfg config.Config) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Init(cfg.TracingConfig())
 	if err != nil {
 		return err
 	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Errorf("failed flushing traces: %s", err)
+		}
+	}()
 
-	racingDB, err := sql.Open("sqlite3", "./db/racing.db")
+	conn, err := net.Listen("tcp", cfg.Listen)
 	if err != nil {
 		return err
 	}
 
-	racesRepo := db.NewRacesRepo(racingDB)
-	if err := racesRepo.Init(); err != nil {
+	racesRepo, racingDB, err := openRepo(ctx, cfg)
+	if err != nil {
 		return err
 	}
+	if racingDB != nil {
+		defer func() {
+			if err := racingDB.Close(); err != nil {
+				log.Errorf("failed closing database: %s", err)
+			}
+		}()
+	}
 
-	grpcServer := grpc.NewServer()
+	tracker := &shutdown.Tracker{}
+
+	interceptors := []grpc.UnaryServerInterceptor{
+		tracker.UnaryServerInterceptor(),
+		deadline.UnaryServerInterceptor(cfg.Timeouts.Request, nil),
+	}
+	if cfg.Dev.SimulatedTime {
+		log.Warnf("answering requests as of their %s, do not enable in production", clock.Header)
+		interceptors = append(interceptors, clock.UnaryServerInterceptor())
+	}
+
+	serverOpts := []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(interceptors...),
+	}
+
+	tlsConfig, err := tlsconfig.NewServerConfig(ctx, cfg.TLS)
+	if err != nil {
+		return err
+	}
+	if tlsConfig != nil {
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	grpcServer := grpc.NewServer(serverOpts...)
+
+	// The health server reports NOT_SERVING until the repository has been initialised.
+	healthServer := health.NewServer(racing.Racing_ServiceDesc.ServiceName)
+	grpc_health_v1.RegisterHealthServer(grpcServer, healthServer)
+
+	// Downstream systems subscribe to the scratchings within the process. Log them meanwhile.
+	scratchings := pubsub.NewBroker[*racing.ScratchingEvent]("scratchings")
+	go logScratchings(ctx, scratchings)
 
 	racing.RegisterRacingServer(
 		grpcServer,
 		service.NewRacingService(
 			racesRepo,
+			scratchings,
 		),
 	)
 
-	log.Infof("gRPC server listening on: %s", *grpcEndpoint)
+	log.Infof("gRPC server listening on: %s", cfg.Listen)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- grpcServer.Serve(conn)
+	}()
 
-	if err := grpcServer.Serve(conn); err != nil {
+	if err := racesRepo.Init(ctx); err != nil {
+		grpcServer.Stop()
 		return err
 	}
 
+	if racingDB != nil {
+		go healthServer.Monitor(ctx, racingDB, cfg.Health.Interval)
+	} else {
+		healthServer.SetServing(true)
+	}
+
+	// The configuration only allows a drop directory along with a SQL database.
+	if len(cfg.Ingest.Dir) != 0 {
+		sqlDialect, _ := dialect.Parse(cfg.Database.DSN)
+		ingester := ingest.NewIngester(db.NewFeedStore(racingDB, sqlDialect, scratchings))
+
+		watchCtx, stopWatching := context.WithCancel(ctx)
+		watched := make(chan struct{})
+		go func() {
+			defer close(watched)
+
+			if err := ingester.Watch(watchCtx, cfg.Ingest.Dir, cfg.Ingest.Interval); err != nil {
+				log.Errorf("failed watching feed drop directory: %s", err)
+			}
+		}()
+
+		// Let the feed being saved roll back before the database is closed.
+		defer func() {
+			stopWatching()
+			<-watched
+		}()
+	}
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Info("received shutdown signal")
+
+	// Report NOT_SERVING so that no new traffic is routed here while draining.
+	healthServer.Shutdown()
+	shutdown.GRPC(grpcServer, tracker, cfg.Timeouts.Shutdown)
+
 	return nil
 }
+
+// logScratchings logs the scratching events published until the context is done.
+func logScratchings(ctx context.Context, broker *pubsub.Broker[*racing.ScratchingEvent]) {
+	events, cancel := broker.Subscribe(64)
+	defer cancel()
+
+	for {
+		select {
+		case event := <-events:
+			s := event.Scratching
+			log.Infof("%s runner %d of race %d with %.2f%% deduction", event.Type, s.RunnerNumber, s.RaceId, s.Deduction)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// openRepo creates the repository selected by the DSN. The database is returned to be monitored and closed,
+// and is nil for the in-memory repository.
+func openRepo(ctx context.Context, cfg config.Config) (_ db.RacesRepo, _ *sql.DB, err error) {
+	if cfg.Database.InMemory() {
+		log.Warn("using the in-memory repository, data is lost on exit")
+		return db.NewMemoryRacesRepo(nil, clock.System, seedOptions(cfg)), nil, nil
+	}
+
+	racingDB, sqlDialect, err := dialect.Open(cfg.Database.DSN)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err != nil {
+			racingDB.Close()
+		}
+	}()
+
+	migrator, err := migrate.New(racingDB, sqlDialect, db.Migrations(sqlDialect))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if cfg.Database.AutoMigrate {
+		if _, err := migrator.Up(ctx); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Refuse to run against a schema the code does not expect.
+	if err := migrator.Check(ctx); err != nil {
+		if errors.Is(err, migrate.ErrOutdated) {
+			return nil, nil, fmt.Errorf("%w: run `racing migrate up` or start with -auto-migrate", err)
+		}
+
+		return nil, nil, err
+	}
+
+	return db.NewRacesRepo(racingDB, sqlDialect, clock.System, seedOptions(cfg)), racingDB, nil
+}
+
+// runMigrate runs the migrate subcommand against the configured database.
+func runMigrate(args []string) error {
+	action, steps, flags, err := migrate.ParseArgs(args)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(config.ServiceRacing, flags)
+	if err != nil {
+		return err
+	}
+
+	if err := cfg.Log.Configure(); err != nil {
+		return err
+	}
+
+	if cfg.Database.InMemory() {
+		return errors.New("the in-memory repository has no migrations")
+	}
+
+	racingDB, sqlDialect, err := dialect.Open(cfg.Database.DSN)
+	if err != nil {
+		return err
+	}
+	defer racingDB.Close()
+
+	migrator, err := migrate.New(racingDB, sqlDialect, db.Migrations(sqlDialect))
+	if err != nil {
+		return err
+	}
+
+	return migrate.Command(context.Background(), migrator, action, steps)
+}
+
+// runIngest runs the ingest subcommand, ingesting the feed files given in order. It logs the report of each file
+// and fails when any of them could not be ingested.
+func runIngest(args []string) error {
+	files, flags, err := ingest.ParseArgs(args)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(config.ServiceRacing, flags)
+	if err != nil {
+		return err
+	}
+
+	if err := cfg.Log.Configure(); err != nil {
+		return err
+	}
+
+	if cfg.Database.InMemory() {
+		return errors.New("feed ingestion needs a SQL database, not the in-memory repository")
+	}
+
+	// Open through the repository so that the schema is checked, without seeding.
+	cfg.Seed.Enabled = false
+
+	_, racingDB, err := openRepo(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+	defer racingDB.Close()
+
+	// Nothing subscribes to the scratchings within this process. They are stored for ListScratchings all the same.
+	sqlDialect, _ := dialect.Parse(cfg.Database.DSN)
+	ingester := ingest.NewIngester(db.NewFeedStore(racingDB, sqlDialect, nil))
+
+	failed := 0
+	for _, file := range files {
+		report, err := ingester.IngestFile(context.Background(), file)
+		if err != nil {
+			log.Errorf("failed ingesting feed %s: %s", file, err)
+			failed++
+			continue
+		}
+
+		ingest.LogReport(report)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d feeds could not be ingested", failed, len(files))
+	}
+
+	return nil
+}
+
+// runSeed runs the seed subcommand, inserting the configured dummy races into the database.
+func runSeed(args []string) error {
+	cfg, err := config.Load(config.ServiceRacing, args)
+	if err != nil {
+		return err
+	}
+
+	if err := cfg.Log.Configure(); err != nil {
+		return err
+	}
+
+	if cfg.Database.InMemory() {
+		return errors.New("the in-memory repository is lost on exit, seed it on startup instead")
+	}
+
+	cfg.Seed.Enabled = true
+
+	repo, racingDB, err := openRepo(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+	defer racingDB.Close()
+
+	return repo.Init(context.Background())
+}
+
+// seedOptions returns the dummy data settings of the repository, or nil when seeding is disabled.
+// A random seed is picked when none is configured, and logged so that the data can be generated again.
+func seedOptions(cfg config.Config) *db.SeedOptions {
+	if !cfg.Seed.Enabled {
+		return nil
+	}
+
+	// The anchor was validated with the configuration.
+	anchor, _ := cfg.Seed.AnchorTime()
+
+	opts := &db.SeedOptions{
+		RandomSeed: cfg.Seed.RandomSeed,
+		Count:      cfg.Seed.Count,
+		Meetings:   cfg.Seed.Meetings,
+		Anchor:     anchor,
+		Reset:      cfg.Seed.Reset,
+	}
+	if opts.RandomSeed == 0 {
+		opts.RandomSeed = time.Now().UnixNano()
+	}
+
+	log.Infof("seeding %d races around %s with -seed-random %d", opts.Count, opts.Anchor.UTC().Format(time.RFC3339), opts.RandomSeed)
+
+	return opts
+}
@@ -1,31 +1,59 @@
 package service
 
 import (
+	"errors"
+	"math"
+	"time"
+
+	"git.neds.sh/matty/entain/pkg/pubsub"
+	"git.neds.sh/matty/entain/pkg/search"
 	"git.neds.sh/matty/entain/racing/db"
 	"git.neds.sh/matty/entain/racing/proto/racing"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// maxBatchGetIDs is the most ids a BatchGetRaces call may ask for.
+const maxBatchGetIDs = 100
+
 type Racing interface {
 	// ListRaces will return a collection of races.
 	ListRaces(ctx context.Context, in *racing.ListRacesRequest) (*racing.ListRacesResponse, error)
 
 	// GetRace will return the information of a race.
 	GetRace(ctx context.Context, in *racing.GetRaceRequest) (*racing.GetRaceResponse, error)
+
+	// BatchGetRaces will return the races of the given ids, reporting the ids without a race.
+	BatchGetRaces(ctx context.Context, in *racing.BatchGetRacesRequest) (*racing.BatchGetRacesResponse, error)
+
+	// Search will return the races whose name matches the query, most relevant first.
+	Search(ctx context.Context, in *racing.SearchRequest) (*racing.SearchResponse, error)
+
+	// ScratchRunner will scratch a runner from a race and publish the scratching.
+	ScratchRunner(ctx context.Context, in *racing.ScratchRunnerRequest) (*racing.ScratchRunnerResponse, error)
+
+	// UnscratchRunner will reinstate a scratched runner and publish the scratching.
+	UnscratchRunner(ctx context.Context, in *racing.UnscratchRunnerRequest) (*racing.UnscratchRunnerResponse, error)
+
+	// ListScratchings will return the scratchings changed in a time range, oldest change first.
+	ListScratchings(ctx context.Context, in *racing.ListScratchingsRequest) (*racing.ListScratchingsResponse, error)
 }
 
 // racingService implements the Racing interface.
 type racingService struct {
-	racesRepo db.RacesRepo
+	racesRepo   db.RacesRepo
+	scratchings *pubsub.Broker[*racing.ScratchingEvent]
 }
 
-// NewRacingService instantiates and returns a new racingService.
-func NewRacingService(racesRepo db.RacesRepo) Racing {
-	return &racingService{racesRepo}
+// NewRacingService instantiates and returns a new racingService, publishing the changes of scratchings to the
+// subscribers of the scratchings broker.
+func NewRacingService(racesRepo db.RacesRepo, scratchings *pubsub.Broker[*racing.ScratchingEvent]) Racing {
+	return &racingService{racesRepo, scratchings}
 }
 
 func (s *racingService) ListRaces(ctx context.Context, in *racing.ListRacesRequest) (*racing.ListRacesResponse, error) {
-	races, err := s.racesRepo.List(in.Filter, in.OrderBy)
+	races, err := s.racesRepo.List(ctx, in.Filter, in.OrderBy, in.ReadMask)
 	if err != nil {
 		return nil, err
 	}
@@ -35,10 +63,124 @@ func (s *racingService) ListRaces(ctx context.Context, in *racing.ListRacesReque
 
 // GetRace will return the information of a race.
 func (s *racingService) GetRace(ctx context.Context, in *racing.GetRaceRequest) (*racing.GetRaceResponse, error) {
-	race, err := s.racesRepo.GetRaceByID(in.Id)
+	race, err := s.racesRepo.GetRaceByID(ctx, in.Id, in.ReadMask)
 	if err != nil {
 		return nil, err
 	}
 
 	return &racing.GetRaceResponse{Race: race}, nil
 }
+
+// BatchGetRaces will return the races of the given ids with a single repository query. The results follow the order
+// of the requested ids, duplicates included, and mark the ids without a race as not found.
+func (s *racingService) BatchGetRaces(ctx context.Context, in *racing.BatchGetRacesRequest) (*racing.BatchGetRacesResponse, error) {
+	if len(in.Ids) > maxBatchGetIDs {
+		return nil, status.Errorf(codes.InvalidArgument, "too many ids: %d, at most %d can be requested", len(in.Ids), maxBatchGetIDs)
+	}
+
+	ids := make([]int64, 0, len(in.Ids))
+	seen := make(map[int64]bool, len(in.Ids))
+	for _, id := range in.Ids {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	races, err := s.racesRepo.GetRacesByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int64]*racing.Race, len(races))
+	for _, race := range races {
+		byID[race.Id] = race
+	}
+
+	results := make([]*racing.BatchGetRacesResult, 0, len(in.Ids))
+	for _, id := range in.Ids {
+		race, found := byID[id]
+		results = append(results, &racing.BatchGetRacesResult{Id: id, Found: found, Race: race})
+	}
+
+	return &racing.BatchGetRacesResponse{Results: results}, nil
+}
+
+// Search will return the races whose name matches the query, most relevant first.
+func (s *racingService) Search(ctx context.Context, in *racing.SearchRequest) (*racing.SearchResponse, error) {
+	limit, err := search.Limit(in.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := s.racesRepo.Search(ctx, in.Query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &racing.SearchResponse{Results: results}, nil
+}
+
+// ScratchRunner will scratch a runner from a race and publish the scratching once stored.
+func (s *racingService) ScratchRunner(ctx context.Context, in *racing.ScratchRunnerRequest) (*racing.ScratchRunnerResponse, error) {
+	if in.RunnerNumber < 1 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid runner_number: %d", in.RunnerNumber)
+	}
+
+	if math.IsNaN(in.Deduction) || in.Deduction < 0 || in.Deduction > 100 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid deduction: %v, must be between 0 and 100", in.Deduction)
+	}
+
+	var at time.Time
+	if in.ScratchedAt != nil {
+		at = in.ScratchedAt.AsTime()
+	}
+
+	scratching, err := s.racesRepo.Scratch(ctx, in.RaceId, in.RunnerNumber, in.Deduction, at)
+	if err != nil {
+		return nil, scratchingError(err)
+	}
+
+	s.scratchings.Publish(&racing.ScratchingEvent{Type: racing.ScratchingEventType_SCRATCHED, Scratching: scratching})
+
+	return &racing.ScratchRunnerResponse{Scratching: scratching}, nil
+}
+
+// UnscratchRunner will reinstate a scratched runner and publish the scratching once stored.
+func (s *racingService) UnscratchRunner(ctx context.Context, in *racing.UnscratchRunnerRequest) (*racing.UnscratchRunnerResponse, error) {
+	var at time.Time
+	if in.UnscratchedAt != nil {
+		at = in.UnscratchedAt.AsTime()
+	}
+
+	scratching, err := s.racesRepo.Unscratch(ctx, in.RaceId, in.RunnerNumber, at)
+	if err != nil {
+		return nil, scratchingError(err)
+	}
+
+	s.scratchings.Publish(&racing.ScratchingEvent{Type: racing.ScratchingEventType_UNSCRATCHED, Scratching: scratching})
+
+	return &racing.UnscratchRunnerResponse{Scratching: scratching}, nil
+}
+
+// ListScratchings will return the scratchings changed in a time range, oldest change first.
+func (s *racingService) ListScratchings(ctx context.Context, in *racing.ListScratchingsRequest) (*racing.ListScratchingsResponse, error) {
+	scratchings, err := s.racesRepo.ListScratchings(ctx, in.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &racing.ListScratchingsResponse{Scratchings: scratchings}, nil
+}
+
+// scratchingError converts the repository errors of scratchings into gRPC statuses.
+func scratchingError(err error) error {
+	switch {
+	case errors.Is(err, db.ErrRaceNotFound), errors.Is(err, db.ErrRunnerNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, db.ErrAlreadyScratched), errors.Is(err, db.ErrNotScratched):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	return err
+}
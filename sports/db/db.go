@@ -1,45 +1,124 @@
 package db
 
 import (
+	"context"
 	"time"
 
+	"google.golang.org/protobuf/types/known/timestamppb"
 	"syreclabs.com/go/faker"
+
+	"git.neds.sh/matty/entain/sports/proto/sports"
 )
 
-// seed add dummy data to sports database.
-func (s *sportsRepo) seed() error {
-	return s.seedEvents()
+// sportDurations is the typical length of an event of each sport, indexed by sport id - 1:
+// football, basketball, tennis, cricket, rugby, ice hockey, baseball, golf, american football and boxing.
+var sportDurations = []time.Duration{
+	2 * time.Hour,
+	150 * time.Minute,
+	3 * time.Hour,
+	7 * time.Hour,
+	100 * time.Minute,
+	150 * time.Minute,
+	3 * time.Hour,
+	270 * time.Minute,
+	210 * time.Minute,
+	time.Hour,
+}
+
+// SeedOptions controls the dummy sport events generated when seeding a repository.
+type SeedOptions struct {
+	// RandomSeed makes the generated events reproducible.
+	RandomSeed int64
+	// Count is the number of events generated.
+	Count int
+	// Anchor is the time the events are scheduled around.
+	Anchor time.Time
+	// Reset deletes the existing events before seeding.
+	Reset bool
+}
+
+// GenerateEvents returns the dummy sport events described by the options. The same options always generate
+// the same events. Events start between five days before and two days after the anchor, on the hour or half hour,
+// and last the typical duration of their sport give or take 15%.
+func GenerateEvents(opts SeedOptions) []*sports.Event {
+	if opts.Count <= 0 {
+		return nil
+	}
+
+	faker.Seed(opts.RandomSeed)
+
+	anchor := opts.Anchor.UTC().Truncate(time.Minute)
+
+	events := make([]*sports.Event, 0, opts.Count)
+
+	for id := int64(1); id <= int64(opts.Count); id++ {
+		sportID := faker.RandomInt(1, len(sportDurations))
+		start := faker.Time().Between(anchor.AddDate(0, 0, -5), anchor.AddDate(0, 0, 2)).Truncate(30 * time.Minute)
+
+		duration := sportDurations[sportID-1]
+		duration += duration * time.Duration(faker.RandomInt(-15, 15)) / 100
+
+		events = append(events, &sports.Event{
+			Id:                  id,
+			Name:                faker.Team().Name(),
+			VenueId:             int64(faker.RandomInt(1, 30)),
+			SportId:             int64(sportID),
+			ParticipantsId:      int64(faker.RandomInt(1, 10)),
+			AdvertisedStartTime: timestamppb.New(start),
+			AdvertisedEndTime:   timestamppb.New(start.Add(duration).Truncate(time.Minute)),
+		})
+	}
+
+	return events
 }
 
-// seedEvents add dummy data to events table in sports database.
-func (s *sportsRepo) seedEvents() error {
-	statement, err := s.db.Prepare(`CREATE TABLE IF NOT EXISTS events (id INTEGER PRIMARY KEY, name TEXT, venue_id INTEGER, sport_id INTEGER, participants_id INTEGER, advertised_start_time DATETIME, advertised_end_time DATETIME)`)
+// seedEvents inserts the dummy sport events in a single transaction, keeping existing events with the same ids
+// unless resetting.
+func (s *sportsRepo) seedEvents(ctx context.Context) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if s.seed.Reset {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM events`); err != nil {
+			return err
+		}
+	}
 
-	if _, err = statement.Exec(); err != nil {
+	statement, err := tx.PrepareContext(ctx, s.dialect.Rebind(`INSERT INTO events(id, name, venue_id, sport_id, participants_id, advertised_start_time, advertised_end_time) VALUES (?,?,?,?,?,?,?) ON CONFLICT DO NOTHING`))
+	if err != nil {
 		return err
 	}
+	defer statement.Close()
 
-	for i := 1; i <= 100; i++ {
-		statement, err = s.db.Prepare(`INSERT OR IGNORE INTO events(id, name, venue_id, sport_id, participants_id, advertised_start_time, advertised_end_time) VALUES (?,?,?,?,?,?,?)`)
+	for _, event := range GenerateEvents(*s.seed) {
+		_, err = statement.ExecContext(
+			ctx,
+			event.Id,
+			event.Name,
+			event.VenueId,
+			event.SportId,
+			event.ParticipantsId,
+			s.dialect.Time(event.AdvertisedStartTime.AsTime()),
+			s.dialect.Time(event.AdvertisedEndTime.AsTime()),
+		)
 		if err != nil {
 			return err
 		}
-		_, err = statement.Exec(
-			i,
-			faker.Team().Name(),
-			faker.Number().Between(1, 30),
-			faker.Number().Between(1, 20),
-			faker.Number().Between(1, 10),
-			faker.Time().Between(time.Now().AddDate(0, 0, -5), time.Now().AddDate(0, 0, 2)).Format(time.RFC3339),
-			faker.Time().Between(time.Now().AddDate(0, 0, 3), time.Now().AddDate(0, 0, 5)).Format(time.RFC3339),
-		)
-		if err != nil {
+	}
+
+	// The imports insert events under generated ids, which must skip the seeded ones.
+	if resync := s.dialect.ResyncID("events"); resync != "" {
+		if _, err := tx.ExecContext(ctx, resync); err != nil {
 			return err
 		}
 	}
 
-	return nil
+	return tx.Commit()
 }
@@ -0,0 +1,607 @@
+// Package dbtest is a conformance suite for SportsRepo implementations. Like testing/fstest it reports
+// mismatches as an error, so the same suite can be run by the tests of every implementation.
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"git.neds.sh/matty/entain/pkg/clock"
+	"git.neds.sh/matty/entain/pkg/dialect"
+	"git.neds.sh/matty/entain/pkg/fieldmask"
+	"git.neds.sh/matty/entain/pkg/migrate"
+	"git.neds.sh/matty/entain/pkg/search"
+	"git.neds.sh/matty/entain/sports/db"
+	"git.neds.sh/matty/entain/sports/proto/sports"
+)
+
+// NewRepo creates the repository under test holding exactly the given events and telling the time with clk.
+type NewRepo func(ctx context.Context, clk clock.Clock, events []*sports.Event) (db.SportsRepo, error)
+
+// Now is the time the fixtures are scheduled around and the clock of the repository under test is stopped at.
+var Now = time.Date(2024, time.May, 1, 12, 0, 0, 0, time.UTC)
+
+// Events returns the fixture events of the suite. Start and end times are relative to now,
+// so the statuses are known.
+func Events(now time.Time) []*sports.Event {
+	now = now.UTC().Truncate(time.Second)
+
+	event := func(id int64, name string, venueID, sportID, participantsID int64, start, end time.Duration) *sports.Event {
+		return &sports.Event{
+			Id:                  id,
+			Name:                name,
+			VenueId:             venueID,
+			SportId:             sportID,
+			ParticipantsId:      participantsID,
+			AdvertisedStartTime: timestamppb.New(now.Add(start)),
+			AdvertisedEndTime:   timestamppb.New(now.Add(end)),
+		}
+	}
+
+	return []*sports.Event{
+		event(1, "Alpha", 30, 1, 1, -3*time.Hour, -time.Hour),
+		event(2, "Bravo", 10, 1, 2, -time.Hour, time.Hour),
+		event(3, "Charlie", 50, 2, 3, time.Hour, 3*time.Hour),
+		event(4, "Delta", 20, 2, 4, -2*time.Hour, 2*time.Hour),
+		event(5, "Echo", 40, 3, 5, 2*time.Hour, 4*time.Hour),
+	}
+}
+
+// listCase is an EventsList call with the events expected back, in order when ordered is set.
+// The call is made with a simulated time that far after Now when simulate is set.
+type listCase struct {
+	name     string
+	filter   *sports.ListEventsRequestFilter
+	orderBy  string
+	simulate time.Duration
+	want     []int64
+	ordered  bool
+}
+
+// TestSportsRepo loads the fixture events with newRepo and checks that filters, ordering, statuses, simulated times
+// and timestamps behave the same as in every other implementation. It returns all the mismatches found.
+func TestSportsRepo(ctx context.Context, newRepo NewRepo) error {
+	fixtures := Events(Now)
+
+	repo, err := newRepo(ctx, clock.Fixed(Now), fixtures)
+	if err != nil {
+		return fmt.Errorf("failed creating repository: %w", err)
+	}
+
+	if err := repo.Init(ctx); err != nil {
+		return fmt.Errorf("failed initialising repository: %w", err)
+	}
+
+	sportID, otherSportID, unknownSportID := int64(1), int64(2), int64(9)
+	at := func(d time.Duration) *timestamppb.Timestamp { return timestamppb.New(Now.Add(d)) }
+
+	cases := []listCase{
+		{name: "no filter", want: []int64{1, 2, 3, 4, 5}},
+		{name: "empty filter", filter: &sports.ListEventsRequestFilter{}, want: []int64{1, 2, 3, 4, 5}},
+		{name: "sport id", filter: &sports.ListEventsRequestFilter{SportId: &sportID}, want: []int64{1, 2}},
+		{name: "unknown sport id", filter: &sports.ListEventsRequestFilter{SportId: &unknownSportID}},
+		{name: "closed", filter: &sports.ListEventsRequestFilter{Status: "CLOSED"}, want: []int64{1}},
+		{name: "open", filter: &sports.ListEventsRequestFilter{Status: " open "}, want: []int64{3, 5}},
+		{name: "ongoing", filter: &sports.ListEventsRequestFilter{Status: "ONGOING"}, want: []int64{2, 4}},
+		{name: "unknown status", filter: &sports.ListEventsRequestFilter{Status: "DELAYED"}, want: []int64{1, 2, 3, 4, 5}},
+		{name: "sport id and ongoing", filter: &sports.ListEventsRequestFilter{SportId: &otherSportID, Status: "ONGOING"}, want: []int64{4}},
+		{name: "order by start", orderBy: "advertised_start_time", want: []int64{1, 4, 2, 3, 5}, ordered: true},
+		{name: "order by end desc", orderBy: "advertised_end_time desc", want: []int64{5, 3, 4, 2, 1}, ordered: true},
+		{name: "order by venue desc", orderBy: " venue_id DESC ", want: []int64{3, 5, 1, 4, 2}, ordered: true},
+		{name: "order by status", orderBy: "status", want: []int64{1, 2, 3, 4, 5}},
+		{name: "open ordered by start", filter: &sports.ListEventsRequestFilter{Status: "OPEN"}, orderBy: "advertised_start_time asc", want: []int64{3, 5}, ordered: true},
+		{name: "simulated time", simulate: 150 * time.Minute, want: []int64{1, 2, 3, 4, 5}},
+		{name: "ongoing at a simulated time", filter: &sports.ListEventsRequestFilter{Status: "ONGOING"}, simulate: 150 * time.Minute, want: []int64{3, 5}},
+		{name: "closed at a simulated time", filter: &sports.ListEventsRequestFilter{Status: "CLOSED"}, simulate: 150 * time.Minute, want: []int64{1, 2, 4}},
+		{name: "start time from", filter: &sports.ListEventsRequestFilter{StartTimeFrom: at(0)}, want: []int64{3, 5}},
+		{name: "start time to", filter: &sports.ListEventsRequestFilter{StartTimeTo: at(0)}, want: []int64{1, 2, 4}},
+		{name: "start time range", filter: &sports.ListEventsRequestFilter{StartTimeFrom: at(-2 * time.Hour), StartTimeTo: at(time.Hour)}, want: []int64{2, 4}},
+		{name: "local date in UTC", filter: &sports.ListEventsRequestFilter{LocalDate: "2024-05-01"}, want: []int64{1, 2, 3, 4, 5}},
+		{name: "local date in Melbourne", filter: &sports.ListEventsRequestFilter{LocalDate: "2024-05-01", TimeZone: "Australia/Melbourne"}, want: []int64{1, 2, 3, 4}},
+		{name: "next local date in Melbourne", filter: &sports.ListEventsRequestFilter{LocalDate: "2024-05-02", TimeZone: "Australia/Melbourne"}, want: []int64{5}},
+		{name: "ongoing from a start time", filter: &sports.ListEventsRequestFilter{Status: "ONGOING", StartTimeFrom: at(-time.Hour)}, want: []int64{2}},
+		{name: "open at a simulated time in the past", filter: &sports.ListEventsRequestFilter{Status: "OPEN"}, simulate: -150 * time.Minute, want: []int64{2, 3, 4, 5}},
+	}
+
+	byID := make(map[int64]*sports.Event, len(fixtures))
+	for _, event := range fixtures {
+		byID[event.Id] = event
+	}
+
+	var errs []error
+
+	for _, c := range cases {
+		listCtx, at := ctx, Now
+		if c.simulate != 0 {
+			at = Now.Add(c.simulate)
+			listCtx = clock.WithSimulatedTime(ctx, at)
+		}
+
+		events, err := repo.EventsList(listCtx, c.filter, c.orderBy, nil)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("EventsList %s: %w", c.name, err))
+			continue
+		}
+
+		if err := compareIDs(ids(events), c.want, c.ordered); err != nil {
+			errs = append(errs, fmt.Errorf("EventsList %s: %w", c.name, err))
+		}
+
+		for _, event := range events {
+			if want, ok := byID[event.Id]; ok {
+				if err := compareEvent(event, want, at); err != nil {
+					errs = append(errs, fmt.Errorf("EventsList %s: %w", c.name, err))
+				}
+			}
+		}
+	}
+
+	for _, filter := range []*sports.ListEventsRequestFilter{
+		{StartTimeFrom: at(time.Hour), StartTimeTo: at(-time.Hour)},
+		{LocalDate: "2024-13-01"},
+		{LocalDate: "01/05/2024"},
+		{LocalDate: "2024-05-01", TimeZone: "Mars/Olympus_Mons"},
+		{TimeZone: "Australia/Melbourne"},
+	} {
+		if _, err := repo.EventsList(ctx, filter, "", nil); err == nil {
+			errs = append(errs, fmt.Errorf("EventsList with filter %v: expected an error", filter))
+		}
+	}
+
+	for _, orderBy := range []string{"unknown_field", "venue_id sideways", "venue_id desc extra"} {
+		if _, err := repo.EventsList(ctx, nil, orderBy, nil); err == nil {
+			errs = append(errs, fmt.Errorf("EventsList ordered by %q: expected an error", orderBy))
+		}
+	}
+
+	for _, at := range []time.Time{Now, Now.Add(150 * time.Minute)} {
+		getCtx := ctx
+		if !at.Equal(Now) {
+			getCtx = clock.WithSimulatedTime(ctx, at)
+		}
+
+		for _, want := range fixtures {
+			event, err := repo.GetEventByID(getCtx, want.Id, nil)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("GetEventByID %d at %s: %w", want.Id, at, err))
+				continue
+			}
+
+			if err := compareEvent(event, want, at); err != nil {
+				errs = append(errs, fmt.Errorf("GetEventByID %d at %s: %w", want.Id, at, err))
+			}
+		}
+	}
+
+	if _, err := repo.GetEventByID(ctx, 999, nil); err == nil {
+		errs = append(errs, errors.New("GetEventByID 999: expected an error for a missing event"))
+	}
+
+	errs = append(errs, testReadMasks(ctx, repo, fixtures)...)
+
+	events, err := repo.GetEventsByIDs(ctx, []int64{4, 999, 1})
+	if err != nil {
+		errs = append(errs, fmt.Errorf("GetEventsByIDs: %w", err))
+	} else {
+		if err := compareIDs(ids(events), []int64{1, 4}, false); err != nil {
+			errs = append(errs, fmt.Errorf("GetEventsByIDs: %w", err))
+		}
+
+		for _, event := range events {
+			if want, ok := byID[event.Id]; ok {
+				if err := compareEvent(event, want, Now); err != nil {
+					errs = append(errs, fmt.Errorf("GetEventsByIDs: %w", err))
+				}
+			}
+		}
+	}
+
+	if events, err := repo.GetEventsByIDs(ctx, nil); err != nil || len(events) != 0 {
+		errs = append(errs, fmt.Errorf("GetEventsByIDs without ids: got %v, %v, want no events", ids(events), err))
+	}
+
+	// Search and upserts run last as they load their own events.
+	errs = append(errs, testSearch(ctx, newRepo)...)
+	errs = append(errs, testUpsertEvents(ctx, newRepo)...)
+
+	return errors.Join(errs...)
+}
+
+// testReadMasks checks that EventsList and GetEventByID return only the fields of a read mask, given by proto or
+// JSON names, and reject unknown fields.
+func testReadMasks(ctx context.Context, repo db.SportsRepo, fixtures []*sports.Event) []error {
+	var errs []error
+
+	for _, paths := range [][]string{
+		{"name", "advertisedStartTime"},
+		{"id", "status"},
+		{"venue_id", "sport_id", "participantsId", "advertised_end_time"},
+	} {
+		mask := &fieldmaskpb.FieldMask{Paths: paths}
+
+		events, err := repo.EventsList(ctx, nil, "id", mask)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("EventsList with read mask %v: %w", paths, err))
+			continue
+		}
+
+		if len(events) != len(fixtures) {
+			errs = append(errs, fmt.Errorf("EventsList with read mask %v: got %d events, want %d", paths, len(events), len(fixtures)))
+			continue
+		}
+
+		for i, want := range fixtures {
+			if err := compareMasked(events[i], want, mask); err != nil {
+				errs = append(errs, fmt.Errorf("EventsList with read mask %v: %w", paths, err))
+			}
+
+			event, err := repo.GetEventByID(ctx, want.Id, mask)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("GetEventByID %d with read mask %v: %w", want.Id, paths, err))
+				continue
+			}
+
+			if err := compareMasked(event, want, mask); err != nil {
+				errs = append(errs, fmt.Errorf("GetEventByID %d with read mask %v: %w", want.Id, paths, err))
+			}
+		}
+	}
+
+	// The status filter still applies when the advertised times are left out of the mask.
+	events, err := repo.EventsList(ctx, &sports.ListEventsRequestFilter{Status: "ONGOING"}, "", &fieldmaskpb.FieldMask{Paths: []string{"id"}})
+	if err != nil {
+		errs = append(errs, fmt.Errorf("EventsList ongoing with read mask [id]: %w", err))
+	} else if err := compareIDs(ids(events), []int64{2, 4}, false); err != nil {
+		errs = append(errs, fmt.Errorf("EventsList ongoing with read mask [id]: %w", err))
+	}
+
+	unknown := &fieldmaskpb.FieldMask{Paths: []string{"id", "unknown_field"}}
+
+	if _, err := repo.EventsList(ctx, nil, "", unknown); err == nil {
+		errs = append(errs, errors.New("EventsList with an unknown read mask field: expected an error"))
+	}
+
+	if _, err := repo.GetEventByID(ctx, fixtures[0].Id, unknown); err == nil {
+		errs = append(errs, errors.New("GetEventByID with an unknown read mask field: expected an error"))
+	}
+
+	return errs
+}
+
+// searchCase is a Search call with the events expected back in order and the snippet of the first one.
+type searchCase struct {
+	query   string
+	limit   int
+	want    []int64
+	snippet string
+}
+
+// testSearch loads events with multi-word names and checks that Search matches word prefixes whatever their case
+// and punctuation, ranks the closer matches first and highlights the matched words of the escaped name.
+func testSearch(ctx context.Context, newRepo NewRepo) []error {
+	fixtures := Events(Now)
+	for i, name := range []string{"Richmond Tigers v Carlton Blues", "Tigers", "Lions", "Fish & Chips <img src=x onerror=alert(1)> Cup", "Hawks Handicap"} {
+		fixtures[i].Name = name
+	}
+
+	repo, err := newRepo(ctx, clock.Fixed(Now), fixtures)
+	if err != nil {
+		return []error{fmt.Errorf("failed creating search repository: %w", err)}
+	}
+
+	byID := make(map[int64]*sports.Event, len(fixtures))
+	for _, event := range fixtures {
+		byID[event.Id] = event
+	}
+
+	var errs []error
+
+	for _, c := range []searchCase{
+		{query: "tigers", limit: 10, want: []int64{2, 1}, snippet: "<mark>Tigers</mark>"},
+		{query: "TIG", limit: 1, want: []int64{2}, snippet: "<mark>Tigers</mark>"},
+		{query: "richmond tigers", limit: 10, want: []int64{1}, snippet: "<mark>Richmond</mark> <mark>Tigers</mark> v Carlton Blues"},
+		{query: `"hand*" OR lions`, limit: 10},
+		{query: "hand-", limit: 10, want: []int64{5}, snippet: "Hawks <mark>Handicap</mark>"},
+		{query: "chips", limit: 10, want: []int64{4}, snippet: "Fish &amp; <mark>Chips</mark> &lt;img src=x onerror=alert(1)&gt; Cup"},
+		{query: "zebras", limit: 10},
+	} {
+		results, err := repo.Search(ctx, c.query, c.limit)
+		if errors.Is(err, search.ErrNoFTS5) && !dialect.FTS5 {
+			// SQLite without FTS5 has no index to search.
+			return nil
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Search %q: %w", c.query, err))
+			continue
+		}
+
+		got := make([]int64, 0, len(results))
+		for _, result := range results {
+			got = append(got, result.Event.Id)
+
+			if err := compareEvent(result.Event, byID[result.Event.Id], Now); err != nil {
+				errs = append(errs, fmt.Errorf("Search %q: %w", c.query, err))
+			}
+		}
+
+		if err := compareIDs(got, c.want, true); err != nil {
+			errs = append(errs, fmt.Errorf("Search %q: %w", c.query, err))
+			continue
+		}
+
+		if len(results) > 0 && results[0].Snippet != c.snippet {
+			errs = append(errs, fmt.Errorf("Search %q: got snippet %q, want %q", c.query, results[0].Snippet, c.snippet))
+		}
+	}
+
+	for _, query := range []string{"", " -*- "} {
+		if _, err := repo.Search(ctx, query, 10); err == nil {
+			errs = append(errs, fmt.Errorf("Search %q: expected an error for an empty query", query))
+		}
+	}
+
+	return errs
+}
+
+// upsertCase is an UpsertEvents call with the events expected created, and the external references of all the
+// imported events after it.
+type upsertCase struct {
+	name    string
+	events  []*sports.Event
+	dryRun  bool
+	created []bool
+	want    []string
+}
+
+// testUpsertEvents loads the fixtures and checks that UpsertEvents gives the events of new external references new
+// ids, updates the events of known references in place, counting the changes of their times in their sequence, and
+// saves nothing on a dry run. The ids are generated by the database, which may skip some, so only their stability
+// is checked.
+func testUpsertEvents(ctx context.Context, newRepo NewRepo) []error {
+	fixtures := Events(Now)
+
+	repo, err := newRepo(ctx, clock.Fixed(Now), fixtures)
+	if err != nil {
+		return []error{fmt.Errorf("failed creating upsert repository: %w", err)}
+	}
+
+	imported := func(ref, name string, start time.Duration) *sports.Event {
+		return &sports.Event{
+			ExternalRef:         ref,
+			Name:                name,
+			VenueId:             7,
+			SportId:             4,
+			ParticipantsId:      8,
+			AdvertisedStartTime: timestamppb.New(Now.Add(start)),
+			AdvertisedEndTime:   timestamppb.New(Now.Add(start + 2*time.Hour)),
+		}
+	}
+
+	foxtrot, golf := imported("FIX-F", "Foxtrot", time.Hour), imported("FIX-G", "Golf", -time.Hour)
+	renamed, hotel := imported("FIX-F", "Foxtrot Final", 3*time.Hour), imported("FIX-H", "Hotel", 5*time.Hour)
+	// Moving the event counts a change of its times.
+	renamed.Sequence = 1
+
+	var errs []error
+
+	// byReference holds the id each imported event got when first saved.
+	byReference := make(map[string]int64)
+
+	for _, c := range []upsertCase{
+		{name: "dry run", events: []*sports.Event{foxtrot, golf}, dryRun: true, created: []bool{true, true}},
+		{name: "new references", events: []*sports.Event{foxtrot, golf}, created: []bool{true, true}, want: []string{"FIX-F", "FIX-G"}},
+		{name: "known and new references", events: []*sports.Event{renamed, hotel}, created: []bool{false, true}, want: []string{"FIX-F", "FIX-G", "FIX-H"}},
+		{name: "dry run of known references", events: []*sports.Event{foxtrot}, dryRun: true, created: []bool{false}, want: []string{"FIX-F", "FIX-G", "FIX-H"}},
+	} {
+		created, err := repo.UpsertEvents(ctx, c.events, c.dryRun)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("UpsertEvents %s: %w", c.name, err))
+			continue
+		}
+
+		if fmt.Sprint(created) != fmt.Sprint(c.created) {
+			errs = append(errs, fmt.Errorf("UpsertEvents %s: got created %v, want %v", c.name, created, c.created))
+		}
+
+		events, err := repo.EventsList(ctx, nil, "", nil)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("UpsertEvents %s: %w", c.name, err))
+			continue
+		}
+
+		var refs []string
+		seen := make(map[int64]bool, len(events))
+		for _, event := range events {
+			if seen[event.Id] {
+				errs = append(errs, fmt.Errorf("UpsertEvents %s: id %d given twice", c.name, event.Id))
+			}
+			seen[event.Id] = true
+
+			if event.ExternalRef == "" {
+				continue
+			}
+			refs = append(refs, event.ExternalRef)
+
+			if id, ok := byReference[event.ExternalRef]; ok && id != event.Id {
+				errs = append(errs, fmt.Errorf("UpsertEvents %s: %s moved from id %d to %d", c.name, event.ExternalRef, id, event.Id))
+			}
+			byReference[event.ExternalRef] = event.Id
+		}
+		sort.Strings(refs)
+
+		if len(events) != len(fixtures)+len(refs) || fmt.Sprint(refs) != fmt.Sprint(c.want) {
+			errs = append(errs, fmt.Errorf("UpsertEvents %s: got %d events imported as %v, want %d as %v",
+				c.name, len(events), refs, len(fixtures)+len(c.want), c.want))
+		}
+	}
+
+	for _, want := range []*sports.Event{renamed, golf, hotel} {
+		want.Id = byReference[want.ExternalRef]
+
+		event, err := repo.GetEventByID(ctx, want.Id, nil)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("UpsertEvents: GetEventByID(%d): %w", want.Id, err))
+			continue
+		}
+
+		if err := compareEvent(event, want, Now); err != nil {
+			errs = append(errs, fmt.Errorf("UpsertEvents: %w", err))
+		}
+	}
+
+	return errs
+}
+
+// SQL returns a NewRepo which migrates the database, replaces its events with the given ones
+// and wraps it in the SQL repository.
+func SQL(sqlDB *sql.DB, d dialect.Dialect) NewRepo {
+	return func(ctx context.Context, clk clock.Clock, events []*sports.Event) (_ db.SportsRepo, err error) {
+		migrator, err := migrate.New(sqlDB, d, db.Migrations(d))
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := migrator.Up(ctx); err != nil {
+			return nil, err
+		}
+
+		tx, err := sqlDB.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err != nil {
+				tx.Rollback()
+			}
+		}()
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM events`); err != nil {
+			return nil, err
+		}
+
+		insert := d.Rebind(`INSERT INTO events(id, name, venue_id, sport_id, participants_id, advertised_start_time, advertised_end_time) VALUES (?,?,?,?,?,?,?)`)
+		for _, event := range events {
+			if _, err := tx.ExecContext(ctx, insert, event.Id, event.Name, event.VenueId, event.SportId, event.ParticipantsId,
+				d.Time(event.AdvertisedStartTime.AsTime()), d.Time(event.AdvertisedEndTime.AsTime())); err != nil {
+				return nil, err
+			}
+		}
+
+		// The imports insert events under generated ids, which must skip the ones inserted above.
+		if resync := d.ResyncID("events"); resync != "" {
+			if _, err := tx.ExecContext(ctx, resync); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+
+		return db.NewSportsRepo(sqlDB, d, clk, nil), nil
+	}
+}
+
+// Memory returns a NewRepo which creates an in-memory repository holding the given events.
+func Memory() NewRepo {
+	return func(ctx context.Context, clk clock.Clock, events []*sports.Event) (db.SportsRepo, error) {
+		return db.NewMemorySportsRepo(events, clk, nil), nil
+	}
+}
+
+// compareEvent checks the fields of an event returned by the repository against its fixture, with its status at the given time.
+func compareEvent(got, want *sports.Event, at time.Time) error {
+	start, end := want.AdvertisedStartTime.AsTime(), want.AdvertisedEndTime.AsTime()
+
+	status := "CLOSED"
+	if start.After(at) {
+		status = "OPEN"
+	} else if end.After(at) {
+		status = "ONGOING"
+	}
+
+	switch {
+	case got.Name != want.Name || got.VenueId != want.VenueId || got.SportId != want.SportId || got.ParticipantsId != want.ParticipantsId ||
+		got.ExternalRef != want.ExternalRef || got.Sequence != want.Sequence:
+		return fmt.Errorf("event %d: got %v, want %v", want.Id, got, want)
+	case got.AdvertisedStartTime == nil || !got.AdvertisedStartTime.AsTime().Equal(start):
+		return fmt.Errorf("event %d: got start %v, want %v", want.Id, got.AdvertisedStartTime.AsTime(), start)
+	case got.AdvertisedEndTime == nil || !got.AdvertisedEndTime.AsTime().Equal(end):
+		return fmt.Errorf("event %d: got end %v, want %v", want.Id, got.AdvertisedEndTime.AsTime(), end)
+	case got.Status != status:
+		return fmt.Errorf("event %d: got status %s, want %s", want.Id, got.Status, status)
+	}
+
+	return nil
+}
+
+// compareMasked checks an event returned with a read mask against its fixture with its status at Now,
+// pruned to the fields of the mask.
+func compareMasked(got, want *sports.Event, mask *fieldmaskpb.FieldMask) error {
+	paths, err := fieldmask.Paths(mask, want)
+	if err != nil {
+		return err
+	}
+
+	want = proto.Clone(want).(*sports.Event)
+	want.Status = "CLOSED"
+	if want.AdvertisedStartTime.AsTime().After(Now) {
+		want.Status = "OPEN"
+	} else if want.AdvertisedEndTime.AsTime().After(Now) {
+		want.Status = "ONGOING"
+	}
+	fieldmask.Prune(want, paths)
+
+	if !proto.Equal(got, want) {
+		return fmt.Errorf("got %v, want %v", got, want)
+	}
+
+	return nil
+}
+
+func ids(events []*sports.Event) []int64 {
+	ids := make([]int64, 0, len(events))
+	for _, event := range events {
+		ids = append(ids, event.Id)
+	}
+
+	return ids
+}
+
+// compareIDs checks the returned ids against the expected ones, ignoring their order unless ordered is set.
+func compareIDs(got, want []int64, ordered bool) error {
+	if len(got) != len(want) {
+		return fmt.Errorf("got events %v, want %v", got, want)
+	}
+
+	if ordered {
+		for i := range got {
+			if got[i] != want[i] {
+				return fmt.Errorf("got events %v, want %v", got, want)
+			}
+		}
+
+		return nil
+	}
+
+	seen := make(map[int64]bool, len(got))
+	for _, id := range got {
+		seen[id] = true
+	}
+
+	for _, id := range want {
+		if !seen[id] {
+			return fmt.Errorf("got events %v, want %v", got, want)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,276 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+
+	"git.neds.sh/matty/entain/pkg/clock"
+	"git.neds.sh/matty/entain/pkg/fieldmask"
+	"git.neds.sh/matty/entain/pkg/search"
+	"git.neds.sh/matty/entain/pkg/timewindow"
+	"git.neds.sh/matty/entain/sports/proto/sports"
+)
+
+type memorySportsRepo struct {
+	mu     sync.RWMutex
+	events map[int64]*sports.Event
+	clock  clock.Clock
+	seed   *SeedOptions
+	init   sync.Once
+}
+
+// NewMemorySportsRepo creates a sports repository holding the given events in memory, for tests and demos.
+// It filters and orders events and computes their statuses like the SQL repository, and inserts dummy events on Init
+// when seed is set.
+func NewMemorySportsRepo(events []*sports.Event, clk clock.Clock, seed *SeedOptions) SportsRepo {
+	s := &memorySportsRepo{events: make(map[int64]*sports.Event, len(events)), clock: clk, seed: seed}
+
+	for _, event := range events {
+		s.events[event.Id] = proto.Clone(event).(*sports.Event)
+	}
+
+	return s
+}
+
+// Init adds the dummy sport events when seeding, keeping existing events with the same ids unless resetting.
+func (s *memorySportsRepo) Init(ctx context.Context) error {
+	s.init.Do(func() {
+		if s.seed == nil {
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.seed.Reset {
+			s.events = make(map[int64]*sports.Event)
+		}
+
+		for _, event := range GenerateEvents(*s.seed) {
+			if _, ok := s.events[event.Id]; !ok {
+				s.events[event.Id] = event
+			}
+		}
+	})
+
+	return nil
+}
+
+// GetEventByID will return the information of a given event id.
+func (s *memorySportsRepo) GetEventByID(ctx context.Context, id int64, mask *fieldmaskpb.FieldMask) (*sports.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	paths, err := fieldmask.Paths(mask, &sports.Event{})
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	event, ok := s.events[id]
+	if !ok {
+		return nil, fmt.Errorf("event not found")
+	}
+
+	event = withStatus(event, clock.FromContext(ctx, s.clock).Now())
+	fieldmask.Prune(event, paths)
+
+	return event, nil
+}
+
+// GetEventsByIDs will return the sport events of the given ids, in no particular order.
+func (s *memorySportsRepo) GetEventsByIDs(ctx context.Context, ids []int64) ([]*sports.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	now := clock.FromContext(ctx, s.clock).Now()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var events []*sports.Event
+	for _, id := range ids {
+		if event, ok := s.events[id]; ok {
+			events = append(events, withStatus(event, now))
+		}
+	}
+
+	return events, nil
+}
+
+// Search will return the sport events whose name matches the query, scored by the share of the name matched.
+func (s *memorySportsRepo) Search(ctx context.Context, query string, limit int) ([]*sports.SearchResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	terms, err := search.Terms(query)
+	if err != nil {
+		return nil, err
+	}
+
+	now := clock.FromContext(ctx, s.clock).Now()
+
+	s.mu.RLock()
+	var results []*sports.SearchResult
+	for _, event := range s.events {
+		if score, snippet, ok := search.Match(event.Name, terms); ok {
+			results = append(results, &sports.SearchResult{Event: withStatus(event, now), Score: score, Snippet: snippet})
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+
+		return results[i].Event.Id < results[j].Event.Id
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// EventsList returns the sport events matching the filter, sorted like the ORDER BY clause of the SQL repository.
+func (s *memorySportsRepo) EventsList(ctx context.Context, filter *sports.ListEventsRequestFilter, orderBy string, mask *fieldmaskpb.FieldMask) ([]*sports.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	paths, err := fieldmask.Paths(mask, &sports.Event{})
+	if err != nil {
+		return nil, err
+	}
+
+	column, order, err := parseOrderBy(orderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	var window timewindow.Window
+	if filter != nil {
+		if window, err = timewindow.New(filter.StartTimeFrom, filter.StartTimeTo, filter.LocalDate, filter.TimeZone); err != nil {
+			return nil, err
+		}
+	}
+
+	now := clock.FromContext(ctx, s.clock).Now()
+
+	s.mu.RLock()
+	var events []*sports.Event
+	for _, event := range s.events {
+		if matchesFilter(event, filter, window, now) {
+			events = append(events, withStatus(event, now))
+		}
+	}
+	s.mu.RUnlock()
+
+	// Without ORDER BY the SQL repository returns the events in id order.
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Id < events[j].Id
+	})
+
+	if len(column) != 0 {
+		less, err := eventLess(column)
+		if err != nil {
+			return nil, err
+		}
+
+		sort.SliceStable(events, func(i, j int) bool {
+			if order == "DESC" {
+				return less(events[j], events[i])
+			}
+
+			return less(events[i], events[j])
+		})
+	}
+
+	// Fields are pruned once sorted, since the order may depend on fields left out of the mask.
+	for _, event := range events {
+		fieldmask.Prune(event, paths)
+	}
+
+	return events, nil
+}
+
+// matchesFilter reports whether the event satisfies every condition of the filter at the given time, starting in
+// the window of its time filters.
+func matchesFilter(event *sports.Event, filter *sports.ListEventsRequestFilter, window timewindow.Window, now time.Time) bool {
+	if filter == nil {
+		return true
+	}
+
+	if filter.SportId != nil && event.SportId != *filter.SportId {
+		return false
+	}
+
+	start, end := event.AdvertisedStartTime.AsTime(), event.AdvertisedEndTime.AsTime()
+
+	if !window.Contains(start) {
+		return false
+	}
+
+	switch strings.ToUpper(strings.TrimSpace(filter.Status)) {
+	case "CLOSED":
+		return !end.After(now)
+	case "OPEN":
+		return start.After(now)
+	case "ONGOING":
+		return !start.After(now) && end.After(now)
+	}
+
+	return true
+}
+
+// eventLess returns the comparison of events on a column, matching how the databases sort it.
+func eventLess(column string) (func(a, b *sports.Event) bool, error) {
+	switch column {
+	case "id":
+		return func(a, b *sports.Event) bool { return a.Id < b.Id }, nil
+	case "name":
+		return func(a, b *sports.Event) bool { return a.Name < b.Name }, nil
+	case "venue_id":
+		return func(a, b *sports.Event) bool { return a.VenueId < b.VenueId }, nil
+	case "sport_id":
+		return func(a, b *sports.Event) bool { return a.SportId < b.SportId }, nil
+	case "participants_id":
+		return func(a, b *sports.Event) bool { return a.ParticipantsId < b.ParticipantsId }, nil
+	case "advertised_start_time":
+		return func(a, b *sports.Event) bool {
+			return a.AdvertisedStartTime.AsTime().Before(b.AdvertisedStartTime.AsTime())
+		}, nil
+	case "advertised_end_time":
+		return func(a, b *sports.Event) bool {
+			return a.AdvertisedEndTime.AsTime().Before(b.AdvertisedEndTime.AsTime())
+		}, nil
+	case "external_ref":
+		return func(a, b *sports.Event) bool { return a.ExternalRef < b.ExternalRef }, nil
+	case "sequence":
+		return func(a, b *sports.Event) bool { return a.Sequence < b.Sequence }, nil
+	}
+
+	return nil, fmt.Errorf("unable to sort by the column: %s", column)
+}
+
+// withStatus returns a copy of the event with its status at the given time.
+func withStatus(event *sports.Event, now time.Time) *sports.Event {
+	event = proto.Clone(event).(*sports.Event)
+	event.Status = getEventStatus(event.AdvertisedStartTime.AsTime(), event.AdvertisedEndTime.AsTime(), now)
+
+	return event
+}
@@ -0,0 +1,14 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+
+	"git.neds.sh/matty/entain/sports/db/dbtest"
+)
+
+func TestMemorySportsRepo(t *testing.T) {
+	if err := dbtest.TestSportsRepo(context.Background(), dbtest.Memory()); err != nil {
+		t.Error(err)
+	}
+}
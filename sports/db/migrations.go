@@ -0,0 +1,34 @@
+package db
+
+import (
+	"embed"
+	"io/fs"
+	"path"
+
+	"git.neds.sh/matty/entain/pkg/dialect"
+	"git.neds.sh/matty/entain/pkg/migrate"
+)
+
+//go:embed migrations
+var migrations embed.FS
+
+// Migrations returns the numbered schema migrations for a database of the given dialect. Without FTS5, the SQLite
+// search migration creates no index.
+func Migrations(d dialect.Dialect) fs.FS {
+	if d.Name() == dialect.SQLite.Name() && !dialect.FTS5 {
+		return migrate.Override(sub(d.Name()), sub("sqlite_nofts5"))
+	}
+
+	return sub(d.Name())
+}
+
+// sub returns a directory of the embedded migrations.
+func sub(dir string) fs.FS {
+	sub, err := fs.Sub(migrations, path.Join("migrations", dir))
+	if err != nil {
+		// The directory is embedded at build time, so this can only fail on a programming error.
+		panic(err)
+	}
+
+	return sub
+}
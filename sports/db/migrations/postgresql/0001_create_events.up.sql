@@ -0,0 +1 @@
+CREATE TABLE IF NOT EXISTS events (id BIGINT PRIMARY KEY, name TEXT, venue_id BIGINT, sport_id BIGINT, participants_id BIGINT, advertised_start_time TIMESTAMPTZ, advertised_end_time TIMESTAMPTZ);
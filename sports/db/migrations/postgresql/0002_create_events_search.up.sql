@@ -0,0 +1 @@
+CREATE INDEX events_search ON events USING GIN (to_tsvector('simple', name));
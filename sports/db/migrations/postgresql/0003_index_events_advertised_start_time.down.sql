@@ -0,0 +1 @@
+DROP INDEX events_advertised_start_time;
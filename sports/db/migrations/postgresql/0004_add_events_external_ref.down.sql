@@ -0,0 +1,2 @@
+DROP INDEX events_external_ref;
+ALTER TABLE events DROP COLUMN external_ref;
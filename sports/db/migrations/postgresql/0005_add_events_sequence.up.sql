@@ -0,0 +1 @@
+ALTER TABLE events ADD COLUMN sequence BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- The original offsets are not kept, and the UTC times are the same instants.
+SELECT 1;
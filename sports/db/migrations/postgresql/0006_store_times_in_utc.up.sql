@@ -0,0 +1,2 @@
+-- TIMESTAMPTZ values are already compared as instants.
+SELECT 1;
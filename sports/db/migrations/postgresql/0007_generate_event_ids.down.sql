@@ -0,0 +1 @@
+ALTER TABLE events ALTER COLUMN id DROP IDENTITY;
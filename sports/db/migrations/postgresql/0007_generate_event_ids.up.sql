@@ -0,0 +1,3 @@
+-- The imports insert events without an id, so that PostgreSQL generates it.
+ALTER TABLE events ALTER COLUMN id ADD GENERATED BY DEFAULT AS IDENTITY;
+SELECT setval(pg_get_serial_sequence('events', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM events;
@@ -0,0 +1 @@
+CREATE TABLE IF NOT EXISTS events (id INTEGER PRIMARY KEY, name TEXT, venue_id INTEGER, sport_id INTEGER, participants_id INTEGER, advertised_start_time DATETIME, advertised_end_time DATETIME);
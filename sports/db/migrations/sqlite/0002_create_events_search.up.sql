@@ -0,0 +1,12 @@
+CREATE VIRTUAL TABLE events_search USING fts5(name, content='events', content_rowid='id');
+CREATE TRIGGER events_search_insert AFTER INSERT ON events BEGIN
+  INSERT INTO events_search(rowid, name) VALUES (new.id, new.name);
+END;
+CREATE TRIGGER events_search_delete AFTER DELETE ON events BEGIN
+  INSERT INTO events_search(events_search, rowid, name) VALUES ('delete', old.id, old.name);
+END;
+CREATE TRIGGER events_search_update AFTER UPDATE ON events BEGIN
+  INSERT INTO events_search(events_search, rowid, name) VALUES ('delete', old.id, old.name);
+  INSERT INTO events_search(rowid, name) VALUES (new.id, new.name);
+END;
+INSERT INTO events_search(events_search) VALUES ('rebuild');
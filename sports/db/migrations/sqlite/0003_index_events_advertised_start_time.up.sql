@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS events_advertised_start_time ON events (advertised_start_time);
@@ -0,0 +1,2 @@
+ALTER TABLE events ADD COLUMN external_ref TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS events_external_ref ON events (external_ref);
@@ -0,0 +1,4 @@
+-- Rows stored before the repositories wrote UTC keep their original offset, e.g. +10:00, and would compare
+-- and sort wrongly against the UTC RFC3339 arguments of the queries.
+UPDATE events SET advertised_start_time = strftime('%Y-%m-%dT%H:%M:%SZ', advertised_start_time) WHERE advertised_start_time NOT LIKE '%Z';
+UPDATE events SET advertised_end_time = strftime('%Y-%m-%dT%H:%M:%SZ', advertised_end_time) WHERE advertised_end_time NOT LIKE '%Z';
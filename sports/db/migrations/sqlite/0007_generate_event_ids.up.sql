@@ -0,0 +1,2 @@
+-- The imports insert events without an id, which SQLite generates for the INTEGER PRIMARY KEY from the highest one.
+SELECT 1;
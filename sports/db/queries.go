@@ -1,21 +1,67 @@
 package db
 
+import "git.neds.sh/matty/entain/pkg/dialect"
+
 const (
 	eventsList = "list"
+
+	eventsByReference = "byReference"
+	eventsInsert      = "insert"
+	eventsUpdate      = "update"
+	eventsResequence  = "resequence"
 )
 
 func getEventsQueries() map[string]string {
 	return map[string]string{
 		eventsList: `
-			SELECT 
-				id,
-				name, 
-				venue_id, 
-				sport_id,
-				participants_id,
-				advertised_start_time,
-				advertised_end_time 
+			SELECT %s
+			FROM events
+		`,
+	}
+}
+
+// getEventUpsertQueries returns the queries saving imported sport events, matched by external reference.
+func getEventUpsertQueries() map[string]string {
+	return map[string]string{
+		eventsByReference: `SELECT id FROM events WHERE external_ref = ?`,
+		eventsInsert: `
+			INSERT INTO events(external_ref, name, venue_id, sport_id, participants_id, advertised_start_time, advertised_end_time)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (external_ref) DO NOTHING
+			RETURNING id
+		`,
+		eventsResequence: `
+			UPDATE events
+			SET sequence = sequence + 1
+			WHERE id = ? AND (advertised_start_time <> ? OR advertised_end_time <> ?)
+		`,
+		eventsUpdate: `
+			UPDATE events
+			SET name = ?, venue_id = ?, sport_id = ?, participants_id = ?, advertised_start_time = ?, advertised_end_time = ?
+			WHERE id = ?
+		`,
+	}
+}
+
+// getEventSearchQueries returns the query ranking the sport events matching a search, by dialect name.
+// The columns of the events are followed by the score, higher being more relevant, and the highlighted name.
+func getEventSearchQueries() map[string]string {
+	return map[string]string{
+		dialect.SQLite.Name(): `
+			SELECT %s, -bm25(events_search) AS score, highlight(events_search, 0, ?, ?)
+			FROM events_search
+			JOIN events ON events.id = events_search.rowid
+			WHERE events_search MATCH ?
+			ORDER BY score DESC, events.id
+			LIMIT ?
+		`,
+		dialect.Postgres.Name(): `
+			SELECT %s, ts_rank(to_tsvector('simple', events.name), to_tsquery('simple', ?), 1) AS score,
+				ts_headline('simple', events.name, to_tsquery('simple', ?), ?)
 			FROM events
+			WHERE to_tsvector('simple', events.name) @@ to_tsquery('simple', ?)
+			ORDER BY score DESC, events.id
+			LIMIT ?
 		`,
 	}
 }
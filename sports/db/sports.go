@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"reflect"
@@ -8,59 +9,92 @@ import (
 	"sync"
 	"time"
 
+	"git.neds.sh/matty/entain/pkg/clock"
+	"git.neds.sh/matty/entain/pkg/dialect"
+	"git.neds.sh/matty/entain/pkg/fieldmask"
+	"git.neds.sh/matty/entain/pkg/search"
+	"git.neds.sh/matty/entain/pkg/timewindow"
+	"git.neds.sh/matty/entain/pkg/tracing"
 	"git.neds.sh/matty/entain/sports/proto/sports"
-	_ "github.com/mattn/go-sqlite3"
+	"go.opentelemetry.io/otel"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+var tracer = otel.Tracer("git.neds.sh/matty/entain/sports/db")
+
+// eventColumns are the columns of the events table, named like the Event fields they hold.
+var eventColumns = []string{"id", "name", "venue_id", "sport_id", "participants_id", "advertised_start_time", "advertised_end_time", "external_ref", "sequence"}
+
 type sportsRepo struct {
-	db   *sql.DB
-	init sync.Once
+	db      *sql.DB
+	dialect dialect.Dialect
+	clock   clock.Clock
+	seed    *SeedOptions
+	init    sync.Once
 }
 
 // SportsRepo provides repository access to sports.
 type SportsRepo interface {
 	// Init will initialise our sports repository.
-	Init() error
-
-	// EventsList will return a list of sport events.
-	EventsList(filter *sports.ListEventsRequestFilter, orderBy string) ([]*sports.Event, error)
-	// GetEventById will return the information of a given event id.
-	GetEventByID(id int64) (*sports.Event, error)
+	Init(ctx context.Context) error
+
+	// EventsList will return a list of sport events, with only the fields of the mask when it is set.
+	EventsList(ctx context.Context, filter *sports.ListEventsRequestFilter, orderBy string, mask *fieldmaskpb.FieldMask) ([]*sports.Event, error)
+	// GetEventById will return the information of a given event id, with only the fields of the mask when it is set.
+	GetEventByID(ctx context.Context, id int64, mask *fieldmaskpb.FieldMask) (*sports.Event, error)
+	// GetEventsByIDs will return the sport events of the given ids in a single query, in no particular order.
+	// Ids without an event are left out.
+	GetEventsByIDs(ctx context.Context, ids []int64) ([]*sports.Event, error)
+	// Search will return at most limit sport events whose name has a word starting with each word of the query,
+	// most relevant first.
+	Search(ctx context.Context, query string, limit int) ([]*sports.SearchResult, error)
+	// UpsertEvents will update the events with the external references of the given ones and insert the others
+	// under new ids, in a single transaction which is rolled back on a dry run. It reports which events were created.
+	UpsertEvents(ctx context.Context, events []*sports.Event, dryRun bool) ([]bool, error)
 }
 
-// NewSportsRepo creates a new sport repository.
-func NewSportsRepo(db *sql.DB) SportsRepo {
-	return &sportsRepo{db: db}
+// NewSportsRepo creates a new sport repository on a database of the given dialect, filtering and computing the
+// event statuses with clk unless the request context carries a simulated time. It inserts dummy sport events on Init
+// when seed is set.
+func NewSportsRepo(db *sql.DB, d dialect.Dialect, clk clock.Clock, seed *SeedOptions) SportsRepo {
+	return &sportsRepo{db: db, dialect: d, clock: clk, seed: seed}
 }
 
-// Init prepares the sports repository dummy data.
-func (s *sportsRepo) Init() error {
+// Init prepares the sports repository dummy data. The schema is created by the migrations.
+func (s *sportsRepo) Init(ctx context.Context) error {
 	var err error
 
 	s.init.Do(func() {
+		if s.seed == nil {
+			return
+		}
+
 		// For test/example purposes, we seed the DB with some dummy sport events.
-		err = s.seed()
+		err = s.seedEvents(ctx)
 	})
 
 	return err
 }
 
 // GetEventByID will return the information of a given event id.
-func (s *sportsRepo) GetEventByID(id int64) (*sports.Event, error) {
-	var event sports.Event
-	var advertisedStart, advertisedEnd time.Time
+func (s *sportsRepo) GetEventByID(ctx context.Context, id int64, mask *fieldmaskpb.FieldMask) (_ *sports.Event, err error) {
+	paths, err := fieldmask.Paths(mask, &sports.Event{})
+	if err != nil {
+		return nil, err
+	}
+
+	columns := selectColumns(paths)
+
+	query := fmt.Sprintf(getEventsQueries()[eventsList], strings.Join(columns, ", ")) + " WHERE id = ?"
 
-	row := s.db.QueryRow(`SELECT id, 
-	name, 
-	venue_id, 
-	sport_id, 
-	participants_id, 
-	advertised_start_time,
-	advertised_end_time
-	FROM events where id=?`, id)
+	ctx, span := tracing.StartQuerySpan(ctx, tracer, s.dialect.Name(), "sportsRepo.GetEventByID", query)
+	defer func() { tracing.EndSpan(span, err) }()
 
-	if err := row.Scan(&event.Id, &event.Name, &event.VenueId, &event.SportId, &event.ParticipantsId, &advertisedStart, &advertisedEnd); err != nil {
+	row := s.db.QueryRowContext(ctx, s.dialect.Rebind(query), id)
+
+	event, err := scanEvent(row.Scan, columns, paths, clock.FromContext(ctx, s.clock).Now())
+	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("event not found")
 		}
@@ -68,49 +102,154 @@ func (s *sportsRepo) GetEventByID(id int64) (*sports.Event, error) {
 		return nil, err
 	}
 
-	// Convert time to proto timestamp.
-	event.AdvertisedStartTime = timestamppb.New(advertisedStart)
-	event.AdvertisedEndTime = timestamppb.New(advertisedEnd)
+	return event, nil
+}
 
-	event.Status = getEventStatus(advertisedStart, advertisedEnd)
+// GetEventsByIDs will return the sport events of the given ids in a single query, in no particular order.
+func (s *sportsRepo) GetEventsByIDs(ctx context.Context, ids []int64) (_ []*sports.Event, err error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
 
-	return &event, nil
+	query := fmt.Sprintf(getEventsQueries()[eventsList], strings.Join(eventColumns, ", ")) +
+		" WHERE id IN (" + strings.Repeat("?,", len(ids)-1) + "?)"
+
+	args := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	ctx, span := tracing.StartQuerySpan(ctx, tracer, s.dialect.Name(), "sportsRepo.GetEventsByIDs", query)
+	defer func() { tracing.EndSpan(span, err) }()
+
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.scanEvents(rows, eventColumns, nil, clock.FromContext(ctx, s.clock).Now())
+}
+
+// Search will return the sport events matching the query from the full-text index of their names,
+// most relevant first. SQLite builds without FTS5 have no index and fail with search.ErrNoFTS5.
+func (s *sportsRepo) Search(ctx context.Context, query string, limit int) (_ []*sports.SearchResult, err error) {
+	if s.dialect.Name() == dialect.SQLite.Name() && !dialect.FTS5 {
+		return nil, search.ErrNoFTS5
+	}
+
+	terms, err := search.Terms(query)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]string, 0, len(eventColumns))
+	for _, column := range eventColumns {
+		columns = append(columns, "events."+column)
+	}
+
+	statement := fmt.Sprintf(getEventSearchQueries()[s.dialect.Name()], strings.Join(columns, ", "))
+
+	var args []interface{}
+	if s.dialect.Name() == dialect.Postgres.Name() {
+		tsQuery := search.TSQuery(terms)
+		headline := "StartSel=" + search.HighlightStart + ", StopSel=" + search.HighlightEnd + ", HighlightAll=true"
+		args = []interface{}{tsQuery, tsQuery, headline, tsQuery, limit}
+	} else {
+		args = []interface{}{search.HighlightStart, search.HighlightEnd, search.FTS5Query(terms), limit}
+	}
+
+	ctx, span := tracing.StartQuerySpan(ctx, tracer, s.dialect.Name(), "sportsRepo.Search", statement)
+	defer func() { tracing.EndSpan(span, err) }()
+
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(statement), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := rows.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	now := clock.FromContext(ctx, s.clock).Now()
+
+	var results []*sports.SearchResult
+
+	for rows.Next() {
+		result := &sports.SearchResult{}
+
+		scan := func(dest ...interface{}) error {
+			return rows.Scan(append(dest, &result.Score, &result.Snippet)...)
+		}
+
+		if result.Event, err = scanEvent(scan, eventColumns, nil, now); err != nil {
+			return nil, err
+		}
+		result.Snippet = search.Snippet(result.Snippet)
+
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
 }
 
 // EventsList will return a list of sport events.
-func (s *sportsRepo) EventsList(filter *sports.ListEventsRequestFilter, orderBy string) ([]*sports.Event, error) {
+func (s *sportsRepo) EventsList(ctx context.Context, filter *sports.ListEventsRequestFilter, orderBy string, mask *fieldmaskpb.FieldMask) (_ []*sports.Event, err error) {
 	var (
-		err   error
 		query string
 		args  []interface{}
 	)
 
-	query = getEventsQueries()[eventsList]
+	paths, err := fieldmask.Paths(mask, &sports.Event{})
+	if err != nil {
+		return nil, err
+	}
+
+	columns := selectColumns(paths)
+
+	now := clock.FromContext(ctx, s.clock).Now()
 
-	query, args = s.applyFilter(query, filter)
+	query = fmt.Sprintf(getEventsQueries()[eventsList], strings.Join(columns, ", "))
+
+	query, args, err = s.applyFilter(query, filter, now)
+	if err != nil {
+		return nil, err
+	}
 
 	query, err = s.applyOrderBy(query, orderBy)
 	if err != nil {
 		return nil, err
 	}
 
-	rows, err := s.db.Query(query, args...)
+	ctx, span := tracing.StartQuerySpan(ctx, tracer, s.dialect.Name(), "sportsRepo.EventsList", query)
+	defer func() { tracing.EndSpan(span, err) }()
+
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(query), args...)
 	if err != nil {
 		return nil, err
 	}
 
-	return s.scanEvents(rows)
+	return s.scanEvents(rows, columns, paths, now)
 }
 
 // applyFilter builds the requested filter for sports events.
-func (s *sportsRepo) applyFilter(query string, filter *sports.ListEventsRequestFilter) (string, []interface{}) {
+func (s *sportsRepo) applyFilter(query string, filter *sports.ListEventsRequestFilter, at time.Time) (string, []interface{}, error) {
 	var (
 		clauses []string
 		args    []interface{}
 	)
 
 	if filter == nil {
-		return query, args
+		return query, args, nil
+	}
+
+	window, err := timewindow.New(filter.StartTimeFrom, filter.StartTimeTo, filter.LocalDate, filter.TimeZone)
+	if err != nil {
+		return "", nil, err
 	}
 
 	// Add filter for sport_id
@@ -122,16 +261,17 @@ func (s *sportsRepo) applyFilter(query string, filter *sports.ListEventsRequestF
 	if len(filter.Status) > 0 {
 		status := strings.TrimSpace(filter.Status)
 		status = strings.ToUpper(status)
-		now := time.Now().Format(time.RFC3339)
+		now := s.dialect.Time(at)
 
+		// Match getEventStatus at the boundaries: an event is ONGOING at its start time and CLOSED at its end time.
 		if status == "CLOSED" {
-			clauses = append(clauses, "advertised_end_time < ?")
+			clauses = append(clauses, "advertised_end_time <= ?")
 			args = append(args, now)
 		} else if status == "OPEN" {
 			clauses = append(clauses, "advertised_start_time > ?")
 			args = append(args, now)
 		} else if status == "ONGOING" {
-			clauses = append(clauses, "advertised_start_time < ?")
+			clauses = append(clauses, "advertised_start_time <= ?")
 			args = append(args, now)
 
 			clauses = append(clauses, "advertised_end_time > ?")
@@ -139,25 +279,53 @@ func (s *sportsRepo) applyFilter(query string, filter *sports.ListEventsRequestF
 		}
 	}
 
+	if !window.From.IsZero() {
+		clauses = append(clauses, "advertised_start_time >= ?")
+		args = append(args, s.dialect.Time(window.From))
+	}
+
+	if !window.To.IsZero() {
+		clauses = append(clauses, "advertised_start_time < ?")
+		args = append(args, s.dialect.Time(window.To))
+	}
+
 	if len(clauses) != 0 {
 		query += " WHERE " + strings.Join(clauses, " AND ")
 	}
 
-	return query, args
+	return query, args, nil
 }
 
 // applyOrderBy adds the ORDER BY clause in the query based on the requested sorting option.
 func (s *sportsRepo) applyOrderBy(query string, orderBy string) (string, error) {
+	column, order, err := parseOrderBy(orderBy)
+	if err != nil || len(column) == 0 {
+		return query, err
+	}
+
+	clause := column
+	if len(order) != 0 {
+		clause += " " + order
+	}
+
+	query += " ORDER BY " + clause
+
+	return query, nil
+}
+
+// parseOrderBy splits the requested sorting option into a column name and an optional ASC or DESC order.
+// The column is empty when no sorting was requested.
+func parseOrderBy(orderBy string) (column, order string, err error) {
 	// Return immediately when orderBy is empty.
 	if len(orderBy) == 0 {
-		return query, nil
+		return "", "", nil
 	}
 
 	orderBy = strings.TrimSpace(orderBy)
 
 	// TODO: Implement sorting using status as it is not a column in the event table.
 	if orderBy == "status" {
-		return query, nil
+		return "", "", nil
 	}
 
 	// Check for desc/asc option.
@@ -167,39 +335,110 @@ func (s *sportsRepo) applyOrderBy(query string, orderBy string) (string, error)
 
 	// Return invalid value error if the length of params is more than 2. Field name and asc/desc are the only values accepted.
 	if paramsLength > 2 {
-		return query, fmt.Errorf("invalid orderBy value: %s Format is `fieldName desc`", orderBy)
+		return "", "", fmt.Errorf("invalid orderBy value: %s Format is `fieldName desc`", orderBy)
 	}
 
 	// Get the corresponding column name.
-	clause := getEventColumnName(fieldName)
+	column = getEventColumnName(fieldName)
 
-	if len(clause) == 0 {
-		return query, fmt.Errorf("unable to find the field name: %s", fieldName)
+	if len(column) == 0 {
+		return "", "", fmt.Errorf("unable to find the field name: %s", fieldName)
 	}
 
 	// Check if asc or desc is provided.
 	if paramsLength == 2 {
-		order := strings.ToUpper(params[1])
+		order = strings.ToUpper(params[1])
 		if order != "ASC" && order != "DESC" {
-			return query, fmt.Errorf("invalid sort order: %s. Choose either `asc` or `desc`", params[1])
+			return "", "", fmt.Errorf("invalid sort order: %s. Choose either `asc` or `desc`", params[1])
 		}
-		clause += " " + order
 	}
 
-	query += " ORDER BY " + clause
+	return column, order, nil
+}
 
-	return query, nil
+// selectColumns returns the columns holding the fields of the paths, along with the advertised times
+// when the status is computed from them.
+func selectColumns(paths []string) []string {
+	var columns []string
+
+	for _, column := range eventColumns {
+		isTime := column == "advertised_start_time" || column == "advertised_end_time"
+		if fieldmask.Selects(paths, column) || isTime && fieldmask.Selects(paths, "status") {
+			columns = append(columns, column)
+		}
+	}
+
+	return columns
 }
 
-// scanEvents copies the data from the database into the values of each event.
-func (s *sportsRepo) scanEvents(rows *sql.Rows) ([]*sports.Event, error) {
+// scanEvent copies the columns of a row into an event with its status at the given time, keeping only the fields
+// of the paths.
+func scanEvent(scan func(dest ...interface{}) error, columns []string, paths []string, now time.Time) (*sports.Event, error) {
+	var event sports.Event
+	var advertisedStart, advertisedEnd time.Time
+	var externalRef sql.NullString
+
+	dest := make([]interface{}, len(columns))
+	for i, column := range columns {
+		switch column {
+		case "id":
+			dest[i] = &event.Id
+		case "name":
+			dest[i] = &event.Name
+		case "venue_id":
+			dest[i] = &event.VenueId
+		case "sport_id":
+			dest[i] = &event.SportId
+		case "participants_id":
+			dest[i] = &event.ParticipantsId
+		case "advertised_start_time":
+			dest[i] = &advertisedStart
+		case "advertised_end_time":
+			dest[i] = &advertisedEnd
+		case "external_ref":
+			dest[i] = &externalRef
+		case "sequence":
+			dest[i] = &event.Sequence
+		}
+	}
+
+	if err := scan(dest...); err != nil {
+		return nil, err
+	}
+
+	// Events which were not imported have no reference.
+	event.ExternalRef = externalRef.String
+
+	// Convert time to proto timestamp.
+	if fieldmask.Selects(paths, "advertised_start_time") {
+		event.AdvertisedStartTime = timestamppb.New(advertisedStart)
+	}
+
+	if fieldmask.Selects(paths, "advertised_end_time") {
+		event.AdvertisedEndTime = timestamppb.New(advertisedEnd)
+	}
+
+	if fieldmask.Selects(paths, "status") {
+		event.Status = getEventStatus(advertisedStart, advertisedEnd, now)
+	}
+
+	return &event, nil
+}
+
+// scanEvents copies the data from the database into the values of each event and closes the rows,
+// returning any error met while iterating.
+func (s *sportsRepo) scanEvents(rows *sql.Rows, columns []string, paths []string, now time.Time) (_ []*sports.Event, err error) {
+	defer func() {
+		if closeErr := rows.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
 	var events []*sports.Event
 
 	for rows.Next() {
-		var event sports.Event
-		var advertisedStart, advertisedEnd time.Time
-
-		if err := rows.Scan(&event.Id, &event.Name, &event.VenueId, &event.SportId, &event.ParticipantsId, &advertisedStart, &advertisedEnd); err != nil {
+		event, err := scanEvent(rows.Scan, columns, paths, now)
+		if err != nil {
 			if err == sql.ErrNoRows {
 				return nil, nil
 			}
@@ -207,27 +446,24 @@ func (s *sportsRepo) scanEvents(rows *sql.Rows) ([]*sports.Event, error) {
 			return nil, err
 		}
 
-		// Convert time to proto timestamp.
-		event.AdvertisedStartTime = timestamppb.New(advertisedStart)
-		event.AdvertisedEndTime = timestamppb.New(advertisedEnd)
-
-		event.Status = getEventStatus(advertisedStart, advertisedEnd)
+		events = append(events, event)
+	}
 
-		events = append(events, &event)
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
 	return events, nil
 }
 
-// getEventStatus gets the correct status of the event: OPEN for future event, ONGOING for event that has started and CLOSED for past event.
-func getEventStatus(advertisedStart, advertisedEnd time.Time) string {
+// getEventStatus gets the correct status of the event at the given time: OPEN for future event, ONGOING from its start
+// until its end and CLOSED from its end.
+func getEventStatus(advertisedStart, advertisedEnd, now time.Time) string {
 	status := "CLOSED"
 
-	now := time.Now()
-
 	if advertisedStart.After(now) {
 		status = "OPEN"
-	} else if advertisedStart.Before(now) && advertisedEnd.After(now) {
+	} else if advertisedEnd.After(now) {
 		status = "ONGOING"
 	}
 
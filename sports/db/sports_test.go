@@ -0,0 +1,40 @@
+package db_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"git.neds.sh/matty/entain/pkg/dialect"
+	"git.neds.sh/matty/entain/sports/db/dbtest"
+)
+
+// postgresDSNEnv names the variable holding the DSN of a PostgreSQL database the conformance suite may wipe.
+const postgresDSNEnv = "SPORTS_TEST_POSTGRES_DSN"
+
+func TestSportsRepoSQLite(t *testing.T) {
+	testSportsRepoSQL(t, filepath.Join(t.TempDir(), "sports.db"))
+}
+
+func TestSportsRepoPostgres(t *testing.T) {
+	dsn := os.Getenv(postgresDSNEnv)
+	if len(dsn) == 0 {
+		t.Skipf("%s is not set", postgresDSNEnv)
+	}
+
+	testSportsRepoSQL(t, dsn)
+}
+
+// testSportsRepoSQL runs the conformance suite against the SQL repository on the database of the DSN.
+func testSportsRepoSQL(t *testing.T, dsn string) {
+	sqlDB, d, err := dialect.Open(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := dbtest.TestSportsRepo(context.Background(), dbtest.SQL(sqlDB, d)); err != nil {
+		t.Error(err)
+	}
+}
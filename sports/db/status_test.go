@@ -0,0 +1,92 @@
+package db_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"git.neds.sh/matty/entain/pkg/clock"
+	"git.neds.sh/matty/entain/pkg/dialect"
+	"git.neds.sh/matty/entain/sports/db/dbtest"
+	"git.neds.sh/matty/entain/sports/proto/sports"
+)
+
+func TestEventStatus(t *testing.T) {
+	start := dbtest.Now
+	end := start.Add(2 * time.Hour)
+
+	cases := []struct {
+		name string
+		now  time.Time
+		want string
+	}{
+		{name: "a second before the start", now: start.Add(-time.Second), want: "OPEN"},
+		{name: "at the start", now: start, want: "ONGOING"},
+		{name: "a second after the start", now: start.Add(time.Second), want: "ONGOING"},
+		{name: "a second before the end", now: end.Add(-time.Second), want: "ONGOING"},
+		{name: "at the end", now: end, want: "CLOSED"},
+		{name: "a second after the end", now: end.Add(time.Second), want: "CLOSED"},
+	}
+
+	for name, newRepo := range newRepos(t) {
+		for _, c := range cases {
+			t.Run(name+"/"+c.name, func(t *testing.T) {
+				ctx := context.Background()
+
+				event := &sports.Event{
+					Id:                  1,
+					Name:                "Alpha",
+					VenueId:             1,
+					SportId:             1,
+					ParticipantsId:      1,
+					AdvertisedStartTime: timestamppb.New(start),
+					AdvertisedEndTime:   timestamppb.New(end),
+				}
+
+				repo, err := newRepo(ctx, clock.Fixed(c.now), []*sports.Event{event})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				got, err := repo.GetEventByID(ctx, event.Id, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if got.Status != c.want {
+					t.Errorf("status at %s = %s, want %s", c.now.Format(time.RFC3339), got.Status, c.want)
+				}
+
+				// The status filter must agree with the status reported.
+				for _, status := range []string{"OPEN", "ONGOING", "CLOSED"} {
+					events, err := repo.EventsList(ctx, &sports.ListEventsRequestFilter{Status: status}, "", nil)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					if matched := len(events) == 1; matched != (status == c.want) {
+						t.Errorf("status filter %s at %s matched the event: %t", status, c.now.Format(time.RFC3339), matched)
+					}
+				}
+			})
+		}
+	}
+}
+
+// newRepos returns the repositories under test by name: the in-memory one and SQLite.
+func newRepos(t *testing.T) map[string]dbtest.NewRepo {
+	repos := map[string]dbtest.NewRepo{"memory": dbtest.Memory()}
+
+	sqlDB, d, err := dialect.Open(filepath.Join(t.TempDir(), "sports.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	repos["sqlite"] = dbtest.SQL(sqlDB, d)
+
+	return repos
+}
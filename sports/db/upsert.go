@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"google.golang.org/protobuf/proto"
+
+	"git.neds.sh/matty/entain/pkg/tracing"
+	"git.neds.sh/matty/entain/sports/proto/sports"
+)
+
+// UpsertEvents saves the events in a single transaction, the database generating the ids of the new ones.
+func (s *sportsRepo) UpsertEvents(ctx context.Context, events []*sports.Event, dryRun bool) (created []bool, err error) {
+	ctx, span := tracer.Start(ctx, "sportsRepo.UpsertEvents")
+	defer func() { tracing.EndSpan(span, err) }()
+
+	queries := getEventUpsertQueries()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil || dryRun {
+			tx.Rollback()
+		}
+	}()
+
+	created = make([]bool, 0, len(events))
+
+	for _, event := range events {
+		start := s.dialect.Time(event.AdvertisedStartTime.AsTime())
+		end := s.dialect.Time(event.AdvertisedEndTime.AsTime())
+
+		var id int64
+		err := tx.QueryRowContext(ctx, s.dialect.Rebind(queries[eventsByReference]), event.ExternalRef).Scan(&id)
+		if err == sql.ErrNoRows {
+			err = tx.QueryRowContext(ctx, s.dialect.Rebind(queries[eventsInsert]),
+				event.ExternalRef, event.Name, event.VenueId, event.SportId, event.ParticipantsId, start, end).Scan(&id)
+			if err == nil {
+				created = append(created, true)
+				continue
+			}
+
+			// Another import inserted the event since, which is updated like any known one.
+			if err == sql.ErrNoRows {
+				err = tx.QueryRowContext(ctx, s.dialect.Rebind(queries[eventsByReference]), event.ExternalRef).Scan(&id)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Calendars pick up a moved event by its higher sequence.
+		if _, err := tx.ExecContext(ctx, s.dialect.Rebind(queries[eventsResequence]), id, start, end); err != nil {
+			return nil, err
+		}
+
+		_, err = tx.ExecContext(ctx, s.dialect.Rebind(queries[eventsUpdate]),
+			event.Name, event.VenueId, event.SportId, event.ParticipantsId, start, end, id)
+		if err != nil {
+			return nil, err
+		}
+
+		created = append(created, false)
+	}
+
+	if dryRun {
+		return created, nil
+	}
+
+	return created, tx.Commit()
+}
+
+// UpsertEvents saves the events like the SQL repository, leaving the repository untouched on a dry run.
+func (s *memorySportsRepo) UpsertEvents(ctx context.Context, events []*sports.Event, dryRun bool) ([]bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := int64(1)
+	byReference := make(map[string]*sports.Event, len(s.events))
+	for id, event := range s.events {
+		if event.ExternalRef != "" {
+			byReference[event.ExternalRef] = event
+		}
+		if id >= next {
+			next = id + 1
+		}
+	}
+
+	created := make([]bool, 0, len(events))
+
+	for _, event := range events {
+		event = proto.Clone(event).(*sports.Event)
+		event.Status, event.Sequence = "", 0
+
+		saved, ok := byReference[event.ExternalRef]
+		if ok {
+			event.Id, event.Sequence = saved.Id, saved.Sequence
+			if !proto.Equal(event.AdvertisedStartTime, saved.AdvertisedStartTime) || !proto.Equal(event.AdvertisedEndTime, saved.AdvertisedEndTime) {
+				event.Sequence++
+			}
+		} else {
+			event.Id = next
+			next++
+		}
+		created = append(created, !ok)
+
+		byReference[event.ExternalRef] = event
+		if !dryRun {
+			s.events[event.Id] = event
+		}
+	}
+
+	return created, nil
+}
@@ -0,0 +1,169 @@
+package fixtures
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"git.neds.sh/matty/entain/sports/proto/sports"
+)
+
+const (
+	// ImportUsage is the usage of the import subcommand.
+	ImportUsage = "import <file> [-map field=column,...] [-dry-run] [-time-zone zone] [-format csv|json] [flags]"
+	// ExportUsage is the usage of the export subcommand, writing to the standard output when the file is -.
+	ExportUsage = "export <file|-> [-map field=column,...] [-sport-id id] [-status status] [-start-time-from time] " +
+		"[-start-time-to time] [-local-date date] [-time-zone zone] [-order-by field] [-format csv|json] [flags]"
+)
+
+// ParseImportArgs parses the arguments of the import subcommand into the fixture file and the request importing
+// it, without its content, and returns the remaining flags.
+func ParseImportArgs(args []string) (file string, req *sports.ImportEventsRequest, flags []string, err error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return "", nil, nil, fmt.Errorf("missing fixture file, usage: %s", ImportUsage)
+	}
+	file = args[0]
+
+	req = &sports.ImportEventsRequest{ColumnMapping: map[string]string{}}
+
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Var(mappingFlag(req.ColumnMapping), "map", "Columns of the event fields, as field=column pairs")
+	fs.BoolVar(&req.DryRun, "dry-run", false, "Validate and report without saving")
+	fs.StringVar(&req.TimeZone, "time-zone", "", "IANA time zone of the times given without an offset")
+	format := fs.String("format", "", "Format of the file, csv or json, from its extension when empty")
+
+	own, flags := splitFlags(fs, args[1:])
+	if err := fs.Parse(own); err != nil {
+		return "", nil, nil, fmt.Errorf("%w, usage: %s", err, ImportUsage)
+	}
+
+	if req.Format, err = fileFormat(file, *format); err != nil {
+		return "", nil, nil, err
+	}
+
+	return file, req, flags, nil
+}
+
+// ParseExportArgs parses the arguments of the export subcommand into the fixture file and the request exporting
+// it, and returns the remaining flags.
+func ParseExportArgs(args []string) (file string, req *sports.ExportEventsRequest, flags []string, err error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") && args[0] != "-" {
+		return "", nil, nil, fmt.Errorf("missing fixture file, usage: %s", ExportUsage)
+	}
+	file = args[0]
+
+	req = &sports.ExportEventsRequest{ColumnMapping: map[string]string{}, Filter: &sports.ListEventsRequestFilter{}}
+
+	var sportID int64
+	var from, to string
+
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Var(mappingFlag(req.ColumnMapping), "map", "Columns of the event fields, as field=column pairs")
+	fs.Int64Var(&sportID, "sport-id", 0, "Sport id of the events, all sports when 0")
+	fs.StringVar(&req.Filter.Status, "status", "", "Status of the events: OPEN, ONGOING or CLOSED")
+	fs.StringVar(&from, "start-time-from", "", "RFC3339 time the events start at or after")
+	fs.StringVar(&to, "start-time-to", "", "RFC3339 time the events start before")
+	fs.StringVar(&req.Filter.LocalDate, "local-date", "", "YYYY-MM-DD date the events start on in -time-zone")
+	fs.StringVar(&req.Filter.TimeZone, "time-zone", "", "IANA time zone of -local-date")
+	fs.StringVar(&req.OrderBy, "order-by", "", "Field the events are ordered by, followed by asc or desc")
+	format := fs.String("format", "", "Format of the file, csv or json, from its extension when empty")
+
+	own, flags := splitFlags(fs, args[1:])
+	if err := fs.Parse(own); err != nil {
+		return "", nil, nil, fmt.Errorf("%w, usage: %s", err, ExportUsage)
+	}
+
+	if sportID != 0 {
+		req.Filter.SportId = &sportID
+	}
+
+	for _, t := range []struct {
+		flag, value string
+		dest        **timestamppb.Timestamp
+	}{
+		{"start-time-from", from, &req.Filter.StartTimeFrom},
+		{"start-time-to", to, &req.Filter.StartTimeTo},
+	} {
+		if t.value == "" {
+			continue
+		}
+
+		parsed, err := time.Parse(time.RFC3339, t.value)
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("invalid -%s %q, expected RFC3339", t.flag, t.value)
+		}
+		*t.dest = timestamppb.New(parsed)
+	}
+
+	if file == "-" && *format == "" {
+		return "", nil, nil, fmt.Errorf("-format is required when exporting to the standard output")
+	}
+
+	if req.Format, err = fileFormat(file, *format); err != nil {
+		return "", nil, nil, err
+	}
+
+	return file, req, flags, nil
+}
+
+// fileFormat returns the format named by the -format flag, or else the format of the file extension.
+func fileFormat(file, name string) (sports.FixtureFormat, error) {
+	if name != "" {
+		return ParseFormat(name)
+	}
+
+	return FormatOf(file)
+}
+
+// splitFlags separates the flags defined by the flag set, along with their values, from the other arguments.
+func splitFlags(fs *flag.FlagSet, args []string) (own, rest []string) {
+	for i := 0; i < len(args); i++ {
+		name, _, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+
+		f := fs.Lookup(name)
+		if !strings.HasPrefix(args[i], "-") || f == nil {
+			rest = append(rest, args[i])
+			continue
+		}
+
+		own = append(own, args[i])
+
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); !hasValue && !(ok && b.IsBoolFlag()) && i+1 < len(args) {
+			i++
+			own = append(own, args[i])
+		}
+	}
+
+	return own, rest
+}
+
+// mappingFlag collects the field=column pairs of the -map flags.
+type mappingFlag map[string]string
+
+func (m mappingFlag) String() string {
+	pairs := make([]string, 0, len(m))
+	for field, column := range m {
+		pairs = append(pairs, field+"="+column)
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func (m mappingFlag) Set(value string) error {
+	parsed, err := ParseMapping(value)
+	if err != nil {
+		return err
+	}
+
+	for field, column := range parsed {
+		m[field] = column
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+// Package fixtures reads and writes the sports events as the fixture lists kept by the operations team in
+// spreadsheets, as CSV files or JSON arrays.
+//
+// A CSV fixture list has a header row naming its columns, matched to the event fields whatever their case. A JSON
+// fixture list is an array of objects whose keys are matched the same way, with numbers given as JSON numbers or
+// strings:
+//
+//	external_ref,name,venue_id,sport_id,participants_id,advertised_start_time,advertised_end_time
+//	AFL-2024-R8-1,Richmond v Carlton,12,9,4,2024-05-02 19:40,2024-05-02 22:30
+//
+//	[{"external_ref": "AFL-2024-R8-1", "name": "Richmond v Carlton", "sport_id": 9,
+//	  "advertised_start_time": "2024-05-02T09:40:00Z", "advertised_end_time": "2024-05-02T12:30:00Z"}]
+//
+// The columns default to the names of the fields and can be renamed with a mapping, e.g. name=Match. Columns which
+// are not mapped to a field are ignored. Every fixture has an external reference, the key it is imported by: the
+// event with the same reference is updated, or a new event is created.
+//
+// The advertised times are RFC3339, or YYYY-MM-DD HH:MM[:SS] in the time zone of the import. Exported lists also
+// hold the id and status of the events, which are ignored when the list is imported back.
+package fixtures
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"git.neds.sh/matty/entain/sports/proto/sports"
+)
+
+// Fields are the event fields of a fixture list, in the order of the exported columns.
+var Fields = []string{"external_ref", "id", "name", "venue_id", "sport_id", "participants_id", "advertised_start_time", "advertised_end_time", "status"}
+
+// requiredFields are the fields every imported fixture gives, whose column must be in a CSV header.
+var requiredFields = []string{"external_ref", "name", "sport_id", "advertised_start_time", "advertised_end_time"}
+
+// importedFields are the fields read from an imported fixture, the id and status being the repository's.
+var importedFields = []string{"external_ref", "name", "venue_id", "sport_id", "participants_id", "advertised_start_time", "advertised_end_time"}
+
+// FormatOf returns the format of a fixture list file from its extension.
+func FormatOf(path string) (sports.FixtureFormat, error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".csv":
+		return sports.FixtureFormat_CSV, nil
+	case ".json":
+		return sports.FixtureFormat_JSON, nil
+	default:
+		return sports.FixtureFormat_FIXTURE_FORMAT_UNSPECIFIED, fmt.Errorf("unsupported fixture file extension %q: use .csv or .json", ext)
+	}
+}
+
+// ParseFormat returns the format of its lower or upper case name.
+func ParseFormat(name string) (sports.FixtureFormat, error) {
+	format := sports.FixtureFormat(sports.FixtureFormat_value[strings.ToUpper(strings.TrimSpace(name))])
+	if format == sports.FixtureFormat_FIXTURE_FORMAT_UNSPECIFIED {
+		return format, fmt.Errorf("unsupported fixture format %q: use csv or json", name)
+	}
+
+	return format, nil
+}
+
+// Mapping maps event fields to the columns holding them. Fields left out are held by the column of their name.
+type Mapping map[string]string
+
+// NewMapping checks that the fields of a mapping are event fields and that no two fields share a column.
+func NewMapping(m map[string]string) (Mapping, error) {
+	columns := make(map[string]string, len(m))
+
+	for field, column := range m {
+		if !isField(field) {
+			return nil, fmt.Errorf("unknown field %q in column mapping, expected one of %s", field, strings.Join(Fields, ", "))
+		}
+
+		column = strings.TrimSpace(column)
+		if column == "" {
+			return nil, fmt.Errorf("empty column for field %s in column mapping", field)
+		}
+
+		if other, ok := columns[strings.ToLower(column)]; ok {
+			return nil, fmt.Errorf("column %q is mapped to both %s and %s", column, other, field)
+		}
+		columns[strings.ToLower(column)] = field
+	}
+
+	mapping := make(Mapping, len(m))
+	for field, column := range m {
+		mapping[field] = strings.TrimSpace(column)
+	}
+
+	// A field left out keeps the column of its name, unless another field took it.
+	for _, field := range Fields {
+		if other, ok := columns[field]; ok && other != field && mapping[field] == "" {
+			return nil, fmt.Errorf("column %q is mapped to %s, map %s to another column", field, other, field)
+		}
+	}
+
+	return mapping, nil
+}
+
+// Column returns the column holding a field.
+func (m Mapping) Column(field string) string {
+	if column, ok := m[field]; ok {
+		return column
+	}
+
+	return field
+}
+
+// ParseMapping parses a column mapping given as comma-separated field=column pairs, e.g. name=Match,sport_id=Sport.
+func ParseMapping(s string) (map[string]string, error) {
+	m := make(map[string]string)
+
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		field, column, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid column mapping %q, expected field=column", pair)
+		}
+
+		m[strings.TrimSpace(field)] = strings.TrimSpace(column)
+	}
+
+	return m, nil
+}
+
+func isField(field string) bool {
+	for _, f := range Fields {
+		if f == field {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,345 @@
+package fixtures_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"git.neds.sh/matty/entain/pkg/clock"
+	"git.neds.sh/matty/entain/sports/db"
+	"git.neds.sh/matty/entain/sports/fixtures"
+	"git.neds.sh/matty/entain/sports/proto/sports"
+	"git.neds.sh/matty/entain/sports/service"
+)
+
+// describe formats the records read as row: field=value pairs in field order, one record per line.
+func describe(records []fixtures.Record) string {
+	var lines []string
+	for _, record := range records {
+		var pairs []string
+		for field, value := range record.Values {
+			pairs = append(pairs, field+"="+value)
+		}
+		sort.Strings(pairs)
+
+		lines = append(lines, fmt.Sprintf("%d: %s", record.Row, strings.Join(pairs, " ")))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func TestRead(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		format  sports.FixtureFormat
+		mapping map[string]string
+		list    string
+		want    []string
+		invalid bool
+	}{
+		{
+			name:   "CSV with a byte order mark and header of any case",
+			format: sports.FixtureFormat_CSV,
+			list: "\ufeffExternal_Ref, NAME,sport_id,advertised_start_time,advertised_end_time,notes\n" +
+				"AFL-1, Richmond v Carlton ,9,2024-05-02 19:40,2024-05-02 22:30,sold out\n",
+			want: []string{"2: advertised_end_time=2024-05-02 22:30 advertised_start_time=2024-05-02 19:40 external_ref=AFL-1 name=Richmond v Carlton sport_id=9"},
+		},
+		{
+			name:    "CSV columns mapped",
+			format:  sports.FixtureFormat_CSV,
+			mapping: map[string]string{"external_ref": "Fixture ID", "name": "Match", "advertised_start_time": "Start", "advertised_end_time": "End", "venue_id": "name"},
+			list:    "Fixture ID,Match,sport_id,Start,End,name\nAFL-1,Richmond v Carlton,9,2024-05-02 19:40,2024-05-02 22:30,12\n",
+			want:    []string{"2: advertised_end_time=2024-05-02 22:30 advertised_start_time=2024-05-02 19:40 external_ref=AFL-1 name=Richmond v Carlton sport_id=9 venue_id=12"},
+		},
+		{
+			name:   "CSV short row",
+			format: sports.FixtureFormat_CSV,
+			list:   "external_ref,name,sport_id,advertised_start_time,advertised_end_time\nAFL-1,Richmond v Carlton\n\nAFL-2,Geelong v Collingwood,9\n",
+			want:   []string{"2: external_ref=AFL-1 name=Richmond v Carlton", "4: external_ref=AFL-2 name=Geelong v Collingwood sport_id=9"},
+		},
+		{
+			name:    "CSV header missing a required column",
+			format:  sports.FixtureFormat_CSV,
+			mapping: map[string]string{"name": "Match"},
+			list:    "external_ref,name,sport_id,advertised_start_time,advertised_end_time\n",
+			invalid: true,
+		},
+		{name: "empty CSV", format: sports.FixtureFormat_CSV, invalid: true},
+		{name: "CSV with a stray quote", format: sports.FixtureFormat_CSV, list: "external_ref,name,sport_id,advertised_start_time,advertised_end_time\nAFL-1,\"Richmond,9\n", invalid: true},
+		{
+			name:    "JSON with numbers, null and keys of any case",
+			format:  sports.FixtureFormat_JSON,
+			mapping: map[string]string{"name": "Match"},
+			list: `[{"External_Ref": "AFL-1", "match": " Richmond v Carlton ", "sport_id": 9, "venue_id": "12", "participants_id": null,
+				"advertised_start_time": "2024-05-02T09:40:00Z", "advertised_end_time": "2024-05-02T12:30:00Z", "notes": "sold out"}]`,
+			want: []string{"1: advertised_end_time=2024-05-02T12:30:00Z advertised_start_time=2024-05-02T09:40:00Z external_ref=AFL-1 name=Richmond v Carlton participants_id= sport_id=9 venue_id=12"},
+		},
+		{
+			name:   "JSON values neither string nor number",
+			format: sports.FixtureFormat_JSON,
+			list:   `[{"external_ref": "AFL-1", "name": ["Richmond", "Carlton"], "sport_id": true, "venue_id": {"id": 12}}]`,
+			want:   []string{`1: external_ref=AFL-1 name=["Richmond", "Carlton"] sport_id=true venue_id={"id": 12}`},
+		},
+		{name: "JSON object", format: sports.FixtureFormat_JSON, list: `{"external_ref": "AFL-1"}`, invalid: true},
+		{name: "unspecified format", list: "external_ref\n", invalid: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mapping, err := fixtures.NewMapping(tc.mapping)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			records, err := fixtures.Read(strings.NewReader(tc.list), tc.format, mapping)
+			if tc.invalid {
+				if err == nil {
+					t.Fatalf("Read() = %s, want an error", describe(records))
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Read() failed: %v", err)
+			}
+
+			if got, want := describe(records), strings.Join(tc.want, "\n"); got != want {
+				t.Errorf("Read() =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	melbourne, err := time.LoadLocation("Australia/Melbourne")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := func(row int, ref, sportID, start, end string) fixtures.Record {
+		return fixtures.Record{Row: row, Values: map[string]string{
+			"external_ref": ref, "name": "Richmond v Carlton", "sport_id": sportID,
+			"advertised_start_time": start, "advertised_end_time": end,
+		}}
+	}
+	at := func(s string) *timestamppb.Timestamp {
+		t, _ := time.Parse(time.RFC3339, s)
+		return timestamppb.New(t)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		record   fixtures.Record
+		start    *timestamppb.Timestamp
+		rejected string
+	}{
+		{name: "RFC3339", record: record(2, "AFL-1", "9", "2024-05-02T19:40:00+10:00", "2024-05-02T22:30:00+10:00"), start: at("2024-05-02T09:40:00Z")},
+		{name: "RFC3339 truncated to the second", record: record(2, "AFL-1", "9", "2024-05-02T09:40:00.75Z", "2024-05-02T12:30:00Z"), start: at("2024-05-02T09:40:00Z")},
+		{name: "local minutes in the time zone", record: record(2, "AFL-1", "9", "2024-05-02 19:40", "2024-05-02 22:30"), start: at("2024-05-02T09:40:00Z")},
+		{name: "local seconds in summer time", record: record(2, "AFL-1", "9", "2024-01-02T19:40:30", "2024-01-02T22:30"), start: at("2024-01-02T08:40:30Z")},
+		{name: "missing external_ref", record: record(2, "", "9", "2024-05-02 19:40", "2024-05-02 22:30"), rejected: "missing external_ref"},
+		{name: "sport id as JSON boolean", record: record(2, "AFL-1", "true", "2024-05-02 19:40", "2024-05-02 22:30"), rejected: `invalid sport_id "true", expected a positive integer`},
+		{name: "missing sport id", record: record(2, "AFL-1", "", "2024-05-02 19:40", "2024-05-02 22:30"), rejected: `invalid sport_id "", expected a positive integer`},
+		{name: "day first date", record: record(2, "AFL-1", "9", "02/05/2024 19:40", "2024-05-02 22:30"), rejected: `invalid advertised_start_time "02/05/2024 19:40", expected RFC3339 or YYYY-MM-DD HH:MM`},
+		{name: "end before start", record: record(2, "AFL-1", "9", "2024-05-02 19:40", "2024-05-02 19:40"), rejected: "advertised_end_time is not after advertised_start_time"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			events, rejections := fixtures.Validate([]fixtures.Record{tc.record}, melbourne)
+
+			if tc.rejected != "" {
+				if len(events) != 0 || len(rejections) != 1 || rejections[0].Reason != tc.rejected || rejections[0].Row != 2 {
+					t.Fatalf("Validate() = %v, %v, want the rejection %q", events, rejections, tc.rejected)
+				}
+				return
+			}
+
+			if len(events) != 1 || len(rejections) != 0 {
+				t.Fatalf("Validate() = %v, %v, want an event", events, rejections)
+			}
+			if !proto.Equal(events[0].AdvertisedStartTime, tc.start) || events[0].SportId != 9 || events[0].ExternalRef != "AFL-1" {
+				t.Errorf("Validate() = %v, want AFL-1 of sport 9 starting at %s", events[0], tc.start.AsTime())
+			}
+		})
+	}
+
+	// The first fixture of a reference is kept.
+	events, rejections := fixtures.Validate([]fixtures.Record{
+		record(2, "AFL-1", "9", "2024-05-02 19:40", "2024-05-02 22:30"),
+		record(3, "AFL-1", "9", "2024-05-03 19:40", "2024-05-03 22:30"),
+		record(4, "AFL-1", "0", "2024-05-03 19:40", "2024-05-03 22:30"),
+	}, time.UTC)
+	if len(events) != 1 || events[0].AdvertisedStartTime.AsTime().Day() != 2 {
+		t.Errorf("Validate() kept %v, want the fixture of row 2", events)
+	}
+	if len(rejections) != 2 || rejections[0].Row != 3 || rejections[0].Reason != "duplicate external_ref" || rejections[1].Row != 4 || rejections[1].Reason != "duplicate external_ref" {
+		t.Errorf("Validate() rejected %v, want rows 3 and 4 as duplicates", rejections)
+	}
+}
+
+func TestNewMapping(t *testing.T) {
+	for _, tc := range []struct {
+		mapping string
+		invalid bool
+	}{
+		{mapping: "external_ref=Fixture ID, name=Match"},
+		{mapping: "name=external_ref,external_ref=Fixture ID"},
+		{mapping: "title=Match", invalid: true},
+		{mapping: "name=", invalid: true},
+		{mapping: "name=Match,venue_id=match", invalid: true},
+		{mapping: "name=external_ref", invalid: true},
+	} {
+		m, err := fixtures.ParseMapping(tc.mapping)
+		if err == nil {
+			_, err = fixtures.NewMapping(m)
+		}
+
+		if (err != nil) != tc.invalid {
+			t.Errorf("mapping %q: got error %v, want an error: %t", tc.mapping, err, tc.invalid)
+		}
+	}
+
+	if _, err := fixtures.ParseMapping("name:Match"); err == nil {
+		t.Error("ParseMapping(name:Match) succeeded")
+	}
+}
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	start := time.Date(2024, time.May, 2, 9, 40, 0, 0, time.UTC)
+	events := []*sports.Event{
+		{Id: 1, ExternalRef: "AFL-1", Name: `Richmond v Carlton, "Round 8"`, VenueId: 12, SportId: 9, ParticipantsId: 4,
+			AdvertisedStartTime: timestamppb.New(start), AdvertisedEndTime: timestamppb.New(start.Add(170 * time.Minute)), Status: "OPEN"},
+		{Id: 2, ExternalRef: "NRL-1", Name: "Storm v Broncos", SportId: 7,
+			AdvertisedStartTime: timestamppb.New(start.Add(time.Hour)), AdvertisedEndTime: timestamppb.New(start.Add(3 * time.Hour)), Status: "OPEN"},
+	}
+
+	for _, format := range []sports.FixtureFormat{sports.FixtureFormat_CSV, sports.FixtureFormat_JSON} {
+		t.Run(format.String(), func(t *testing.T) {
+			mapping, err := fixtures.NewMapping(map[string]string{"name": "Match", "external_ref": "Fixture ID"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			if err := fixtures.Write(&buf, format, mapping, events); err != nil {
+				t.Fatalf("Write() failed: %v", err)
+			}
+
+			records, err := fixtures.Read(&buf, format, mapping)
+			if err != nil {
+				t.Fatalf("Read() failed: %v", err)
+			}
+
+			imported, rejections := fixtures.Validate(records, time.UTC)
+			if len(rejections) != 0 || len(imported) != len(events) {
+				t.Fatalf("Validate() = %v, %v", imported, rejections)
+			}
+
+			for i, event := range imported {
+				want := proto.Clone(events[i]).(*sports.Event)
+				want.Id, want.Status = 0, ""
+
+				if !proto.Equal(event, want) {
+					t.Errorf("imported %v, want %v", event, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteEmptyJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := fixtures.Write(&buf, sports.FixtureFormat_JSON, nil, nil); err != nil || buf.String() != "[]\n" {
+		t.Errorf("Write() = %q, %v, want an empty array", buf.String(), err)
+	}
+}
+
+func TestImportReport(t *testing.T) {
+	ctx := context.Background()
+	svc := service.NewSportsService(db.NewMemorySportsRepo(nil, clock.Fixed(time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)), nil))
+
+	list := []byte("external_ref,name,sport_id,advertised_start_time,advertised_end_time\n" +
+		"AFL-1,Richmond v Carlton,9,2024-05-02 19:40,2024-05-02 22:30\n" +
+		"AFL-2,Geelong v Collingwood,9,2024-05-03 19:40,2024-05-03 19:00\n" +
+		"AFL-3,Essendon v Hawthorn,9,2024-05-04 13:10,2024-05-04 16:00\n")
+	req := func(dryRun bool) *sports.ImportEventsRequest {
+		return &sports.ImportEventsRequest{Format: sports.FixtureFormat_CSV, Content: list, DryRun: dryRun, TimeZone: "Australia/Melbourne"}
+	}
+
+	for _, tc := range []struct {
+		name    string
+		dryRun  bool
+		created int32
+		updated int32
+		saved   int
+	}{
+		{name: "dry run", dryRun: true, created: 2},
+		{name: "import", created: 2, saved: 2},
+		{name: "import again", updated: 2, saved: 2},
+		{name: "dry run again", dryRun: true, updated: 2, saved: 2},
+	} {
+		resp, err := svc.ImportEvents(ctx, req(tc.dryRun))
+		if err != nil {
+			t.Fatalf("%s: ImportEvents() failed: %v", tc.name, err)
+		}
+
+		if resp.Rows != 3 || resp.Created != tc.created || resp.Updated != tc.updated || resp.DryRun != tc.dryRun ||
+			len(resp.Rejected) != 1 || resp.Rejected[0].Row != 3 || resp.Rejected[0].ExternalRef != "AFL-2" {
+			t.Errorf("%s: ImportEvents() = %v, want 3 rows, %d created, %d updated and row 3 rejected", tc.name, resp, tc.created, tc.updated)
+		}
+
+		list, err := svc.ListEvents(ctx, &sports.ListEventsRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list.Events) != tc.saved {
+			t.Errorf("%s: %d events saved, want %d", tc.name, len(list.Events), tc.saved)
+		}
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	file, req, flags, err := fixtures.ParseImportArgs([]string{"fixtures.csv", "-map", "name=Match", "-db-dsn", "sports.db", "-dry-run", "-time-zone=Australia/Melbourne", "-map", "sport_id=Sport"})
+	if err != nil {
+		t.Fatalf("ParseImportArgs() failed: %v", err)
+	}
+	if file != "fixtures.csv" || req.Format != sports.FixtureFormat_CSV || !req.DryRun || req.TimeZone != "Australia/Melbourne" ||
+		req.ColumnMapping["name"] != "Match" || req.ColumnMapping["sport_id"] != "Sport" || strings.Join(flags, " ") != "-db-dsn sports.db" {
+		t.Errorf("ParseImportArgs() = %q, %v, %q", file, req, flags)
+	}
+
+	file, exportReq, flags, err := fixtures.ParseExportArgs([]string{"-", "-format", "json", "-sport-id", "9", "-start-time-from", "2024-05-01T00:00:00Z", "-log-level", "debug"})
+	if err != nil {
+		t.Fatalf("ParseExportArgs() failed: %v", err)
+	}
+	if file != "-" || exportReq.Format != sports.FixtureFormat_JSON || exportReq.Filter.GetSportId() != 9 ||
+		exportReq.Filter.StartTimeFrom.AsTime().Day() != 1 || strings.Join(flags, " ") != "-log-level debug" {
+		t.Errorf("ParseExportArgs() = %q, %v, %q", file, exportReq, flags)
+	}
+
+	for _, args := range [][]string{
+		{},
+		{"-dry-run"},
+		{"fixtures.xlsx"},
+		{"fixtures.csv", "-map", "name"},
+	} {
+		if _, _, _, err := fixtures.ParseImportArgs(args); err == nil {
+			t.Errorf("ParseImportArgs(%q) succeeded", args)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"-"},
+		{"events.csv", "-start-time-from", "yesterday"},
+		{"events.csv", "-format", "xlsx"},
+	} {
+		if _, _, _, err := fixtures.ParseExportArgs(args); err == nil {
+			t.Errorf("ParseExportArgs(%q) succeeded", args)
+		}
+	}
+}
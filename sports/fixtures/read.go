@@ -0,0 +1,139 @@
+package fixtures
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"git.neds.sh/matty/entain/sports/proto/sports"
+)
+
+// Record is a fixture read from a list, before validation.
+type Record struct {
+	// Row is the line of the fixture in a CSV file, header included, or its position from 1 in a JSON array.
+	Row int
+	// Values holds the text of the imported fields by field name, empty when not given.
+	Values map[string]string
+}
+
+// byteOrderMark starts the CSV files saved as UTF-8 by some spreadsheets.
+const byteOrderMark = "\ufeff"
+
+// Read decodes the fixtures of a list, taking the fields from the columns of the mapping. The error reports a list
+// which could not be decoded, or a CSV header missing the column of a required field.
+func Read(r io.Reader, format sports.FixtureFormat, mapping Mapping) ([]Record, error) {
+	switch format {
+	case sports.FixtureFormat_CSV:
+		return readCSV(r, mapping)
+	case sports.FixtureFormat_JSON:
+		return readJSON(r, mapping)
+	default:
+		return nil, fmt.Errorf("unsupported fixture format %s", format)
+	}
+}
+
+func readCSV(r io.Reader, mapping Mapping) ([]Record, error) {
+	reader := csv.NewReader(r)
+	// Short rows are read as missing values, so that they are rejected on their own.
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("empty CSV fixture list, expected a header row")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("malformed CSV fixture list: %w", err)
+	}
+
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], byteOrderMark)
+	}
+
+	positions := make(map[string]int, len(header))
+	for i, column := range header {
+		positions[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+
+	indexes := make(map[string]int, len(importedFields))
+	for _, field := range importedFields {
+		if i, ok := positions[strings.ToLower(mapping.Column(field))]; ok {
+			indexes[field] = i
+		}
+	}
+
+	for _, field := range requiredFields {
+		if _, ok := indexes[field]; !ok {
+			return nil, fmt.Errorf("missing column %q of field %s in the CSV header", mapping.Column(field), field)
+		}
+	}
+
+	var records []Record
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("malformed CSV fixture list: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		values := make(map[string]string, len(indexes))
+		for field, i := range indexes {
+			if i < len(row) {
+				values[field] = strings.TrimSpace(row[i])
+			}
+		}
+
+		records = append(records, Record{Row: line, Values: values})
+	}
+}
+
+func readJSON(r io.Reader, mapping Mapping) ([]Record, error) {
+	var objects []map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&objects); err != nil {
+		return nil, fmt.Errorf("malformed JSON fixture list, expected an array of objects: %w", err)
+	}
+
+	records := make([]Record, 0, len(objects))
+	for i, object := range objects {
+		keys := make(map[string]json.RawMessage, len(object))
+		for key, value := range object {
+			keys[strings.ToLower(strings.TrimSpace(key))] = value
+		}
+
+		values := make(map[string]string, len(importedFields))
+		for _, field := range importedFields {
+			if raw, ok := keys[strings.ToLower(mapping.Column(field))]; ok {
+				values[field] = jsonText(raw)
+			}
+		}
+
+		records = append(records, Record{Row: i + 1, Values: values})
+	}
+
+	return records, nil
+}
+
+// jsonText returns the text of a JSON string, or the JSON of any other value so that validation reports it.
+// Null is read as a missing value.
+func jsonText(raw json.RawMessage) string {
+	raw = bytes.TrimSpace(raw)
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return strings.TrimSpace(s)
+	}
+
+	if string(raw) == "null" {
+		return ""
+	}
+
+	return string(raw)
+}
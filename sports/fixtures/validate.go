@@ -0,0 +1,112 @@
+package fixtures
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"git.neds.sh/matty/entain/sports/proto/sports"
+)
+
+// localLayouts are the layouts of the advertised times given without an offset, as spreadsheets write them.
+var localLayouts = []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04:05", "2006-01-02T15:04"}
+
+// Validate checks the fixtures of a list and returns the events of the valid ones, with their external references
+// but no ids, and the rejections. A fixture repeating the external reference of an earlier one is rejected, the
+// earlier one being kept. The advertised times without an offset are read in loc, and all are truncated to the
+// second as the databases store them.
+func Validate(records []Record, loc *time.Location) ([]*sports.Event, []*sports.ImportRejection) {
+	var (
+		events     []*sports.Event
+		rejections []*sports.ImportRejection
+	)
+
+	seen := make(map[string]bool, len(records))
+	for _, record := range records {
+		ref := record.Values["external_ref"]
+
+		event, reason := parseEvent(record.Values, loc)
+		switch {
+		case ref == "":
+			reason = "missing external_ref"
+		case seen[ref]:
+			reason = "duplicate external_ref"
+		}
+
+		if reason != "" {
+			rejections = append(rejections, &sports.ImportRejection{Row: int32(record.Row), ExternalRef: ref, Reason: reason})
+		} else {
+			events = append(events, event)
+		}
+
+		seen[ref] = true
+	}
+
+	return events, rejections
+}
+
+// parseEvent converts the values of a fixture into an event, returning the reason to reject it when invalid.
+func parseEvent(values map[string]string, loc *time.Location) (*sports.Event, string) {
+	event := &sports.Event{ExternalRef: values["external_ref"], Name: values["name"]}
+
+	if event.Name == "" {
+		return nil, "missing name"
+	}
+
+	for _, id := range []struct {
+		field    string
+		dest     *int64
+		required bool
+	}{
+		{"sport_id", &event.SportId, true},
+		{"venue_id", &event.VenueId, false},
+		{"participants_id", &event.ParticipantsId, false},
+	} {
+		value := values[id.field]
+		if value == "" && !id.required {
+			continue
+		}
+
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n < 1 {
+			return nil, fmt.Sprintf("invalid %s %q, expected a positive integer", id.field, value)
+		}
+		*id.dest = n
+	}
+
+	start, ok := parseTime(values["advertised_start_time"], loc)
+	if !ok {
+		return nil, fmt.Sprintf("invalid advertised_start_time %q, expected RFC3339 or YYYY-MM-DD HH:MM", values["advertised_start_time"])
+	}
+
+	end, ok := parseTime(values["advertised_end_time"], loc)
+	if !ok {
+		return nil, fmt.Sprintf("invalid advertised_end_time %q, expected RFC3339 or YYYY-MM-DD HH:MM", values["advertised_end_time"])
+	}
+
+	if !end.After(start) {
+		return nil, "advertised_end_time is not after advertised_start_time"
+	}
+
+	event.AdvertisedStartTime = timestamppb.New(start)
+	event.AdvertisedEndTime = timestamppb.New(end)
+
+	return event, ""
+}
+
+// parseTime parses an RFC3339 time, or a time of the local layouts in loc.
+func parseTime(value string, loc *time.Location) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC().Truncate(time.Second), true
+	}
+
+	for _, layout := range localLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t.UTC().Truncate(time.Second), true
+		}
+	}
+
+	return time.Time{}, false
+}
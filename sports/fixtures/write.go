@@ -0,0 +1,130 @@
+package fixtures
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"git.neds.sh/matty/entain/sports/proto/sports"
+)
+
+// Write encodes the events as a fixture list with a column per field, in the order of Fields and renamed by the
+// mapping. The advertised times are written in RFC3339 UTC, the unset venue and participants ids are left empty, or
+// null in JSON, and a JSON list has an object per line.
+func Write(w io.Writer, format sports.FixtureFormat, mapping Mapping, events []*sports.Event) error {
+	columns := make([]string, 0, len(Fields))
+	for _, field := range Fields {
+		columns = append(columns, mapping.Column(field))
+	}
+
+	switch format {
+	case sports.FixtureFormat_CSV:
+		return writeCSV(w, columns, events)
+	case sports.FixtureFormat_JSON:
+		return writeJSON(w, columns, events)
+	default:
+		return fmt.Errorf("unsupported fixture format %s", format)
+	}
+}
+
+func writeCSV(w io.Writer, columns []string, events []*sports.Event) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(columns); err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		row := make([]string, 0, len(Fields))
+		for _, value := range values(event) {
+			switch v := value.(type) {
+			case int64:
+				row = append(row, strconv.FormatInt(v, 10))
+			case nil:
+				row = append(row, "")
+			default:
+				row = append(row, v.(string))
+			}
+		}
+
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+func writeJSON(w io.Writer, columns []string, events []*sports.Event) error {
+	buf := bufio.NewWriter(w)
+
+	if len(events) == 0 {
+		buf.WriteString("[]\n")
+		return buf.Flush()
+	}
+
+	buf.WriteString("[\n")
+	for i, event := range events {
+		// The object is written key by key to keep the columns in order.
+		buf.WriteString("  {")
+		for j, value := range values(event) {
+			if j > 0 {
+				buf.WriteString(", ")
+			}
+
+			key, err := json.Marshal(columns[j])
+			if err != nil {
+				return err
+			}
+
+			data, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+
+			buf.Write(key)
+			buf.WriteString(": ")
+			buf.Write(data)
+		}
+		buf.WriteString("}")
+
+		if i < len(events)-1 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n")
+	}
+	buf.WriteString("]\n")
+
+	return buf.Flush()
+}
+
+// values returns the values of the fields of an event, in the order of Fields. The optional ids are nil when unset,
+// so that the list can be imported back.
+func values(event *sports.Event) []interface{} {
+	return []interface{}{
+		event.ExternalRef,
+		event.Id,
+		event.Name,
+		optionalID(event.VenueId),
+		event.SportId,
+		optionalID(event.ParticipantsId),
+		event.AdvertisedStartTime.AsTime().UTC().Format(time.RFC3339),
+		event.AdvertisedEndTime.AsTime().UTC().Format(time.RFC3339),
+		event.Status,
+	}
+}
+
+// optionalID returns the id, or nil when unset.
+func optionalID(id int64) interface{} {
+	if id == 0 {
+		return nil
+	}
+
+	return id
+}
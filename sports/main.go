@@ -1,46 +1,149 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"flag"
+	"fmt"
 	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"git.neds.sh/matty/entain/pkg/clock"
+	"git.neds.sh/matty/entain/pkg/config"
+	"git.neds.sh/matty/entain/pkg/deadline"
+	"git.neds.sh/matty/entain/pkg/dialect"
+	"git.neds.sh/matty/entain/pkg/health"
+	"git.neds.sh/matty/entain/pkg/migrate"
+	"git.neds.sh/matty/entain/pkg/shutdown"
+	"git.neds.sh/matty/entain/pkg/tlsconfig"
+	"git.neds.sh/matty/entain/pkg/tracing"
 	"git.neds.sh/matty/entain/sports/db"
+	"git.neds.sh/matty/entain/sports/fixtures"
 	"git.neds.sh/matty/entain/sports/proto/sports"
 	"git.neds.sh/matty/entain/sports/service"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
-)
-
-var (
-	grpcEndpoint = flag.String("grpc-endpoint", "localhost:7000", "gRPC server endpoint")
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health/grpc_health_v1"
 )
 
 func main() {
-	flag.Parse()
+	args := os.Args[1:]
+
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := runMigrate(args[1:]); err != nil {
+			log.Fatalf("failed running migrations: %s", err)
+		}
+		return
+	}
+
+	if len(args) > 0 && args[0] == "import" {
+		if err := runImport(args[1:]); err != nil {
+			log.Fatalf("failed importing fixtures: %s", err)
+		}
+		return
+	}
+
+	if len(args) > 0 && args[0] == "export" {
+		if err := runExport(args[1:]); err != nil {
+			log.Fatalf("failed exporting fixtures: %s", err)
+		}
+		return
+	}
+
+	if len(args) > 0 && args[0] == "seed" {
+		if err := runSeed(args[1:]); err != nil {
+			log.Fatalf("failed seeding database: %s", err)
+		}
+		return
+	}
 
-	if err := run(); err != nil {
+	cfg, err := config.Load(config.ServiceSports, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("invalid configuration: %s", err)
+	}
+
+	if err := cfg.Log.Configure(); err != nil {
+		log.Fatalf("invalid configuration: %s", err)
+	}
+
+	if err := run(cfg); err != nil {
 		log.Fatalf("failed running grpc server: %s", err)
 	}
 }
 
-func run() error {
-	conn, err := net.Listen("tcp", ":7000")
+func run(cfg config.Config) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Init(cfg.TracingConfig())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Errorf("failed flushing traces: %s", err)
+		}
+	}()
+
+	conn, err := net.Listen("tcp", cfg.Listen)
 	if err != nil {
 		return err
 	}
 
-	sportsDB, err := sql.Open("sqlite3", "./db/sports.db")
+	sportsRepo, sportsDB, err := openRepo(ctx, cfg)
 	if err != nil {
 		return err
 	}
+	if sportsDB != nil {
+		defer func() {
+			if err := sportsDB.Close(); err != nil {
+				log.Errorf("failed closing database: %s", err)
+			}
+		}()
+	}
+
+	tracker := &shutdown.Tracker{}
+
+	interceptors := []grpc.UnaryServerInterceptor{
+		tracker.UnaryServerInterceptor(),
+		// Fixture imports and exports go through every event of a file or of the filter.
+		deadline.UnaryServerInterceptor(cfg.Timeouts.Request, map[string]time.Duration{
+			"/sports.Sports/ImportEvents": cfg.Timeouts.Bulk,
+			"/sports.Sports/ExportEvents": cfg.Timeouts.Bulk,
+		}),
+	}
+	if cfg.Dev.SimulatedTime {
+		log.Warnf("answering requests as of their %s, do not enable in production", clock.Header)
+		interceptors = append(interceptors, clock.UnaryServerInterceptor())
+	}
+
+	serverOpts := []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(interceptors...),
+	}
 
-	sportsRepo := db.NewSportsRepo(sportsDB)
-	if err := sportsRepo.Init(); err != nil {
+	tlsConfig, err := tlsconfig.NewServerConfig(ctx, cfg.TLS)
+	if err != nil {
 		return err
 	}
+	if tlsConfig != nil {
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
 
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(serverOpts...)
+
+	// The health server reports NOT_SERVING until the repository has been initialised.
+	healthServer := health.NewServer(sports.Sports_ServiceDesc.ServiceName)
+	grpc_health_v1.RegisterHealthServer(grpcServer, healthServer)
 
 	sports.RegisterSportsServer(
 		grpcServer,
@@ -49,11 +152,259 @@ func run() error {
 		),
 	)
 
-	log.Infof("gRPC server listening on: %s", *grpcEndpoint)
+	log.Infof("gRPC server listening on: %s", cfg.Listen)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- grpcServer.Serve(conn)
+	}()
+
+	if err := sportsRepo.Init(ctx); err != nil {
+		grpcServer.Stop()
+		return err
+	}
+
+	if sportsDB != nil {
+		go healthServer.Monitor(ctx, sportsDB, cfg.Health.Interval)
+	} else {
+		healthServer.SetServing(true)
+	}
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Info("received shutdown signal")
+
+	// Report NOT_SERVING so that no new traffic is routed here while draining.
+	healthServer.Shutdown()
+	shutdown.GRPC(grpcServer, tracker, cfg.Timeouts.Shutdown)
+
+	return nil
+}
+
+// openRepo creates the repository selected by the DSN. The database is returned to be monitored and closed,
+// and is nil for the in-memory repository.
+func openRepo(ctx context.Context, cfg config.Config) (_ db.SportsRepo, _ *sql.DB, err error) {
+	if cfg.Database.InMemory() {
+		log.Warn("using the in-memory repository, data is lost on exit")
+		return db.NewMemorySportsRepo(nil, clock.System, seedOptions(cfg)), nil, nil
+	}
+
+	sportsDB, sqlDialect, err := dialect.Open(cfg.Database.DSN)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err != nil {
+			sportsDB.Close()
+		}
+	}()
+
+	migrator, err := migrate.New(sportsDB, sqlDialect, db.Migrations(sqlDialect))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if cfg.Database.AutoMigrate {
+		if _, err := migrator.Up(ctx); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Refuse to run against a schema the code does not expect.
+	if err := migrator.Check(ctx); err != nil {
+		if errors.Is(err, migrate.ErrOutdated) {
+			return nil, nil, fmt.Errorf("%w: run `sports migrate up` or start with -auto-migrate", err)
+		}
+
+		return nil, nil, err
+	}
+
+	return db.NewSportsRepo(sportsDB, sqlDialect, clock.System, seedOptions(cfg)), sportsDB, nil
+}
+
+// runMigrate runs the migrate subcommand against the configured database.
+func runMigrate(args []string) error {
+	action, steps, flags, err := migrate.ParseArgs(args)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(config.ServiceSports, flags)
+	if err != nil {
+		return err
+	}
+
+	if err := cfg.Log.Configure(); err != nil {
+		return err
+	}
+
+	if cfg.Database.InMemory() {
+		return errors.New("the in-memory repository has no migrations")
+	}
+
+	sportsDB, sqlDialect, err := dialect.Open(cfg.Database.DSN)
+	if err != nil {
+		return err
+	}
+	defer sportsDB.Close()
+
+	migrator, err := migrate.New(sportsDB, sqlDialect, db.Migrations(sqlDialect))
+	if err != nil {
+		return err
+	}
+
+	return migrate.Command(context.Background(), migrator, action, steps)
+}
+
+// runImport runs the import subcommand, upserting the fixtures of a CSV or JSON file into the database through
+// the service, and logs the import report.
+func runImport(args []string) error {
+	file, req, flags, err := fixtures.ParseImportArgs(args)
+	if err != nil {
+		return err
+	}
+
+	if req.Content, err = os.ReadFile(file); err != nil {
+		return err
+	}
+
+	svc, closeDB, err := openService(flags)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
 
-	if err := grpcServer.Serve(conn); err != nil {
+	report, err := svc.ImportEvents(context.Background(), req)
+	if err != nil {
 		return err
 	}
 
+	log.WithFields(log.Fields{
+		"file":     file,
+		"rows":     report.Rows,
+		"created":  report.Created,
+		"updated":  report.Updated,
+		"rejected": len(report.Rejected),
+		"dry_run":  report.DryRun,
+	}).Info("imported fixtures")
+
+	for _, r := range report.Rejected {
+		log.WithFields(log.Fields{"file": file, "row": r.Row, "external_ref": r.ExternalRef}).Warnf("rejected fixture: %s", r.Reason)
+	}
+
 	return nil
 }
+
+// runExport runs the export subcommand, writing the sports events matching the filter flags to a CSV or JSON file.
+func runExport(args []string) error {
+	file, req, flags, err := fixtures.ParseExportArgs(args)
+	if err != nil {
+		return err
+	}
+
+	svc, closeDB, err := openService(flags)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	export, err := svc.ExportEvents(context.Background(), req)
+	if err != nil {
+		return err
+	}
+
+	if file == "-" {
+		_, err = os.Stdout.Write(export.Content)
+		return err
+	}
+
+	if err := os.WriteFile(file, export.Content, 0o644); err != nil {
+		return err
+	}
+
+	log.Infof("exported %d events to %s", export.Count, file)
+
+	return nil
+}
+
+// openService loads the configuration of the flags and creates the service on the configured database, so that the
+// subcommands behave like the RPCs. The returned function closes the database.
+func openService(flags []string) (service.Sports, func(), error) {
+	cfg, err := config.Load(config.ServiceSports, flags)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := cfg.Log.Configure(); err != nil {
+		return nil, nil, err
+	}
+
+	if cfg.Database.InMemory() {
+		return nil, nil, errors.New("the in-memory repository is lost on exit, use the ImportEvents and ExportEvents RPCs of a running server instead")
+	}
+
+	// Open through the repository so that the schema is checked, without seeding.
+	cfg.Seed.Enabled = false
+
+	repo, sportsDB, err := openRepo(context.Background(), cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return service.NewSportsService(repo), func() { sportsDB.Close() }, nil
+}
+
+// runSeed runs the seed subcommand, inserting the configured dummy sport events into the database.
+func runSeed(args []string) error {
+	cfg, err := config.Load(config.ServiceSports, args)
+	if err != nil {
+		return err
+	}
+
+	if err := cfg.Log.Configure(); err != nil {
+		return err
+	}
+
+	if cfg.Database.InMemory() {
+		return errors.New("the in-memory repository is lost on exit, seed it on startup instead")
+	}
+
+	cfg.Seed.Enabled = true
+
+	repo, sportsDB, err := openRepo(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+	defer sportsDB.Close()
+
+	return repo.Init(context.Background())
+}
+
+// seedOptions returns the dummy data settings of the repository, or nil when seeding is disabled.
+// A random seed is picked when none is configured, and logged so that the data can be generated again.
+func seedOptions(cfg config.Config) *db.SeedOptions {
+	if !cfg.Seed.Enabled {
+		return nil
+	}
+
+	// The anchor was validated with the configuration.
+	anchor, _ := cfg.Seed.AnchorTime()
+
+	opts := &db.SeedOptions{
+		RandomSeed: cfg.Seed.RandomSeed,
+		Count:      cfg.Seed.Count,
+		Anchor:     anchor,
+		Reset:      cfg.Seed.Reset,
+	}
+	if opts.RandomSeed == 0 {
+		opts.RandomSeed = time.Now().UnixNano()
+	}
+
+	log.Infof("seeding %d sport events around %s with -seed-random %d", opts.Count, opts.Anchor.UTC().Format(time.RFC3339), opts.RandomSeed)
+
+	return opts
+}
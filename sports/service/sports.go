@@ -1,16 +1,34 @@
 package service
 
 import (
+	"bytes"
+	"time"
+
+	"git.neds.sh/matty/entain/pkg/search"
 	"git.neds.sh/matty/entain/sports/db"
+	"git.neds.sh/matty/entain/sports/fixtures"
 	"git.neds.sh/matty/entain/sports/proto/sports"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// maxBatchGetIDs is the most ids a BatchGetEvents call may ask for.
+const maxBatchGetIDs = 100
+
 type Sports interface {
 	// ListEvents will return a collection of all sports events.
 	ListEvents(ctx context.Context, in *sports.ListEventsRequest) (*sports.ListEventsResponse, error)
 	// GetEvent will return a single sports event.
 	GetEvent(ctx context.Context, in *sports.GetEventRequest) (*sports.GetEventResponse, error)
+	// BatchGetEvents will return the sports events of the given ids, reporting the ids without an event.
+	BatchGetEvents(ctx context.Context, in *sports.BatchGetEventsRequest) (*sports.BatchGetEventsResponse, error)
+	// Search will return the sports events whose name matches the query, most relevant first.
+	Search(ctx context.Context, in *sports.SearchRequest) (*sports.SearchResponse, error)
+	// ImportEvents will upsert the sports events of a fixture list by external reference, reporting the rows rejected.
+	ImportEvents(ctx context.Context, in *sports.ImportEventsRequest) (*sports.ImportEventsResponse, error)
+	// ExportEvents will return the sports events matching a filter as a fixture list.
+	ExportEvents(ctx context.Context, in *sports.ExportEventsRequest) (*sports.ExportEventsResponse, error)
 }
 
 // sportsService implements the Sports interface.
@@ -20,12 +38,12 @@ type sportsService struct {
 
 // NewSportsService instantiates and returns a new sportsService.
 func NewSportsService(sportsRepo db.SportsRepo) Sports {
-	return &sportsService{sportsRepo}
+	return &sportsService{sportsRepo: sportsRepo}
 }
 
 // ListEvents will return a collection of all sports events.
 func (s *sportsService) ListEvents(ctx context.Context, in *sports.ListEventsRequest) (*sports.ListEventsResponse, error) {
-	events, err := s.sportsRepo.EventsList(in.Filter, in.OrderBy)
+	events, err := s.sportsRepo.EventsList(ctx, in.Filter, in.OrderBy, in.ReadMask)
 	if err != nil {
 		return nil, err
 	}
@@ -35,10 +53,122 @@ func (s *sportsService) ListEvents(ctx context.Context, in *sports.ListEventsReq
 
 // GetEvent will return a single sports event.
 func (s *sportsService) GetEvent(ctx context.Context, in *sports.GetEventRequest) (*sports.GetEventResponse, error) {
-	event, err := s.sportsRepo.GetEventByID(in.Id)
+	event, err := s.sportsRepo.GetEventByID(ctx, in.Id, in.ReadMask)
 	if err != nil {
 		return nil, err
 	}
 
 	return &sports.GetEventResponse{Event: event}, nil
 }
+
+// BatchGetEvents will return the sports events of the given ids with a single repository query. The results follow
+// the order of the requested ids, duplicates included, and mark the ids without an event as not found.
+func (s *sportsService) BatchGetEvents(ctx context.Context, in *sports.BatchGetEventsRequest) (*sports.BatchGetEventsResponse, error) {
+	if len(in.Ids) > maxBatchGetIDs {
+		return nil, status.Errorf(codes.InvalidArgument, "too many ids: %d, at most %d can be requested", len(in.Ids), maxBatchGetIDs)
+	}
+
+	ids := make([]int64, 0, len(in.Ids))
+	seen := make(map[int64]bool, len(in.Ids))
+	for _, id := range in.Ids {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	events, err := s.sportsRepo.GetEventsByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int64]*sports.Event, len(events))
+	for _, event := range events {
+		byID[event.Id] = event
+	}
+
+	results := make([]*sports.BatchGetEventsResult, 0, len(in.Ids))
+	for _, id := range in.Ids {
+		event, found := byID[id]
+		results = append(results, &sports.BatchGetEventsResult{Id: id, Found: found, Event: event})
+	}
+
+	return &sports.BatchGetEventsResponse{Results: results}, nil
+}
+
+// Search will return the sports events whose name matches the query, most relevant first.
+func (s *sportsService) Search(ctx context.Context, in *sports.SearchRequest) (*sports.SearchResponse, error) {
+	limit, err := search.Limit(in.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := s.sportsRepo.Search(ctx, in.Query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sports.SearchResponse{Results: results}, nil
+}
+
+// ImportEvents will validate the fixtures of the list and upsert the valid ones by external reference in a single
+// transaction, rolled back on a dry run. A list which cannot be read is rejected as a whole, while invalid fixtures
+// are only reported.
+func (s *sportsService) ImportEvents(ctx context.Context, in *sports.ImportEventsRequest) (*sports.ImportEventsResponse, error) {
+	mapping, err := fixtures.NewMapping(in.ColumnMapping)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	loc, err := time.LoadLocation(in.TimeZone)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid time zone %q", in.TimeZone)
+	}
+
+	records, err := fixtures.Read(bytes.NewReader(in.Content), in.Format, mapping)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	events, rejections := fixtures.Validate(records, loc)
+
+	created, err := s.sportsRepo.UpsertEvents(ctx, events, in.DryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &sports.ImportEventsResponse{Rows: int32(len(records)), Rejected: rejections, DryRun: in.DryRun}
+	for _, c := range created {
+		if c {
+			resp.Created++
+		} else {
+			resp.Updated++
+		}
+	}
+
+	return resp, nil
+}
+
+// ExportEvents will return the sports events matching the filter, in the requested order, as a fixture list.
+func (s *sportsService) ExportEvents(ctx context.Context, in *sports.ExportEventsRequest) (*sports.ExportEventsResponse, error) {
+	mapping, err := fixtures.NewMapping(in.ColumnMapping)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if in.Format == sports.FixtureFormat_FIXTURE_FORMAT_UNSPECIFIED {
+		return nil, status.Error(codes.InvalidArgument, "missing fixture format")
+	}
+
+	events, err := s.sportsRepo.EventsList(ctx, in.Filter, in.OrderBy, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := fixtures.Write(&buf, in.Format, mapping, events); err != nil {
+		return nil, err
+	}
+
+	return &sports.ExportEventsResponse{Content: buf.Bytes(), Count: int32(len(events))}, nil
+}